@@ -0,0 +1,253 @@
+package charger
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/weilun-shrimp/wlgo_ocpp_charger_simulator/config"
+	"github.com/weilun-shrimp/wlgo_ocpp_charger_simulator/logging"
+	"github.com/weilun-shrimp/wlgo_ocpp_charger_simulator/ocpp/v201"
+)
+
+// StopReasonDeAuthorized is the stop reason used when the server invalidates
+// the idTag of a running transaction (same value in OCPP 1.6 and 2.0.1)
+const StopReasonDeAuthorized = "DeAuthorized"
+
+// isIdTagAccepted reports whether an IdTagInfo/IdTokenInfo status still
+// authorizes charging. An empty status means the response carried no
+// authorization information, which leaves the transaction untouched.
+func isIdTagAccepted(status string) bool {
+	return status == "" || status == "Accepted"
+}
+
+// isIdTagUnknown reports whether the server does not know the idTag (Invalid
+// in OCPP 1.6, Unknown or Invalid in OCPP 2.0.1), as opposed to a known idTag
+// that is blocked or expired
+func isIdTagUnknown(status string) bool {
+	return status == "Invalid" || status == "Unknown"
+}
+
+// checkAuthorization reacts to the authorization status returned by the server
+// for the idTag of the running transaction (StartTransaction.conf idTagInfo in
+// OCPP 1.6, TransactionEventResponse idTokenInfo in OCPP 2.0.1).
+func (c *Charger) checkAuthorization(status string) {
+	if isIdTagAccepted(status) {
+		return
+	}
+
+	c.mu.Lock()
+	if !c.isCharging || c.deauthorized {
+		c.mu.Unlock()
+		return
+	}
+	c.deauthorized = true
+	c.deauthorizedMeter = c.meterValue
+	idTag := c.idTag
+	c.mu.Unlock()
+
+	c.log().charger.Info("idTag deauthorized by server", "status", status)
+	if isIdTagUnknown(status) {
+		c.RaiseSecurityEvent(securityEventUnknownIdTag, fmt.Sprintf("idTag %s rejected: status=%s", idTag, status))
+	}
+
+	c.mu.RLock()
+	settings := c.invalidId
+	c.mu.RUnlock()
+
+	// StopTransactionOnInvalidId: end the transaction right away
+	if settings.stopTransaction {
+		if err := c.StopTransaction(StopReasonDeAuthorized); err != nil {
+			c.log().charger.Error("Failed to stop deauthorized transaction", logging.Err(err))
+		}
+		return
+	}
+
+	// Otherwise keep the transaction open but stop energy delivery, optionally
+	// after delivering up to MaxEnergyOnInvalidId more Wh (see MeterValues)
+	if settings.maxEnergy > 0 {
+		c.log().charger.Info("Transaction kept open before suspending", "max_energy_wh", settings.maxEnergy)
+		return
+	}
+	if err := c.suspendDeauthorized(); err != nil {
+		c.log().charger.Error("Failed to suspend deauthorized transaction", logging.Err(err))
+	}
+}
+
+// deauthorizedEnergyLeft returns how many Wh may still be delivered after a
+// deauthorization. Must be called with c.mu held.
+func (c *Charger) deauthorizedEnergyLeft() int {
+	left := c.invalidId.maxEnergy - (c.meterValue - c.deauthorizedMeter)
+	if left < 0 {
+		return 0
+	}
+	return left
+}
+
+// suspendDeauthorized stops energy delivery for a deauthorized transaction
+// that is kept open (StopTransactionOnInvalidId = false).
+// OCPP 1.6: status changes to SuspendedEVSE (this also stops the meter loop)
+// OCPP 2.0.1: TransactionEvent (Updated) with chargingState SuspendedEVSE and trigger Deauthorized
+func (c *Charger) suspendDeauthorized() error {
+	c.mu.Lock()
+	if c.deauthSuspended {
+		c.mu.Unlock()
+		return nil
+	}
+	c.deauthSuspended = true
+	transactionIdStr := c.transactionIdStr
+	isConnected := c.isConnected
+	c.seqNo++
+	seqNo := c.seqNo
+	c.mu.Unlock()
+
+	c.log().charger.Info("Energy delivery suspended: idTag deauthorized")
+
+	if c.config.IsOCPP16() {
+		return c.SetStatus("SuspendedEVSE")
+	}
+
+	if !isConnected {
+		return nil
+	}
+
+	req := v201.TransactionEventRequest{
+		EventType:     v201.TransactionEventUpdated,
+		Timestamp:     time.Now().UTC().Format(time.RFC3339),
+		TriggerReason: v201.TriggerReasonDeauthorized,
+		SeqNo:         seqNo,
+		TransactionInfo: v201.Transaction{
+			TransactionId: transactionIdStr,
+			ChargingState: v201.ChargingStateSuspendedEVSE,
+		},
+	}
+
+	if _, err := c.sendCall(v201.ActionTransactionEvent, req); err != nil {
+		return fmt.Errorf("TransactionEvent (Updated) failed: %w", err)
+	}
+
+	c.log().charger.Info("TransactionEvent (Updated) sent", logging.KeyTransactionID, transactionIdStr, "charging_state", v201.ChargingStateSuspendedEVSE)
+	return nil
+}
+
+// clearDeauthorization resets the deauthorization state at the end of a
+// transaction. Must be called with c.mu held.
+func (c *Charger) clearDeauthorization() {
+	c.deauthorized = false
+	c.deauthorizedMeter = 0
+	c.deauthSuspended = false
+}
+
+// checkTransactionEventResponseV201 inspects a TransactionEvent response for
+// idTokenInfo, which the CSMS may use to deauthorize the running transaction
+func (c *Charger) checkTransactionEventResponseV201(resp []byte) {
+	var raw []json.RawMessage
+	if err := json.Unmarshal(resp, &raw); err != nil || len(raw) < 3 {
+		return
+	}
+
+	var eventResp v201.TransactionEventResponse
+	if err := json.Unmarshal(raw[2], &eventResp); err != nil {
+		return
+	}
+
+	if eventResp.IdTokenInfo != nil {
+		c.log().charger.Info("TransactionEvent response", "id_token_info_status", eventResp.IdTokenInfo.Status)
+		c.checkAuthorization(eventResp.IdTokenInfo.Status)
+	}
+}
+
+// triggerReasonForStop maps a stop reason to the OCPP 2.0.1 trigger reason of
+// the TransactionEvent (Ended) message
+func triggerReasonForStop(reason string) v201.TriggerReason {
+	switch reason {
+	case StopReasonDeAuthorized:
+		return v201.TriggerReasonDeauthorized
+	case StopReasonUnlockCommand:
+		return v201.TriggerReasonUnlockCommand
+	case "Remote":
+		return v201.TriggerReasonRemoteStop
+	case StopReasonImmediateReset, StopReasonReboot:
+		return v201.TriggerReasonResetCommand
+	case StopReasonPowerLoss, "GroundFault", "OvercurrentFault", "PowerQuality", "Other":
+		// Stopped by a power loss or a fault (see RaiseFault)
+		return v201.TriggerReasonAbnormalCondition
+	default:
+		return v201.TriggerReasonStopAuthorized
+	}
+}
+
+// stoppedReasonV201 maps a stop reason to the OCPP 2.0.1 stoppedReason. OCPP
+// 2.0.1 has no UnlockCommand reason: UnlockConnector only ends a transaction
+// that is no longer authorized, so the reason reported is DeAuthorized.
+func stoppedReasonV201(reason string) string {
+	if reason == StopReasonUnlockCommand {
+		return StopReasonDeAuthorized
+	}
+	return reason
+}
+
+// invalidIdSettings is the handling of a transaction whose idTag the server
+// deauthorizes (StopTransactionOnInvalidId and MaxEnergyOnInvalidId)
+type invalidIdSettings struct {
+	stopTransaction bool
+	maxEnergy       int // Wh
+}
+
+// GetStopTransactionOnInvalidId returns whether a deauthorized transaction is
+// stopped right away
+func (c *Charger) GetStopTransactionOnInvalidId() bool {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.invalidId.stopTransaction
+}
+
+// GetMaxEnergyOnInvalidId returns the energy in Wh still delivered to a
+// deauthorized transaction that is kept open
+func (c *Charger) GetMaxEnergyOnInvalidId() int {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.invalidId.maxEnergy
+}
+
+// setStopTransactionOnInvalidId sets StopTransactionOnInvalidId from a
+// configuration value
+func (c *Charger) setStopTransactionOnInvalidId(value string) (bool, error) {
+	stop, err := parseConfigBool(value)
+	if err != nil {
+		return false, fmt.Errorf("invalid StopTransactionOnInvalidId %q", value)
+	}
+	return false, c.updateInvalidId(func(s *invalidIdSettings) { s.stopTransaction = stop })
+}
+
+// setMaxEnergyOnInvalidId sets MaxEnergyOnInvalidId from a configuration value
+func (c *Charger) setMaxEnergyOnInvalidId(value string) (bool, error) {
+	maxEnergy, err := strconv.Atoi(value)
+	if err != nil {
+		return false, fmt.Errorf("invalid MaxEnergyOnInvalidId %q", value)
+	}
+	return false, c.updateInvalidId(func(s *invalidIdSettings) { s.maxEnergy = maxEnergy })
+}
+
+// updateInvalidId changes the invalid idTag handling, checked with the rules
+// of the configuration file
+func (c *Charger) updateInvalidId(update func(s *invalidIdSettings)) error {
+	c.mu.Lock()
+	next := c.invalidId
+	update(&next)
+	problems := config.CheckInvalidId(next.stopTransaction, next.maxEnergy)
+	if len(problems.Errors) > 0 {
+		c.mu.Unlock()
+		return errors.New(problems.Errors[0])
+	}
+	c.invalidId = next
+	c.mu.Unlock()
+
+	for _, warning := range problems.Warnings {
+		c.log().charger.Warn(warning)
+	}
+	c.log().charger.Info("Invalid idTag handling changed", "stop_transaction", next.stopTransaction, "max_energy_wh", next.maxEnergy)
+	return nil
+}
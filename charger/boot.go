@@ -1,31 +1,71 @@
 package charger
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
-	"log"
+	"time"
 
 	"github.com/weilun-shrimp/wlgo_ocpp_charger_simulator/ocpp/v16"
 	"github.com/weilun-shrimp/wlgo_ocpp_charger_simulator/ocpp/v201"
 )
 
-// BootNotification sends a BootNotification request
+// rebootDelay is how long a simulated reboot keeps the charger offline
+const rebootDelay = 3 * time.Second
+
+// Boot reasons (OCPP 2.0.1 BootReasonEnumType) of the causes the simulator has
+const (
+	bootReasonPowerUp        = "PowerUp"        // first boot of the simulator
+	bootReasonFirmwareUpdate = "FirmwareUpdate" // reboot after installing firmware
+	bootReasonTriggered      = "Triggered"      // requested with TriggerMessage
+	bootReasonRemoteReset    = "RemoteReset"    // Reset from the server
+	bootReasonLocalReset     = "LocalReset"     // reset by the operator
+	bootReasonWatchdog       = "Watchdog"       // restart by the watchdog
+)
+
+// BootNotification sends a BootNotification request with the reason of the
+// last restart
 func (c *Charger) BootNotification() error {
+	return c.BootNotificationContext(context.Background())
+}
+
+// BootNotificationContext sends a BootNotification request with the reason of
+// the last restart, waiting for the response until ctx is done
+func (c *Charger) BootNotificationContext(ctx context.Context) error {
+	c.mu.RLock()
+	reason := c.nextBootReason
+	c.mu.RUnlock()
+	return c.sendBootNotification(ctx, reason)
+}
+
+// sendBootNotification sends a BootNotification with the given boot reason
+// (only reported in OCPP 2.0.1)
+func (c *Charger) sendBootNotification(ctx context.Context, reason string) error {
+	c.mu.Lock()
+	c.bootReason = reason
+	c.mu.Unlock()
+
 	if c.config.IsOCPP16() {
-		return c.bootNotificationV16()
+		return c.bootNotificationV16(ctx)
 	}
-	return c.bootNotificationV201()
+	return c.bootNotificationV201(ctx, reason)
 }
 
-func (c *Charger) bootNotificationV16() error {
+func (c *Charger) bootNotificationV16(ctx context.Context) error {
+	identity := c.config.GetIdentity()
 	req := v16.BootNotificationRequest{
-		ChargePointVendor:       "Simulator",
-		ChargePointModel:        "WLGO-SIM-1",
-		ChargePointSerialNumber: c.config.ChargerID,
-		FirmwareVersion:         "1.0.0",
+		ChargePointVendor:       identity.Vendor,
+		ChargePointModel:        identity.Model,
+		ChargePointSerialNumber: identity.SerialNumber,
+		ChargeBoxSerialNumber:   identity.ChargeBoxSerialNumber,
+		FirmwareVersion:         c.GetFirmwareVersion(),
+		Iccid:                   identity.Iccid,
+		Imsi:                    identity.Imsi,
+		MeterType:               identity.MeterType,
+		MeterSerialNumber:       identity.MeterSerialNumber,
 	}
 
-	resp, err := c.sendCall(v16.ActionBootNotification, req)
+	resp, err := c.sendCallContext(ctx, v16.ActionBootNotification, req)
 	if err != nil {
 		return fmt.Errorf("BootNotification failed: %w", err)
 	}
@@ -40,31 +80,30 @@ func (c *Charger) bootNotificationV16() error {
 		if err := json.Unmarshal(raw[2], &bootResp); err != nil {
 			return fmt.Errorf("failed to parse BootNotification response: %w", err)
 		}
-		log.Printf("BootNotification response: status=%s, interval=%d", bootResp.Status, bootResp.Interval)
+		c.log().charger.Info("BootNotification response", "status", bootResp.Status, "interval", bootResp.Interval)
 
-		// Use server-provided interval if available
-		if bootResp.Interval > 0 {
-			c.SetHeartbeatInterval(bootResp.Interval)
-		}
-		// Start heartbeat loop
-		go c.StartHeartbeatLoop()
+		c.applyBootResponse(string(bootResp.Status), bootResp.Interval)
 	}
 
 	return nil
 }
 
-func (c *Charger) bootNotificationV201() error {
+func (c *Charger) bootNotificationV201(ctx context.Context, reason string) error {
+	identity := c.config.GetIdentity()
 	req := v201.BootNotificationRequest{
-		Reason: "PowerUp",
+		Reason: reason,
 		ChargingStation: v201.ChargingStation{
-			VendorName:      "Simulator",
-			Model:           "WLGO-SIM-2",
-			SerialNumber:    c.config.ChargerID,
-			FirmwareVersion: "2.0.0",
+			VendorName:      identity.Vendor,
+			Model:           identity.Model,
+			SerialNumber:    identity.SerialNumber,
+			FirmwareVersion: c.GetFirmwareVersion(),
 		},
 	}
+	if identity.Iccid != "" || identity.Imsi != "" {
+		req.ChargingStation.Modem = &v201.Modem{Iccid: identity.Iccid, Imsi: identity.Imsi}
+	}
 
-	resp, err := c.sendCall(v201.ActionBootNotification, req)
+	resp, err := c.sendCallContext(ctx, v201.ActionBootNotification, req)
 	if err != nil {
 		return fmt.Errorf("BootNotification failed: %w", err)
 	}
@@ -79,15 +118,42 @@ func (c *Charger) bootNotificationV201() error {
 		if err := json.Unmarshal(raw[2], &bootResp); err != nil {
 			return fmt.Errorf("failed to parse BootNotification response: %w", err)
 		}
-		log.Printf("BootNotification response: status=%s, interval=%d", bootResp.Status, bootResp.Interval)
+		c.log().charger.Info("BootNotification response", "status", bootResp.Status, "interval", bootResp.Interval)
 
-		// Use server-provided interval if available
-		if bootResp.Interval > 0 {
-			c.SetHeartbeatInterval(bootResp.Interval)
-		}
-		// Start heartbeat loop
-		go c.StartHeartbeatLoop()
+		c.applyBootResponse(string(bootResp.Status), bootResp.Interval)
 	}
 
 	return nil
 }
+
+// reboot simulates a restart of the charger: the connection is closed and, if
+// it was open, re-opened, followed by a BootNotification with the given reason
+// and, once accepted, the current StatusNotification
+func (c *Charger) reboot(reason string) error {
+	wasConnected := c.IsConnected()
+	c.log().charger.Info("Rebooting", "reason", reason)
+	c.Disconnect()
+	c.mu.Lock()
+	c.registration = ""
+	c.nextBootReason = reason // reported on the next connect if offline
+	c.mu.Unlock()
+	// Raised while offline: delivered after the BootNotification
+	c.RaiseSecurityEvent(securityEventResetOrReboot, "Reboot: "+reason)
+
+	time.Sleep(rebootDelay)
+
+	if !wasConnected {
+		c.log().charger.Info("Reboot complete (offline)")
+		return nil
+	}
+	if err := c.Connect(); err != nil {
+		return fmt.Errorf("reconnect after reboot failed: %w", err)
+	}
+	if err := c.sendBootNotification(context.Background(), reason); err != nil {
+		return err
+	}
+	if c.GetRegistrationStatus() != registrationAccepted {
+		return nil // sent by the BootNotification retry once accepted
+	}
+	return c.StatusNotification(c.GetStatus())
+}
@@ -0,0 +1,169 @@
+package charger
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/weilun-shrimp/wlgo_ocpp_charger_simulator/logging"
+	"github.com/weilun-shrimp/wlgo_ocpp_charger_simulator/ocpp/v16"
+	"github.com/weilun-shrimp/wlgo_ocpp_charger_simulator/ocpp/v201"
+)
+
+// StopReasonUnlockCommand is the stop reason used when UnlockConnector ends a
+// transaction (OCPP 1.6)
+const StopReasonUnlockCommand = "UnlockCommand"
+
+// lockCable locks the cable to the connector when a transaction starts.
+// Must be called with c.mu held.
+func (c *Charger) lockCable() {
+	if !c.config.HasCableLock() || c.cableLocked {
+		return
+	}
+	c.cableLocked = true
+	c.log().charger.Info("Cable locked")
+}
+
+// unlockCable releases the cable lock. It returns false when the lock is
+// jammed and the cable stays locked. Must be called with c.mu held.
+func (c *Charger) unlockCable() bool {
+	if !c.cableLocked {
+		return true
+	}
+	if c.lockJammed {
+		c.log().charger.Warn("Cable lock is jammed: cable stays locked")
+		return false
+	}
+	c.cableLocked = false
+	c.log().charger.Info("Cable unlocked")
+	return true
+}
+
+// IsCableLocked returns whether the cable is locked to the connector
+func (c *Charger) IsCableLocked() bool {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.cableLocked
+}
+
+// IsLockJammed returns whether the cable lock is jammed
+func (c *Charger) IsLockJammed() bool {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.lockJammed
+}
+
+// SetLockJammed jams or releases the cable lock. Releasing a jammed lock
+// unlocks the cable when no transaction is running.
+func (c *Charger) SetLockJammed(jammed bool) error {
+	if !c.config.HasCableLock() {
+		return fmt.Errorf("connector has no cable lock (cable_lock.not_supported is set)")
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.lockJammed = jammed
+	if jammed {
+		c.log().charger.Warn("Cable lock jammed")
+		return nil
+	}
+
+	c.log().charger.Info("Cable lock released")
+	if !c.isCharging {
+		c.unlockCable()
+	}
+	return nil
+}
+
+// unlockConnector stops the running transaction, if any, with reason
+// UnlockCommand and then unlocks the cable. It returns whether the cable
+// is unlocked.
+func (c *Charger) unlockConnector() bool {
+	if c.IsCharging() {
+		if err := c.StopTransaction(StopReasonUnlockCommand); err != nil {
+			c.log().charger.Error("Failed to stop transaction for UnlockConnector", logging.Err(err))
+		}
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.unlockCable()
+}
+
+// handleUnlockConnectorV16 handles UnlockConnector from server (OCPP 1.6)
+func (c *Charger) handleUnlockConnectorV16(uniqueId string, payload json.RawMessage) {
+	var req v16.UnlockConnectorRequest
+	if err := json.Unmarshal(payload, &req); err != nil {
+		c.log().charger.Error("Failed to parse UnlockConnector", logging.Action("UnlockConnector"), logging.UniqueID(uniqueId), logging.Err(err))
+		return
+	}
+
+	c.log().charger.Info("Received UnlockConnector", logging.Action("UnlockConnector"), logging.UniqueID(uniqueId), "connector_id", req.ConnectorId)
+
+	// The transaction is stopped and the cable unlocked before answering, so
+	// the response reports the outcome of the unlock attempt
+	var status string
+	switch {
+	case req.ConnectorId != c.config.ConnectorID:
+		c.log().charger.Warn("UnlockConnector: unknown connector", logging.Action("UnlockConnector"), logging.UniqueID(uniqueId), "connector_id", req.ConnectorId)
+		status = "NotSupported"
+	case !c.config.HasCableLock():
+		status = "NotSupported"
+	case c.unlockConnector():
+		status = "Unlocked"
+	default:
+		status = "UnlockFailed"
+	}
+
+	resp := v16.UnlockConnectorResponse{
+		Status: status,
+	}
+
+	if err := c.sendCallResult(uniqueId, resp); err != nil {
+		c.log().charger.Error("Failed to send UnlockConnector response", logging.Action("UnlockConnector"), logging.UniqueID(uniqueId), logging.Err(err))
+	}
+}
+
+// handleUnlockConnectorV201 handles UnlockConnector from server (OCPP 2.0.1).
+// Per OCPP 2.0.1 an authorized transaction is not stopped: the station answers
+// OngoingAuthorizedTransaction. A transaction that is no longer authorized is
+// ended with trigger reason UnlockCommand before unlocking.
+func (c *Charger) handleUnlockConnectorV201(uniqueId string, payload json.RawMessage) {
+	var req v201.UnlockConnectorRequest
+	if err := json.Unmarshal(payload, &req); err != nil {
+		c.log().charger.Error("Failed to parse UnlockConnector", logging.Action("UnlockConnector"), logging.UniqueID(uniqueId), logging.Err(err))
+		return
+	}
+
+	c.log().charger.Info("Received UnlockConnector", logging.Action("UnlockConnector"), logging.UniqueID(uniqueId), "evse_id", req.EvseId, "connector_id", req.ConnectorId)
+
+	c.mu.RLock()
+	authorizedTransaction := c.isCharging && !c.deauthorized
+	c.mu.RUnlock()
+
+	var status string
+	var statusInfo *v201.StatusInfo
+	switch {
+	case req.EvseId != c.config.ConnectorID || req.ConnectorId != 1:
+		c.log().charger.Warn("UnlockConnector: unknown EVSE or connector", logging.Action("UnlockConnector"), logging.UniqueID(uniqueId), "evse_id", req.EvseId, "connector_id", req.ConnectorId)
+		status = "UnknownConnector"
+	case !c.config.HasCableLock():
+		status = "UnlockFailed"
+		statusInfo = &v201.StatusInfo{ReasonCode: "UnsupportedRequest", AdditionalInfo: "connector has no cable lock"}
+	case authorizedTransaction:
+		status = "OngoingAuthorizedTransaction"
+	case c.unlockConnector():
+		status = "Unlocked"
+	default:
+		status = "UnlockFailed"
+	}
+
+	resp := v201.UnlockConnectorResponse{
+		Status:     status,
+		StatusInfo: statusInfo,
+	}
+
+	if err := c.sendCallResult(uniqueId, resp); err != nil {
+		c.log().charger.Error("Failed to send UnlockConnector response", logging.Action("UnlockConnector"), logging.UniqueID(uniqueId), logging.Err(err))
+	}
+}
@@ -0,0 +1,157 @@
+package charger
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"time"
+
+	"github.com/weilun-shrimp/wlgo_ocpp_charger_simulator/config"
+	"github.com/weilun-shrimp/wlgo_ocpp_charger_simulator/logging"
+)
+
+// callResponseData is the data available to the response templates of
+// call_responses
+type callResponseData struct {
+	Action    string
+	UniqueId  string
+	ChargerID string
+	Now       string
+	Request   interface{}
+}
+
+// SendCall sends a Call with any action and a raw JSON payload, e.g. a vendor
+// DataTransfer or an action the simulator has no code for, and returns the
+// payload of the CallResult. A CallError is returned as a *CallError.
+func (c *Charger) SendCall(action string, payload json.RawMessage) (json.RawMessage, error) {
+	return c.SendCallContext(context.Background(), action, payload)
+}
+
+// SendCallContext is SendCall, waiting for the response until ctx is done
+func (c *Charger) SendCallContext(ctx context.Context, action string, payload json.RawMessage) (json.RawMessage, error) {
+	if !json.Valid(payload) {
+		return nil, fmt.Errorf("payload is not valid JSON: %s", payload)
+	}
+
+	resp, err := c.sendCallContext(ctx, action, payload)
+	if err != nil {
+		return nil, fmt.Errorf("%s failed: %w", action, err)
+	}
+
+	var raw []json.RawMessage
+	if err := json.Unmarshal(resp, &raw); err != nil || len(raw) < 3 {
+		return nil, fmt.Errorf("failed to parse %s response: %s", action, resp)
+	}
+	return raw[2], nil
+}
+
+// handleUnknownCall answers a server Call without a handler from the
+// call_responses table, or with a NotImplemented CallError if no entry fits
+func (c *Charger) handleUnknownCall(uniqueId, action string, payload json.RawMessage) {
+	var request interface{}
+	json.Unmarshal(payload, &request)
+
+	r := c.findCallResponse(action, request)
+	if r == nil {
+		c.log().charger.Warn("Unknown action", logging.Action(action), logging.UniqueID(uniqueId))
+		if err := c.sendCallError(uniqueId, "NotImplemented", "Unknown action "+action); err != nil {
+			c.log().charger.Error("Failed to send CallError", logging.Err(err))
+		}
+		return
+	}
+
+	if r.Error != nil {
+		c.log().charger.Info("Answering with canned CallError", logging.Action(action), logging.UniqueID(uniqueId), "error_code", r.Error.Code)
+		if err := c.sendCallError(uniqueId, r.Error.Code, r.Error.Description); err != nil {
+			c.log().charger.Error("Failed to send CallError", logging.Err(err))
+		}
+		return
+	}
+
+	resp, err := renderCallResponse(r, callResponseData{
+		Action:    action,
+		UniqueId:  uniqueId,
+		ChargerID: c.config.ChargerID,
+		Now:       time.Now().UTC().Format(time.RFC3339),
+		Request:   request,
+	})
+	if err != nil {
+		c.log().charger.Warn("Canned response failed", logging.Action(action), logging.UniqueID(uniqueId), logging.Err(err))
+		if err := c.sendCallError(uniqueId, "InternalError", "Canned response failed"); err != nil {
+			c.log().charger.Error("Failed to send CallError", logging.Err(err))
+		}
+		return
+	}
+
+	c.log().charger.Info("Answering with a canned response", logging.Action(action), logging.UniqueID(uniqueId))
+	if err := c.sendCallResult(uniqueId, resp); err != nil {
+		c.log().charger.Error("Failed to send response", logging.Action(action), logging.UniqueID(uniqueId), logging.Err(err))
+	}
+}
+
+// hasCallResponse reports whether a call_responses entry answers the Call
+func (c *Charger) hasCallResponse(action string, payload json.RawMessage) bool {
+	var request interface{}
+	json.Unmarshal(payload, &request)
+	return c.findCallResponse(action, request) != nil
+}
+
+// findCallResponse returns the first call_responses entry for action whose
+// match predicates all hold for the request, or nil if there is none
+func (c *Charger) findCallResponse(action string, request interface{}) *config.CallResponseConfig {
+	for i := range c.config.CallResponses {
+		r := &c.config.CallResponses[i]
+		if r.Action != action {
+			continue
+		}
+		paths, err := r.MatchPaths()
+		if err != nil {
+			continue
+		}
+		matched := true
+		for path, want := range paths {
+			got, ok := path.Lookup(request)
+			if !ok || !sameJSON(got, want) {
+				matched = false
+				break
+			}
+		}
+		if matched {
+			return r
+		}
+	}
+	return nil
+}
+
+// sameJSON reports whether a decoded JSON value equals a value from the YAML
+// configuration, comparing both as JSON (so 1 and 1.0 are equal)
+func sameJSON(got, want interface{}) bool {
+	b, err := json.Marshal(want)
+	if err != nil {
+		return false
+	}
+	var normalized interface{}
+	if err := json.Unmarshal(b, &normalized); err != nil {
+		return false
+	}
+	return reflect.DeepEqual(got, normalized)
+}
+
+// renderCallResponse executes the response template and checks that the
+// result is JSON
+func renderCallResponse(r *config.CallResponseConfig, data callResponseData) (json.RawMessage, error) {
+	tmpl, err := r.ResponseTemplate()
+	if err != nil {
+		return nil, err
+	}
+	var out bytes.Buffer
+	if err := tmpl.Execute(&out, data); err != nil {
+		return nil, err
+	}
+	if !json.Valid(out.Bytes()) {
+		return nil, fmt.Errorf("response is not valid JSON: %s", out.String())
+	}
+	return json.RawMessage(out.Bytes()), nil
+}
@@ -0,0 +1,771 @@
+package charger
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/sha512"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"hash"
+	"strings"
+	"time"
+
+	"github.com/weilun-shrimp/wlgo_ocpp_charger_simulator/logging"
+	"github.com/weilun-shrimp/wlgo_ocpp_charger_simulator/ocpp/v16"
+	"github.com/weilun-shrimp/wlgo_ocpp_charger_simulator/ocpp/v201"
+)
+
+// Certificate types of the trust store
+const (
+	certCentralSystemRoot = "CentralSystemRootCertificate" // OCPP 1.6
+	certCSMSRoot          = "CSMSRootCertificate"          // OCPP 2.0.1
+	certManufacturerRoot  = "ManufacturerRootCertificate"
+	certV2GRoot           = "V2GRootCertificate" // OCPP 2.0.1
+	certMORoot            = "MORootCertificate"  // OCPP 2.0.1
+
+	certChargingStation = "ChargingStationCertificate" // OCPP 2.0.1 SignCertificate/CertificateSigned
+)
+
+// installedCertificate is a certificate of the trust store
+type installedCertificate struct {
+	certificateType string
+	cert            *x509.Certificate
+}
+
+// certificateHash identifies a certificate as in CertificateHashData
+type certificateHash struct {
+	hashAlgorithm  string
+	issuerNameHash string
+	issuerKeyHash  string
+	serialNumber   string
+}
+
+// matches compares hashes the way the server sends them (hex case-insensitive)
+func (h certificateHash) matches(other certificateHash) bool {
+	return strings.EqualFold(h.hashAlgorithm, other.hashAlgorithm) &&
+		strings.EqualFold(h.issuerNameHash, other.issuerNameHash) &&
+		strings.EqualFold(h.issuerKeyHash, other.issuerKeyHash) &&
+		strings.EqualFold(strings.TrimLeft(h.serialNumber, "0"), strings.TrimLeft(other.serialNumber, "0"))
+}
+
+// newHash returns the hash function of a CertificateHashData hashAlgorithm
+func newHash(algorithm string) (hash.Hash, bool) {
+	switch algorithm {
+	case "SHA256":
+		return sha256.New(), true
+	case "SHA384":
+		return sha512.New384(), true
+	case "SHA512":
+		return sha512.New(), true
+	default:
+		return nil, false
+	}
+}
+
+// hashCertificate computes the CertificateHashData of cert. The issuer key is
+// taken from issuer; for a self-signed certificate that is cert itself.
+func hashCertificate(cert, issuer *x509.Certificate, algorithm string) (certificateHash, error) {
+	var spki struct {
+		Algorithm pkix.AlgorithmIdentifier
+		PublicKey asn1.BitString
+	}
+	if _, err := asn1.Unmarshal(issuer.RawSubjectPublicKeyInfo, &spki); err != nil {
+		return certificateHash{}, fmt.Errorf("failed to parse issuer public key: %w", err)
+	}
+
+	sum := func(data []byte) string {
+		h, _ := newHash(algorithm)
+		h.Write(data)
+		return hex.EncodeToString(h.Sum(nil))
+	}
+	if _, ok := newHash(algorithm); !ok {
+		return certificateHash{}, fmt.Errorf("unsupported hash algorithm %q", algorithm)
+	}
+
+	return certificateHash{
+		hashAlgorithm:  algorithm,
+		issuerNameHash: sum(cert.RawIssuer),
+		issuerKeyHash:  sum(spki.PublicKey.Bytes),
+		serialNumber:   cert.SerialNumber.Text(16),
+	}, nil
+}
+
+// issuerOf returns the issuer of cert from the trust store, cert itself if it
+// is self-signed or its issuer is not installed. c.mu must be held.
+func (c *Charger) issuerOf(cert *x509.Certificate) *x509.Certificate {
+	for _, ic := range c.trustStore {
+		if string(ic.cert.RawSubject) == string(cert.RawIssuer) && cert.CheckSignatureFrom(ic.cert) == nil {
+			return ic.cert
+		}
+	}
+	return cert
+}
+
+// hashInstalled computes the hash of an installed certificate. c.mu must be held.
+func (c *Charger) hashInstalled(cert *x509.Certificate, algorithm string) (certificateHash, error) {
+	return hashCertificate(cert, c.issuerOf(cert), algorithm)
+}
+
+// parseCertificateChain parses the PEM certificates of a chain, leaf first
+func parseCertificateChain(chainPEM string) ([]*x509.Certificate, error) {
+	var certs []*x509.Certificate
+	rest := []byte(chainPEM)
+	for {
+		var block *pem.Block
+		block, rest = pem.Decode(rest)
+		if block == nil {
+			break
+		}
+		if block.Type != "CERTIFICATE" {
+			continue
+		}
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse certificate: %w", err)
+		}
+		certs = append(certs, cert)
+	}
+	if len(certs) == 0 {
+		return nil, fmt.Errorf("no PEM encoded certificate found")
+	}
+	return certs, nil
+}
+
+// checkValidity returns an error if cert is not valid now
+func checkValidity(cert *x509.Certificate) error {
+	now := time.Now()
+	if now.Before(cert.NotBefore) || now.After(cert.NotAfter) {
+		return fmt.Errorf("certificate %s is valid from %s to %s", cert.Subject, cert.NotBefore.UTC().Format(time.RFC3339), cert.NotAfter.UTC().Format(time.RFC3339))
+	}
+	return nil
+}
+
+// GetClientCertificate returns the TLS client certificate used for the next
+// connection: the one installed with CertificateSigned, else the configured
+// one. It returns nil if there is none.
+func (c *Charger) GetClientCertificate() *x509.Certificate {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.clientCertificateLocked()
+}
+
+// clientCertificateLocked returns the current client certificate. c.mu must be held.
+func (c *Charger) clientCertificateLocked() *x509.Certificate {
+	if c.clientCert != nil {
+		return c.clientCert.Leaf
+	}
+	if c.tlsConfig == nil || len(c.tlsConfig.Certificates) == 0 {
+		return nil
+	}
+	configured := c.tlsConfig.Certificates[0]
+	if configured.Leaf != nil {
+		return configured.Leaf
+	}
+	cert, err := x509.ParseCertificate(configured.Certificate[0])
+	if err != nil {
+		return nil
+	}
+	return cert
+}
+
+// connectTLSConfig returns the TLS settings for a new connection: the
+// configured ones, with the client certificate from CertificateSigned and the
+// installed CSMS root certificates
+func (c *Charger) connectTLSConfig() *tls.Config {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	var roots []*x509.Certificate
+	for _, ic := range c.trustStore {
+		if ic.certificateType == certCentralSystemRoot || ic.certificateType == certCSMSRoot {
+			roots = append(roots, ic.cert)
+		}
+	}
+	if c.clientCert == nil && len(roots) == 0 {
+		return c.tlsConfig
+	}
+
+	tlsConfig := &tls.Config{}
+	if c.tlsConfig != nil {
+		tlsConfig = c.tlsConfig.Clone()
+	}
+	if c.clientCert != nil {
+		tlsConfig.Certificates = []tls.Certificate{*c.clientCert}
+	}
+	if len(roots) > 0 {
+		pool := tlsConfig.RootCAs
+		if pool != nil {
+			pool = pool.Clone()
+		} else if system, err := x509.SystemCertPool(); err == nil {
+			pool = system
+		} else {
+			pool = x509.NewCertPool()
+		}
+		for _, root := range roots {
+			pool.AddCert(root)
+		}
+		tlsConfig.RootCAs = pool
+	}
+	return tlsConfig
+}
+
+// verifySigningCertificate checks that a firmware signing certificate chains
+// to an installed ManufacturerRootCertificate. Without manufacturer roots any
+// signing certificate is accepted.
+func (c *Charger) verifySigningCertificate(cert *x509.Certificate) error {
+	c.mu.RLock()
+	pool := x509.NewCertPool()
+	hasRoots := false
+	for _, ic := range c.trustStore {
+		if ic.certificateType == certManufacturerRoot {
+			pool.AddCert(ic.cert)
+			hasRoots = true
+		}
+	}
+	c.mu.RUnlock()
+
+	if !hasRoots {
+		return nil
+	}
+	if _, err := cert.Verify(x509.VerifyOptions{Roots: pool, KeyUsages: []x509.ExtKeyUsage{x509.ExtKeyUsageAny}}); err != nil {
+		return fmt.Errorf("signing certificate is not issued by an installed ManufacturerRootCertificate: %w", err)
+	}
+	return nil
+}
+
+// RenewCertificate generates a new key pair and sends its certificate signing
+// request in SignCertificate. The signed certificate arrives in CertificateSigned.
+func (c *Charger) RenewCertificate() error {
+	if !c.IsConnected() {
+		return ErrNotConnected
+	}
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return fmt.Errorf("failed to generate key: %w", err)
+	}
+
+	template := &x509.CertificateRequest{
+		Subject: pkix.Name{
+			CommonName:   c.config.ChargerID,
+			Organization: []string{c.config.GetCertificateOrganization()},
+		},
+	}
+	der, err := x509.CreateCertificateRequest(rand.Reader, template, key)
+	if err != nil {
+		return fmt.Errorf("failed to create certificate signing request: %w", err)
+	}
+	csr := string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE REQUEST", Bytes: der}))
+
+	c.mu.Lock()
+	c.pendingKey = key
+	c.mu.Unlock()
+
+	var status string
+	if c.config.IsOCPP16() {
+		status, err = c.signCertificateV16(csr)
+	} else {
+		status, err = c.signCertificateV201(csr)
+	}
+	if err != nil {
+		return err
+	}
+
+	c.log().charger.Info("SignCertificate response", "status", status)
+	if status != "Accepted" {
+		c.mu.Lock()
+		if c.pendingKey == key {
+			c.pendingKey = nil
+		}
+		c.mu.Unlock()
+		return fmt.Errorf("SignCertificate %s", strings.ToLower(status))
+	}
+	return nil
+}
+
+func (c *Charger) signCertificateV16(csr string) (string, error) {
+	resp, err := c.sendCall(v16.ActionSignCertificate, v16.SignCertificateRequest{Csr: csr})
+	if err != nil {
+		return "", fmt.Errorf("SignCertificate failed: %w", err)
+	}
+
+	var raw []json.RawMessage
+	if err := json.Unmarshal(resp, &raw); err != nil {
+		return "", fmt.Errorf("failed to parse response: %w", err)
+	}
+
+	var signResp v16.SignCertificateResponse
+	if len(raw) >= 3 {
+		if err := json.Unmarshal(raw[2], &signResp); err != nil {
+			return "", fmt.Errorf("failed to parse SignCertificate response: %w", err)
+		}
+	}
+	return signResp.Status, nil
+}
+
+func (c *Charger) signCertificateV201(csr string) (string, error) {
+	req := v201.SignCertificateRequest{
+		Csr:             csr,
+		CertificateType: certChargingStation,
+	}
+
+	resp, err := c.sendCall(v201.ActionSignCertificate, req)
+	if err != nil {
+		return "", fmt.Errorf("SignCertificate failed: %w", err)
+	}
+
+	var raw []json.RawMessage
+	if err := json.Unmarshal(resp, &raw); err != nil {
+		return "", fmt.Errorf("failed to parse response: %w", err)
+	}
+
+	var signResp v201.SignCertificateResponse
+	if len(raw) >= 3 {
+		if err := json.Unmarshal(raw[2], &signResp); err != nil {
+			return "", fmt.Errorf("failed to parse SignCertificate response: %w", err)
+		}
+	}
+	return signResp.Status, nil
+}
+
+// installClientCertificate checks a chain received in CertificateSigned
+// against the pending key and makes it the client certificate for the next
+// connection
+func (c *Charger) installClientCertificate(chainPEM string) error {
+	certs, err := parseCertificateChain(chainPEM)
+	if err != nil {
+		return err
+	}
+	leaf := certs[0]
+	if err := checkValidity(leaf); err != nil {
+		return err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.pendingKey == nil {
+		return fmt.Errorf("no certificate signing request pending")
+	}
+	if !c.pendingKey.PublicKey.Equal(leaf.PublicKey) {
+		return fmt.Errorf("certificate does not match the pending certificate signing request")
+	}
+
+	chain := make([][]byte, len(certs))
+	for i, cert := range certs {
+		chain[i] = cert.Raw
+	}
+	c.clientCert = &tls.Certificate{
+		Certificate: chain,
+		PrivateKey:  c.pendingKey,
+		Leaf:        leaf,
+	}
+	c.pendingKey = nil
+
+	c.log().charger.Info("Client certificate installed, used from the next connection", "subject", leaf.Subject, "expires", leaf.NotAfter.UTC().Format(time.RFC3339))
+	return nil
+}
+
+// rejectClientCertificate reports a rejected CertificateSigned chain
+func (c *Charger) rejectClientCertificate(err error) {
+	c.log().charger.Warn("CertificateSigned rejected", logging.Err(err))
+	c.RaiseSecurityEvent(c.invalidCertificateEvent(), err.Error())
+}
+
+// installTrustedCertificate adds a root certificate to the trust store,
+// replacing an identical one
+func (c *Charger) installTrustedCertificate(certificateType, certPEM string) error {
+	certs, err := parseCertificateChain(certPEM)
+	if err != nil {
+		return err
+	}
+	cert := certs[0]
+	if err := checkValidity(cert); err != nil {
+		return err
+	}
+	if !cert.IsCA {
+		return fmt.Errorf("certificate %s is not a CA certificate", cert.Subject)
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for i, ic := range c.trustStore {
+		if ic.certificateType == certificateType && ic.cert.Equal(cert) {
+			c.trustStore = append(c.trustStore[:i], c.trustStore[i+1:]...)
+			break
+		}
+	}
+	c.trustStore = append(c.trustStore, installedCertificate{certificateType: certificateType, cert: cert})
+
+	c.log().charger.Info("Certificate installed", "certificate_type", certificateType, "subject", cert.Subject)
+	return nil
+}
+
+// installedCertificateHashes returns the hashes of the installed certificates
+// of the given types (all if none given)
+func (c *Charger) installedCertificateHashes(types ...string) []installedHash {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	var hashes []installedHash
+	for _, ic := range c.trustStore {
+		if len(types) > 0 && !containsString(types, ic.certificateType) {
+			continue
+		}
+		h, err := c.hashInstalled(ic.cert, "SHA256")
+		if err != nil {
+			c.log().charger.Error("Failed to hash certificate", "certificate_type", ic.certificateType, "subject", ic.cert.Subject, logging.Err(err))
+			continue
+		}
+		hashes = append(hashes, installedHash{certificateType: ic.certificateType, hash: h})
+	}
+	return hashes
+}
+
+// installedHash is the hash of a trust store certificate and its type
+type installedHash struct {
+	certificateType string
+	hash            certificateHash
+}
+
+// deleteCertificate removes the trust store certificate with the given hash.
+// It returns the DeleteCertificate status: Accepted, Failed or NotFound.
+func (c *Charger) deleteCertificate(target certificateHash) string {
+	if _, ok := newHash(target.hashAlgorithm); !ok {
+		c.log().charger.Warn("DeleteCertificate: unsupported hash algorithm", "hash_algorithm", target.hashAlgorithm)
+		return "NotFound"
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	// The charger's own certificate cannot be deleted
+	if own := c.clientCertificateLocked(); own != nil {
+		if h, err := c.hashInstalled(own, target.hashAlgorithm); err == nil && h.matches(target) {
+			c.log().charger.Warn("DeleteCertificate: refusing to delete the client certificate")
+			return "Failed"
+		}
+	}
+
+	for i, ic := range c.trustStore {
+		h, err := c.hashInstalled(ic.cert, target.hashAlgorithm)
+		if err != nil || !h.matches(target) {
+			continue
+		}
+		c.trustStore = append(c.trustStore[:i], c.trustStore[i+1:]...)
+		c.log().charger.Info("Certificate deleted", "certificate_type", ic.certificateType, "subject", ic.cert.Subject)
+		return "Accepted"
+	}
+	return "NotFound"
+}
+
+// reportExpiringCertificates raises a security event for every
+// certificate (client certificate and trust store) expiring within the
+// configured warning period
+func (c *Charger) reportExpiringCertificates() {
+	deadline := time.Now().Add(c.config.GetCertificateExpiryWarning())
+
+	type expiring struct {
+		certificateType string
+		cert            *x509.Certificate
+	}
+	var certs []expiring
+
+	c.mu.RLock()
+	if own := c.clientCertificateLocked(); own != nil {
+		certs = append(certs, expiring{c.clientCertificateType(), own})
+	}
+	for _, ic := range c.trustStore {
+		certs = append(certs, expiring{ic.certificateType, ic.cert})
+	}
+	c.mu.RUnlock()
+
+	for _, e := range certs {
+		if e.cert.NotAfter.After(deadline) {
+			continue
+		}
+		techInfo := fmt.Sprintf("%s %s expires %s", e.certificateType, e.cert.Subject, e.cert.NotAfter.UTC().Format(time.RFC3339))
+		c.RaiseSecurityEvent(securityEventCertificateExpiring, techInfo)
+	}
+}
+
+// clientCertificateType returns the name of the client certificate type
+func (c *Charger) clientCertificateType() string {
+	if c.config.IsOCPP16() {
+		return "ChargePointCertificate"
+	}
+	return certChargingStation
+}
+
+// containsString reports whether list contains s
+func containsString(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+// trustStoreTypeValid returns true if certificateType can be installed with
+// InstallCertificate in the configured OCPP version
+func (c *Charger) trustStoreTypeValid(certificateType string) bool {
+	if c.config.IsOCPP16() {
+		return certificateType == certCentralSystemRoot || certificateType == certManufacturerRoot
+	}
+	switch certificateType {
+	case certCSMSRoot, certManufacturerRoot, certV2GRoot, certMORoot:
+		return true
+	}
+	return false
+}
+
+// handleCertificateSignedV16 handles CertificateSigned from server (OCPP 1.6 Security Whitepaper)
+func (c *Charger) handleCertificateSignedV16(uniqueId string, payload json.RawMessage) {
+	var req v16.CertificateSignedRequest
+	if err := json.Unmarshal(payload, &req); err != nil {
+		c.log().charger.Error("Failed to parse CertificateSigned", logging.Action("CertificateSigned"), logging.UniqueID(uniqueId), logging.Err(err))
+		return
+	}
+
+	c.log().charger.Info("Received CertificateSigned", logging.Action("CertificateSigned"), logging.UniqueID(uniqueId))
+
+	status := "Accepted"
+	if err := c.installClientCertificate(req.CertificateChain); err != nil {
+		status = "Rejected"
+		c.rejectClientCertificate(err)
+	}
+
+	resp := v16.CertificateSignedResponse{
+		Status: status,
+	}
+
+	if err := c.sendCallResult(uniqueId, resp); err != nil {
+		c.log().charger.Error("Failed to send CertificateSigned response", logging.Action("CertificateSigned"), logging.UniqueID(uniqueId), logging.Err(err))
+	}
+}
+
+// handleCertificateSignedV201 handles CertificateSigned from server (OCPP 2.0.1)
+func (c *Charger) handleCertificateSignedV201(uniqueId string, payload json.RawMessage) {
+	var req v201.CertificateSignedRequest
+	if err := json.Unmarshal(payload, &req); err != nil {
+		c.log().charger.Error("Failed to parse CertificateSigned", logging.Action("CertificateSigned"), logging.UniqueID(uniqueId), logging.Err(err))
+		return
+	}
+
+	c.log().charger.Info("Received CertificateSigned", logging.Action("CertificateSigned"), logging.UniqueID(uniqueId), "certificate_type", req.CertificateType)
+
+	status := "Accepted"
+	var statusInfo *v201.StatusInfo
+	if req.CertificateType != "" && req.CertificateType != certChargingStation {
+		c.log().charger.Warn("CertificateSigned rejected: certificate type not supported", logging.Action("CertificateSigned"), logging.UniqueID(uniqueId), "certificate_type", req.CertificateType)
+		status = "Rejected"
+		statusInfo = &v201.StatusInfo{ReasonCode: "UnsupportedCertificateType"}
+	} else if err := c.installClientCertificate(req.CertificateChain); err != nil {
+		status = "Rejected"
+		statusInfo = &v201.StatusInfo{ReasonCode: "InvalidCertificate", AdditionalInfo: err.Error()}
+		c.rejectClientCertificate(err)
+	}
+
+	resp := v201.CertificateSignedResponse{
+		Status:     status,
+		StatusInfo: statusInfo,
+	}
+
+	if err := c.sendCallResult(uniqueId, resp); err != nil {
+		c.log().charger.Error("Failed to send CertificateSigned response", logging.Action("CertificateSigned"), logging.UniqueID(uniqueId), logging.Err(err))
+	}
+}
+
+// handleInstallCertificateV16 handles InstallCertificate from server (OCPP 1.6 Security Whitepaper)
+func (c *Charger) handleInstallCertificateV16(uniqueId string, payload json.RawMessage) {
+	var req v16.InstallCertificateRequest
+	if err := json.Unmarshal(payload, &req); err != nil {
+		c.log().charger.Error("Failed to parse InstallCertificate", logging.Action("InstallCertificate"), logging.UniqueID(uniqueId), logging.Err(err))
+		return
+	}
+
+	c.log().charger.Info("Received InstallCertificate", logging.Action("InstallCertificate"), logging.UniqueID(uniqueId), "certificate_type", req.CertificateType)
+
+	status := "Accepted"
+	if !c.trustStoreTypeValid(req.CertificateType) {
+		c.log().charger.Warn("InstallCertificate rejected: unknown certificate type", logging.Action("InstallCertificate"), logging.UniqueID(uniqueId), "certificate_type", req.CertificateType)
+		status = "Rejected"
+	} else if err := c.installTrustedCertificate(req.CertificateType, req.Certificate); err != nil {
+		c.log().charger.Warn("InstallCertificate rejected", logging.Err(err))
+		status = "Rejected"
+	}
+
+	resp := v16.InstallCertificateResponse{
+		Status: status,
+	}
+
+	if err := c.sendCallResult(uniqueId, resp); err != nil {
+		c.log().charger.Error("Failed to send InstallCertificate response", logging.Action("InstallCertificate"), logging.UniqueID(uniqueId), logging.Err(err))
+	}
+}
+
+// handleInstallCertificateV201 handles InstallCertificate from server (OCPP 2.0.1)
+func (c *Charger) handleInstallCertificateV201(uniqueId string, payload json.RawMessage) {
+	var req v201.InstallCertificateRequest
+	if err := json.Unmarshal(payload, &req); err != nil {
+		c.log().charger.Error("Failed to parse InstallCertificate", logging.Action("InstallCertificate"), logging.UniqueID(uniqueId), logging.Err(err))
+		return
+	}
+
+	c.log().charger.Info("Received InstallCertificate", logging.Action("InstallCertificate"), logging.UniqueID(uniqueId), "certificate_type", req.CertificateType)
+
+	status := "Accepted"
+	var statusInfo *v201.StatusInfo
+	if !c.trustStoreTypeValid(req.CertificateType) {
+		c.log().charger.Warn("InstallCertificate rejected: unknown certificate type", logging.Action("InstallCertificate"), logging.UniqueID(uniqueId), "certificate_type", req.CertificateType)
+		status = "Rejected"
+		statusInfo = &v201.StatusInfo{ReasonCode: "UnsupportedCertificateType"}
+	} else if err := c.installTrustedCertificate(req.CertificateType, req.Certificate); err != nil {
+		c.log().charger.Warn("InstallCertificate rejected", logging.Err(err))
+		status = "Rejected"
+		statusInfo = &v201.StatusInfo{ReasonCode: "InvalidCertificate", AdditionalInfo: err.Error()}
+	}
+
+	resp := v201.InstallCertificateResponse{
+		Status:     status,
+		StatusInfo: statusInfo,
+	}
+
+	if err := c.sendCallResult(uniqueId, resp); err != nil {
+		c.log().charger.Error("Failed to send InstallCertificate response", logging.Action("InstallCertificate"), logging.UniqueID(uniqueId), logging.Err(err))
+	}
+}
+
+// handleGetInstalledCertificateIdsV16 handles GetInstalledCertificateIds from
+// server (OCPP 1.6 Security Whitepaper)
+func (c *Charger) handleGetInstalledCertificateIdsV16(uniqueId string, payload json.RawMessage) {
+	var req v16.GetInstalledCertificateIdsRequest
+	if err := json.Unmarshal(payload, &req); err != nil {
+		c.log().charger.Error("Failed to parse GetInstalledCertificateIds", logging.Action("GetInstalledCertificateIds"), logging.UniqueID(uniqueId), logging.Err(err))
+		return
+	}
+
+	c.log().charger.Info("Received GetInstalledCertificateIds", logging.Action("GetInstalledCertificateIds"), logging.UniqueID(uniqueId), "certificate_type", req.CertificateType)
+
+	resp := v16.GetInstalledCertificateIdsResponse{
+		Status: "NotFound",
+	}
+	var types []string
+	if req.CertificateType != "" {
+		types = append(types, req.CertificateType)
+	}
+	for _, ih := range c.installedCertificateHashes(types...) {
+		resp.Status = "Accepted"
+		resp.CertificateHashData = append(resp.CertificateHashData, ih.hash.toV16())
+	}
+
+	if err := c.sendCallResult(uniqueId, resp); err != nil {
+		c.log().charger.Error("Failed to send GetInstalledCertificateIds response", logging.Action("GetInstalledCertificateIds"), logging.UniqueID(uniqueId), logging.Err(err))
+	}
+}
+
+// handleGetInstalledCertificateIdsV201 handles GetInstalledCertificateIds from server (OCPP 2.0.1)
+func (c *Charger) handleGetInstalledCertificateIdsV201(uniqueId string, payload json.RawMessage) {
+	var req v201.GetInstalledCertificateIdsRequest
+	if err := json.Unmarshal(payload, &req); err != nil {
+		c.log().charger.Error("Failed to parse GetInstalledCertificateIds", logging.Action("GetInstalledCertificateIds"), logging.UniqueID(uniqueId), logging.Err(err))
+		return
+	}
+
+	c.log().charger.Info("Received GetInstalledCertificateIds", logging.Action("GetInstalledCertificateIds"), logging.UniqueID(uniqueId), "certificate_type", req.CertificateType)
+
+	resp := v201.GetInstalledCertificateIdsResponse{
+		Status: "NotFound",
+	}
+	for _, ih := range c.installedCertificateHashes(req.CertificateType...) {
+		resp.Status = "Accepted"
+		resp.CertificateHashDataChain = append(resp.CertificateHashDataChain, v201.CertificateHashDataChain{
+			CertificateType:     ih.certificateType,
+			CertificateHashData: ih.hash.toV201(),
+		})
+	}
+
+	if err := c.sendCallResult(uniqueId, resp); err != nil {
+		c.log().charger.Error("Failed to send GetInstalledCertificateIds response", logging.Action("GetInstalledCertificateIds"), logging.UniqueID(uniqueId), logging.Err(err))
+	}
+}
+
+// handleDeleteCertificateV16 handles DeleteCertificate from server (OCPP 1.6 Security Whitepaper)
+func (c *Charger) handleDeleteCertificateV16(uniqueId string, payload json.RawMessage) {
+	var req v16.DeleteCertificateRequest
+	if err := json.Unmarshal(payload, &req); err != nil {
+		c.log().charger.Error("Failed to parse DeleteCertificate", logging.Action("DeleteCertificate"), logging.UniqueID(uniqueId), logging.Err(err))
+		return
+	}
+
+	c.log().charger.Info("Received DeleteCertificate", logging.Action("DeleteCertificate"), logging.UniqueID(uniqueId), "serial_number", req.CertificateHashData.SerialNumber)
+
+	h := req.CertificateHashData
+	resp := v16.DeleteCertificateResponse{
+		Status: c.deleteCertificate(certificateHash{
+			hashAlgorithm:  h.HashAlgorithm,
+			issuerNameHash: h.IssuerNameHash,
+			issuerKeyHash:  h.IssuerKeyHash,
+			serialNumber:   h.SerialNumber,
+		}),
+	}
+
+	if err := c.sendCallResult(uniqueId, resp); err != nil {
+		c.log().charger.Error("Failed to send DeleteCertificate response", logging.Action("DeleteCertificate"), logging.UniqueID(uniqueId), logging.Err(err))
+	}
+}
+
+// handleDeleteCertificateV201 handles DeleteCertificate from server (OCPP 2.0.1)
+func (c *Charger) handleDeleteCertificateV201(uniqueId string, payload json.RawMessage) {
+	var req v201.DeleteCertificateRequest
+	if err := json.Unmarshal(payload, &req); err != nil {
+		c.log().charger.Error("Failed to parse DeleteCertificate", logging.Action("DeleteCertificate"), logging.UniqueID(uniqueId), logging.Err(err))
+		return
+	}
+
+	c.log().charger.Info("Received DeleteCertificate", logging.Action("DeleteCertificate"), logging.UniqueID(uniqueId), "serial_number", req.CertificateHashData.SerialNumber)
+
+	h := req.CertificateHashData
+	resp := v201.DeleteCertificateResponse{
+		Status: c.deleteCertificate(certificateHash{
+			hashAlgorithm:  h.HashAlgorithm,
+			issuerNameHash: h.IssuerNameHash,
+			issuerKeyHash:  h.IssuerKeyHash,
+			serialNumber:   h.SerialNumber,
+		}),
+	}
+
+	if err := c.sendCallResult(uniqueId, resp); err != nil {
+		c.log().charger.Error("Failed to send DeleteCertificate response", logging.Action("DeleteCertificate"), logging.UniqueID(uniqueId), logging.Err(err))
+	}
+}
+
+// toV16 converts the hash to OCPP 1.6 CertificateHashData
+func (h certificateHash) toV16() v16.CertificateHashData {
+	return v16.CertificateHashData{
+		HashAlgorithm:  h.hashAlgorithm,
+		IssuerNameHash: h.issuerNameHash,
+		IssuerKeyHash:  h.issuerKeyHash,
+		SerialNumber:   h.serialNumber,
+	}
+}
+
+// toV201 converts the hash to OCPP 2.0.1 CertificateHashData
+func (h certificateHash) toV201() v201.CertificateHashData {
+	return v201.CertificateHashData{
+		HashAlgorithm:  h.hashAlgorithm,
+		IssuerNameHash: h.issuerNameHash,
+		IssuerKeyHash:  h.issuerKeyHash,
+		SerialNumber:   h.serialNumber,
+	}
+}
@@ -1,15 +1,19 @@
 package charger
 
 import (
+	"context"
+	"crypto/ecdsa"
 	"crypto/tls"
 	"fmt"
 	"io"
-	"log"
+	"log/slog"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/weilun-shrimp/wlgo_ocpp_charger_simulator/config"
+	"github.com/weilun-shrimp/wlgo_ocpp_charger_simulator/logging"
 	"github.com/weilun-shrimp/wlgows/client"
 	"github.com/weilun-shrimp/wlgows/connection"
 )
@@ -41,61 +45,182 @@ type Charger struct {
 	// Pending remote start authorization (for Remote Start Flow)
 	pendingRemoteStartIdTag string // idTag from RemoteStartTransaction, empty if none pending
 	pendingRemoteStartId    int    // remoteStartId from OCPP 2.0.1 RequestStartTransaction
+	// Deauthorization of the running transaction by the server
+	deauthorized      bool // idTag was rejected by the server after the transaction started
+	deauthorizedMeter int  // meter value (Wh) at the moment of deauthorization
+	deauthSuspended   bool // energy delivery stopped because of deauthorization
+	// Active ReserveNow reservation, nil if none
+	reservation *reservation
+	// Cable lock of the connector
+	cableLocked bool // cable is locked to the connector
+	lockJammed  bool // lock is jammed: unlock attempts fail
+	// Firmware
+	firmwareVersion string          // version reported in BootNotification
+	firmwareUpdate  *firmwareUpdate // update in progress, nil if none
+	// Diagnostics
+	diagnostics *diagnosticsLog // recent frames and state snapshots
+	logUpload   *logUpload      // diagnostics/log upload in progress, nil if none
+	// Security events not yet sent and the security log
+	securityEvents *securityEventLog
+	// Active faults raised with RaiseFault
+	faults faultState
+	// Network fault injection between the charger and the connection
+	netFaults *networkFaults
+	// Registration from the BootNotification response
+	registration   string      // Accepted, Pending, Rejected or "" if not registered
+	bootReason     string      // reason of the last BootNotification, reused by retries
+	bootRetry      *time.Timer // scheduled BootNotification retry, nil if none
+	triggeredCalls int         // TriggerMessage sends in progress, allowed while Pending
+	nextBootReason string      // reported on connect: cause of the last restart
+	// WebSocket subprotocol agreed with the server and ping keepalive
+	subprotocol string
+	keepalive   *keepalive
+	// Security profile and Basic auth password, changeable by the server
+	security securityState
+	// Handling of a deauthorized transaction, changeable by the server
+	invalidId invalidIdSettings
+	// Certificates
+	clientCert *tls.Certificate  // signed with CertificateSigned, nil: configured one
+	pendingKey *ecdsa.PrivateKey // key of the pending SignCertificate request
+	trustStore []installedCertificate
+	// State file kept across restarts, nil if not configured
+	persistence *statePersistence
+	powerLoss   *interruptedTransaction // stopped by a power loss, reported once accepted
+	// Library API: Call timeout and event subscriptions
+	callTimeout time.Duration
+	events      *eventHub
+	// Handlers of incoming DataTransfer requests by vendor and message
+	dataTransfer *dataTransferRegistry
+	// Charging profiles installed by the server and the last Heartbeat answered
+	chargingProfiles []chargingProfile
+	lastHeartbeat    time.Time
+	// Structured logging: the logger given to New and the subsystem loggers
+	// derived from it
+	logger  *slog.Logger
+	loggers atomic.Pointer[loggers]
 }
 
 // New creates a new Charger instance
-func New(cfg *config.Config) (*Charger, error) {
-	tlsConfig, err := cfg.GetTLSConfig()
-	if err != nil {
-		return nil, fmt.Errorf("failed to get TLS config: %w", err)
-	}
-
-	return &Charger{
-		config:       cfg,
-		tlsConfig:    tlsConfig,
-		status:       cfg.InitialStatus,
-		meterValue:   0,
-		soc:          cfg.InitialSOC,
-		current:      cfg.MaxCurrent, // Default to max current
-		power:        cfg.MaxPower,   // Default to max power
-		stopCh:       make(chan struct{}),
-		pendingCalls: make(map[string]chan []byte),
-	}, nil
+func New(cfg *config.Config, opts ...Option) (*Charger, error) {
+	diagnostics := &diagnosticsLog{}
+	diagnostics.addSecret(cfg.AuthorizationKey)
+
+	c := &Charger{
+		config:          cfg,
+		status:          cfg.InitialStatus,
+		meterValue:      0,
+		soc:             cfg.InitialSOC,
+		current:         cfg.MaxCurrent, // Default to max current
+		power:           cfg.MaxPower,   // Default to max power
+		stopCh:          make(chan struct{}),
+		pendingCalls:    make(map[string]chan []byte),
+		lockJammed:      cfg.CableLock != nil && cfg.CableLock.Jammed,
+		firmwareVersion: cfg.GetFirmwareVersion(),
+		nextBootReason:  bootReasonPowerUp,
+		diagnostics:     diagnostics,
+		securityEvents:  &securityEventLog{},
+		netFaults:       newNetworkFaults(cfg.GetNetworkFaults()),
+		keepalive:       newKeepalive(cfg.WebSocketPingInterval),
+		security: securityState{
+			profile:          cfg.SecurityProfile,
+			authorizationKey: cfg.AuthorizationKey,
+		},
+		invalidId: invalidIdSettings{
+			stopTransaction: cfg.StopTransactionOnInvalidId,
+			maxEnergy:       cfg.MaxEnergyOnInvalidId,
+		},
+		persistence: newStatePersistence(cfg),
+		callTimeout: defaultCallTimeout,
+		events:      &eventHub{},
+	}
+	c.dataTransfer = newDataTransferRegistry(c)
+	for _, opt := range opts {
+		opt(c)
+	}
+	if c.logger == nil {
+		c.logger = slog.Default()
+	}
+	c.updateLoggers(cfg.OCPPVersion)
+
+	if c.tlsConfig == nil {
+		tlsConfig, err := cfg.GetTLSConfig()
+		if err != nil {
+			return nil, fmt.Errorf("failed to get TLS config: %w", err)
+		}
+		c.tlsConfig = tlsConfig
+	}
+
+	if c.persistence != nil {
+		if err := c.restoreState(); err != nil {
+			return nil, err
+		}
+		go c.saveStateLoop()
+	}
+	return c, nil
 }
 
 // Connect establishes a WebSocket connection to the server
 func (c *Charger) Connect() error {
+	return c.ConnectContext(context.Background())
+}
+
+// ConnectContext establishes a WebSocket connection to the server. ctx bounds
+// the dial and the handshake.
+func (c *Charger) ConnectContext(ctx context.Context) error {
 	c.mu.Lock()
 	if c.isConnected {
 		c.mu.Unlock()
-		return fmt.Errorf("already connected")
+		return invalidState("already connected")
 	}
 	// Create new stop channel for this connection
 	c.stopCh = make(chan struct{})
+	security := c.security
 	c.mu.Unlock()
 
-	log.Printf("Connecting to %s...", c.config.ServerURL)
+	serverURL := security.serverURL(c.config.ServerURL)
+	c.log().wire.Info("Connecting", "url", serverURL, "security_profile", security.profile)
 
-	conn, err := client.Dial(c.config.ServerURL, c.tlsConfig)
+	conn, err := dialContext(ctx, serverURL, c.connectTLSConfig())
 	if err != nil {
+		if ctx.Err() == nil {
+			c.raiseConnectionSecurityEvent(err, 0)
+		}
 		return fmt.Errorf("failed to dial: %w", err)
 	}
 
-	if authHeader := c.config.GetAuthHeader(); authHeader != "" {
+	if authHeader := c.authHeader(security); authHeader != "" {
 		conn.ClientRequest.Header.Set("Authorization", authHeader)
 	}
+	offered := c.config.GetSubprotocols()
+	conn.ClientRequest.Header.Set("Sec-WebSocket-Protocol", strings.Join(offered, ", "))
 
-	if err := conn.HandShake(); err != nil {
+	// Interrupt the handshake when ctx is done
+	stopInterrupt := context.AfterFunc(ctx, func() { conn.SetDeadline(time.Now()) })
+	err = conn.HandShake()
+	if !stopInterrupt() && ctx.Err() != nil {
+		conn.Close()
+		return fmt.Errorf("handshake failed: %w", ctx.Err())
+	}
+	if err != nil {
+		httpStatus := 0
 		// Surface the server's response (status + body) for diagnostics, e.g. a 401
 		// Unauthorized with an explanation when auth credentials are wrong.
 		if resp := conn.ServerResponse; resp != nil {
 			body, _ := io.ReadAll(resp.Body)
 			resp.Body.Close()
-			log.Printf("Handshake failed: server responded %s", resp.Status)
+			httpStatus = resp.StatusCode
+			c.log().wire.Error("Handshake failed", "http_status", resp.Status)
 			if len(body) > 0 {
-				log.Printf("Server response body: %s", strings.TrimSpace(string(body)))
+				c.log().wire.Error("Server response body", "body", strings.TrimSpace(string(body)))
 			}
 		}
+		conn.Close()
+		err = fmt.Errorf("handshake failed: %w", err)
+		c.raiseConnectionSecurityEvent(err, httpStatus)
+		return err
+	}
+
+	if err := c.acceptSubprotocol(conn.ServerResponse.Header.Get("Sec-WebSocket-Protocol"), offered); err != nil {
 		conn.Close()
 		return fmt.Errorf("handshake failed: %w", err)
 	}
@@ -103,16 +228,46 @@ func (c *Charger) Connect() error {
 	c.mu.Lock()
 	c.conn = conn
 	c.isConnected = true
+	stopCh := c.stopCh
 	c.mu.Unlock()
 
-	log.Printf("Connected successfully")
+	c.log().wire.Info("Connected successfully")
+	c.events.publish(Event{Type: EventConnected})
 
 	go c.receiveMessages()
+	go c.pingLoop(conn, stopCh)
 
 	return nil
 }
 
-// Disconnect disconnects from the server
+// dialContext dials the server, giving up when ctx is done
+func dialContext(ctx context.Context, serverURL string, tlsConfig *tls.Config) (*connection.ClientConn, error) {
+	type dialResult struct {
+		conn *connection.ClientConn
+		err  error
+	}
+	result := make(chan dialResult, 1)
+	go func() {
+		conn, err := client.Dial(serverURL, tlsConfig)
+		result <- dialResult{conn, err}
+	}()
+
+	select {
+	case r := <-result:
+		return r.conn, r.err
+	case <-ctx.Done():
+		// Close the connection if the dial still succeeds
+		go func() {
+			if r := <-result; r.conn != nil {
+				r.conn.Close()
+			}
+		}()
+		return nil, ctx.Err()
+	}
+}
+
+// Disconnect disconnects from the server. It is not a restart: the next
+// BootNotification reports the reason of the last boot again.
 func (c *Charger) Disconnect() {
 	c.mu.Lock()
 	defer c.mu.Unlock()
@@ -121,26 +276,43 @@ func (c *Charger) Disconnect() {
 		return
 	}
 
+	c.closeConnectionLocked()
+	c.isCharging = false
+}
+
+// closeConnectionLocked closes the connection and stops the heartbeat loop,
+// keeping the charging state. c.mu must be held.
+func (c *Charger) closeConnectionLocked() {
 	// Stop heartbeat loop
 	if c.heartbeatStopCh != nil {
 		close(c.heartbeatStopCh)
 		c.heartbeatStopCh = nil
 	}
 
+	c.stopBootRetryLocked()
 	close(c.stopCh)
 	if c.conn != nil {
 		c.conn.Close()
 		c.conn = nil
 	}
 	c.isConnected = false
-	c.isCharging = false
+	c.subprotocol = ""
+	c.events.publish(Event{Type: EventDisconnected})
 }
 
-// Close closes the connection (for defer)
+// Close saves the state and closes the connection (for defer)
 func (c *Charger) Close() {
+	c.stopSavingState()
 	c.Disconnect()
 }
 
+// GetFirmwareVersion returns the installed firmware version
+func (c *Charger) GetFirmwareVersion() string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.firmwareVersion
+}
+
 // IsConnected returns whether the charger is connected to the server
 func (c *Charger) IsConnected() bool {
 	c.mu.RLock()
@@ -155,6 +327,40 @@ func (c *Charger) IsCharging() bool {
 	return c.isCharging
 }
 
+// GetTransactionId returns the id of the running transaction, "" if there is
+// none (or, in 1.6, the server has not assigned one yet)
+func (c *Charger) GetTransactionId() string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	if !c.isCharging {
+		return ""
+	}
+	return c.transactionIdLocked()
+}
+
+// GetIdTag returns the idTag of the running transaction, "" if there is none
+func (c *Charger) GetIdTag() string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	if !c.isCharging {
+		return ""
+	}
+	return c.idTag
+}
+
+// GetMeterValue returns the energy meter reading in Wh
+func (c *Charger) GetMeterValue() int {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.meterValue
+}
+
+// GetActualPower returns the power drawn now in W: 0 unless energy is being
+// delivered
+func (c *Charger) GetActualPower() float64 {
+	return c.currentSample().power
+}
+
 // GetStatus returns the current status
 func (c *Charger) GetStatus() string {
 	c.mu.RLock()
@@ -217,9 +423,10 @@ func (c *Charger) SetCurrent(current float64) error {
 	oldCurrent := c.current
 	c.current = current
 	status := c.status
+	energySuspended := c.energySuspendedLocked()
 	c.mu.Unlock()
 
-	log.Printf("Current set to %.1f A", current)
+	c.log().profile.Info("Current set", "current_a", current)
 
 	// Handle status transitions per OCPP 1.6 spec:
 	// - Charging -> SuspendedEVSE when EVSE sets current to 0
@@ -228,7 +435,7 @@ func (c *Charger) SetCurrent(current float64) error {
 	if c.config.IsOCPP16() {
 		if current == 0 && oldCurrent > 0 && status == "Charging" {
 			return c.SetStatus("SuspendedEVSE")
-		} else if current > 0 && oldCurrent == 0 && status == "SuspendedEVSE" {
+		} else if current > 0 && oldCurrent == 0 && status == "SuspendedEVSE" && !energySuspended {
 			return c.SetStatus("Charging")
 		}
 	}
@@ -265,9 +472,10 @@ func (c *Charger) SetPower(power float64) error {
 		c.current = 0
 	}
 	status := c.status
+	energySuspended := c.energySuspendedLocked()
 	c.mu.Unlock()
 
-	log.Printf("Power set to %.1f W (current: %.1f A)", power, power/c.config.Voltage)
+	c.log().profile.Info("Power set", "power_w", power, "current_a", power/c.config.Voltage)
 
 	// Handle status transitions per OCPP 1.6 spec:
 	// - Charging -> SuspendedEVSE when EVSE sets power to 0
@@ -276,7 +484,7 @@ func (c *Charger) SetPower(power float64) error {
 	if c.config.IsOCPP16() {
 		if power == 0 && oldPower > 0 && status == "Charging" {
 			return c.SetStatus("SuspendedEVSE")
-		} else if power > 0 && oldPower == 0 && status == "SuspendedEVSE" {
+		} else if power > 0 && oldPower == 0 && status == "SuspendedEVSE" && !energySuspended {
 			return c.SetStatus("Charging")
 		}
 	}
@@ -289,9 +497,10 @@ func (c *Charger) Plugin() error {
 	c.mu.Lock()
 	status := c.status
 	pendingIdTag := c.pendingRemoteStartIdTag
-	if status != "Available" {
+	// A Reserved connector accepts the cable; the reservation is enforced when the transaction starts
+	if status != "Available" && status != "Reserved" {
 		c.mu.Unlock()
-		return fmt.Errorf("cannot plug in: status must be Available (current: %s)", status)
+		return invalidState("cannot plug in: status must be Available or Reserved (current: %s)", status)
 	}
 	// Clear pending if we're going to use it
 	if pendingIdTag != "" {
@@ -313,12 +522,12 @@ func (c *Charger) Plugin() error {
 
 	// If there was a pending remote start, auto-start the transaction
 	if pendingIdTag != "" {
-		log.Printf("Auto-starting transaction for pending remote start: idTag=%s", pendingIdTag)
+		c.log().remote.Info("Auto-starting transaction for pending remote start", "id_tag", pendingIdTag)
 		go func() {
 			// Small delay to ensure status notification is sent first
 			time.Sleep(500 * time.Millisecond)
 			if err := c.StartTransaction(pendingIdTag); err != nil {
-				log.Printf("Failed to auto-start transaction: %v", err)
+				c.log().remote.Error("Failed to auto-start transaction", logging.Err(err))
 			}
 		}()
 	}
@@ -326,9 +535,17 @@ func (c *Charger) Plugin() error {
 	return nil
 }
 
-// Unplug simulates car unplugging - stops all background tasks and resets state
+// Unplug simulates car unplugging - stops all background tasks and resets state.
+// It fails while the cable is locked to the connector.
 func (c *Charger) Unplug() error {
 	c.mu.Lock()
+	if c.cableLocked {
+		c.mu.Unlock()
+		if c.lockJammed {
+			return invalidState("cannot unplug: cable lock is jammed")
+		}
+		return invalidState("cannot unplug: cable is locked (stop the transaction first)")
+	}
 	// Stop meter loop if running
 	if c.meterStopCh != nil {
 		close(c.meterStopCh)
@@ -340,10 +557,17 @@ func (c *Charger) Unplug() error {
 	c.idTag = ""
 	c.soc = c.config.InitialSOC
 	c.meterValue = 0
+	c.clearDeauthorization()
+	c.faults.suspended = false
 	// Clear any pending remote start
 	c.pendingRemoteStartIdTag = ""
 	c.pendingRemoteStartId = 0
+	reserved := c.reservation != nil
 	c.mu.Unlock()
 
+	// An unused reservation still holds the connector
+	if reserved {
+		return c.SetStatus("Reserved")
+	}
 	return c.SetStatus("Available")
 }
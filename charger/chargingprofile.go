@@ -0,0 +1,49 @@
+package charger
+
+import "fmt"
+
+// chargingProfile is a charging profile installed with SetChargingProfile.
+// Only the limit of its first schedule period is applied.
+type chargingProfile struct {
+	id         int
+	purpose    string // ChargePointMaxProfile/ChargingStationMaxProfile, TxDefaultProfile or TxProfile
+	stackLevel int
+	limit      float64
+	unit       string // A or W
+}
+
+// installChargingProfileLocked adds a profile, replacing the one with the same
+// id. c.mu must be held.
+func (c *Charger) installChargingProfileLocked(p chargingProfile) {
+	for i := range c.chargingProfiles {
+		if c.chargingProfiles[i].id == p.id {
+			c.chargingProfiles[i] = p
+			return
+		}
+	}
+	c.chargingProfiles = append(c.chargingProfiles, p)
+}
+
+// clearTxProfilesLocked removes the TxProfiles, which end with their
+// transaction. c.mu must be held.
+func (c *Charger) clearTxProfilesLocked() {
+	kept := c.chargingProfiles[:0]
+	for _, p := range c.chargingProfiles {
+		if p.purpose != "TxProfile" {
+			kept = append(kept, p)
+		}
+	}
+	c.chargingProfiles = kept
+}
+
+// ChargingProfiles describes the installed charging profiles, e.g. "#1
+// TxProfile stack 0: 16.0 A"
+func (c *Charger) ChargingProfiles() []string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	profiles := make([]string, 0, len(c.chargingProfiles))
+	for _, p := range c.chargingProfiles {
+		profiles = append(profiles, fmt.Sprintf("#%d %s stack %d: %.1f %s", p.id, p.purpose, p.stackLevel, p.limit, p.unit))
+	}
+	return profiles
+}
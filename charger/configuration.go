@@ -0,0 +1,349 @@
+package charger
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/weilun-shrimp/wlgo_ocpp_charger_simulator/logging"
+	"github.com/weilun-shrimp/wlgo_ocpp_charger_simulator/ocpp/v16"
+	"github.com/weilun-shrimp/wlgo_ocpp_charger_simulator/ocpp/v201"
+)
+
+// configVariable is a setting the server reads and changes with
+// GetConfiguration/ChangeConfiguration (OCPP 1.6) or GetVariables/SetVariables
+// (OCPP 2.0.1)
+type configVariable struct {
+	key       string // OCPP 1.6 configuration key
+	component string // OCPP 2.0.1 component name
+	variable  string // OCPP 2.0.1 variable name
+	security  bool   // changes raise ReconfigurationOfSecurityParameters
+	// get returns the value; nil for write-only variables such as passwords
+	get func(c *Charger) string
+	// set validates and applies the value. It returns true if the security
+	// settings changed and the charger must reconnect.
+	set func(c *Charger, value string) (bool, error)
+}
+
+// configVariables lists the settings exposed to the server
+var configVariables = []configVariable{
+	{
+		key:       "AuthorizationKey",
+		component: "SecurityCtrlr",
+		variable:  "BasicAuthPassword",
+		security:  true,
+		set:       (*Charger).setAuthorizationKey,
+	},
+	{
+		// OCPP 2.0.1 changes the profile with SetNetworkProfile, which is not
+		// simulated; SetVariables on SecurityCtrlr.SecurityProfile stands in for
+		// it and reconnects like ChangeConfiguration in 1.6
+		key:       "SecurityProfile",
+		component: "SecurityCtrlr",
+		variable:  "SecurityProfile",
+		security:  true,
+		get:       func(c *Charger) string { return strconv.Itoa(c.GetSecurityProfile()) },
+		set:       (*Charger).setSecurityProfile,
+	},
+	{
+		key:       "WebSocketPingInterval",
+		component: "OCPPCommCtrlr",
+		variable:  "WebSocketPingInterval",
+		get:       func(c *Charger) string { return strconv.Itoa(c.GetWebSocketPingInterval()) },
+		set:       (*Charger).setWebSocketPingInterval,
+	},
+	{
+		key:       "StopTransactionOnInvalidId",
+		component: "TxCtrlr",
+		variable:  "StopTxOnInvalidId",
+		get:       func(c *Charger) string { return strconv.FormatBool(c.GetStopTransactionOnInvalidId()) },
+		set:       (*Charger).setStopTransactionOnInvalidId,
+	},
+	{
+		key:       "MaxEnergyOnInvalidId",
+		component: "TxCtrlr",
+		variable:  "MaxEnergyOnInvalidId",
+		get:       func(c *Charger) string { return strconv.Itoa(c.GetMaxEnergyOnInvalidId()) },
+		set:       (*Charger).setMaxEnergyOnInvalidId,
+	},
+}
+
+// redactConfigValues replaces the values of write-only variables (see get) in
+// a ChangeConfiguration or SetVariables request frame with REDACTED. Other
+// frames are returned unchanged.
+func redactConfigValues(frame string) string {
+	if !strings.Contains(frame, v16.ActionChangeConfiguration) && !strings.Contains(frame, v201.ActionSetVariables) {
+		return frame
+	}
+	var msg []json.RawMessage
+	if err := json.Unmarshal([]byte(frame), &msg); err != nil || len(msg) != 4 {
+		return frame
+	}
+	var action string
+	json.Unmarshal(msg[2], &action)
+
+	var values []json.RawMessage
+	switch action {
+	case v16.ActionChangeConfiguration:
+		var req struct {
+			Key   string          `json:"key"`
+			Value json.RawMessage `json:"value"`
+		}
+		if json.Unmarshal(msg[3], &req) == nil {
+			if v := findConfigKey(req.Key); v != nil && v.get == nil {
+				values = append(values, req.Value)
+			}
+		}
+	case v201.ActionSetVariables:
+		var req struct {
+			SetVariableData []struct {
+				AttributeValue json.RawMessage `json:"attributeValue"`
+				Component      v201.Component  `json:"component"`
+				Variable       v201.Variable   `json:"variable"`
+			} `json:"setVariableData"`
+		}
+		if json.Unmarshal(msg[3], &req) == nil {
+			for _, data := range req.SetVariableData {
+				if v, _ := findConfigVariable(data.Component.Name, data.Variable.Name); v != nil && v.get == nil {
+					values = append(values, data.AttributeValue)
+				}
+			}
+		}
+	}
+	for _, value := range values {
+		if len(value) > 0 {
+			frame = strings.ReplaceAll(frame, string(value), `"REDACTED"`)
+		}
+	}
+	return frame
+}
+
+// parseConfigBool parses an OCPP boolean configuration value, "true" or "false"
+func parseConfigBool(value string) (bool, error) {
+	switch strings.ToLower(value) {
+	case "true":
+		return true, nil
+	case "false":
+		return false, nil
+	}
+	return false, fmt.Errorf("invalid boolean %q", value)
+}
+
+// findConfigKey returns the variable with the given OCPP 1.6 key
+func findConfigKey(key string) *configVariable {
+	for i := range configVariables {
+		if configVariables[i].key == key {
+			return &configVariables[i]
+		}
+	}
+	return nil
+}
+
+// findConfigVariable returns the variable of the given OCPP 2.0.1 component, and
+// the attribute status to report if there is none (UnknownComponent or UnknownVariable)
+func findConfigVariable(component, variable string) (*configVariable, string) {
+	status := "UnknownComponent"
+	for i := range configVariables {
+		if configVariables[i].component != component {
+			continue
+		}
+		if configVariables[i].variable == variable {
+			return &configVariables[i], ""
+		}
+		status = "UnknownVariable"
+	}
+	return nil, status
+}
+
+// handleChangeConfigurationV16 handles ChangeConfiguration from server (OCPP 1.6)
+func (c *Charger) handleChangeConfigurationV16(uniqueId string, payload json.RawMessage) {
+	var req v16.ChangeConfigurationRequest
+	if err := json.Unmarshal(payload, &req); err != nil {
+		c.log().charger.Error("Failed to parse ChangeConfiguration", logging.Action("ChangeConfiguration"), logging.UniqueID(uniqueId), logging.Err(err))
+		return
+	}
+
+	c.log().charger.Info("Received ChangeConfiguration", logging.Action("ChangeConfiguration"), logging.UniqueID(uniqueId), "key", req.Key)
+
+	previous := c.securityState()
+	status := "Accepted"
+	reconnect := false
+
+	v := findConfigKey(req.Key)
+	if v == nil {
+		status = "NotSupported"
+	} else {
+		var err error
+		if reconnect, err = v.set(c, req.Value); err != nil {
+			c.log().charger.Warn("ChangeConfiguration rejected", logging.Err(err))
+			status = "Rejected"
+		}
+	}
+
+	var changed []string
+	if status == "Accepted" && v.security {
+		changed = append(changed, req.Key)
+	}
+
+	resp := v16.ChangeConfigurationResponse{
+		Status: status,
+	}
+
+	if err := c.sendCallResult(uniqueId, resp); err != nil {
+		c.log().charger.Error("Failed to send ChangeConfiguration response", logging.Action("ChangeConfiguration"), logging.UniqueID(uniqueId), logging.Err(err))
+	}
+
+	c.applyConfigurationChanges(changed, reconnect, previous)
+}
+
+// handleGetConfigurationV16 handles GetConfiguration from server (OCPP 1.6).
+// Write-only keys are reported without a value.
+func (c *Charger) handleGetConfigurationV16(uniqueId string, payload json.RawMessage) {
+	var req v16.GetConfigurationRequest
+	if err := json.Unmarshal(payload, &req); err != nil {
+		c.log().charger.Error("Failed to parse GetConfiguration", logging.Action("GetConfiguration"), logging.UniqueID(uniqueId), logging.Err(err))
+		return
+	}
+
+	c.log().charger.Info("Received GetConfiguration", logging.Action("GetConfiguration"), logging.UniqueID(uniqueId), "keys", req.Key)
+
+	var resp v16.GetConfigurationResponse
+	keyValue := func(v *configVariable) v16.KeyValue {
+		kv := v16.KeyValue{Key: v.key}
+		if v.get != nil {
+			kv.Value = v.get(c)
+		}
+		return kv
+	}
+
+	if len(req.Key) == 0 {
+		for i := range configVariables {
+			resp.ConfigurationKey = append(resp.ConfigurationKey, keyValue(&configVariables[i]))
+		}
+	}
+	for _, key := range req.Key {
+		if v := findConfigKey(key); v != nil {
+			resp.ConfigurationKey = append(resp.ConfigurationKey, keyValue(v))
+		} else {
+			resp.UnknownKey = append(resp.UnknownKey, key)
+		}
+	}
+
+	if err := c.sendCallResult(uniqueId, resp); err != nil {
+		c.log().charger.Error("Failed to send GetConfiguration response", logging.Action("GetConfiguration"), logging.UniqueID(uniqueId), logging.Err(err))
+	}
+}
+
+// handleSetVariablesV201 handles SetVariables from server (OCPP 2.0.1)
+func (c *Charger) handleSetVariablesV201(uniqueId string, payload json.RawMessage) {
+	var req v201.SetVariablesRequest
+	if err := json.Unmarshal(payload, &req); err != nil {
+		c.log().charger.Error("Failed to parse SetVariables", logging.Action("SetVariables"), logging.UniqueID(uniqueId), logging.Err(err))
+		return
+	}
+
+	c.log().charger.Info("Received SetVariables", logging.Action("SetVariables"), logging.UniqueID(uniqueId), "variables", len(req.SetVariableData))
+
+	previous := c.securityState()
+	reconnect := false
+	var changedNames []string
+	resp := v201.SetVariablesResponse{
+		SetVariableResult: make([]v201.SetVariableResult, 0, len(req.SetVariableData)),
+	}
+
+	for _, data := range req.SetVariableData {
+		result := v201.SetVariableResult{
+			AttributeType:   data.AttributeType,
+			AttributeStatus: "Accepted",
+			Component:       data.Component,
+			Variable:        data.Variable,
+		}
+
+		v, status := findConfigVariable(data.Component.Name, data.Variable.Name)
+		switch {
+		case v == nil:
+			result.AttributeStatus = status
+		case data.AttributeType != "" && data.AttributeType != "Actual":
+			result.AttributeStatus = "NotSupportedAttributeType"
+		default:
+			changed, err := v.set(c, data.AttributeValue)
+			if err != nil {
+				c.log().charger.Warn("SetVariables rejected", logging.Action("SetVariables"), logging.UniqueID(uniqueId), "component", data.Component.Name, "variable", data.Variable.Name, logging.Err(err))
+				result.AttributeStatus = "Rejected"
+				result.AttributeStatusInfo = &v201.StatusInfo{ReasonCode: "InvalidValue", AdditionalInfo: err.Error()}
+			} else if v.security {
+				changedNames = append(changedNames, data.Component.Name+"."+data.Variable.Name)
+			}
+			reconnect = reconnect || changed
+		}
+
+		resp.SetVariableResult = append(resp.SetVariableResult, result)
+	}
+
+	if err := c.sendCallResult(uniqueId, resp); err != nil {
+		c.log().charger.Error("Failed to send SetVariables response", logging.Action("SetVariables"), logging.UniqueID(uniqueId), logging.Err(err))
+	}
+
+	c.applyConfigurationChanges(changedNames, reconnect, previous)
+}
+
+// applyConfigurationChanges raises a ReconfigurationOfSecurityParameters
+// security event for the changed settings and reconnects if required. When
+// reconnecting, the event is raised once the old connection is closed, so it
+// is delivered on the new one.
+func (c *Charger) applyConfigurationChanges(changed []string, reconnect bool, previous securityState) {
+	if len(changed) == 0 {
+		return
+	}
+	techInfo := "Changed: " + strings.Join(changed, ", ")
+	if reconnect {
+		go c.reconnectWithSecurity(previous, techInfo)
+		return
+	}
+	c.RaiseSecurityEvent(securityEventReconfigurationOfSecurityParameters, techInfo)
+}
+
+// handleGetVariablesV201 handles GetVariables from server (OCPP 2.0.1).
+// Write-only variables are rejected.
+func (c *Charger) handleGetVariablesV201(uniqueId string, payload json.RawMessage) {
+	var req v201.GetVariablesRequest
+	if err := json.Unmarshal(payload, &req); err != nil {
+		c.log().charger.Error("Failed to parse GetVariables", logging.Action("GetVariables"), logging.UniqueID(uniqueId), logging.Err(err))
+		return
+	}
+
+	c.log().charger.Info("Received GetVariables", logging.Action("GetVariables"), logging.UniqueID(uniqueId), "variables", len(req.GetVariableData))
+
+	resp := v201.GetVariablesResponse{
+		GetVariableResult: make([]v201.GetVariableResult, 0, len(req.GetVariableData)),
+	}
+
+	for _, data := range req.GetVariableData {
+		result := v201.GetVariableResult{
+			AttributeStatus: "Accepted",
+			AttributeType:   data.AttributeType,
+			Component:       data.Component,
+			Variable:        data.Variable,
+		}
+
+		v, status := findConfigVariable(data.Component.Name, data.Variable.Name)
+		switch {
+		case v == nil:
+			result.AttributeStatus = status
+		case data.AttributeType != "" && data.AttributeType != "Actual":
+			result.AttributeStatus = "NotSupportedAttributeType"
+		case v.get == nil:
+			result.AttributeStatus = "Rejected"
+			result.AttributeStatusInfo = &v201.StatusInfo{ReasonCode: "WriteOnly"}
+		default:
+			result.AttributeValue = v.get(c)
+		}
+
+		resp.GetVariableResult = append(resp.GetVariableResult, result)
+	}
+
+	if err := c.sendCallResult(uniqueId, resp); err != nil {
+		c.log().charger.Error("Failed to send GetVariables response", logging.Action("GetVariables"), logging.UniqueID(uniqueId), logging.Err(err))
+	}
+}
@@ -0,0 +1,252 @@
+package charger
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/weilun-shrimp/wlgo_ocpp_charger_simulator/config"
+	"github.com/weilun-shrimp/wlgo_ocpp_charger_simulator/logging"
+	"github.com/weilun-shrimp/wlgo_ocpp_charger_simulator/ocpp/v16"
+	"github.com/weilun-shrimp/wlgo_ocpp_charger_simulator/ocpp/v201"
+)
+
+// DataTransfer statuses
+const (
+	DataTransferAccepted         = "Accepted"
+	DataTransferRejected         = "Rejected"
+	DataTransferUnknownMessageId = "UnknownMessageId"
+	DataTransferUnknownVendorId  = "UnknownVendorId"
+)
+
+// DataTransferRequest is a DataTransfer request received from the server
+type DataTransferRequest struct {
+	VendorId  string
+	MessageId string
+	Data      string // a 2.0.1 value other than a string is passed as its JSON text
+}
+
+// DataTransferResponse answers a DataTransfer request
+type DataTransferResponse struct {
+	Status string // Accepted, Rejected, UnknownMessageId or UnknownVendorId
+	Data   string
+}
+
+// DataTransferHandler answers the DataTransfer requests of a vendor. It runs
+// on the receive loop, so it must not block.
+type DataTransferHandler func(req DataTransferRequest) DataTransferResponse
+
+// EchoDataTransfer accepts the request and returns its data
+func EchoDataTransfer(req DataTransferRequest) DataTransferResponse {
+	return DataTransferResponse{Status: DataTransferAccepted, Data: req.Data}
+}
+
+// RejectDataTransfer rejects the request
+func RejectDataTransfer(req DataTransferRequest) DataTransferResponse {
+	return DataTransferResponse{Status: DataTransferRejected}
+}
+
+// UnknownVendorDataTransfer answers as if the vendor were not supported
+func UnknownVendorDataTransfer(req DataTransferRequest) DataTransferResponse {
+	return DataTransferResponse{Status: DataTransferUnknownVendorId}
+}
+
+// dataTransferKey identifies the handler of a vendor message; an empty
+// messageId handles every message of the vendor
+type dataTransferKey struct {
+	vendorId  string
+	messageId string
+}
+
+// dataTransferRegistry holds the handlers of incoming DataTransfer requests
+type dataTransferRegistry struct {
+	mu       sync.RWMutex
+	handlers map[dataTransferKey]DataTransferHandler
+}
+
+// newDataTransferRegistry returns a registry with the scripted handlers of
+// the charger's configuration
+func newDataTransferRegistry(c *Charger) *dataTransferRegistry {
+	r := &dataTransferRegistry{handlers: make(map[dataTransferKey]DataTransferHandler)}
+	for i := range c.config.DataTransfer {
+		d := &c.config.DataTransfer[i]
+		r.register(d.VendorId, d.MessageId, c.scriptedDataTransfer(d))
+	}
+	return r
+}
+
+// register sets the handler of a vendor message, replacing any previous one
+func (r *dataTransferRegistry) register(vendorId, messageId string, handler DataTransferHandler) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.handlers[dataTransferKey{vendorId, messageId}] = handler
+}
+
+// unregister removes the handler of a vendor message
+func (r *dataTransferRegistry) unregister(vendorId, messageId string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.handlers, dataTransferKey{vendorId, messageId})
+}
+
+// lookup returns the handler of a vendor message, falling back to the one for
+// every message of the vendor. vendorKnown reports whether the vendor has any
+// handler.
+func (r *dataTransferRegistry) lookup(vendorId, messageId string) (handler DataTransferHandler, vendorKnown bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	if h, ok := r.handlers[dataTransferKey{vendorId, messageId}]; ok {
+		return h, true
+	}
+	if h, ok := r.handlers[dataTransferKey{vendorId, ""}]; ok {
+		return h, true
+	}
+	for key := range r.handlers {
+		if key.vendorId == vendorId {
+			return nil, true
+		}
+	}
+	return nil, false
+}
+
+// scriptedDataTransfer returns the handler of a data_transfer entry
+func (c *Charger) scriptedDataTransfer(d *config.DataTransferConfig) DataTransferHandler {
+	switch d.Handler {
+	case config.DataTransferEcho:
+		return EchoDataTransfer
+	case config.DataTransferReject:
+		return RejectDataTransfer
+	case config.DataTransferUnknownVendor:
+		return UnknownVendorDataTransfer
+	}
+
+	// The template was checked when the configuration was loaded
+	tmpl, _ := d.DataTemplate()
+	return func(req DataTransferRequest) DataTransferResponse {
+		var out bytes.Buffer
+		err := tmpl.Execute(&out, struct {
+			VendorId  string
+			MessageId string
+			Data      string
+			ChargerID string
+			Now       string
+		}{req.VendorId, req.MessageId, req.Data, c.config.ChargerID, time.Now().UTC().Format(time.RFC3339)})
+		if err != nil {
+			c.log().charger.Error("DataTransfer data template failed", "vendor_id", d.VendorId, logging.Err(err))
+			return DataTransferResponse{Status: DataTransferRejected}
+		}
+		return DataTransferResponse{Status: d.Status, Data: out.String()}
+	}
+}
+
+// RegisterDataTransferHandler sets the handler of incoming DataTransfer
+// requests with vendorId and messageId ("" for every message of the vendor),
+// replacing the configured or previously registered one
+func (c *Charger) RegisterDataTransferHandler(vendorId, messageId string, handler DataTransferHandler) {
+	c.dataTransfer.register(vendorId, messageId, handler)
+}
+
+// UnregisterDataTransferHandler removes the handler of vendorId and messageId
+func (c *Charger) UnregisterDataTransferHandler(vendorId, messageId string) {
+	c.dataTransfer.unregister(vendorId, messageId)
+}
+
+// DataTransfer sends a DataTransfer request with a vendor message and returns
+// the server's answer
+func (c *Charger) DataTransfer(vendorId, messageId, data string) (DataTransferResponse, error) {
+	return c.DataTransferContext(context.Background(), vendorId, messageId, data)
+}
+
+// DataTransferContext is DataTransfer, waiting for the response until ctx is
+// done
+func (c *Charger) DataTransferContext(ctx context.Context, vendorId, messageId, data string) (DataTransferResponse, error) {
+	var req interface{}
+	if c.config.IsOCPP16() {
+		req = v16.DataTransferRequest{VendorId: vendorId, MessageId: messageId, Data: data}
+	} else {
+		req = v201.DataTransferRequest{VendorId: vendorId, MessageId: messageId, Data: data}
+	}
+
+	resp, err := c.sendCallContext(ctx, v16.ActionDataTransfer, req)
+	if err != nil {
+		return DataTransferResponse{}, fmt.Errorf("DataTransfer failed: %w", err)
+	}
+
+	var raw []json.RawMessage
+	if err := json.Unmarshal(resp, &raw); err != nil || len(raw) < 3 {
+		return DataTransferResponse{}, fmt.Errorf("failed to parse DataTransfer response: %s", resp)
+	}
+
+	var dtResp struct {
+		Status string          `json:"status"`
+		Data   json.RawMessage `json:"data"`
+	}
+	if err := json.Unmarshal(raw[2], &dtResp); err != nil {
+		return DataTransferResponse{}, fmt.Errorf("failed to parse DataTransfer response: %w", err)
+	}
+	c.log().charger.Info("DataTransfer response", logging.Action(v16.ActionDataTransfer), "vendor_id", vendorId, "status", dtResp.Status)
+	return DataTransferResponse{Status: dtResp.Status, Data: dataTransferText(dtResp.Data)}, nil
+}
+
+// handleDataTransfer answers a DataTransfer request from the server with the
+// handler registered for its vendor and message. Without one, a call_responses
+// entry for DataTransfer answers it, otherwise the status is UnknownVendorId
+// or UnknownMessageId.
+func (c *Charger) handleDataTransfer(uniqueId string, payload json.RawMessage) {
+	var msg struct {
+		VendorId  string          `json:"vendorId"`
+		MessageId string          `json:"messageId"`
+		Data      json.RawMessage `json:"data"`
+	}
+	if err := json.Unmarshal(payload, &msg); err != nil {
+		c.log().charger.Error("Failed to parse DataTransfer", logging.Action("DataTransfer"), logging.UniqueID(uniqueId), logging.Err(err))
+		return
+	}
+	req := DataTransferRequest{
+		VendorId:  msg.VendorId,
+		MessageId: msg.MessageId,
+		Data:      dataTransferText(msg.Data),
+	}
+
+	c.log().charger.Info("Received DataTransfer", logging.Action("DataTransfer"), logging.UniqueID(uniqueId), "vendor_id", req.VendorId, "message_id", req.MessageId)
+
+	handler, vendorKnown := c.dataTransfer.lookup(req.VendorId, req.MessageId)
+	var resp DataTransferResponse
+	switch {
+	case handler != nil:
+		resp = handler(req)
+	case c.hasCallResponse(v16.ActionDataTransfer, payload):
+		c.handleUnknownCall(uniqueId, v16.ActionDataTransfer, payload)
+		return
+	case vendorKnown:
+		resp = DataTransferResponse{Status: DataTransferUnknownMessageId}
+	default:
+		resp = DataTransferResponse{Status: DataTransferUnknownVendorId}
+	}
+
+	var result interface{}
+	if c.config.IsOCPP16() {
+		result = v16.DataTransferResponse{Status: resp.Status, Data: resp.Data}
+	} else {
+		result = v201.DataTransferResponse{Status: resp.Status, Data: resp.Data}
+	}
+	if err := c.sendCallResult(uniqueId, result); err != nil {
+		c.log().charger.Error("Failed to send DataTransfer response", logging.Action("DataTransfer"), logging.UniqueID(uniqueId), logging.Err(err))
+	}
+}
+
+// dataTransferText returns DataTransfer data as text: a JSON string unquoted,
+// any other value as its JSON text, and "" if there is none
+func dataTransferText(data json.RawMessage) string {
+	if len(data) == 0 || string(data) == "null" {
+		return ""
+	}
+	var s string
+	if err := json.Unmarshal(data, &s); err == nil {
+		return s
+	}
+	return string(data)
+}
@@ -0,0 +1,629 @@
+package charger
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/weilun-shrimp/wlgo_ocpp_charger_simulator/logging"
+	"github.com/weilun-shrimp/wlgo_ocpp_charger_simulator/ocpp/v16"
+	"github.com/weilun-shrimp/wlgo_ocpp_charger_simulator/ocpp/v201"
+	"gopkg.in/yaml.v3"
+)
+
+// Sizes of the diagnostics ring buffers
+const (
+	maxFrameRecords   = 1000
+	maxStateSnapshots = 200
+)
+
+// Log types of GetLog
+const (
+	logTypeDiagnostics = "DiagnosticsLog"
+	logTypeSecurity    = "SecurityLog"
+)
+
+// Upload statuses reported in LogStatusNotification. GetDiagnostics (1.6)
+// reports every failure as UploadFailed.
+const (
+	uploadIdle             = "Idle"
+	uploadUploading        = "Uploading"
+	uploadUploaded         = "Uploaded"
+	uploadFailure          = "UploadFailure"
+	uploadPermissionDenied = "PermissionDenied"
+	uploadBadMessage       = "BadMessage"
+	uploadAcceptedCanceled = "AcceptedCanceled" // OCPP 2.0.1 only
+	diagnosticsFailed      = "UploadFailed"
+)
+
+// defaultUploadRetryInterval is used when the server gives no retryInterval
+const defaultUploadRetryInterval = 30 * time.Second
+
+// frameRecord is one OCPP frame sent or received
+type frameRecord struct {
+	Time      time.Time
+	Direction string // "Sent" or "Received"
+	Frame     string
+}
+
+// stateSnapshot is the charger state at a point in time
+type stateSnapshot struct {
+	Time            time.Time `json:"time"`
+	Event           string    `json:"event"`
+	Status          string    `json:"status"`
+	Connected       bool      `json:"connected"`
+	Charging        bool      `json:"charging"`
+	IdTag           string    `json:"idTag,omitempty"`
+	TransactionId   string    `json:"transactionId,omitempty"`
+	MeterWh         int       `json:"meterWh"`
+	SoC             float64   `json:"soc"`
+	CurrentA        float64   `json:"currentA"`
+	PowerW          float64   `json:"powerW"`
+	CableLocked     bool      `json:"cableLocked"`
+	FirmwareVersion string    `json:"firmwareVersion"`
+	Faults          []string  `json:"faults,omitempty"` // error codes of the active faults
+}
+
+// diagnosticsLog keeps the recent frames and state snapshots included in
+// diagnostics archives. It has its own lock so frames can be recorded while
+// c.mu is held.
+type diagnosticsLog struct {
+	mu        sync.Mutex
+	frames    []frameRecord
+	snapshots []stateSnapshot
+	secrets   []string // credentials removed from frames by redact
+}
+
+// addSecret registers a credential, e.g. an AuthorizationKey, to redact from frames
+func (d *diagnosticsLog) addSecret(secret string) {
+	if secret == "" {
+		return
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.secrets = append(d.secrets, secret)
+}
+
+// recordFrame adds an OCPP frame, dropping the oldest when full
+func (d *diagnosticsLog) recordFrame(direction string, frame []byte) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if len(d.frames) >= maxFrameRecords {
+		d.frames = d.frames[1:]
+	}
+	d.frames = append(d.frames, frameRecord{Time: time.Now().UTC(), Direction: direction, Frame: string(frame)})
+}
+
+// recordSnapshot adds a state snapshot, dropping the oldest when full
+func (d *diagnosticsLog) recordSnapshot(s stateSnapshot) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if len(d.snapshots) >= maxStateSnapshots {
+		d.snapshots = d.snapshots[1:]
+	}
+	d.snapshots = append(d.snapshots, s)
+}
+
+// redact removes credentials from a frame: the registered secrets and the
+// values of write-only configuration variables in ChangeConfiguration and
+// SetVariables requests, e.g. a new AuthorizationKey before it is registered
+func (d *diagnosticsLog) redact(frame string) string {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.redactLocked(frame)
+}
+
+// redactLocked is redact with d.mu held
+func (d *diagnosticsLog) redactLocked(frame string) string {
+	for _, secret := range d.secrets {
+		frame = strings.ReplaceAll(frame, secret, "REDACTED")
+	}
+	return redactConfigValues(frame)
+}
+
+// between returns the frames (with secrets redacted) and snapshots within
+// [from, to]; a zero bound is open
+func (d *diagnosticsLog) between(from, to time.Time) ([]frameRecord, []stateSnapshot) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	inRange := func(t time.Time) bool {
+		return (from.IsZero() || !t.Before(from)) && (to.IsZero() || !t.After(to))
+	}
+
+	var frames []frameRecord
+	for _, f := range d.frames {
+		if inRange(f.Time) {
+			f.Frame = d.redactLocked(f.Frame)
+			frames = append(frames, f)
+		}
+	}
+	var snapshots []stateSnapshot
+	for _, s := range d.snapshots {
+		if inRange(s.Time) {
+			snapshots = append(snapshots, s)
+		}
+	}
+	return frames, snapshots
+}
+
+// snapshot captures the current charger state. Must not be called with c.mu held.
+func (c *Charger) snapshot(event string) stateSnapshot {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	s := stateSnapshot{
+		Time:            time.Now().UTC(),
+		Event:           event,
+		Status:          c.status,
+		Connected:       c.isConnected,
+		Charging:        c.isCharging,
+		MeterWh:         c.meterValue,
+		SoC:             c.soc,
+		CurrentA:        c.current,
+		PowerW:          c.power,
+		CableLocked:     c.cableLocked,
+		FirmwareVersion: c.firmwareVersion,
+	}
+	if c.isCharging {
+		s.IdTag = c.idTag
+		s.TransactionId = c.transactionIdStr
+		if c.config.IsOCPP16() {
+			s.TransactionId = fmt.Sprintf("%d", c.transactionId)
+		}
+	}
+	for _, f := range c.faults.active {
+		s.Faults = append(s.Faults, f.ErrorCode)
+	}
+	return s
+}
+
+// recordSnapshot records the current state for diagnostics. Must not be
+// called with c.mu held.
+func (c *Charger) recordSnapshot(event string) {
+	c.diagnostics.recordSnapshot(c.snapshot(event))
+}
+
+// recordFrame records an OCPP frame for diagnostics and reports it to
+// subscribers with credentials redacted
+func (c *Charger) recordFrame(direction string, frame []byte) {
+	c.diagnostics.recordFrame(direction, frame)
+	eventType := EventFrameSent
+	if direction == "Received" {
+		eventType = EventFrameReceived
+	}
+	c.events.publish(Event{Type: eventType, Frame: c.diagnostics.redact(string(frame))})
+}
+
+// RecentFrames returns the recorded OCPP frames, oldest first, with
+// credentials redacted, as "15:04:05 -> frame" for sent and "15:04:05 <- frame"
+// for received frames
+func (c *Charger) RecentFrames() []string {
+	frames, _ := c.diagnostics.between(time.Time{}, time.Time{})
+	lines := make([]string, 0, len(frames))
+	for _, f := range frames {
+		arrow := "->"
+		if f.Direction == "Received" {
+			arrow = "<-"
+		}
+		lines = append(lines, f.Time.Local().Format("15:04:05")+" "+arrow+" "+f.Frame)
+	}
+	return lines
+}
+
+// buildDiagnosticsArchive zips the frames and state snapshots within
+// [from, to] together with the current state and the (redacted) configuration
+func (c *Charger) buildDiagnosticsArchive(from, to time.Time) ([]byte, error) {
+	frames, snapshots := c.diagnostics.between(from, to)
+	snapshots = append(snapshots, c.snapshot("DiagnosticsRequested"))
+
+	var framesLog bytes.Buffer
+	for _, f := range frames {
+		fmt.Fprintf(&framesLog, "%s %-8s %s\n", f.Time.Format(time.RFC3339Nano), f.Direction, f.Frame)
+	}
+
+	state, err := json.MarshalIndent(snapshots, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode state snapshots: %w", err)
+	}
+
+	// Credentials are not included in the archive
+	cfg, err := yaml.Marshal(c.config.Redacted())
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode config: %w", err)
+	}
+
+	return zipFiles([]archiveFile{
+		{"ocpp-frames.log", framesLog.Bytes()},
+		{"state-snapshots.json", state},
+		{"config.yaml", cfg},
+	})
+}
+
+// buildSecurityLogArchive zips the security events raised within [from, to]
+func (c *Charger) buildSecurityLogArchive(from, to time.Time) ([]byte, error) {
+	var events bytes.Buffer
+	for _, e := range c.securityEvents.between(from, to) {
+		fmt.Fprintf(&events, "%s %s %s\n", e.Time.Format(time.RFC3339Nano), e.Type, e.TechInfo)
+	}
+	return zipFiles([]archiveFile{
+		{"security-events.log", events.Bytes()},
+	})
+}
+
+// archiveFile is a file of a diagnostics or log archive
+type archiveFile struct {
+	name string
+	data []byte
+}
+
+// zipFiles builds a zip archive of files
+func zipFiles(files []archiveFile) ([]byte, error) {
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for _, file := range files {
+		w, err := zw.Create(file.name)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create %s: %w", file.name, err)
+		}
+		if _, err := w.Write(file.data); err != nil {
+			return nil, fmt.Errorf("failed to write %s: %w", file.name, err)
+		}
+	}
+	if err := zw.Close(); err != nil {
+		return nil, fmt.Errorf("failed to finish archive: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// logUpload is a diagnostics or log upload in progress
+type logUpload struct {
+	requestId     int    // GetLog requestId
+	logType       string // GetLog logType, "" for GetDiagnostics (1.6)
+	location      string
+	fileName      string
+	from, to      time.Time // zero: unbounded
+	retries       int
+	retryInterval time.Duration
+	status        string        // last reported status
+	cancelCh      chan struct{} // closed when a newer request replaces this one
+}
+
+// newLogUpload creates an upload to location. retryInterval is in seconds; 0
+// uses the default.
+func (c *Charger) newLogUpload(logType, location string, from, to time.Time, retries, retryInterval int) *logUpload {
+	interval := defaultUploadRetryInterval
+	if retryInterval > 0 {
+		interval = time.Duration(retryInterval) * time.Second
+	}
+	if retries < 0 {
+		retries = 0
+	}
+	prefix := "diagnostics"
+	if logType == logTypeSecurity {
+		prefix = "securitylog"
+	}
+	return &logUpload{
+		logType:       logType,
+		location:      location,
+		fileName:      fmt.Sprintf("%s-%s-%s.zip", prefix, c.config.ChargerID, time.Now().UTC().Format("20060102T150405Z")),
+		from:          from,
+		to:            to,
+		retries:       retries,
+		retryInterval: interval,
+		cancelCh:      make(chan struct{}),
+	}
+}
+
+// sleep waits for d, returning false if the upload was cancelled meanwhile
+func (u *logUpload) sleep(d time.Duration) bool {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-u.cancelCh:
+		return false
+	case <-timer.C:
+		return true
+	}
+}
+
+// cancelled reports whether a newer request replaced the upload
+func (u *logUpload) cancelled() bool {
+	select {
+	case <-u.cancelCh:
+		return true
+	default:
+		return false
+	}
+}
+
+// acceptLogUpload registers u as the upload in progress, cancelling a
+// previous one. It returns "AcceptedCanceled" when an upload was cancelled.
+func (c *Charger) acceptLogUpload(u *logUpload) string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	status := "Accepted"
+	if current := c.logUpload; current != nil {
+		close(current.cancelCh)
+		c.log().charger.Info("Previous upload cancelled")
+		status = "AcceptedCanceled"
+	}
+	c.logUpload = u
+	return status
+}
+
+// finishLogUpload clears u once it has ended (unless already replaced)
+func (c *Charger) finishLogUpload(u *logUpload) {
+	c.mu.Lock()
+	if c.logUpload == u {
+		c.logUpload = nil
+	}
+	c.mu.Unlock()
+}
+
+// runLogUpload builds the archive and uploads it, retrying as requested by the
+// server and reporting each step
+func (c *Charger) runLogUpload(u *logUpload) {
+	defer c.finishLogUpload(u)
+
+	build := c.buildDiagnosticsArchive
+	if u.logType == logTypeSecurity {
+		build = c.buildSecurityLogArchive
+	}
+	data, err := build(u.from, u.to)
+	if err != nil {
+		c.log().charger.Error("Failed to build archive", logging.Err(err))
+		c.sendUploadStatus(u, uploadFailure)
+		return
+	}
+	c.log().charger.Info("Archive built", "file_name", u.fileName, "bytes", len(data))
+
+	attempts := u.retries + 1
+	for attempt := 1; ; attempt++ {
+		c.sendUploadStatus(u, uploadUploading)
+
+		err := c.uploadFile(u.location, u.fileName, data)
+		if u.cancelled() {
+			c.reportUploadCancelled(u)
+			return
+		}
+		if err == nil {
+			c.log().charger.Info("Diagnostics uploaded", "location", u.location)
+			c.sendUploadStatus(u, uploadUploaded)
+			return
+		}
+
+		c.log().charger.Warn("Diagnostics upload failed", "attempt", attempt, "attempts", attempts, logging.Err(err))
+		// Retrying cannot fix a bad location or missing permission
+		switch {
+		case errors.Is(err, errUploadBadLocation):
+			c.sendUploadStatus(u, uploadBadMessage)
+			return
+		case errors.Is(err, errUploadPermissionDenied):
+			c.sendUploadStatus(u, uploadPermissionDenied)
+			return
+		}
+		if attempt >= attempts {
+			c.sendUploadStatus(u, uploadFailure)
+			return
+		}
+		if !u.sleep(u.retryInterval) {
+			c.reportUploadCancelled(u)
+			return
+		}
+	}
+}
+
+// reportUploadCancelled reports an upload cancelled by a newer GetLog (OCPP 2.0.1)
+func (c *Charger) reportUploadCancelled(u *logUpload) {
+	if c.config.IsOCPP16() || u.logType == "" {
+		return
+	}
+	c.sendUploadStatus(u, uploadAcceptedCanceled)
+}
+
+// sendUploadStatus records the upload status and reports it to the server
+func (c *Charger) sendUploadStatus(u *logUpload, status string) {
+	c.mu.Lock()
+	u.status = status
+	isConnected := c.isConnected
+	c.mu.Unlock()
+
+	if !isConnected {
+		c.log().charger.Info("Upload status not sent, offline", "status", status)
+		return
+	}
+
+	var err error
+	if u.logType == "" {
+		err = c.sendDiagnosticsStatusNotificationV16(status)
+	} else {
+		err = c.sendLogStatusNotification(status, u.requestId)
+	}
+	if err != nil {
+		c.log().charger.Warn("Upload status notification error", logging.Err(err))
+	}
+}
+
+// sendDiagnosticsStatusNotificationV16 sends DiagnosticsStatusNotification,
+// which knows a single failure status (OCPP 1.6)
+func (c *Charger) sendDiagnosticsStatusNotificationV16(status string) error {
+	switch status {
+	case uploadFailure, uploadPermissionDenied, uploadBadMessage:
+		status = diagnosticsFailed
+	}
+
+	req := v16.DiagnosticsStatusNotificationRequest{
+		Status: status,
+	}
+	if _, err := c.sendCall(v16.ActionDiagnosticsStatusNotification, req); err != nil {
+		return fmt.Errorf("DiagnosticsStatusNotification failed: %w", err)
+	}
+
+	c.log().charger.Info("DiagnosticsStatusNotification sent", "status", status)
+	return nil
+}
+
+// sendLogStatusNotification sends LogStatusNotification (OCPP 2.0.1 and the
+// 1.6 Security Whitepaper)
+func (c *Charger) sendLogStatusNotification(status string, requestId int) error {
+	var err error
+	if c.config.IsOCPP16() {
+		req := v16.LogStatusNotificationRequest{
+			Status:    status,
+			RequestId: requestId,
+		}
+		_, err = c.sendCall(v16.ActionLogStatusNotification, req)
+	} else {
+		req := v201.LogStatusNotificationRequest{
+			Status:    status,
+			RequestId: requestId,
+		}
+		_, err = c.sendCall(v201.ActionLogStatusNotification, req)
+	}
+	if err != nil {
+		return fmt.Errorf("LogStatusNotification failed: %w", err)
+	}
+
+	c.log().charger.Info("LogStatusNotification sent", "status", status)
+	return nil
+}
+
+// sendTriggeredUploadStatus reports Uploading while an upload of the
+// requested kind is in progress, otherwise Idle, in response to a TriggerMessage
+func (c *Charger) sendTriggeredUploadStatus(diagnostics bool) error {
+	c.mu.RLock()
+	status := uploadIdle
+	requestId := 0
+	if u := c.logUpload; u != nil && (u.logType == "") == diagnostics && u.status == uploadUploading {
+		status = uploadUploading
+		requestId = u.requestId
+	}
+	c.mu.RUnlock()
+
+	if diagnostics {
+		return c.sendDiagnosticsStatusNotificationV16(status)
+	}
+	return c.sendLogStatusNotification(status, requestId)
+}
+
+// parseLogTime parses a start/stop timestamp; an invalid one is ignored
+func (c *Charger) parseLogTime(field, value string) time.Time {
+	if value == "" {
+		return time.Time{}
+	}
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		c.log().charger.Warn("Invalid timestamp, ignored", "field", field, "value", value, logging.Err(err))
+		return time.Time{}
+	}
+	return t
+}
+
+// handleGetDiagnosticsV16 handles GetDiagnostics from server (OCPP 1.6)
+func (c *Charger) handleGetDiagnosticsV16(uniqueId string, payload json.RawMessage) {
+	var req v16.GetDiagnosticsRequest
+	if err := json.Unmarshal(payload, &req); err != nil {
+		c.log().charger.Error("Failed to parse GetDiagnostics", logging.Action("GetDiagnostics"), logging.UniqueID(uniqueId), logging.Err(err))
+		return
+	}
+
+	c.log().charger.Info("Received GetDiagnostics", logging.Action("GetDiagnostics"), logging.UniqueID(uniqueId), "location", req.Location, "start_time", req.StartTime, "stop_time", req.StopTime, "retries", req.Retries, "retry_interval", req.RetryInterval)
+
+	u := c.newLogUpload("", req.Location, c.parseLogTime("startTime", req.StartTime), c.parseLogTime("stopTime", req.StopTime), req.Retries, req.RetryInterval)
+	c.acceptLogUpload(u)
+
+	resp := v16.GetDiagnosticsResponse{
+		FileName: u.fileName,
+	}
+
+	if err := c.sendCallResult(uniqueId, resp); err != nil {
+		c.log().charger.Error("Failed to send GetDiagnostics response", logging.Action("GetDiagnostics"), logging.UniqueID(uniqueId), logging.Err(err))
+	}
+
+	go c.runLogUpload(u)
+}
+
+// logParams are the GetLog log parameters common to both OCPP versions
+type logParams struct {
+	location, oldest, latest string
+}
+
+// acceptGetLog validates a GetLog request and registers the upload. It
+// returns the response status and the upload to run (nil when rejected).
+func (c *Charger) acceptGetLog(logType string, requestId int, params logParams, retries, retryInterval int) (string, *logUpload) {
+	if logType != logTypeDiagnostics && logType != logTypeSecurity {
+		c.log().charger.Info("GetLog rejected: log type not supported", "log_type", logType)
+		return "Rejected", nil
+	}
+
+	u := c.newLogUpload(logType, params.location, c.parseLogTime("oldestTimestamp", params.oldest), c.parseLogTime("latestTimestamp", params.latest), retries, retryInterval)
+	u.requestId = requestId
+	return c.acceptLogUpload(u), u
+}
+
+// handleGetLogV16 handles GetLog from server (OCPP 1.6 Security Whitepaper)
+func (c *Charger) handleGetLogV16(uniqueId string, payload json.RawMessage) {
+	var req v16.GetLogRequest
+	if err := json.Unmarshal(payload, &req); err != nil {
+		c.log().charger.Error("Failed to parse GetLog", logging.Action("GetLog"), logging.UniqueID(uniqueId), logging.Err(err))
+		return
+	}
+
+	c.log().charger.Info("Received GetLog", logging.Action("GetLog"), logging.UniqueID(uniqueId), "log_type", req.LogType, "request_id", req.RequestId, "remote_location", req.Log.RemoteLocation)
+
+	params := logParams{location: req.Log.RemoteLocation, oldest: req.Log.OldestTimestamp, latest: req.Log.LatestTimestamp}
+	status, u := c.acceptGetLog(req.LogType, req.RequestId, params, req.Retries, req.RetryInterval)
+
+	resp := v16.GetLogResponse{
+		Status: status,
+	}
+	if u != nil {
+		resp.Filename = u.fileName
+	}
+
+	if err := c.sendCallResult(uniqueId, resp); err != nil {
+		c.log().charger.Error("Failed to send GetLog response", logging.Action("GetLog"), logging.UniqueID(uniqueId), logging.Err(err))
+	}
+
+	if u != nil {
+		go c.runLogUpload(u)
+	}
+}
+
+// handleGetLogV201 handles GetLog from server (OCPP 2.0.1)
+func (c *Charger) handleGetLogV201(uniqueId string, payload json.RawMessage) {
+	var req v201.GetLogRequest
+	if err := json.Unmarshal(payload, &req); err != nil {
+		c.log().charger.Error("Failed to parse GetLog", logging.Action("GetLog"), logging.UniqueID(uniqueId), logging.Err(err))
+		return
+	}
+
+	c.log().charger.Info("Received GetLog", logging.Action("GetLog"), logging.UniqueID(uniqueId), "log_type", req.LogType, "request_id", req.RequestId, "remote_location", req.Log.RemoteLocation)
+
+	params := logParams{location: req.Log.RemoteLocation, oldest: req.Log.OldestTimestamp, latest: req.Log.LatestTimestamp}
+	status, u := c.acceptGetLog(req.LogType, req.RequestId, params, req.Retries, req.RetryInterval)
+
+	resp := v201.GetLogResponse{
+		Status: status,
+	}
+	if u != nil {
+		resp.Filename = u.fileName
+	} else {
+		resp.StatusInfo = &v201.StatusInfo{ReasonCode: "UnsupportedRequest", AdditionalInfo: "log type " + req.LogType + " is not supported"}
+	}
+
+	if err := c.sendCallResult(uniqueId, resp); err != nil {
+		c.log().charger.Error("Failed to send GetLog response", logging.Action("GetLog"), logging.UniqueID(uniqueId), logging.Err(err))
+	}
+
+	if u != nil {
+		go c.runLogUpload(u)
+	}
+}
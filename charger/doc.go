@@ -0,0 +1,25 @@
+// Package charger simulates an OCPP 1.6 / 2.0.1 charger. Besides driving the
+// interactive CLI, it can be embedded in Go integration tests of a server:
+//
+//	cfg, _ := config.Load("charger.yaml")
+//	c, err := charger.New(cfg, charger.WithCallTimeout(5*time.Second))
+//	if err != nil { ... }
+//	defer c.Close()
+//
+//	events := c.Subscribe()
+//	defer events.Close()
+//
+//	if err := c.ConnectContext(ctx); err != nil { ... }
+//	if err := c.BootNotificationContext(ctx); err != nil { ... }
+//	if err := c.Plugin(); err != nil { ... }
+//	if err := c.StartTransactionContext(ctx, "TAG1"); err != nil { ... }
+//
+//	// Wait for the server to stop the transaction remotely
+//	e, err := events.Next(ctx, func(e charger.Event) bool {
+//		return e.Type == charger.EventTransactionStopped
+//	})
+//
+// Errors can be checked with errors.Is against ErrNotConnected,
+// ErrInvalidState, ErrNotAccepted and ErrTimeout, and with errors.As against
+// *CallError when the server answered a Call with a CallError.
+package charger
@@ -0,0 +1,90 @@
+package charger
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/weilun-shrimp/wlgo_ocpp_charger_simulator/ocpp/v16"
+)
+
+// Errors returned by the charger. Check them with errors.Is; the returned
+// errors carry the details in their message.
+var (
+	// ErrNotConnected: the charger has no connection to the server
+	ErrNotConnected = errors.New("not connected to server")
+	// ErrInvalidState: the operation is not possible in the current state, e.g.
+	// starting a transaction while the connector is not Preparing/Occupied
+	ErrInvalidState = errors.New("invalid state")
+	// ErrNotAccepted: the message may not be sent in the current registration
+	// state (see GetRegistrationStatus)
+	ErrNotAccepted = errors.New("not accepted by the server")
+	// ErrTimeout: the server did not answer a Call within the call timeout
+	ErrTimeout = errors.New("timeout waiting for response")
+)
+
+// CallError is returned when the server answers a Call with a CallError
+type CallError struct {
+	Action      string          // action of the Call
+	Code        string          // errorCode, e.g. NotImplemented or FormationViolation
+	Description string          // errorDescription
+	Details     json.RawMessage // errorDetails
+}
+
+// Error implements error
+func (e *CallError) Error() string {
+	if e.Description == "" {
+		return fmt.Sprintf("server answered %s with CallError %s", e.Action, e.Code)
+	}
+	return fmt.Sprintf("server answered %s with CallError %s: %s", e.Action, e.Code, e.Description)
+}
+
+// parseCallError returns the CallError of a response to action, or nil if the
+// response is a CallResult. The message type is the same in both versions.
+func parseCallError(action string, resp []byte) *CallError {
+	var raw []json.RawMessage
+	if err := json.Unmarshal(resp, &raw); err != nil || len(raw) < 3 {
+		return nil
+	}
+	var messageType int
+	if err := json.Unmarshal(raw[0], &messageType); err != nil || messageType != v16.MessageTypeCallError {
+		return nil
+	}
+
+	e := &CallError{Action: action}
+	json.Unmarshal(raw[2], &e.Code)
+	if len(raw) > 3 {
+		json.Unmarshal(raw[3], &e.Description)
+	}
+	if len(raw) > 4 {
+		e.Details = raw[4]
+	}
+	return e
+}
+
+// kindError is an error with its own message that matches one of the errors
+// above with errors.Is
+type kindError struct {
+	kind    error
+	message string
+}
+
+// Error implements error
+func (e *kindError) Error() string {
+	return e.message
+}
+
+// Is reports whether target is the kind of the error
+func (e *kindError) Is(target error) bool {
+	return target == e.kind
+}
+
+// invalidState returns an ErrInvalidState with the given message
+func invalidState(format string, args ...any) error {
+	return &kindError{kind: ErrInvalidState, message: fmt.Sprintf(format, args...)}
+}
+
+// notAccepted returns an ErrNotAccepted with the given message
+func notAccepted(format string, args ...any) error {
+	return &kindError{kind: ErrNotAccepted, message: fmt.Sprintf(format, args...)}
+}
@@ -0,0 +1,206 @@
+package charger
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// EventType identifies what an Event reports
+type EventType string
+
+// Event types
+const (
+	EventConnected          EventType = "Connected"          // WebSocket connection established
+	EventDisconnected       EventType = "Disconnected"       // connection closed or lost
+	EventRegistration       EventType = "Registration"       // BootNotification response received
+	EventStatusChanged      EventType = "StatusChanged"      // connector status changed
+	EventTransactionStarted EventType = "TransactionStarted" // transaction started (after the server's response, if connected)
+	EventTransactionStopped EventType = "TransactionStopped" // transaction stopped locally
+	EventMeterValues        EventType = "MeterValues"        // meter advanced during a transaction
+	EventFaultRaised        EventType = "FaultRaised"        // fault raised with RaiseFault
+	EventFaultCleared       EventType = "FaultCleared"       // fault cleared with ClearFault
+	EventFrameSent          EventType = "FrameSent"          // OCPP message sent to the server
+	EventFrameReceived      EventType = "FrameReceived"      // OCPP message received from the server
+)
+
+// ErrSubscriptionClosed is returned by Subscription.Next after Close
+var ErrSubscriptionClosed = errors.New("subscription closed")
+
+// Event is a change of the charger reported to subscribers. Only the fields
+// of its type are set.
+type Event struct {
+	Type          EventType
+	Time          time.Time
+	Status        string       // StatusChanged: new status; Registration: Accepted, Pending or Rejected
+	TransactionId string       // transaction events and MeterValues ("" in 1.6 before the server assigned one)
+	IdTag         string       // transaction events
+	Reason        string       // TransactionStopped: stop reason
+	Meter         *MeterSample // MeterValues
+	Fault         string       // FaultRaised/FaultCleared: error code
+	Frame         string       // FrameSent/FrameReceived: the OCPP message, credentials redacted
+}
+
+// MeterSample is one meter reading reported in MeterValues
+type MeterSample struct {
+	EnergyWh int // Energy.Active.Import.Register
+	VoltageV float64
+	CurrentA float64
+	PowerW   float64
+	SoC      float64 // State of charge in %
+}
+
+// Subscription receives the events of a charger in order. Events are queued
+// without limit until read, so the charger never waits for a subscriber;
+// close the subscription when done.
+type Subscription struct {
+	C <-chan Event // events, closed after Close
+
+	ch        chan Event
+	hub       *eventHub
+	mu        sync.Mutex
+	queue     []Event
+	wake      chan struct{}
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+// Subscribe returns a subscription to the charger's events from now on
+func (c *Charger) Subscribe() *Subscription {
+	return c.events.subscribe()
+}
+
+// OnEvent calls handler for every event, in order, on its own goroutine. It
+// returns a function that stops the calls.
+func (c *Charger) OnEvent(handler func(Event)) (cancel func()) {
+	s := c.Subscribe()
+	go func() {
+		for e := range s.C {
+			handler(e)
+		}
+	}()
+	return s.Close
+}
+
+// Next waits for the next event for which match returns true (any event if
+// match is nil), discarding the others. It fails when ctx is done or the
+// subscription is closed.
+func (s *Subscription) Next(ctx context.Context, match func(Event) bool) (Event, error) {
+	for {
+		select {
+		case e, ok := <-s.C:
+			if !ok {
+				return Event{}, ErrSubscriptionClosed
+			}
+			if match == nil || match(e) {
+				return e, nil
+			}
+		case <-ctx.Done():
+			return Event{}, ctx.Err()
+		}
+	}
+}
+
+// Close stops the subscription and closes C
+func (s *Subscription) Close() {
+	s.hub.unsubscribe(s)
+	s.closeOnce.Do(func() { close(s.done) })
+}
+
+// push queues an event for the subscriber
+func (s *Subscription) push(e Event) {
+	s.mu.Lock()
+	s.queue = append(s.queue, e)
+	s.mu.Unlock()
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+}
+
+// forward delivers the queued events to C until Close
+func (s *Subscription) forward() {
+	defer close(s.ch)
+	for {
+		s.mu.Lock()
+		if len(s.queue) == 0 {
+			s.mu.Unlock()
+			select {
+			case <-s.wake:
+				continue
+			case <-s.done:
+				return
+			}
+		}
+		e := s.queue[0]
+		s.queue = s.queue[1:]
+		s.mu.Unlock()
+
+		select {
+		case s.ch <- e:
+		case <-s.done:
+			return
+		}
+	}
+}
+
+// eventHub fans the charger's events out to the subscriptions. It has its own
+// lock so events can be published while c.mu is held.
+type eventHub struct {
+	mu            sync.Mutex
+	subscriptions map[*Subscription]struct{}
+}
+
+// subscribe adds a subscription
+func (h *eventHub) subscribe() *Subscription {
+	ch := make(chan Event)
+	s := &Subscription{
+		C:    ch,
+		ch:   ch,
+		hub:  h,
+		wake: make(chan struct{}, 1),
+		done: make(chan struct{}),
+	}
+	h.mu.Lock()
+	if h.subscriptions == nil {
+		h.subscriptions = make(map[*Subscription]struct{})
+	}
+	h.subscriptions[s] = struct{}{}
+	h.mu.Unlock()
+
+	go s.forward()
+	return s
+}
+
+// unsubscribe removes a subscription
+func (h *eventHub) unsubscribe(s *Subscription) {
+	h.mu.Lock()
+	delete(h.subscriptions, s)
+	h.mu.Unlock()
+}
+
+// publish sends an event to every subscription
+func (h *eventHub) publish(e Event) {
+	if e.Time.IsZero() {
+		e.Time = time.Now().UTC()
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for s := range h.subscriptions {
+		s.push(e)
+	}
+}
+
+// transactionIdLocked returns the id of the current transaction as reported in
+// events. c.mu must be held.
+func (c *Charger) transactionIdLocked() string {
+	if !c.config.IsOCPP16() {
+		return c.transactionIdStr
+	}
+	if c.transactionId == 0 {
+		return ""
+	}
+	return fmt.Sprintf("%d", c.transactionId)
+}
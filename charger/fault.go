@@ -0,0 +1,394 @@
+package charger
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/weilun-shrimp/wlgo_ocpp_charger_simulator/logging"
+	"github.com/weilun-shrimp/wlgo_ocpp_charger_simulator/ocpp/v201"
+)
+
+// Fault is an active fault of the connector
+type Fault struct {
+	ErrorCode       string // OCPP 1.6 ChargePointErrorCode, e.g. GroundFailure
+	Info            string // free text: info (1.6) / techInfo (2.0.1)
+	VendorId        string // vendor the VendorErrorCode belongs to
+	VendorErrorCode string // vendorErrorCode (1.6) / techCode (2.0.1)
+	Since           time.Time
+}
+
+// String describes the fault, e.g. "GroundFailure since 2026-01-02T03:04:05Z
+// (RCD tripped, vendor ACME E42)"
+func (f Fault) String() string {
+	var details []string
+	if f.Info != "" {
+		details = append(details, f.Info)
+	}
+	if f.VendorId != "" || f.VendorErrorCode != "" {
+		details = append(details, strings.TrimSpace("vendor "+f.VendorId+" "+f.VendorErrorCode))
+	}
+	s := f.ErrorCode + " since " + f.Since.Format(time.RFC3339)
+	if len(details) > 0 {
+		s += " (" + strings.Join(details, ", ") + ")"
+	}
+	return s
+}
+
+// faultEffect is what a fault does to the connector
+type faultEffect int
+
+const (
+	faultWarning faultEffect = iota // reported only; charging continues
+	faultSuspend                    // energy delivery suspended until cleared
+	faultFaulted                    // status Faulted; a running transaction is stopped
+)
+
+// faultKind describes how a fault is simulated and reported
+type faultKind struct {
+	effect faultEffect
+	// OCPP 2.0.1 stoppedReason when a faultFaulted stops the transaction
+	// (OCPP 1.6 reports Other)
+	stopReason string
+	// OCPP 2.0.1 component variable reported in NotifyEvent
+	component string
+	variable  string
+	evse      bool // the component belongs to the EVSE
+}
+
+// faultKinds lists the faults that can be raised, keyed by OCPP 1.6 error code
+var faultKinds = map[string]faultKind{
+	"ConnectorLockFailure": {faultFaulted, "Other", "ConnectorPlugRetentionLock", "Problem", true},
+	"EVCommunicationError": {faultWarning, "", "ConnectedEV", "Problem", true},
+	"GroundFailure":        {faultFaulted, "GroundFault", "RCD", "Tripped", true},
+	"HighTemperature":      {faultSuspend, "", "EVSE", "Problem", true},
+	"InternalError":        {faultFaulted, "Other", "ChargingStation", "Problem", false},
+	"LocalListConflict":    {faultWarning, "", "ChargingStation", "Problem", false},
+	"OtherError":           {faultWarning, "", "ChargingStation", "Problem", false},
+	"OverCurrentFailure":   {faultFaulted, "OvercurrentFault", "OverCurrentProtection", "Operated", true},
+	"OverVoltage":          {faultFaulted, "PowerQuality", "ElectricalFeed", "Problem", false},
+	"PowerMeterFailure":    {faultWarning, "", "FiscalMetering", "Problem", true},
+	"PowerSwitchFailure":   {faultFaulted, "Other", "PowerContactor", "Problem", true},
+	"ReaderFailure":        {faultWarning, "", "TokenReader", "Problem", false},
+	"ResetFailure":         {faultWarning, "", "ChargingStation", "Problem", false},
+	"UnderVoltage":         {faultFaulted, "PowerQuality", "ElectricalFeed", "Problem", false},
+	"WeakSignal":           {faultWarning, "", "ChargingStation", "Problem", false},
+}
+
+// FaultCodes returns the error codes that can be raised, sorted
+func FaultCodes() []string {
+	codes := make([]string, 0, len(faultKinds))
+	for code := range faultKinds {
+		codes = append(codes, code)
+	}
+	sort.Strings(codes)
+	return codes
+}
+
+// faultState is the set of active faults
+type faultState struct {
+	active         []Fault // oldest first; the latest is reported in StatusNotification (1.6)
+	preFaultStatus string  // status restored when the last faultFaulted is cleared
+	suspended      bool    // energy delivery of the transaction is suspended by a fault
+	nextEventId    int     // NotifyEvent eventId (2.0.1)
+}
+
+// latest returns the most recent active fault, or nil if none
+func (s *faultState) latest() *Fault {
+	if len(s.active) == 0 {
+		return nil
+	}
+	f := s.active[len(s.active)-1]
+	return &f
+}
+
+// has reports whether an active fault has the given effect
+func (s *faultState) has(effect faultEffect) bool {
+	for _, f := range s.active {
+		if faultKinds[f.ErrorCode].effect == effect {
+			return true
+		}
+	}
+	return false
+}
+
+// GetFaults returns the active faults, oldest first
+func (c *Charger) GetFaults() []Fault {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return append([]Fault(nil), c.faults.active...)
+}
+
+// ActiveFaults describes the active faults, oldest first
+func (c *Charger) ActiveFaults() []string {
+	var faults []string
+	for _, f := range c.GetFaults() {
+		faults = append(faults, f.String())
+	}
+	return faults
+}
+
+// energySuspendedLocked reports whether the charger stopped energy delivery
+// of the running transaction (deauthorization or a fault). c.mu must be held.
+func (c *Charger) energySuspendedLocked() bool {
+	return c.deauthSuspended || c.faults.suspended
+}
+
+// RaiseFault raises a fault, replacing an active one with the same error code.
+// Depending on the fault the status changes to Faulted (stopping a running
+// transaction), energy delivery is suspended, or the fault is only reported.
+// OCPP 1.6 reports it in StatusNotification, OCPP 2.0.1 in NotifyEvent.
+func (c *Charger) RaiseFault(errorCode, info, vendorId, vendorErrorCode string) error {
+	kind, ok := faultKinds[errorCode]
+	if !ok {
+		return fmt.Errorf("unknown error code %q (valid: %s)", errorCode, strings.Join(FaultCodes(), ", "))
+	}
+	f := Fault{
+		ErrorCode:       errorCode,
+		Info:            info,
+		VendorId:        vendorId,
+		VendorErrorCode: vendorErrorCode,
+		Since:           time.Now().UTC(),
+	}
+
+	c.mu.Lock()
+	active := c.faults.active[:0]
+	for _, existing := range c.faults.active {
+		if existing.ErrorCode != f.ErrorCode {
+			active = append(active, existing)
+		}
+	}
+	c.faults.active = append(active, f)
+	charging := c.isCharging
+	c.mu.Unlock()
+
+	c.log().charger.Warn("Fault raised", "error_code", f.ErrorCode, "info", f.Info, "vendor_id", f.VendorId, "vendor_error_code", f.VendorErrorCode)
+	c.recordSnapshot("FaultRaised")
+	c.events.publish(Event{Type: EventFaultRaised, Fault: f.ErrorCode})
+
+	if !c.config.IsOCPP16() {
+		if err := c.notifyFaultEvent(f, kind, false); err != nil {
+			c.log().charger.Error("Failed to report fault", logging.Err(err))
+		}
+	}
+
+	switch kind.effect {
+	case faultFaulted:
+		if charging {
+			reason := kind.stopReason
+			if c.config.IsOCPP16() {
+				reason = "Other"
+			}
+			if err := c.StopTransaction(reason); err != nil {
+				c.log().charger.Error("Failed to stop transaction on fault", logging.Err(err))
+			}
+		}
+		c.mu.Lock()
+		status := c.status
+		if status != "Faulted" {
+			c.faults.preFaultStatus = status
+		}
+		c.mu.Unlock()
+		if status != "Faulted" {
+			return c.SetStatus("Faulted")
+		}
+	case faultSuspend:
+		if charging {
+			return c.suspendForFault()
+		}
+	}
+	return c.reportFaultStatus()
+}
+
+// ClearFault clears the active fault with the given error code, or all
+// faults if errorCode is "". The status before the fault is restored and a
+// suspended transaction resumes once no fault requires otherwise.
+func (c *Charger) ClearFault(errorCode string) error {
+	c.mu.Lock()
+	var cleared []Fault
+	active := c.faults.active[:0]
+	for _, f := range c.faults.active {
+		if errorCode == "" || f.ErrorCode == errorCode {
+			cleared = append(cleared, f)
+		} else {
+			active = append(active, f)
+		}
+	}
+	c.faults.active = active
+	if len(cleared) == 0 {
+		c.mu.Unlock()
+		if errorCode == "" {
+			return fmt.Errorf("no active faults")
+		}
+		return fmt.Errorf("no active fault %s", errorCode)
+	}
+
+	restoreStatus := ""
+	if c.status == "Faulted" && c.faults.preFaultStatus != "" && !c.faults.has(faultFaulted) {
+		restoreStatus = c.faults.preFaultStatus
+		c.faults.preFaultStatus = ""
+	}
+	resume := c.faults.suspended && !c.faults.has(faultSuspend)
+	c.mu.Unlock()
+
+	for _, f := range cleared {
+		c.log().charger.Info("Fault cleared", "error_code", f.ErrorCode)
+		c.events.publish(Event{Type: EventFaultCleared, Fault: f.ErrorCode})
+		if !c.config.IsOCPP16() {
+			if err := c.notifyFaultEvent(f, faultKinds[f.ErrorCode], true); err != nil {
+				c.log().charger.Error("Failed to report cleared fault", logging.Err(err))
+			}
+		}
+	}
+	c.recordSnapshot("FaultCleared")
+
+	switch {
+	case restoreStatus != "":
+		return c.SetStatus(restoreStatus)
+	case resume:
+		return c.resumeAfterFault()
+	}
+	return c.reportFaultStatus()
+}
+
+// reportFaultStatus reports the current status with the latest fault (NoError
+// if none) when the fault did not change the status. Only OCPP 1.6 carries
+// error codes in StatusNotification.
+func (c *Charger) reportFaultStatus() error {
+	if !c.config.IsOCPP16() || !c.IsConnected() {
+		return nil
+	}
+	return c.StatusNotification(c.GetStatus())
+}
+
+// suspendForFault suspends energy delivery of the running transaction.
+// OCPP 1.6: status SuspendedEVSE (this also stops the meter loop)
+// OCPP 2.0.1: TransactionEvent (Updated) with chargingState SuspendedEVSE
+func (c *Charger) suspendForFault() error {
+	c.mu.Lock()
+	alreadySuspended := c.energySuspendedLocked()
+	c.faults.suspended = true
+	status := c.status
+	c.mu.Unlock()
+
+	if alreadySuspended {
+		return c.reportFaultStatus()
+	}
+	c.log().charger.Info("Energy delivery suspended: fault")
+	if c.config.IsOCPP16() {
+		if status == "Charging" {
+			return c.SetStatus("SuspendedEVSE")
+		}
+		return c.reportFaultStatus()
+	}
+	return c.sendChargingStateV201(v201.ChargingStateSuspendedEVSE)
+}
+
+// resumeAfterFault resumes energy delivery after the suspending fault was
+// cleared, unless the transaction is suspended for another reason
+func (c *Charger) resumeAfterFault() error {
+	c.mu.Lock()
+	c.faults.suspended = false
+	resume := c.isCharging && !c.energySuspendedLocked() && c.current > 0
+	status := c.status
+	c.mu.Unlock()
+
+	if !resume {
+		return c.reportFaultStatus()
+	}
+	c.log().charger.Info("Energy delivery resumed: fault cleared")
+	if c.config.IsOCPP16() {
+		if status == "SuspendedEVSE" {
+			return c.SetStatus("Charging")
+		}
+		return c.reportFaultStatus()
+	}
+	return c.sendChargingStateV201(v201.ChargingStateCharging)
+}
+
+// sendChargingStateV201 reports a charging state change of the running
+// transaction in TransactionEvent (Updated) (OCPP 2.0.1)
+func (c *Charger) sendChargingStateV201(state v201.ChargingState) error {
+	c.mu.Lock()
+	if !c.isCharging || !c.isConnected {
+		c.mu.Unlock()
+		return nil
+	}
+	transactionIdStr := c.transactionIdStr
+	c.seqNo++
+	seqNo := c.seqNo
+	c.mu.Unlock()
+
+	req := v201.TransactionEventRequest{
+		EventType:     v201.TransactionEventUpdated,
+		Timestamp:     time.Now().UTC().Format(time.RFC3339),
+		TriggerReason: v201.TriggerReasonChargingStateChanged,
+		SeqNo:         seqNo,
+		TransactionInfo: v201.Transaction{
+			TransactionId: transactionIdStr,
+			ChargingState: state,
+		},
+	}
+
+	if _, err := c.sendCall(v201.ActionTransactionEvent, req); err != nil {
+		return fmt.Errorf("TransactionEvent (Updated) failed: %w", err)
+	}
+
+	c.log().charger.Info("TransactionEvent (Updated) sent", logging.KeyTransactionID, transactionIdStr, "charging_state", state)
+	return nil
+}
+
+// notifyFaultEvent reports a raised or cleared fault on its component
+// variable in NotifyEvent (OCPP 2.0.1)
+func (c *Charger) notifyFaultEvent(f Fault, kind faultKind, cleared bool) error {
+	c.mu.Lock()
+	if !c.isConnected {
+		c.mu.Unlock()
+		return nil
+	}
+	c.faults.nextEventId++
+	eventId := c.faults.nextEventId
+	transactionIdStr := ""
+	if c.isCharging {
+		transactionIdStr = c.transactionIdStr
+	}
+	c.mu.Unlock()
+
+	component := v201.Component{Name: kind.component}
+	if kind.evse {
+		component.Evse = &v201.EVSE{Id: c.config.ConnectorID}
+	}
+	techInfo := f.Info
+	if f.VendorId != "" {
+		techInfo = strings.TrimSpace(f.VendorId + ": " + f.Info)
+	}
+
+	now := time.Now().UTC().Format(time.RFC3339)
+	req := v201.NotifyEventRequest{
+		GeneratedAt: now,
+		SeqNo:       0,
+		EventData: []v201.EventData{
+			{
+				EventId:               eventId,
+				Timestamp:             now,
+				Trigger:               "Alerting",
+				ActualValue:           fmt.Sprint(!cleared),
+				TechCode:              f.VendorErrorCode,
+				TechInfo:              techInfo,
+				Cleared:               cleared,
+				TransactionId:         transactionIdStr,
+				EventNotificationType: "HardWiredNotification",
+				Component:             component,
+				Variable:              v201.Variable{Name: kind.variable},
+			},
+		},
+	}
+
+	if _, err := c.sendCall(v201.ActionNotifyEvent, req); err != nil {
+		return fmt.Errorf("NotifyEvent failed: %w", err)
+	}
+
+	c.log().charger.Info("NotifyEvent sent", "component", kind.component, "variable", kind.variable, "value", !cleared, "error_code", f.ErrorCode)
+	return nil
+}
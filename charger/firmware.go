@@ -0,0 +1,579 @@
+package charger
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"path"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/weilun-shrimp/wlgo_ocpp_charger_simulator/config"
+	"github.com/weilun-shrimp/wlgo_ocpp_charger_simulator/logging"
+	"github.com/weilun-shrimp/wlgo_ocpp_charger_simulator/ocpp/v16"
+	"github.com/weilun-shrimp/wlgo_ocpp_charger_simulator/ocpp/v201"
+)
+
+// Firmware statuses reported in (Signed)FirmwareStatusNotification. The ones
+// marked extended only exist in OCPP 2.0.1 and the 1.6 Security Whitepaper.
+const (
+	firmwareIdle                      = "Idle"
+	firmwareDownloadScheduled         = "DownloadScheduled" // extended
+	firmwareDownloading               = "Downloading"
+	firmwareDownloaded                = "Downloaded"
+	firmwareDownloadFailed            = "DownloadFailed"
+	firmwareSignatureVerified         = "SignatureVerified"         // extended
+	firmwareInvalidSignature          = "InvalidSignature"          // extended
+	firmwareInstallVerificationFailed = "InstallVerificationFailed" // extended
+	firmwareInstallScheduled          = "InstallScheduled"          // extended
+	firmwareInstalling                = "Installing"
+	firmwareInstallRebooting          = "InstallRebooting" // extended
+	firmwareInstalled                 = "Installed"
+	firmwareInstallationFailed        = "InstallationFailed"
+)
+
+// defaultFirmwareRetryInterval is used when the server gives no retryInterval
+const defaultFirmwareRetryInterval = 30 * time.Second
+
+// firmwareDownloadTimeout bounds a single download attempt
+const firmwareDownloadTimeout = 60 * time.Second
+
+// firmwareVersionPattern finds a version number (e.g. "2.1.0") in a file name
+var firmwareVersionPattern = regexp.MustCompile(`\d+(\.\d+)+`)
+
+// firmwareUpdate is a firmware update in progress
+type firmwareUpdate struct {
+	requestId          int  // requestId of SignedUpdateFirmware (1.6) or UpdateFirmware (2.0.1)
+	signed             bool // OCPP 1.6 SignedUpdateFirmware: progress goes in SignedFirmwareStatusNotification
+	location           string
+	retrieve           time.Time
+	install            time.Time // zero: install right after download
+	retries            int
+	retryInterval      time.Duration
+	signingCertificate *x509.Certificate // nil: no signature to verify
+	signature          string            // base64 signature of the firmware file
+	status             string            // last reported status
+	installing         bool              // installation started, can no longer be cancelled
+	cancelCh           chan struct{}     // closed when a newer update replaces this one
+}
+
+// newFirmwareUpdate creates an update for the given download location.
+// retryInterval is in seconds; 0 uses the default.
+func newFirmwareUpdate(location string, retrieve time.Time, retries, retryInterval int) *firmwareUpdate {
+	interval := defaultFirmwareRetryInterval
+	if retryInterval > 0 {
+		interval = time.Duration(retryInterval) * time.Second
+	}
+	if retries < 0 {
+		retries = 0
+	}
+	return &firmwareUpdate{
+		location:      location,
+		retrieve:      retrieve,
+		retries:       retries,
+		retryInterval: interval,
+		cancelCh:      make(chan struct{}),
+	}
+}
+
+// sleep waits for d, returning false if the update was cancelled meanwhile
+func (u *firmwareUpdate) sleep(d time.Duration) bool {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-u.cancelCh:
+		return false
+	case <-timer.C:
+		return true
+	}
+}
+
+// extended reports whether the update may use the extended firmware statuses
+func (c *Charger) extendedFirmwareStatus(u *firmwareUpdate) bool {
+	return u.signed || !c.config.IsOCPP16()
+}
+
+// acceptFirmwareUpdate registers u as the update in progress. A previous update
+// that has not started installing is cancelled (AcceptedCanceled); one that is
+// installing cannot be replaced (Rejected).
+func (c *Charger) acceptFirmwareUpdate(u *firmwareUpdate) string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	status := "Accepted"
+	if current := c.firmwareUpdate; current != nil {
+		if current.installing {
+			return "Rejected"
+		}
+		close(current.cancelCh)
+		c.log().charger.Info("Previous firmware update cancelled")
+		status = "AcceptedCanceled"
+	}
+	c.firmwareUpdate = u
+	return status
+}
+
+// finishFirmwareUpdate clears u once it has ended (unless already replaced)
+func (c *Charger) finishFirmwareUpdate(u *firmwareUpdate) {
+	c.mu.Lock()
+	if c.firmwareUpdate == u {
+		c.firmwareUpdate = nil
+	}
+	c.mu.Unlock()
+}
+
+// runFirmwareUpdate drives an accepted update through download, verification,
+// installation and reboot, reporting each step to the server
+func (c *Charger) runFirmwareUpdate(u *firmwareUpdate) {
+	defer c.finishFirmwareUpdate(u)
+
+	if wait := time.Until(u.retrieve); wait > 0 {
+		c.log().charger.Info("Firmware download scheduled", "at", u.retrieve.Format(time.RFC3339))
+		if c.extendedFirmwareStatus(u) {
+			c.sendFirmwareStatus(u, firmwareDownloadScheduled)
+		}
+		if !u.sleep(wait) {
+			return
+		}
+	}
+
+	data, ok := c.downloadFirmware(u)
+	if !ok {
+		return
+	}
+
+	if !c.verifyFirmware(u, data) {
+		return
+	}
+
+	if wait := time.Until(u.install); wait > 0 {
+		c.log().charger.Info("Firmware installation scheduled", "at", u.install.Format(time.RFC3339))
+		if c.extendedFirmwareStatus(u) {
+			c.sendFirmwareStatus(u, firmwareInstallScheduled)
+		}
+		if !u.sleep(wait) {
+			return
+		}
+	}
+
+	// A running transaction is not interrupted: install once it has ended
+	if c.IsCharging() {
+		c.log().charger.Info("Firmware installation waits for the transaction to end")
+		for c.IsCharging() {
+			if !u.sleep(time.Second) {
+				return
+			}
+		}
+	}
+
+	c.mu.Lock()
+	select {
+	case <-u.cancelCh:
+		c.mu.Unlock()
+		return
+	default:
+	}
+	u.installing = true
+	c.mu.Unlock()
+
+	c.sendFirmwareStatus(u, firmwareInstalling)
+	time.Sleep(c.config.GetFirmwareInstallDuration())
+
+	if c.config.GetFirmwareFailAt() == config.FirmwareFailInstall {
+		c.log().charger.Warn("Firmware installation failed (simulated)")
+		c.sendFirmwareStatus(u, firmwareInstallationFailed)
+		return
+	}
+
+	version := firmwareVersionFromLocation(u.location)
+	c.mu.Lock()
+	c.firmwareVersion = version
+	c.mu.Unlock()
+	c.log().charger.Info("Firmware installed", "version", version)
+
+	if c.extendedFirmwareStatus(u) {
+		c.sendFirmwareStatus(u, firmwareInstallRebooting)
+	}
+	if err := c.reboot(bootReasonFirmwareUpdate); err != nil {
+		c.log().charger.Warn("Reboot after firmware update failed", logging.Err(err))
+	}
+	c.sendFirmwareStatus(u, firmwareInstalled)
+}
+
+// downloadFirmware downloads the firmware file, retrying as requested by the
+// server. It returns false when all attempts failed or the update was cancelled.
+func (c *Charger) downloadFirmware(u *firmwareUpdate) ([]byte, bool) {
+	attempts := u.retries + 1
+	for attempt := 1; ; attempt++ {
+		c.sendFirmwareStatus(u, firmwareDownloading)
+
+		data, err := c.fetchFirmware(u.location)
+		if err == nil {
+			c.log().charger.Info("Firmware downloaded", "bytes", len(data))
+			c.sendFirmwareStatus(u, firmwareDownloaded)
+			return data, true
+		}
+
+		c.log().charger.Warn("Firmware download failed", "attempt", attempt, "attempts", attempts, logging.Err(err))
+		if attempt >= attempts {
+			c.sendFirmwareStatus(u, firmwareDownloadFailed)
+			return nil, false
+		}
+		if !u.sleep(u.retryInterval) {
+			return nil, false
+		}
+	}
+}
+
+// fetchFirmware downloads location over HTTP(S), using the charger's TLS
+// settings. A "#sha256=..." fragment is a checksum, not part of the request.
+func (c *Charger) fetchFirmware(location string) ([]byte, error) {
+	if c.config.GetFirmwareFailAt() == config.FirmwareFailDownload {
+		return nil, fmt.Errorf("simulated download failure")
+	}
+
+	u, err := url.Parse(location)
+	if err != nil {
+		return nil, fmt.Errorf("invalid location: %w", err)
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return nil, fmt.Errorf("unsupported scheme %q (only http and https)", u.Scheme)
+	}
+	u.Fragment = ""
+
+	client := &http.Client{
+		Timeout: firmwareDownloadTimeout,
+		Transport: &http.Transport{
+			Proxy:           http.ProxyFromEnvironment,
+			TLSClientConfig: c.tlsConfig,
+		},
+	}
+
+	resp, err := client.Get(u.String())
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("server responded %s", resp.Status)
+	}
+	return io.ReadAll(resp.Body)
+}
+
+// verifyFirmware checks the downloaded file against the checksum in the
+// location fragment and the signature, if any
+func (c *Charger) verifyFirmware(u *firmwareUpdate, data []byte) bool {
+	// Plain OCPP 1.6 has no verification statuses
+	verificationFailed := firmwareInstallationFailed
+	signatureFailed := firmwareInstallationFailed
+	if c.extendedFirmwareStatus(u) {
+		verificationFailed = firmwareInstallVerificationFailed
+		signatureFailed = firmwareInvalidSignature
+	}
+
+	if c.config.GetFirmwareFailAt() == config.FirmwareFailVerify {
+		c.log().charger.Warn("Firmware verification failed (simulated)")
+		if u.signingCertificate != nil {
+			c.RaiseSecurityEvent(securityEventInvalidFirmwareSignature, "Simulated verification failure")
+			c.sendFirmwareStatus(u, signatureFailed)
+		} else {
+			c.sendFirmwareStatus(u, verificationFailed)
+		}
+		return false
+	}
+
+	if want := checksumFromLocation(u.location); want != "" {
+		sum := sha256.Sum256(data)
+		if got := hex.EncodeToString(sum[:]); !strings.EqualFold(got, want) {
+			c.log().charger.Warn("Firmware checksum mismatch", "expected_sha256", want, "got_sha256", got)
+			c.sendFirmwareStatus(u, verificationFailed)
+			return false
+		}
+		c.log().charger.Info("Firmware checksum verified")
+	}
+
+	if u.signingCertificate != nil {
+		if err := verifyFirmwareSignature(u.signingCertificate, u.signature, data); err != nil {
+			c.log().charger.Warn("Firmware signature invalid", logging.Err(err))
+			c.RaiseSecurityEvent(securityEventInvalidFirmwareSignature, err.Error())
+			c.sendFirmwareStatus(u, signatureFailed)
+			return false
+		}
+		c.log().charger.Info("Firmware signature verified")
+		c.sendFirmwareStatus(u, firmwareSignatureVerified)
+	}
+	return true
+}
+
+// checksumFromLocation returns the hex SHA-256 given as "#sha256=<hex>" in the
+// firmware location, or "" if none
+func checksumFromLocation(location string) string {
+	u, err := url.Parse(location)
+	if err != nil {
+		return ""
+	}
+	sum, ok := strings.CutPrefix(u.Fragment, "sha256=")
+	if !ok {
+		return ""
+	}
+	return sum
+}
+
+// firmwareVersionFromLocation derives the installed version from the firmware
+// file name, e.g. ".../charger-2.1.0.bin" installs "2.1.0"
+func firmwareVersionFromLocation(location string) string {
+	name := location
+	if u, err := url.Parse(location); err == nil {
+		name = u.Path
+	}
+	name = path.Base(name)
+	if version := firmwareVersionPattern.FindString(name); version != "" {
+		return version
+	}
+	return strings.TrimSuffix(name, path.Ext(name))
+}
+
+// parseSigningCertificate parses the PEM firmware signing certificate and
+// checks it against the installed manufacturer root certificates
+func (c *Charger) parseSigningCertificate(certPEM string) (*x509.Certificate, error) {
+	block, _ := pem.Decode([]byte(certPEM))
+	if block == nil {
+		return nil, fmt.Errorf("signing certificate is not PEM encoded")
+	}
+	cert, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse signing certificate: %w", err)
+	}
+	now := time.Now()
+	if now.Before(cert.NotBefore) || now.After(cert.NotAfter) {
+		return nil, fmt.Errorf("signing certificate is not valid at %s", now.UTC().Format(time.RFC3339))
+	}
+	if err := c.verifySigningCertificate(cert); err != nil {
+		return nil, err
+	}
+	return cert, nil
+}
+
+// verifyFirmwareSignature checks the base64 signature of the SHA-256 digest of
+// data with the signing certificate's public key (ECDSA or RSA PKCS#1 v1.5)
+func verifyFirmwareSignature(cert *x509.Certificate, signature string, data []byte) error {
+	sig, err := base64.StdEncoding.DecodeString(signature)
+	if err != nil {
+		return fmt.Errorf("signature is not valid base64: %w", err)
+	}
+	digest := sha256.Sum256(data)
+
+	switch pub := cert.PublicKey.(type) {
+	case *ecdsa.PublicKey:
+		if !ecdsa.VerifyASN1(pub, digest[:], sig) {
+			return fmt.Errorf("ECDSA signature does not match")
+		}
+	case *rsa.PublicKey:
+		if err := rsa.VerifyPKCS1v15(pub, crypto.SHA256, digest[:], sig); err != nil {
+			return fmt.Errorf("RSA signature does not match: %w", err)
+		}
+	default:
+		return fmt.Errorf("unsupported signing key type %T", pub)
+	}
+	return nil
+}
+
+// parseFirmwareDate parses a retrieve/install date; an invalid date means now
+func (c *Charger) parseFirmwareDate(field, value string) time.Time {
+	if value == "" {
+		return time.Time{}
+	}
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		c.log().charger.Warn("Invalid date, using now", "field", field, "value", value, logging.Err(err))
+		return time.Now()
+	}
+	return t
+}
+
+// sendFirmwareStatus records the update status and reports it to the server
+func (c *Charger) sendFirmwareStatus(u *firmwareUpdate, status string) {
+	c.mu.Lock()
+	u.status = status
+	isConnected := c.isConnected
+	c.mu.Unlock()
+
+	if !isConnected {
+		c.log().charger.Info("Firmware status not sent, offline", "status", status)
+		return
+	}
+	if err := c.sendFirmwareStatusNotification(status, u.requestId, u.signed); err != nil {
+		c.log().charger.Warn("FirmwareStatusNotification error", logging.Err(err))
+	}
+}
+
+// sendTriggeredFirmwareStatus reports the status of the update in progress,
+// or Idle, in response to a TriggerMessage
+func (c *Charger) sendTriggeredFirmwareStatus(signed bool) error {
+	c.mu.RLock()
+	status := firmwareIdle
+	requestId := 0
+	if u := c.firmwareUpdate; u != nil && u.status != "" {
+		status = u.status
+		requestId = u.requestId
+	}
+	c.mu.RUnlock()
+
+	return c.sendFirmwareStatusNotification(status, requestId, signed)
+}
+
+// sendFirmwareStatusNotification sends FirmwareStatusNotification, or
+// SignedFirmwareStatusNotification for a signed OCPP 1.6 update
+func (c *Charger) sendFirmwareStatusNotification(status string, requestId int, signed bool) error {
+	var err error
+	switch {
+	case c.config.IsOCPP16() && signed:
+		req := v16.SignedFirmwareStatusNotificationRequest{
+			Status:    status,
+			RequestId: requestId,
+		}
+		_, err = c.sendCall(v16.ActionSignedFirmwareStatusNotification, req)
+	case c.config.IsOCPP16():
+		req := v16.FirmwareStatusNotificationRequest{
+			Status: status,
+		}
+		_, err = c.sendCall(v16.ActionFirmwareStatusNotification, req)
+	default:
+		req := v201.FirmwareStatusNotificationRequest{
+			Status:    status,
+			RequestId: requestId,
+		}
+		_, err = c.sendCall(v201.ActionFirmwareStatusNotification, req)
+	}
+	if err != nil {
+		return fmt.Errorf("FirmwareStatusNotification failed: %w", err)
+	}
+
+	c.log().charger.Info("FirmwareStatusNotification sent", "status", status)
+	return nil
+}
+
+// handleUpdateFirmwareV16 handles UpdateFirmware from server (OCPP 1.6)
+func (c *Charger) handleUpdateFirmwareV16(uniqueId string, payload json.RawMessage) {
+	var req v16.UpdateFirmwareRequest
+	if err := json.Unmarshal(payload, &req); err != nil {
+		c.log().charger.Error("Failed to parse UpdateFirmware", logging.Action("UpdateFirmware"), logging.UniqueID(uniqueId), logging.Err(err))
+		return
+	}
+
+	c.log().charger.Info("Received UpdateFirmware", logging.Action("UpdateFirmware"), logging.UniqueID(uniqueId), "location", req.Location, "retrieve_date", req.RetrieveDate, "retries", req.Retries, "retry_interval", req.RetryInterval)
+
+	u := newFirmwareUpdate(req.Location, c.parseFirmwareDate("retrieveDate", req.RetrieveDate), req.Retries, req.RetryInterval)
+	status := c.acceptFirmwareUpdate(u)
+
+	if err := c.sendCallResult(uniqueId, v16.UpdateFirmwareResponse{}); err != nil {
+		c.log().charger.Error("Failed to send UpdateFirmware response", logging.Action("UpdateFirmware"), logging.UniqueID(uniqueId), logging.Err(err))
+	}
+
+	// OCPP 1.6 has no response status: an update that cannot be started is only logged
+	if status == "Rejected" {
+		c.log().charger.Info("UpdateFirmware ignored: another firmware is being installed")
+		return
+	}
+	go c.runFirmwareUpdate(u)
+}
+
+// handleSignedUpdateFirmwareV16 handles SignedUpdateFirmware from server
+// (OCPP 1.6 Security Whitepaper)
+func (c *Charger) handleSignedUpdateFirmwareV16(uniqueId string, payload json.RawMessage) {
+	var req v16.SignedUpdateFirmwareRequest
+	if err := json.Unmarshal(payload, &req); err != nil {
+		c.log().charger.Error("Failed to parse SignedUpdateFirmware", logging.Action("SignedUpdateFirmware"), logging.UniqueID(uniqueId), logging.Err(err))
+		return
+	}
+
+	c.log().charger.Info("Received SignedUpdateFirmware", logging.Action("SignedUpdateFirmware"), logging.UniqueID(uniqueId), "request_id", req.RequestId, "location", req.Firmware.Location, "retrieve_date_time", req.Firmware.RetrieveDateTime)
+
+	var u *firmwareUpdate
+	status := "InvalidCertificate"
+	if cert, err := c.parseSigningCertificate(req.Firmware.SigningCertificate); err != nil {
+		c.log().charger.Warn("SignedUpdateFirmware rejected", logging.Err(err))
+		c.RaiseSecurityEvent(securityEventInvalidFirmwareSigningCertificate, err.Error())
+	} else {
+		u = newFirmwareUpdate(req.Firmware.Location, c.parseFirmwareDate("retrieveDateTime", req.Firmware.RetrieveDateTime), req.Retries, req.RetryInterval)
+		u.requestId = req.RequestId
+		u.signed = true
+		u.install = c.parseFirmwareDate("installDateTime", req.Firmware.InstallDateTime)
+		u.signingCertificate = cert
+		u.signature = req.Firmware.Signature
+		status = c.acceptFirmwareUpdate(u)
+	}
+
+	resp := v16.SignedUpdateFirmwareResponse{
+		Status: status,
+	}
+
+	if err := c.sendCallResult(uniqueId, resp); err != nil {
+		c.log().charger.Error("Failed to send SignedUpdateFirmware response", logging.Action("SignedUpdateFirmware"), logging.UniqueID(uniqueId), logging.Err(err))
+	}
+
+	if status == "Accepted" || status == "AcceptedCanceled" {
+		go c.runFirmwareUpdate(u)
+	}
+}
+
+// handleUpdateFirmwareV201 handles UpdateFirmware from server (OCPP 2.0.1)
+func (c *Charger) handleUpdateFirmwareV201(uniqueId string, payload json.RawMessage) {
+	var req v201.UpdateFirmwareRequest
+	if err := json.Unmarshal(payload, &req); err != nil {
+		c.log().charger.Error("Failed to parse UpdateFirmware", logging.Action("UpdateFirmware"), logging.UniqueID(uniqueId), logging.Err(err))
+		return
+	}
+
+	c.log().charger.Info("Received UpdateFirmware", logging.Action("UpdateFirmware"), logging.UniqueID(uniqueId), "request_id", req.RequestId, "location", req.Firmware.Location, "retrieve_date_time", req.Firmware.RetrieveDateTime)
+
+	u := newFirmwareUpdate(req.Firmware.Location, c.parseFirmwareDate("retrieveDateTime", req.Firmware.RetrieveDateTime), req.Retries, req.RetryInterval)
+	u.requestId = req.RequestId
+	u.install = c.parseFirmwareDate("installDateTime", req.Firmware.InstallDateTime)
+	u.signature = req.Firmware.Signature
+
+	var status string
+	var statusInfo *v201.StatusInfo
+	// The signature is only verified when a signing certificate is given
+	if req.Firmware.SigningCertificate != "" {
+		cert, err := c.parseSigningCertificate(req.Firmware.SigningCertificate)
+		if err != nil {
+			c.log().charger.Warn("UpdateFirmware rejected", logging.Err(err))
+			c.RaiseSecurityEvent(securityEventInvalidFirmwareSigningCertificate, err.Error())
+			status = "InvalidCertificate"
+			statusInfo = &v201.StatusInfo{ReasonCode: "InvalidCertificate", AdditionalInfo: err.Error()}
+		}
+		u.signingCertificate = cert
+	}
+	if status == "" {
+		status = c.acceptFirmwareUpdate(u)
+		if status == "Rejected" {
+			statusInfo = &v201.StatusInfo{ReasonCode: "InstallInProgress"}
+		}
+	}
+
+	resp := v201.UpdateFirmwareResponse{
+		Status:     status,
+		StatusInfo: statusInfo,
+	}
+
+	if err := c.sendCallResult(uniqueId, resp); err != nil {
+		c.log().charger.Error("Failed to send UpdateFirmware response", logging.Action("UpdateFirmware"), logging.UniqueID(uniqueId), logging.Err(err))
+	}
+
+	if status == "Accepted" || status == "AcceptedCanceled" {
+		go c.runFirmwareUpdate(u)
+	}
+}
@@ -3,9 +3,9 @@ package charger
 import (
 	"encoding/json"
 	"fmt"
-	"log"
 	"time"
 
+	"github.com/weilun-shrimp/wlgo_ocpp_charger_simulator/logging"
 	"github.com/weilun-shrimp/wlgo_ocpp_charger_simulator/ocpp/v16"
 	"github.com/weilun-shrimp/wlgo_ocpp_charger_simulator/ocpp/v201"
 )
@@ -17,7 +17,7 @@ func (c *Charger) Heartbeat() error {
 	c.mu.RUnlock()
 
 	if !isConnected {
-		return fmt.Errorf("not connected to server")
+		return ErrNotConnected
 	}
 
 	if c.config.IsOCPP16() {
@@ -44,9 +44,13 @@ func (c *Charger) heartbeatV16() error {
 		if err := json.Unmarshal(raw[2], &heartbeatResp); err != nil {
 			return fmt.Errorf("failed to parse Heartbeat response: %w", err)
 		}
-		log.Printf("Heartbeat response: currentTime=%s", heartbeatResp.CurrentTime)
+		c.log().heartbeat.Info("Heartbeat response", "current_time", heartbeatResp.CurrentTime)
 	}
 
+	c.mu.Lock()
+	c.lastHeartbeat = time.Now()
+	c.mu.Unlock()
+
 	return nil
 }
 
@@ -68,9 +72,13 @@ func (c *Charger) heartbeatV201() error {
 		if err := json.Unmarshal(raw[2], &heartbeatResp); err != nil {
 			return fmt.Errorf("failed to parse Heartbeat response: %w", err)
 		}
-		log.Printf("Heartbeat response: currentTime=%s", heartbeatResp.CurrentTime)
+		c.log().heartbeat.Info("Heartbeat response", "current_time", heartbeatResp.CurrentTime)
 	}
 
+	c.mu.Lock()
+	c.lastHeartbeat = time.Now()
+	c.mu.Unlock()
+
 	return nil
 }
 
@@ -80,9 +88,13 @@ func (c *Charger) StartHeartbeatLoop() {
 	interval := c.heartbeatInterval
 	if interval <= 0 {
 		c.mu.Unlock()
-		log.Printf("Heartbeat disabled (interval=%d)", interval)
+		c.log().heartbeat.Info("Heartbeat disabled", "interval_s", interval)
 		return
 	}
+	// Replace a loop started by an earlier BootNotification
+	if c.heartbeatStopCh != nil {
+		close(c.heartbeatStopCh)
+	}
 	c.heartbeatStopCh = make(chan struct{})
 	stopCh := c.heartbeatStopCh
 	c.mu.Unlock()
@@ -90,16 +102,16 @@ func (c *Charger) StartHeartbeatLoop() {
 	ticker := time.NewTicker(time.Duration(interval) * time.Second)
 	defer ticker.Stop()
 
-	log.Printf("Heartbeat loop started (interval=%ds)", interval)
+	c.log().heartbeat.Info("Heartbeat loop started", "interval_s", interval)
 
 	for {
 		select {
 		case <-stopCh:
-			log.Printf("Heartbeat loop stopped")
+			c.log().heartbeat.Info("Heartbeat loop stopped")
 			return
 		case <-ticker.C:
 			if err := c.Heartbeat(); err != nil {
-				log.Printf("Heartbeat error: %v", err)
+				c.log().heartbeat.Warn("Heartbeat error", logging.Err(err))
 			}
 		}
 	}
@@ -121,5 +133,20 @@ func (c *Charger) SetHeartbeatInterval(interval int) {
 	c.mu.Lock()
 	c.heartbeatInterval = interval
 	c.mu.Unlock()
-	log.Printf("Heartbeat interval set to %d seconds", interval)
+	c.log().heartbeat.Info("Heartbeat interval set", "interval_s", interval)
+}
+
+// GetHeartbeatInterval returns the heartbeat interval in seconds
+func (c *Charger) GetHeartbeatInterval() int {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.heartbeatInterval
+}
+
+// GetLastHeartbeat returns when the server last answered a Heartbeat, zero if
+// it never did
+func (c *Charger) GetLastHeartbeat() time.Time {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.lastHeartbeat
 }
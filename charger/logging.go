@@ -0,0 +1,63 @@
+package charger
+
+import (
+	"encoding/json"
+	"log/slog"
+
+	"github.com/weilun-shrimp/wlgo_ocpp_charger_simulator/logging"
+)
+
+// loggers are the structured loggers of a charger, one per subsystem. Every
+// entry carries the charger ID and OCPP version.
+type loggers struct {
+	charger   *slog.Logger
+	wire      *slog.Logger
+	meter     *slog.Logger
+	heartbeat *slog.Logger
+	remote    *slog.Logger
+	profile   *slog.Logger
+}
+
+// newLoggers derives the subsystem loggers from base
+func newLoggers(base *slog.Logger, chargerID, ocppVersion string) *loggers {
+	l := base.With(logging.KeyChargerID, chargerID, logging.KeyOCPPVersion, ocppVersion)
+	sub := func(subsystem string) *slog.Logger {
+		return l.With(logging.KeySubsystem, subsystem)
+	}
+	return &loggers{
+		charger:   sub(logging.SubsystemCharger),
+		wire:      sub(logging.SubsystemWire),
+		meter:     sub(logging.SubsystemMeter),
+		heartbeat: sub(logging.SubsystemHeartbeat),
+		remote:    sub(logging.SubsystemRemote),
+		profile:   sub(logging.SubsystemProfile),
+	}
+}
+
+// log returns the loggers for the current OCPP version
+func (c *Charger) log() *loggers {
+	return c.loggers.Load()
+}
+
+// updateLoggers derives the loggers again after the OCPP version changed
+func (c *Charger) updateLoggers(ocppVersion string) {
+	c.loggers.Store(newLoggers(c.logger, c.config.ChargerID, ocppVersion))
+}
+
+// frameAttrs returns the unique ID and, for a Call, the action of a received
+// frame; none if it does not parse
+func frameAttrs(data string) []any {
+	var msg []json.RawMessage
+	if err := json.Unmarshal([]byte(data), &msg); err != nil || len(msg) < 3 {
+		return nil
+	}
+	var messageType int
+	var uniqueId, action string
+	json.Unmarshal(msg[0], &messageType)
+	json.Unmarshal(msg[1], &uniqueId)
+	attrs := []any{logging.UniqueID(uniqueId)}
+	if messageType == 2 && json.Unmarshal(msg[2], &action) == nil {
+		attrs = append(attrs, logging.Action(action))
+	}
+	return attrs
+}
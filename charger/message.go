@@ -1,39 +1,78 @@
 package charger
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
-	"log"
+	"log/slog"
 	"time"
 
 	"github.com/google/uuid"
+	"github.com/weilun-shrimp/wlgo_ocpp_charger_simulator/logging"
 	"github.com/weilun-shrimp/wlgo_ocpp_charger_simulator/ocpp/v16"
 	"github.com/weilun-shrimp/wlgo_ocpp_charger_simulator/ocpp/v201"
 )
 
 // receiveMessages handles incoming messages from the server
 func (c *Charger) receiveMessages() {
-	defer c.Disconnect()
+	c.mu.RLock()
+	conn := c.conn
+	stopCh := c.stopCh
+	c.mu.RUnlock()
 
+	// Only tear down the connection this loop was started for; after a
+	// reconnect (e.g. a simulated reboot) a newer connection may be active
+	defer func() {
+		c.mu.RLock()
+		current := c.conn == conn
+		c.mu.RUnlock()
+		if current {
+			c.Disconnect()
+		}
+	}()
+
+	var fragments []byte // payload of a fragmented message received so far
 	for {
 		select {
-		case <-c.stopCh:
+		case <-stopCh:
 			return
 		default:
-			msg, err := c.conn.GetNextMsg()
+			f, err := conn.GetNextFrame()
 			if err != nil {
+				select {
+				case <-stopCh:
+					return // closed by Disconnect, e.g. when the simulator exits
+				default:
+				}
 				if err == io.EOF {
-					log.Printf("Server closed connection (EOF)")
+					c.log().wire.Warn("Server closed connection (EOF)")
 				} else {
-					log.Printf("Error receiving message: %v", err)
+					c.log().wire.Error("Error receiving message", logging.Err(err))
 				}
 				return
 			}
 
-			data := msg.GetStr()
-			log.Printf("Received: %s", data)
+			// Control frames may arrive between the fragments of a message
+			if f.Opcode >= opcodeClose {
+				if !c.handleControlFrame(conn, f) {
+					return
+				}
+				continue
+			}
+			fragments = append(fragments, f.PayloadData...)
+			if !f.FIN {
+				continue
+			}
+			data := string(fragments)
+			fragments = nil
+			c.log().wire.Info("Received", append(frameAttrs(data), logging.Direction(logging.DirectionReceived), slog.String("frame", c.diagnostics.redact(data)))...)
+			c.recordFrame("Received", []byte(data))
 
+			if c.netFaults.dropInbound() {
+				c.log().wire.Warn("Network fault: inbound frame dropped")
+				continue
+			}
 			go c.handleMessage([]byte(data))
 		}
 	}
@@ -52,7 +91,7 @@ func (c *Charger) handleMessage(data []byte) {
 func (c *Charger) handleMessageV16(data []byte) {
 	messageType, uniqueId, payload, action, err := v16.ParseMessage(data)
 	if err != nil {
-		log.Printf("Failed to parse message: %v", err)
+		c.log().wire.Error("Failed to parse message", logging.Err(err))
 		return
 	}
 
@@ -62,7 +101,7 @@ func (c *Charger) handleMessageV16(data []byte) {
 	case v16.MessageTypeCallResult:
 		c.handleCallResult(uniqueId, data)
 	case v16.MessageTypeCallError:
-		log.Printf("Received CallError for %s: %s", uniqueId, string(payload))
+		c.log().wire.Warn("Received CallError", logging.UniqueID(uniqueId), "payload", string(payload))
 		c.handleCallResult(uniqueId, data)
 	}
 }
@@ -71,7 +110,7 @@ func (c *Charger) handleMessageV16(data []byte) {
 func (c *Charger) handleMessageV201(data []byte) {
 	messageType, uniqueId, payload, action, err := v201.ParseMessage(data)
 	if err != nil {
-		log.Printf("Failed to parse message: %v", err)
+		c.log().wire.Error("Failed to parse message", logging.Err(err))
 		return
 	}
 
@@ -81,7 +120,7 @@ func (c *Charger) handleMessageV201(data []byte) {
 	case v201.MessageTypeCallResult:
 		c.handleCallResult(uniqueId, data)
 	case v201.MessageTypeCallError:
-		log.Printf("Received CallError for %s: %s", uniqueId, string(payload))
+		c.log().wire.Warn("Received CallError", logging.UniqueID(uniqueId), "payload", string(payload))
 		c.handleCallResult(uniqueId, data)
 	}
 }
@@ -109,8 +148,42 @@ func (c *Charger) handleCallV16(uniqueId, action string, payload json.RawMessage
 		c.handleRemoteStopTransactionV16(uniqueId, payload)
 	case v16.ActionSetChargingProfile:
 		c.handleSetChargingProfileV16(uniqueId, payload)
+	case v16.ActionReserveNow:
+		c.handleReserveNowV16(uniqueId, payload)
+	case v16.ActionCancelReservation:
+		c.handleCancelReservationV16(uniqueId, payload)
+	case v16.ActionTriggerMessage:
+		c.handleTriggerMessageV16(uniqueId, payload)
+	case v16.ActionExtendedTriggerMessage:
+		c.handleExtendedTriggerMessageV16(uniqueId, payload)
+	case v16.ActionUnlockConnector:
+		c.handleUnlockConnectorV16(uniqueId, payload)
+	case v16.ActionReset:
+		c.handleResetV16(uniqueId, payload)
+	case v16.ActionUpdateFirmware:
+		c.handleUpdateFirmwareV16(uniqueId, payload)
+	case v16.ActionSignedUpdateFirmware:
+		c.handleSignedUpdateFirmwareV16(uniqueId, payload)
+	case v16.ActionGetDiagnostics:
+		c.handleGetDiagnosticsV16(uniqueId, payload)
+	case v16.ActionGetLog:
+		c.handleGetLogV16(uniqueId, payload)
+	case v16.ActionChangeConfiguration:
+		c.handleChangeConfigurationV16(uniqueId, payload)
+	case v16.ActionGetConfiguration:
+		c.handleGetConfigurationV16(uniqueId, payload)
+	case v16.ActionCertificateSigned:
+		c.handleCertificateSignedV16(uniqueId, payload)
+	case v16.ActionInstallCertificate:
+		c.handleInstallCertificateV16(uniqueId, payload)
+	case v16.ActionGetInstalledCertificateIds:
+		c.handleGetInstalledCertificateIdsV16(uniqueId, payload)
+	case v16.ActionDeleteCertificate:
+		c.handleDeleteCertificateV16(uniqueId, payload)
+	case v16.ActionDataTransfer:
+		c.handleDataTransfer(uniqueId, payload)
 	default:
-		log.Printf("Unknown action: %s", action)
+		c.handleUnknownCall(uniqueId, action, payload)
 	}
 }
 
@@ -123,13 +196,51 @@ func (c *Charger) handleCallV201(uniqueId, action string, payload json.RawMessag
 		c.handleRequestStopTransactionV201(uniqueId, payload)
 	case v201.ActionSetChargingProfile:
 		c.handleSetChargingProfileV201(uniqueId, payload)
+	case v201.ActionReserveNow:
+		c.handleReserveNowV201(uniqueId, payload)
+	case v201.ActionCancelReservation:
+		c.handleCancelReservationV201(uniqueId, payload)
+	case v201.ActionTriggerMessage:
+		c.handleTriggerMessageV201(uniqueId, payload)
+	case v201.ActionUnlockConnector:
+		c.handleUnlockConnectorV201(uniqueId, payload)
+	case v201.ActionReset:
+		c.handleResetV201(uniqueId, payload)
+	case v201.ActionUpdateFirmware:
+		c.handleUpdateFirmwareV201(uniqueId, payload)
+	case v201.ActionGetLog:
+		c.handleGetLogV201(uniqueId, payload)
+	case v201.ActionSetVariables:
+		c.handleSetVariablesV201(uniqueId, payload)
+	case v201.ActionGetVariables:
+		c.handleGetVariablesV201(uniqueId, payload)
+	case v201.ActionCertificateSigned:
+		c.handleCertificateSignedV201(uniqueId, payload)
+	case v201.ActionInstallCertificate:
+		c.handleInstallCertificateV201(uniqueId, payload)
+	case v201.ActionGetInstalledCertificateIds:
+		c.handleGetInstalledCertificateIdsV201(uniqueId, payload)
+	case v201.ActionDeleteCertificate:
+		c.handleDeleteCertificateV201(uniqueId, payload)
+	case v201.ActionDataTransfer:
+		c.handleDataTransfer(uniqueId, payload)
 	default:
-		log.Printf("Unknown action: %s", action)
+		c.handleUnknownCall(uniqueId, action, payload)
 	}
 }
 
 // sendCall sends a Call message and waits for response
 func (c *Charger) sendCall(action string, payload interface{}) ([]byte, error) {
+	return c.sendCallContext(context.Background(), action, payload)
+}
+
+// sendCallContext sends a Call message and waits for the response until the
+// call timeout or until ctx is done. A CallError response is returned as a
+// *CallError.
+func (c *Charger) sendCallContext(ctx context.Context, action string, payload interface{}) ([]byte, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
 	uniqueId := uuid.New().String()
 
 	var data []byte
@@ -145,23 +256,40 @@ func (c *Charger) sendCall(action string, payload interface{}) ([]byte, error) {
 		return nil, fmt.Errorf("failed to marshal message: %w", err)
 	}
 
+	c.mu.RLock()
+	conn := c.conn
+	c.mu.RUnlock()
+	if conn == nil {
+		return nil, ErrNotConnected
+	}
+	if err := c.checkRegistration(action); err != nil {
+		return nil, err
+	}
+
 	respCh := make(chan []byte, 1)
 	c.pendingMu.Lock()
 	c.pendingCalls[uniqueId] = respCh
 	c.pendingMu.Unlock()
 
-	log.Printf("Sending: %s", string(data))
-	c.conn.SendText(data)
+	c.log().wire.Info("Sending", logging.Action(action), logging.UniqueID(uniqueId), logging.Direction(logging.DirectionSent), "frame", c.diagnostics.redact(string(data)))
+	c.recordFrame("Sent", data)
+	c.writeFrame(conn, data, false)
 
 	select {
 	case resp := <-respCh:
+		if callErr := parseCallError(action, resp); callErr != nil {
+			return nil, callErr
+		}
 		return resp, nil
-	case <-time.After(30 * time.Second):
-		c.pendingMu.Lock()
-		delete(c.pendingCalls, uniqueId)
-		c.pendingMu.Unlock()
-		return nil, fmt.Errorf("timeout waiting for response")
+	case <-time.After(c.callTimeout):
+		err = ErrTimeout
+	case <-ctx.Done():
+		err = ctx.Err()
 	}
+	c.pendingMu.Lock()
+	delete(c.pendingCalls, uniqueId)
+	c.pendingMu.Unlock()
+	return nil, err
 }
 
 // sendCallResult sends a CallResult message
@@ -179,7 +307,52 @@ func (c *Charger) sendCallResult(uniqueId string, payload interface{}) error {
 		return fmt.Errorf("failed to marshal response: %w", err)
 	}
 
-	log.Printf("Sending: %s", string(data))
-	c.conn.SendText(data)
+	c.mu.RLock()
+	conn := c.conn
+	registration := c.registration
+	c.mu.RUnlock()
+	if conn == nil {
+		return ErrNotConnected
+	}
+	// A rejected charger sends nothing until the BootNotification retry
+	if registration == registrationRejected {
+		return notAccepted("response not sent: registration %s", registration)
+	}
+
+	c.log().wire.Info("Sending", logging.UniqueID(uniqueId), logging.Direction(logging.DirectionSent), "frame", c.diagnostics.redact(string(data)))
+	c.recordFrame("Sent", data)
+	c.writeFrame(conn, data, true)
+	return nil
+}
+
+// sendCallError answers a server Call with a CallError
+func (c *Charger) sendCallError(uniqueId, errorCode, errorDescription string) error {
+	var data []byte
+	var err error
+
+	if c.config.IsOCPP16() {
+		data, err = v16.MarshalCallError(uniqueId, errorCode, errorDescription, struct{}{})
+	} else {
+		data, err = v201.MarshalCallError(uniqueId, errorCode, errorDescription, struct{}{})
+	}
+
+	if err != nil {
+		return fmt.Errorf("failed to marshal CallError: %w", err)
+	}
+
+	c.mu.RLock()
+	conn := c.conn
+	registration := c.registration
+	c.mu.RUnlock()
+	if conn == nil {
+		return ErrNotConnected
+	}
+	if registration == registrationRejected {
+		return notAccepted("CallError not sent: registration %s", registration)
+	}
+
+	c.log().wire.Info("Sending", logging.UniqueID(uniqueId), logging.Direction(logging.DirectionSent), "frame", c.diagnostics.redact(string(data)))
+	c.recordFrame("Sent", data)
+	c.writeFrame(conn, data, true)
 	return nil
 }
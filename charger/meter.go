@@ -2,9 +2,9 @@ package charger
 
 import (
 	"fmt"
-	"log"
 	"time"
 
+	"github.com/weilun-shrimp/wlgo_ocpp_charger_simulator/logging"
 	"github.com/weilun-shrimp/wlgo_ocpp_charger_simulator/ocpp/v16"
 	"github.com/weilun-shrimp/wlgo_ocpp_charger_simulator/ocpp/v201"
 )
@@ -19,6 +19,22 @@ func (c *Charger) MeterValues() error {
 	}
 	// Simulate energy consumption
 	energyWh := int(currentPower * float64(c.config.MeterValuesInterval) / 3600)
+	// A deauthorized transaction kept open only receives MaxEnergyOnInvalidId
+	suspendDeauthorized := false
+	if c.deauthorized {
+		if left := c.deauthorizedEnergyLeft(); energyWh >= left {
+			energyWh = left
+			suspendDeauthorized = !c.deauthSuspended
+		}
+		if c.deauthSuspended {
+			currentPower = 0
+		}
+	}
+	// A fault suspended energy delivery
+	if c.faults.suspended {
+		energyWh = 0
+		currentPower = 0
+	}
 	c.meterValue += energyWh
 
 	// Update SOC
@@ -28,70 +44,176 @@ func (c *Charger) MeterValues() error {
 		c.soc = 100
 	}
 
-	meterValue := c.meterValue
-	soc := c.soc
-	current := c.current
-	power := currentPower
+	sample := meterSample{
+		energy:  c.meterValue,
+		voltage: c.config.Voltage,
+		current: c.current,
+		power:   currentPower,
+		soc:     c.soc,
+	}
 	transactionId := c.transactionId
 	transactionIdStr := c.transactionIdStr
+	transactionIdEvent := c.transactionIdLocked()
 	isConnected := c.isConnected
 	c.seqNo++
 	seqNo := c.seqNo
 	c.mu.Unlock()
 
-	voltage := c.config.Voltage
-
-	log.Printf("MeterValues: energy=%d Wh, voltage=%.1f V, current=%.1f A, power=%.1f W, SoC=%.1f%%", meterValue, voltage, current, power, soc)
+	c.log().meter.Info("MeterValues", "energy_wh", sample.energy, "voltage_v", sample.voltage, "current_a", sample.current, "power_w", sample.power, "soc", sample.soc)
+	c.events.publish(Event{Type: EventMeterValues, TransactionId: transactionIdEvent, Meter: sample.exported()})
 
 	// Send to server if connected
+	var err error
 	if isConnected {
 		if c.config.IsOCPP16() {
-			return c.sendMeterValuesV16(meterValue, soc, voltage, current, power, transactionId)
+			err = c.sendMeterValuesV16(sample, transactionId)
+		} else {
+			err = c.sendMeterValuesV201(sample, transactionIdStr, seqNo)
 		}
-		return c.sendMeterValuesV201(meterValue, soc, voltage, current, power, transactionIdStr, seqNo)
 	}
-	return nil
+
+	// MaxEnergyOnInvalidId reached: stop energy delivery
+	if suspendDeauthorized {
+		if suspendErr := c.suspendDeauthorized(); suspendErr != nil {
+			c.log().meter.Error("Failed to suspend deauthorized transaction", logging.Err(suspendErr))
+		}
+	}
+	return err
 }
 
-func (c *Charger) sendMeterValuesV16(meterValue int, soc, voltage, current, power float64, transactionId int) error {
+// meterSample is one reading of the measurands reported in MeterValues
+type meterSample struct {
+	energy  int     // Energy.Active.Import.Register in Wh
+	voltage float64 // Voltage in V
+	current float64 // Current.Import in A
+	power   float64 // Power.Active.Import in W
+	soc     float64 // SoC in %
+}
+
+// exported returns the sample as reported in events
+func (s meterSample) exported() *MeterSample {
+	return &MeterSample{
+		EnergyWh: s.energy,
+		VoltageV: s.voltage,
+		CurrentA: s.current,
+		PowerW:   s.power,
+		SoC:      s.soc,
+	}
+}
+
+// currentSample reads the meter without advancing it (e.g. for triggered
+// MeterValues). Power is only drawn while energy is being delivered.
+func (c *Charger) currentSample() meterSample {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	power := 0.0
+	if c.isCharging && !c.energySuspendedLocked() {
+		power = c.current * c.config.Voltage
+		if power > c.config.MaxPower {
+			power = c.config.MaxPower
+		}
+	}
+
+	return meterSample{
+		energy:  c.meterValue,
+		voltage: c.config.Voltage,
+		current: c.current,
+		power:   power,
+		soc:     c.soc,
+	}
+}
+
+// sampledValuesV16 builds the OCPP 1.6 sampled values for the given reading context
+func (s meterSample) sampledValuesV16(context string) []v16.SampledValue {
+	return []v16.SampledValue{
+		{
+			Value:     fmt.Sprintf("%d", s.energy),
+			Context:   context,
+			Measurand: "Energy.Active.Import.Register",
+			Unit:      "Wh",
+		},
+		{
+			Value:     fmt.Sprintf("%.1f", s.voltage),
+			Context:   context,
+			Measurand: "Voltage",
+			Unit:      "V",
+		},
+		{
+			Value:     fmt.Sprintf("%.1f", s.current),
+			Context:   context,
+			Measurand: "Current.Import",
+			Unit:      "A",
+		},
+		{
+			Value:     fmt.Sprintf("%.1f", s.power),
+			Context:   context,
+			Measurand: "Power.Active.Import",
+			Unit:      "W",
+		},
+		{
+			Value:     fmt.Sprintf("%.1f", s.soc),
+			Context:   context,
+			Measurand: "SoC",
+			Unit:      "Percent",
+		},
+	}
+}
+
+// sampledValuesV201 builds the OCPP 2.0.1 sampled values for the given reading context
+func (s meterSample) sampledValuesV201(context string) []v201.SampledValue {
+	return []v201.SampledValue{
+		{
+			Value:     float64(s.energy),
+			Context:   context,
+			Measurand: "Energy.Active.Import.Register",
+			UnitOfMeasure: &v201.UnitOfMeasure{
+				Unit: "Wh",
+			},
+		},
+		{
+			Value:     s.voltage,
+			Context:   context,
+			Measurand: "Voltage",
+			UnitOfMeasure: &v201.UnitOfMeasure{
+				Unit: "V",
+			},
+		},
+		{
+			Value:     s.current,
+			Context:   context,
+			Measurand: "Current.Import",
+			UnitOfMeasure: &v201.UnitOfMeasure{
+				Unit: "A",
+			},
+		},
+		{
+			Value:     s.power,
+			Context:   context,
+			Measurand: "Power.Active.Import",
+			UnitOfMeasure: &v201.UnitOfMeasure{
+				Unit: "W",
+			},
+		},
+		{
+			Value:     s.soc,
+			Context:   context,
+			Measurand: "SoC",
+			UnitOfMeasure: &v201.UnitOfMeasure{
+				Unit: "Percent",
+			},
+		},
+	}
+}
+
+func (c *Charger) sendMeterValuesV16(sample meterSample, transactionId int) error {
 	req := v16.MeterValuesRequest{
 		ConnectorId:   c.config.ConnectorID,
 		TransactionId: transactionId,
 		MeterValue: []v16.MeterValueEntry{
 			{
-				Timestamp: time.Now().UTC().Format(time.RFC3339),
-				SampledValue: []v16.SampledValue{
-					{
-						Value:     fmt.Sprintf("%d", meterValue),
-						Context:   "Sample.Periodic",
-						Measurand: "Energy.Active.Import.Register",
-						Unit:      "Wh",
-					},
-					{
-						Value:     fmt.Sprintf("%.1f", voltage),
-						Context:   "Sample.Periodic",
-						Measurand: "Voltage",
-						Unit:      "V",
-					},
-					{
-						Value:     fmt.Sprintf("%.1f", current),
-						Context:   "Sample.Periodic",
-						Measurand: "Current.Import",
-						Unit:      "A",
-					},
-					{
-						Value:     fmt.Sprintf("%.1f", power),
-						Context:   "Sample.Periodic",
-						Measurand: "Power.Active.Import",
-						Unit:      "W",
-					},
-					{
-						Value:     fmt.Sprintf("%.1f", soc),
-						Context:   "Sample.Periodic",
-						Measurand: "SoC",
-						Unit:      "Percent",
-					},
-				},
+				Timestamp:    time.Now().UTC().Format(time.RFC3339),
+				SampledValue: sample.sampledValuesV16("Sample.Periodic"),
 			},
 		},
 	}
@@ -101,11 +223,18 @@ func (c *Charger) sendMeterValuesV16(meterValue int, soc, voltage, current, powe
 		return fmt.Errorf("MeterValues failed: %w", err)
 	}
 
-	log.Printf("MeterValues sent: energy=%d Wh, SoC=%.1f%%", meterValue, soc)
+	c.log().meter.Info("MeterValues sent", logging.KeyTransactionID, transactionId, "energy_wh", sample.energy, "soc", sample.soc)
 	return nil
 }
 
-func (c *Charger) sendMeterValuesV201(meterValue int, soc, voltage, current, power float64, transactionIdStr string, seqNo int) error {
+func (c *Charger) sendMeterValuesV201(sample meterSample, transactionIdStr string, seqNo int) error {
+	chargingState := v201.ChargingStateCharging
+	c.mu.RLock()
+	if c.energySuspendedLocked() {
+		chargingState = v201.ChargingStateSuspendedEVSE
+	}
+	c.mu.RUnlock()
+
 	req := v201.TransactionEventRequest{
 		EventType:     v201.TransactionEventUpdated,
 		Timestamp:     time.Now().UTC().Format(time.RFC3339),
@@ -113,63 +242,24 @@ func (c *Charger) sendMeterValuesV201(meterValue int, soc, voltage, current, pow
 		SeqNo:         seqNo,
 		TransactionInfo: v201.Transaction{
 			TransactionId: transactionIdStr,
-			ChargingState: v201.ChargingStateCharging,
+			ChargingState: chargingState,
 		},
 		MeterValue: []v201.MeterValue{
 			{
-				Timestamp: time.Now().UTC().Format(time.RFC3339),
-				SampledValue: []v201.SampledValue{
-					{
-						Value:     float64(meterValue),
-						Context:   "Sample.Periodic",
-						Measurand: "Energy.Active.Import.Register",
-						UnitOfMeasure: &v201.UnitOfMeasure{
-							Unit: "Wh",
-						},
-					},
-					{
-						Value:     voltage,
-						Context:   "Sample.Periodic",
-						Measurand: "Voltage",
-						UnitOfMeasure: &v201.UnitOfMeasure{
-							Unit: "V",
-						},
-					},
-					{
-						Value:     current,
-						Context:   "Sample.Periodic",
-						Measurand: "Current.Import",
-						UnitOfMeasure: &v201.UnitOfMeasure{
-							Unit: "A",
-						},
-					},
-					{
-						Value:     power,
-						Context:   "Sample.Periodic",
-						Measurand: "Power.Active.Import",
-						UnitOfMeasure: &v201.UnitOfMeasure{
-							Unit: "W",
-						},
-					},
-					{
-						Value:     soc,
-						Context:   "Sample.Periodic",
-						Measurand: "SoC",
-						UnitOfMeasure: &v201.UnitOfMeasure{
-							Unit: "Percent",
-						},
-					},
-				},
+				Timestamp:    time.Now().UTC().Format(time.RFC3339),
+				SampledValue: sample.sampledValuesV201("Sample.Periodic"),
 			},
 		},
 	}
 
-	_, err := c.sendCall(v201.ActionTransactionEvent, req)
+	resp, err := c.sendCall(v201.ActionTransactionEvent, req)
 	if err != nil {
 		return fmt.Errorf("TransactionEvent (Updated) failed: %w", err)
 	}
 
-	log.Printf("TransactionEvent (Updated) sent: energy=%d Wh, SoC=%.1f%%", meterValue, soc)
+	c.log().meter.Info("TransactionEvent (Updated) sent", logging.KeyTransactionID, transactionIdStr, "energy_wh", sample.energy, "soc", sample.soc)
+
+	c.checkTransactionEventResponseV201(resp)
 	return nil
 }
 
@@ -183,16 +273,16 @@ func (c *Charger) StartMeterValuesLoop() {
 	stopCh := c.meterStopCh
 	c.mu.RUnlock()
 
-	log.Printf("Meter loop started")
+	c.log().meter.Info("Meter loop started")
 
 	for {
 		select {
 		case <-stopCh:
-			log.Printf("Meter loop stopped")
+			c.log().meter.Info("Meter loop stopped")
 			return
 		case <-ticker.C:
 			if err := c.MeterValues(); err != nil {
-				log.Printf("MeterValues error: %v", err)
+				c.log().meter.Warn("MeterValues error", logging.Err(err))
 			}
 		}
 	}
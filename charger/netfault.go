@@ -0,0 +1,257 @@
+package charger
+
+import (
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"math/rand"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/weilun-shrimp/wlgo_ocpp_charger_simulator/config"
+	"github.com/weilun-shrimp/wlgo_ocpp_charger_simulator/logging"
+	"github.com/weilun-shrimp/wlgows/connection"
+)
+
+// reorderHoldTime is how long a frame held back for reordering waits for a
+// following frame before it is sent anyway
+const reorderHoldTime = 2 * time.Second
+
+// invalidMessageTypeId replaces the MessageTypeId of frames sent with a wrong type
+const invalidMessageTypeId = 9
+
+// networkFaults injects faults into the frames exchanged with the server. It
+// has its own lock so frames can be written while c.mu is held.
+type networkFaults struct {
+	mu         sync.Mutex
+	settings   config.NetworkFaultsConfig
+	rng        *rand.Rand
+	lastCallId string     // unique ID of the last Call sent, reused by ReuseUniqueId
+	held       *heldFrame // frame held back for reordering, nil if none
+}
+
+// heldFrame is an outbound frame held back to be sent after the next one
+type heldFrame struct {
+	conn  *connection.ClientConn
+	data  []byte
+	timer *time.Timer
+}
+
+// outboundFaults are the faults picked for one outbound frame
+type outboundFaults struct {
+	delay      time.Duration
+	drop       bool
+	disconnect bool
+	duplicate  bool
+	reorder    bool
+	reuseId    bool
+	wrongType  bool
+	malformed  bool
+}
+
+// newNetworkFaults creates the fault injector with the given settings
+func newNetworkFaults(settings config.NetworkFaultsConfig) *networkFaults {
+	n := &networkFaults{}
+	n.set(settings)
+	return n
+}
+
+// set replaces the settings. The random source is reseeded when the seed
+// changes, so a run with the same seed injects the same faults.
+func (n *networkFaults) set(settings config.NetworkFaultsConfig) {
+	if n.rng == nil || settings.Seed != n.settings.Seed {
+		seed := settings.Seed
+		if seed == 0 {
+			seed = time.Now().UnixNano()
+		}
+		n.rng = rand.New(rand.NewSource(seed))
+	}
+	n.settings = settings
+}
+
+// roll returns true with the given chance in percent. n.mu must be held.
+func (n *networkFaults) roll(percent int) bool {
+	return percent > 0 && n.rng.Intn(100) < percent
+}
+
+// pickOutbound decides the faults for an outbound frame
+func (n *networkFaults) pickOutbound(callResult, charging bool) outboundFaults {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	s := n.settings
+	var f outboundFaults
+	f.delay = time.Duration(s.LatencyMs) * time.Millisecond
+	if s.JitterMs > 0 {
+		f.delay += time.Duration(n.rng.Intn(s.JitterMs+1)) * time.Millisecond
+	}
+	if callResult {
+		f.delay += time.Duration(s.CallResultDelayMs) * time.Millisecond
+	}
+	f.disconnect = charging && n.roll(s.Disconnect)
+	f.drop = n.roll(s.DropOutbound)
+	f.duplicate = n.roll(s.Duplicate)
+	f.reorder = n.roll(s.Reorder)
+	f.reuseId = n.roll(s.ReuseUniqueId)
+	f.wrongType = n.roll(s.WrongMessageType)
+	f.malformed = n.roll(s.Malformed)
+	return f
+}
+
+// dropInbound returns true if a received frame is to be dropped
+func (n *networkFaults) dropInbound() bool {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	return n.roll(n.settings.DropInbound)
+}
+
+// rewrite applies the unique ID and message type faults to a frame and
+// remembers the unique ID of Calls
+func (n *networkFaults) rewrite(data []byte, f outboundFaults, logger *slog.Logger) []byte {
+	var raw []json.RawMessage
+	if err := json.Unmarshal(data, &raw); err != nil || len(raw) < 3 {
+		return data
+	}
+	var messageType int
+	json.Unmarshal(raw[0], &messageType)
+
+	changed := false
+	if messageType == 2 {
+		n.mu.Lock()
+		previous := n.lastCallId
+		var uniqueId string
+		json.Unmarshal(raw[1], &uniqueId)
+		n.lastCallId = uniqueId
+		n.mu.Unlock()
+
+		if f.reuseId && previous != "" {
+			logger.Warn("Network fault: Call sent with a reused unique ID", logging.UniqueID(previous))
+			raw[1], _ = json.Marshal(previous)
+			changed = true
+		}
+	}
+	if f.wrongType {
+		logger.Warn("Network fault: MessageTypeId replaced", "message_type_id", messageType, "sent_as", invalidMessageTypeId)
+		raw[0], _ = json.Marshal(invalidMessageTypeId)
+		changed = true
+	}
+	if !changed {
+		return data
+	}
+	rewritten, err := json.Marshal(raw)
+	if err != nil {
+		return data
+	}
+	return rewritten
+}
+
+// NetworkFaults returns the active network fault settings
+func (c *Charger) NetworkFaults() config.NetworkFaultsConfig {
+	c.netFaults.mu.Lock()
+	defer c.netFaults.mu.Unlock()
+	return c.netFaults.settings
+}
+
+// SetNetworkFaults replaces the network fault settings at runtime
+func (c *Charger) SetNetworkFaults(settings config.NetworkFaultsConfig) error {
+	if err := settings.Validate(); err != nil {
+		return err
+	}
+	c.netFaults.mu.Lock()
+	c.netFaults.set(settings)
+	c.netFaults.mu.Unlock()
+	c.log().wire.Info("Network faults set", "settings", fmt.Sprintf("%+v", settings))
+	return nil
+}
+
+// KillConnection drops the TCP connection abruptly (reset, no WebSocket close
+// frame), like a lost network link. A running transaction is not stopped first.
+func (c *Charger) KillConnection() error {
+	c.mu.RLock()
+	conn := c.conn
+	c.mu.RUnlock()
+	if conn == nil {
+		return ErrNotConnected
+	}
+	c.log().wire.Info("Dropping the TCP connection")
+	killConnection(conn)
+	return nil
+}
+
+// killConnection resets the TCP connection under conn. The receive loop
+// notices the broken connection and disconnects.
+func killConnection(conn *connection.ClientConn) {
+	raw := conn.Conn.Conn
+	if tlsConn, ok := raw.(*tls.Conn); ok {
+		raw = tlsConn.NetConn()
+	}
+	if tcp, ok := raw.(*net.TCPConn); ok {
+		tcp.SetLinger(0)
+	}
+	raw.Close()
+}
+
+// writeFrame sends a frame to the server through the network fault injector.
+// callResult marks responses to server Calls, which get the extra CallResult delay.
+func (c *Charger) writeFrame(conn *connection.ClientConn, data []byte, callResult bool) {
+	n := c.netFaults
+	f := n.pickOutbound(callResult, c.IsCharging())
+
+	if f.delay > 0 {
+		c.log().wire.Warn("Network fault: frame delayed", "delay", f.delay)
+		time.Sleep(f.delay)
+	}
+	if f.disconnect {
+		c.log().wire.Warn("Network fault: dropping the TCP connection")
+		killConnection(conn)
+		return
+	}
+	if f.drop {
+		c.log().wire.Warn("Network fault: outbound frame dropped")
+		return
+	}
+
+	data = n.rewrite(data, f, c.log().wire)
+	if f.malformed {
+		c.log().wire.Warn("Network fault: frame truncated to malformed JSON")
+		data = data[:len(data)/2]
+	}
+
+	n.mu.Lock()
+	held := n.held
+	if f.reorder && held == nil {
+		h := &heldFrame{conn: conn, data: data}
+		h.timer = time.AfterFunc(reorderHoldTime, func() { n.releaseHeld(h) })
+		n.held = h
+		n.mu.Unlock()
+		c.log().wire.Warn("Network fault: frame held back to be sent out of order")
+		return
+	}
+	n.held = nil
+	n.mu.Unlock()
+
+	conn.SendText(data)
+	if f.duplicate {
+		c.log().wire.Warn("Network fault: frame sent twice")
+		conn.SendText(data)
+	}
+	if held != nil {
+		held.timer.Stop()
+		c.log().wire.Warn("Network fault: held frame sent after a later one")
+		held.conn.SendText(held.data)
+	}
+}
+
+// releaseHeld sends a held frame no later frame overtook
+func (n *networkFaults) releaseHeld(h *heldFrame) {
+	n.mu.Lock()
+	if n.held != h {
+		n.mu.Unlock()
+		return
+	}
+	n.held = nil
+	n.mu.Unlock()
+	h.conn.SendText(h.data)
+}
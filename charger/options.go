@@ -0,0 +1,53 @@
+package charger
+
+import (
+	"crypto/tls"
+	"log/slog"
+	"time"
+)
+
+// defaultCallTimeout is how long a Call waits for the server's response
+const defaultCallTimeout = 30 * time.Second
+
+// Option customizes a Charger created with New
+type Option func(*Charger)
+
+// WithCallTimeout sets how long a Call waits for the server's response
+// (default: 30 seconds)
+func WithCallTimeout(timeout time.Duration) Option {
+	return func(c *Charger) {
+		c.callTimeout = timeout
+	}
+}
+
+// WithTLSConfig sets the TLS configuration used for wss:// servers instead of
+// the one built from the tls block of the configuration
+func WithTLSConfig(tlsConfig *tls.Config) Option {
+	return func(c *Charger) {
+		c.tlsConfig = tlsConfig
+	}
+}
+
+// WithDataTransferHandler sets the handler of incoming DataTransfer requests
+// with vendorId and messageId (see RegisterDataTransferHandler)
+func WithDataTransferHandler(vendorId, messageId string, handler DataTransferHandler) Option {
+	return func(c *Charger) {
+		c.RegisterDataTransferHandler(vendorId, messageId, handler)
+	}
+}
+
+// WithEventHandler calls handler for every event (see OnEvent), including the
+// ones raised while the saved state is restored in New
+func WithEventHandler(handler func(Event)) Option {
+	return func(c *Charger) {
+		c.OnEvent(handler)
+	}
+}
+
+// WithLogger sets the structured logger (default: slog.Default()). The charger
+// adds the charger_id, ocpp_version and subsystem fields to its entries.
+func WithLogger(logger *slog.Logger) Option {
+	return func(c *Charger) {
+		c.logger = logger
+	}
+}
@@ -0,0 +1,134 @@
+package charger
+
+import (
+	"context"
+	"time"
+
+	"github.com/weilun-shrimp/wlgo_ocpp_charger_simulator/logging"
+	"github.com/weilun-shrimp/wlgo_ocpp_charger_simulator/ocpp/v16"
+)
+
+// Registration states from the BootNotification response (the same in both
+// versions). Until the first response the charger is not registered ("").
+const (
+	registrationAccepted = string(v16.RegistrationAccepted)
+	registrationPending  = string(v16.RegistrationPending)
+	registrationRejected = string(v16.RegistrationRejected)
+)
+
+// defaultBootRetryInterval is the BootNotification retry delay when a Pending
+// or Rejected response has no interval
+const defaultBootRetryInterval = 60 * time.Second
+
+// GetRegistrationStatus returns the status of the last BootNotification
+// response: Accepted, Pending or Rejected, or "" if not registered yet
+func (c *Charger) GetRegistrationStatus() string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.registration
+}
+
+// checkRegistration returns an error if a Call with the given action may not be
+// sent in the current registration state. Only BootNotification is sent before
+// the charger is accepted; while Pending, the messages requested with
+// TriggerMessage are sent too.
+func (c *Charger) checkRegistration(action string) error {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	switch {
+	case action == v16.ActionBootNotification, c.registration == registrationAccepted:
+		return nil
+	case c.registration == registrationPending && c.triggeredCalls > 0:
+		return nil
+	case c.registration == "":
+		return notAccepted("%s not sent: not registered, BootNotification first", action)
+	}
+	return notAccepted("%s not sent: registration %s", action, c.registration)
+}
+
+// sendTriggered sends the messages of an accepted TriggerMessage, which may be
+// sent while the registration is Pending
+func (c *Charger) sendTriggered(send func() error) error {
+	c.mu.Lock()
+	c.triggeredCalls++
+	c.mu.Unlock()
+	defer func() {
+		c.mu.Lock()
+		c.triggeredCalls--
+		c.mu.Unlock()
+	}()
+	return send()
+}
+
+// applyBootResponse updates the registration from a BootNotification response.
+// On Accepted, interval is the heartbeat interval and the heartbeat loop and
+// security reporting start, and a transaction stopped by a power loss is
+// reported. Otherwise, interval is the time until the BootNotification is
+// retried, and nothing else is sent meanwhile.
+func (c *Charger) applyBootResponse(status string, interval int) {
+	c.mu.Lock()
+	c.registration = status
+	c.mu.Unlock()
+	c.events.publish(Event{Type: EventRegistration, Status: status})
+
+	if status == registrationAccepted {
+		if interval > 0 {
+			c.SetHeartbeatInterval(interval)
+		}
+		go c.StartHeartbeatLoop()
+		go c.resumeSecurityReporting()
+		go c.reportPowerLoss()
+		return
+	}
+
+	c.StopHeartbeatLoop()
+	delay := time.Duration(interval) * time.Second
+	if interval <= 0 {
+		delay = defaultBootRetryInterval
+	}
+	c.log().charger.Info("BootNotification retry scheduled", "registration", status, "delay", delay)
+	c.scheduleBootRetry(delay)
+}
+
+// scheduleBootRetry retries the BootNotification after delay, replacing a
+// retry scheduled earlier
+func (c *Charger) scheduleBootRetry(delay time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.bootRetry != nil {
+		c.bootRetry.Stop()
+	}
+	c.bootRetry = time.AfterFunc(delay, c.retryBootNotification)
+}
+
+// stopBootRetryLocked cancels a scheduled BootNotification retry. c.mu must be held.
+func (c *Charger) stopBootRetryLocked() {
+	if c.bootRetry != nil {
+		c.bootRetry.Stop()
+		c.bootRetry = nil
+	}
+}
+
+// retryBootNotification sends the BootNotification again with the last boot
+// reason. Once accepted, the current StatusNotification follows.
+func (c *Charger) retryBootNotification() {
+	if !c.IsConnected() {
+		return
+	}
+
+	c.mu.RLock()
+	reason := c.bootReason
+	c.mu.RUnlock()
+
+	if err := c.sendBootNotification(context.Background(), reason); err != nil {
+		c.log().charger.Warn("BootNotification retry failed", logging.Err(err))
+		c.scheduleBootRetry(defaultBootRetryInterval)
+		return
+	}
+	if c.GetRegistrationStatus() != registrationAccepted {
+		return
+	}
+	if err := c.StatusNotification(c.GetStatus()); err != nil {
+		c.log().charger.Warn("StatusNotification after registration failed", logging.Err(err))
+	}
+}
@@ -3,10 +3,10 @@ package charger
 import (
 	"encoding/json"
 	"fmt"
-	"log"
 	"time"
 
 	"github.com/google/uuid"
+	"github.com/weilun-shrimp/wlgo_ocpp_charger_simulator/logging"
 	"github.com/weilun-shrimp/wlgo_ocpp_charger_simulator/ocpp/v16"
 	"github.com/weilun-shrimp/wlgo_ocpp_charger_simulator/ocpp/v201"
 )
@@ -15,11 +15,11 @@ import (
 func (c *Charger) handleRemoteStartTransactionV16(uniqueId string, payload json.RawMessage) {
 	var req v16.RemoteStartTransactionRequest
 	if err := json.Unmarshal(payload, &req); err != nil {
-		log.Printf("Failed to parse RemoteStartTransaction: %v", err)
+		c.log().remote.Error("Failed to parse RemoteStartTransaction", logging.Action("RemoteStartTransaction"), logging.UniqueID(uniqueId), logging.Err(err))
 		return
 	}
 
-	log.Printf("Received RemoteStartTransaction: idTag=%s, connectorId=%d", req.IdTag, req.ConnectorId)
+	c.log().remote.Info("Received RemoteStartTransaction", logging.Action("RemoteStartTransaction"), logging.UniqueID(uniqueId), "id_tag", req.IdTag, "connector_id", req.ConnectorId)
 
 	c.mu.Lock()
 	status := c.status
@@ -30,15 +30,25 @@ func (c *Charger) handleRemoteStartTransactionV16(uniqueId string, payload json.
 		// Accept and store pending authorization - will auto-start when cable plugged in
 		respStatus = "Accepted"
 		c.pendingRemoteStartIdTag = req.IdTag
-		log.Printf("RemoteStartTransaction accepted: waiting for cable to be plugged in")
+		c.log().remote.Info("RemoteStartTransaction accepted: waiting for cable to be plugged in")
 	case "Preparing":
 		// Cable already plugged in - accept and start immediately
 		respStatus = "Accepted"
 		c.pendingRemoteStartIdTag = "" // Clear any pending
+	case "Reserved":
+		// Only the reserved idTag may use the connector - wait for the cable like Available
+		if c.reservationAllows(req.IdTag, "") {
+			respStatus = "Accepted"
+			c.pendingRemoteStartIdTag = req.IdTag
+			c.log().remote.Info("RemoteStartTransaction accepted for reservation: waiting for cable to be plugged in")
+		} else {
+			respStatus = "Rejected"
+			c.log().remote.Info("RemoteStartTransaction rejected: connector reserved for another idTag")
+		}
 	default:
 		// Reject if charging, finishing, or other states
 		respStatus = "Rejected"
-		log.Printf("RemoteStartTransaction rejected: invalid status (current: %s)", status)
+		c.log().remote.Info("RemoteStartTransaction rejected: invalid status", "status", status)
 	}
 	c.mu.Unlock()
 
@@ -47,7 +57,7 @@ func (c *Charger) handleRemoteStartTransactionV16(uniqueId string, payload json.
 	}
 
 	if err := c.sendCallResult(uniqueId, resp); err != nil {
-		log.Printf("Failed to send RemoteStartTransaction response: %v", err)
+		c.log().remote.Error("Failed to send RemoteStartTransaction response", logging.Action("RemoteStartTransaction"), logging.UniqueID(uniqueId), logging.Err(err))
 		return
 	}
 
@@ -56,7 +66,7 @@ func (c *Charger) handleRemoteStartTransactionV16(uniqueId string, payload json.
 		go func() {
 			time.Sleep(1 * time.Second)
 			if err := c.StartTransaction(req.IdTag); err != nil {
-				log.Printf("Failed to start transaction: %v", err)
+				c.log().remote.Error("Failed to start transaction", logging.Err(err))
 			}
 		}()
 	}
@@ -66,11 +76,11 @@ func (c *Charger) handleRemoteStartTransactionV16(uniqueId string, payload json.
 func (c *Charger) handleRemoteStopTransactionV16(uniqueId string, payload json.RawMessage) {
 	var req v16.RemoteStopTransactionRequest
 	if err := json.Unmarshal(payload, &req); err != nil {
-		log.Printf("Failed to parse RemoteStopTransaction: %v", err)
+		c.log().remote.Error("Failed to parse RemoteStopTransaction", logging.Action("RemoteStopTransaction"), logging.UniqueID(uniqueId), logging.Err(err))
 		return
 	}
 
-	log.Printf("Received RemoteStopTransaction: transactionId=%d", req.TransactionId)
+	c.log().remote.Info("Received RemoteStopTransaction", logging.Action("RemoteStopTransaction"), logging.UniqueID(uniqueId), logging.KeyTransactionID, req.TransactionId)
 
 	c.mu.RLock()
 	currentTransactionId := c.transactionId
@@ -89,7 +99,7 @@ func (c *Charger) handleRemoteStopTransactionV16(uniqueId string, payload json.R
 	}
 
 	if err := c.sendCallResult(uniqueId, resp); err != nil {
-		log.Printf("Failed to send RemoteStopTransaction response: %v", err)
+		c.log().remote.Error("Failed to send RemoteStopTransaction response", logging.Action("RemoteStopTransaction"), logging.UniqueID(uniqueId), logging.Err(err))
 		return
 	}
 
@@ -97,7 +107,7 @@ func (c *Charger) handleRemoteStopTransactionV16(uniqueId string, payload json.R
 		go func() {
 			time.Sleep(1 * time.Second)
 			if err := c.StopTransaction("Remote"); err != nil {
-				log.Printf("Failed to stop transaction: %v", err)
+				c.log().remote.Error("Failed to stop transaction", logging.Err(err))
 			}
 		}()
 	}
@@ -107,11 +117,11 @@ func (c *Charger) handleRemoteStopTransactionV16(uniqueId string, payload json.R
 func (c *Charger) handleRequestStartTransactionV201(uniqueId string, payload json.RawMessage) {
 	var req v201.RequestStartTransactionRequest
 	if err := json.Unmarshal(payload, &req); err != nil {
-		log.Printf("Failed to parse RequestStartTransaction: %v", err)
+		c.log().remote.Error("Failed to parse RequestStartTransaction", logging.Action("RequestStartTransaction"), logging.UniqueID(uniqueId), logging.Err(err))
 		return
 	}
 
-	log.Printf("Received RequestStartTransaction: idToken=%s, evseId=%d, remoteStartId=%d", req.IdToken.IdToken, req.EvseId, req.RemoteStartId)
+	c.log().remote.Info("Received RequestStartTransaction", logging.Action("RequestStartTransaction"), logging.UniqueID(uniqueId), "id_token", req.IdToken.IdToken, "evse_id", req.EvseId, "remote_start_id", req.RemoteStartId)
 
 	c.mu.Lock()
 	status := c.status
@@ -128,7 +138,27 @@ func (c *Charger) handleRequestStartTransactionV201(uniqueId string, payload jso
 		// Generate transaction ID now for the response
 		c.transactionIdStr = uuid.New().String()
 		transactionId = c.transactionIdStr
-		log.Printf("RequestStartTransaction accepted: waiting for cable to be plugged in")
+		c.log().remote.Info("RequestStartTransaction accepted: waiting for cable to be plugged in")
+	case "Reserved":
+		// Only the reserved idToken (or group) may use the EVSE - wait for the cable like Available
+		groupIdToken := ""
+		if req.GroupIdToken != nil {
+			groupIdToken = req.GroupIdToken.IdToken
+		}
+		if c.reservationAllows(req.IdToken.IdToken, groupIdToken) {
+			respStatus = "Accepted"
+			c.pendingRemoteStartIdTag = req.IdToken.IdToken
+			c.pendingRemoteStartId = req.RemoteStartId
+			c.transactionIdStr = uuid.New().String()
+			transactionId = c.transactionIdStr
+			c.log().remote.Info("RequestStartTransaction accepted for reservation: waiting for cable to be plugged in")
+		} else {
+			respStatus = "Rejected"
+			statusInfo = &v201.StatusInfo{
+				ReasonCode: "Reserved",
+			}
+			c.log().remote.Info("RequestStartTransaction rejected: EVSE reserved for another idToken")
+		}
 	case "Occupied":
 		// Cable already plugged in - accept and start immediately
 		respStatus = "Accepted"
@@ -143,7 +173,7 @@ func (c *Charger) handleRequestStartTransactionV201(uniqueId string, payload jso
 			ReasonCode:     "Occupied",
 			AdditionalInfo: fmt.Sprintf("Charger is busy, current status: %s", status),
 		}
-		log.Printf("RequestStartTransaction rejected: invalid status (current: %s)", status)
+		c.log().remote.Info("RequestStartTransaction rejected: invalid status", "status", status)
 	}
 	c.mu.Unlock()
 
@@ -154,7 +184,7 @@ func (c *Charger) handleRequestStartTransactionV201(uniqueId string, payload jso
 	}
 
 	if err := c.sendCallResult(uniqueId, resp); err != nil {
-		log.Printf("Failed to send RequestStartTransaction response: %v", err)
+		c.log().remote.Error("Failed to send RequestStartTransaction response", logging.Action("RequestStartTransaction"), logging.UniqueID(uniqueId), logging.Err(err))
 		return
 	}
 
@@ -163,7 +193,7 @@ func (c *Charger) handleRequestStartTransactionV201(uniqueId string, payload jso
 		go func() {
 			time.Sleep(1 * time.Second)
 			if err := c.StartTransaction(req.IdToken.IdToken); err != nil {
-				log.Printf("Failed to start transaction: %v", err)
+				c.log().remote.Error("Failed to start transaction", logging.Err(err))
 			}
 		}()
 	}
@@ -173,11 +203,11 @@ func (c *Charger) handleRequestStartTransactionV201(uniqueId string, payload jso
 func (c *Charger) handleRequestStopTransactionV201(uniqueId string, payload json.RawMessage) {
 	var req v201.RequestStopTransactionRequest
 	if err := json.Unmarshal(payload, &req); err != nil {
-		log.Printf("Failed to parse RequestStopTransaction: %v", err)
+		c.log().remote.Error("Failed to parse RequestStopTransaction", logging.Action("RequestStopTransaction"), logging.UniqueID(uniqueId), logging.Err(err))
 		return
 	}
 
-	log.Printf("Received RequestStopTransaction: transactionId=%s", req.TransactionId)
+	c.log().remote.Info("Received RequestStopTransaction", logging.Action("RequestStopTransaction"), logging.UniqueID(uniqueId), logging.KeyTransactionID, req.TransactionId)
 
 	c.mu.RLock()
 	currentTransactionId := c.transactionIdStr
@@ -196,7 +226,7 @@ func (c *Charger) handleRequestStopTransactionV201(uniqueId string, payload json
 	}
 
 	if err := c.sendCallResult(uniqueId, resp); err != nil {
-		log.Printf("Failed to send RequestStopTransaction response: %v", err)
+		c.log().remote.Error("Failed to send RequestStopTransaction response", logging.Action("RequestStopTransaction"), logging.UniqueID(uniqueId), logging.Err(err))
 		return
 	}
 
@@ -204,7 +234,7 @@ func (c *Charger) handleRequestStopTransactionV201(uniqueId string, payload json
 		go func() {
 			time.Sleep(1 * time.Second)
 			if err := c.StopTransaction("Remote"); err != nil {
-				log.Printf("Failed to stop transaction: %v", err)
+				c.log().remote.Error("Failed to stop transaction", logging.Err(err))
 			}
 		}()
 	}
@@ -214,11 +244,11 @@ func (c *Charger) handleRequestStopTransactionV201(uniqueId string, payload json
 func (c *Charger) handleSetChargingProfileV16(uniqueId string, payload json.RawMessage) {
 	var req v16.SetChargingProfileRequest
 	if err := json.Unmarshal(payload, &req); err != nil {
-		log.Printf("Failed to parse SetChargingProfile: %v", err)
+		c.log().profile.Error("Failed to parse SetChargingProfile", logging.Action("SetChargingProfile"), logging.UniqueID(uniqueId), logging.Err(err))
 		return
 	}
 
-	log.Printf("Received SetChargingProfile: connectorId=%d", req.ConnectorId)
+	c.log().profile.Info("Received SetChargingProfile", logging.Action("SetChargingProfile"), logging.UniqueID(uniqueId), "connector_id", req.ConnectorId)
 
 	status := "Accepted"
 
@@ -231,27 +261,41 @@ func (c *Charger) handleSetChargingProfileV16(uniqueId string, payload json.RawM
 
 			if unit == "A" {
 				if err := c.SetCurrent(limit); err != nil {
-					log.Printf("Failed to set current: %v", err)
+					c.log().profile.Error("Failed to set current", logging.Err(err))
 					status = "Rejected"
 				}
 			} else if unit == "W" {
 				if err := c.SetPower(limit); err != nil {
-					log.Printf("Failed to set power: %v", err)
+					c.log().profile.Error("Failed to set power", logging.Err(err))
 					status = "Rejected"
 				}
 			} else {
-				log.Printf("Unknown chargingRateUnit: %s", unit)
+				c.log().profile.Warn("Unknown chargingRateUnit", "unit", unit)
 				status = "Rejected"
 			}
 		}
 	}
 
+	if status == "Accepted" && req.ChargingProfile != nil && req.ChargingProfile.ChargingSchedule != nil &&
+		len(req.ChargingProfile.ChargingSchedule.ChargingSchedulePeriod) > 0 {
+		profile := req.ChargingProfile
+		c.mu.Lock()
+		c.installChargingProfileLocked(chargingProfile{
+			id:         profile.ChargingProfileId,
+			purpose:    profile.ChargingProfilePurpose,
+			stackLevel: profile.StackLevel,
+			limit:      profile.ChargingSchedule.ChargingSchedulePeriod[0].Limit,
+			unit:       profile.ChargingSchedule.ChargingRateUnit,
+		})
+		c.mu.Unlock()
+	}
+
 	resp := v16.SetChargingProfileResponse{
 		Status: status,
 	}
 
 	if err := c.sendCallResult(uniqueId, resp); err != nil {
-		log.Printf("Failed to send SetChargingProfile response: %v", err)
+		c.log().profile.Error("Failed to send SetChargingProfile response", logging.Action("SetChargingProfile"), logging.UniqueID(uniqueId), logging.Err(err))
 	}
 }
 
@@ -259,11 +303,11 @@ func (c *Charger) handleSetChargingProfileV16(uniqueId string, payload json.RawM
 func (c *Charger) handleSetChargingProfileV201(uniqueId string, payload json.RawMessage) {
 	var req v201.SetChargingProfileRequest
 	if err := json.Unmarshal(payload, &req); err != nil {
-		log.Printf("Failed to parse SetChargingProfile: %v", err)
+		c.log().profile.Error("Failed to parse SetChargingProfile", logging.Action("SetChargingProfile"), logging.UniqueID(uniqueId), logging.Err(err))
 		return
 	}
 
-	log.Printf("Received SetChargingProfile: evseId=%d", req.EvseId)
+	c.log().profile.Info("Received SetChargingProfile", logging.Action("SetChargingProfile"), logging.UniqueID(uniqueId), "evse_id", req.EvseId)
 
 	status := "Accepted"
 
@@ -281,24 +325,38 @@ func (c *Charger) handleSetChargingProfileV201(uniqueId string, payload json.Raw
 				// Convert power to current
 				currentAmps = limit / c.config.Voltage
 			} else {
-				log.Printf("Unknown charging rate unit: %s", unit)
+				c.log().profile.Warn("Unknown chargingRateUnit", "unit", unit)
 				status = "Rejected"
 			}
 
 			if status == "Accepted" {
 				if err := c.SetCurrent(currentAmps); err != nil {
-					log.Printf("Failed to set current: %v", err)
+					c.log().profile.Error("Failed to set current", logging.Err(err))
 					status = "Rejected"
 				}
 			}
 		}
 	}
 
+	if status == "Accepted" && req.ChargingProfile != nil && len(req.ChargingProfile.ChargingSchedule) > 0 &&
+		len(req.ChargingProfile.ChargingSchedule[0].ChargingSchedulePeriod) > 0 {
+		profile := req.ChargingProfile
+		c.mu.Lock()
+		c.installChargingProfileLocked(chargingProfile{
+			id:         profile.Id,
+			purpose:    profile.ChargingProfilePurpose,
+			stackLevel: profile.StackLevel,
+			limit:      profile.ChargingSchedule[0].ChargingSchedulePeriod[0].Limit,
+			unit:       profile.ChargingSchedule[0].ChargingRateUnit,
+		})
+		c.mu.Unlock()
+	}
+
 	resp := v201.SetChargingProfileResponse{
 		Status: status,
 	}
 
 	if err := c.sendCallResult(uniqueId, resp); err != nil {
-		log.Printf("Failed to send SetChargingProfile response: %v", err)
+		c.log().profile.Error("Failed to send SetChargingProfile response", logging.Action("SetChargingProfile"), logging.UniqueID(uniqueId), logging.Err(err))
 	}
 }
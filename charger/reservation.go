@@ -0,0 +1,333 @@
+package charger
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/weilun-shrimp/wlgo_ocpp_charger_simulator/logging"
+	"github.com/weilun-shrimp/wlgo_ocpp_charger_simulator/ocpp/v16"
+	"github.com/weilun-shrimp/wlgo_ocpp_charger_simulator/ocpp/v201"
+)
+
+// reservation is an active ReserveNow reservation of the connector
+type reservation struct {
+	id          int
+	idTag       string
+	parentIdTag string // parentIdTag (OCPP 1.6) or groupIdToken (OCPP 2.0.1)
+	remoteIdTag string // idTag of a remote start already matched against this reservation
+	expiry      time.Time
+	timer       *time.Timer // fires expireReservation at expiry
+}
+
+// matches reports whether idTag may use the reservation. A tag matches when it
+// is the reserved idTag, or when it shares the reservation's parent/group
+// token. The simulator has no local authorization list to resolve the parent of
+// an arbitrary tag, so presenting the parent/group token itself also matches.
+func (r *reservation) matches(idTag, parentIdTag string) bool {
+	if idTag == r.idTag || (r.remoteIdTag != "" && idTag == r.remoteIdTag) {
+		return true
+	}
+	if r.parentIdTag == "" {
+		return false
+	}
+	return idTag == r.parentIdTag || parentIdTag == r.parentIdTag
+}
+
+// reserve validates and stores a reservation, returning the ReserveNow response
+// status (Accepted, Faulted, Occupied, Rejected, Unavailable). On Accepted the
+// caller must set the status to Reserved once the response has been sent.
+func (c *Charger) reserve(id int, idTag, parentIdTag string, expiry time.Time) string {
+	if !expiry.After(time.Now()) {
+		c.log().charger.Info("Reservation rejected: expiry is in the past", "reservation_id", id, "expiry", expiry.Format(time.RFC3339))
+		return "Rejected"
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	// A reservation with the same id replaces the existing one
+	replacing := c.reservation != nil && c.reservation.id == id
+	if !replacing {
+		switch c.status {
+		case "Available":
+		case "Faulted":
+			return "Faulted"
+		case "Unavailable":
+			return "Unavailable"
+		default:
+			return "Occupied"
+		}
+	}
+
+	if c.reservation != nil {
+		c.reservation.timer.Stop()
+	}
+	c.reservation = &reservation{
+		id:          id,
+		idTag:       idTag,
+		parentIdTag: parentIdTag,
+		expiry:      expiry,
+		timer:       time.AfterFunc(time.Until(expiry), func() { c.expireReservation(id) }),
+	}
+
+	c.log().charger.Info("Reservation accepted", "reservation_id", id, "id_tag", idTag, "expiry", expiry.Format(time.RFC3339))
+	return "Accepted"
+}
+
+// applyReservation moves the connector to Reserved after an accepted ReserveNow
+func (c *Charger) applyReservation() {
+	c.mu.RLock()
+	status := c.status
+	c.mu.RUnlock()
+
+	if status == "Reserved" {
+		return
+	}
+	if err := c.SetStatus("Reserved"); err != nil {
+		c.log().charger.Error("Failed to set Reserved status", logging.Err(err))
+	}
+}
+
+// cancelReservation removes the reservation with the given id. It returns false
+// when no such reservation exists.
+func (c *Charger) cancelReservation(id int) bool {
+	c.mu.Lock()
+	if c.reservation == nil || c.reservation.id != id {
+		c.mu.Unlock()
+		return false
+	}
+	c.reservation.timer.Stop()
+	c.reservation = nil
+	status := c.status
+	c.mu.Unlock()
+
+	c.log().charger.Info("Reservation cancelled", "reservation_id", id)
+
+	if status == "Reserved" {
+		if err := c.SetStatus("Available"); err != nil {
+			c.log().charger.Error("Failed to set Available status", logging.Err(err))
+		}
+	}
+	return true
+}
+
+// expireReservation is called by the reservation timer when it runs out
+func (c *Charger) expireReservation(id int) {
+	c.mu.Lock()
+	if c.reservation == nil || c.reservation.id != id {
+		c.mu.Unlock()
+		return
+	}
+	c.reservation = nil
+	status := c.status
+	isConnected := c.isConnected
+	c.mu.Unlock()
+
+	c.log().charger.Info("Reservation expired", "reservation_id", id)
+
+	if status == "Reserved" {
+		if err := c.SetStatus("Available"); err != nil {
+			c.log().charger.Error("Failed to set Available status", logging.Err(err))
+		}
+	}
+
+	// OCPP 2.0.1 reports the expiry explicitly
+	if isConnected && !c.config.IsOCPP16() {
+		if err := c.sendReservationStatusUpdateV201(id, "Expired"); err != nil {
+			c.log().charger.Warn("ReservationStatusUpdate error", logging.Err(err))
+		}
+	}
+}
+
+// useReservation checks idTag against the active reservation before a
+// transaction starts. It returns the reservation id to report in the
+// transaction (0 when there is no reservation) and consumes the reservation,
+// or an error when the connector is reserved for another idTag.
+// Must be called with c.mu held.
+func (c *Charger) useReservation(idTag string) (int, error) {
+	r := c.reservation
+	if r == nil {
+		return 0, nil
+	}
+	if !r.matches(idTag, "") {
+		return 0, invalidState("connector is reserved for another idTag (reservationId=%d)", r.id)
+	}
+	r.timer.Stop()
+	c.reservation = nil
+	c.log().charger.Info("Reservation used", "reservation_id", r.id, "id_tag", idTag)
+	return r.id, nil
+}
+
+// reservationAllows reports whether a remote start for idTag may proceed while
+// the connector is Reserved. A match is remembered so that the transaction
+// started once the cable is plugged in uses the reservation, even when the
+// match was on the group token only. Must be called with c.mu held.
+func (c *Charger) reservationAllows(idTag, parentIdTag string) bool {
+	if c.reservation == nil || !c.reservation.matches(idTag, parentIdTag) {
+		return false
+	}
+	c.reservation.remoteIdTag = idTag
+	return true
+}
+
+// handleReserveNowV16 handles ReserveNow from server (OCPP 1.6)
+func (c *Charger) handleReserveNowV16(uniqueId string, payload json.RawMessage) {
+	var req v16.ReserveNowRequest
+	if err := json.Unmarshal(payload, &req); err != nil {
+		c.log().charger.Error("Failed to parse ReserveNow", logging.Action("ReserveNow"), logging.UniqueID(uniqueId), logging.Err(err))
+		return
+	}
+
+	c.log().charger.Info("Received ReserveNow", logging.Action("ReserveNow"), logging.UniqueID(uniqueId), "reservation_id", req.ReservationId, "connector_id", req.ConnectorId, "id_tag", req.IdTag, "expiry_date", req.ExpiryDate)
+
+	status := "Rejected"
+	// Connector 0 reserves the charge point; with a single connector that is this connector
+	if req.ConnectorId == 0 || req.ConnectorId == c.config.ConnectorID {
+		if expiry, err := time.Parse(time.RFC3339, req.ExpiryDate); err != nil {
+			c.log().charger.Warn("Invalid expiryDate", logging.Err(err))
+		} else {
+			status = c.reserve(req.ReservationId, req.IdTag, req.ParentIdTag, expiry)
+		}
+	} else {
+		c.log().charger.Info("ReserveNow rejected: unknown connector", logging.Action("ReserveNow"), logging.UniqueID(uniqueId), "connector_id", req.ConnectorId)
+	}
+
+	resp := v16.ReserveNowResponse{
+		Status: status,
+	}
+
+	if err := c.sendCallResult(uniqueId, resp); err != nil {
+		c.log().charger.Error("Failed to send ReserveNow response", logging.Action("ReserveNow"), logging.UniqueID(uniqueId), logging.Err(err))
+		return
+	}
+
+	if status == "Accepted" {
+		c.applyReservation()
+	}
+}
+
+// handleCancelReservationV16 handles CancelReservation from server (OCPP 1.6)
+func (c *Charger) handleCancelReservationV16(uniqueId string, payload json.RawMessage) {
+	var req v16.CancelReservationRequest
+	if err := json.Unmarshal(payload, &req); err != nil {
+		c.log().charger.Error("Failed to parse CancelReservation", logging.Action("CancelReservation"), logging.UniqueID(uniqueId), logging.Err(err))
+		return
+	}
+
+	c.log().charger.Info("Received CancelReservation", logging.Action("CancelReservation"), logging.UniqueID(uniqueId), "reservation_id", req.ReservationId)
+
+	c.mu.RLock()
+	found := c.reservation != nil && c.reservation.id == req.ReservationId
+	c.mu.RUnlock()
+
+	status := "Rejected"
+	if found {
+		status = "Accepted"
+	}
+
+	resp := v16.CancelReservationResponse{
+		Status: status,
+	}
+
+	if err := c.sendCallResult(uniqueId, resp); err != nil {
+		c.log().charger.Error("Failed to send CancelReservation response", logging.Action("CancelReservation"), logging.UniqueID(uniqueId), logging.Err(err))
+		return
+	}
+
+	if found {
+		c.cancelReservation(req.ReservationId)
+	}
+}
+
+// handleReserveNowV201 handles ReserveNow from server (OCPP 2.0.1)
+func (c *Charger) handleReserveNowV201(uniqueId string, payload json.RawMessage) {
+	var req v201.ReserveNowRequest
+	if err := json.Unmarshal(payload, &req); err != nil {
+		c.log().charger.Error("Failed to parse ReserveNow", logging.Action("ReserveNow"), logging.UniqueID(uniqueId), logging.Err(err))
+		return
+	}
+
+	c.log().charger.Info("Received ReserveNow", logging.Action("ReserveNow"), logging.UniqueID(uniqueId), "id", req.Id, "evse_id", req.EvseId, "id_token", req.IdToken.IdToken, "expiry_date_time", req.ExpiryDateTime)
+
+	status := "Rejected"
+	var statusInfo *v201.StatusInfo
+	// An unspecified EVSE reserves the station; with a single EVSE that is this EVSE
+	if req.EvseId == 0 || req.EvseId == c.config.ConnectorID {
+		if expiry, err := time.Parse(time.RFC3339, req.ExpiryDateTime); err != nil {
+			c.log().charger.Warn("Invalid expiryDateTime", logging.Err(err))
+			statusInfo = &v201.StatusInfo{ReasonCode: "InvalidValue", AdditionalInfo: "expiryDateTime is not a valid date-time"}
+		} else {
+			groupIdToken := ""
+			if req.GroupIdToken != nil {
+				groupIdToken = req.GroupIdToken.IdToken
+			}
+			status = c.reserve(req.Id, req.IdToken.IdToken, groupIdToken, expiry)
+		}
+	} else {
+		c.log().charger.Info("ReserveNow rejected: unknown EVSE", logging.Action("ReserveNow"), logging.UniqueID(uniqueId), "evse_id", req.EvseId)
+		statusInfo = &v201.StatusInfo{ReasonCode: "UnknownEvse"}
+	}
+
+	resp := v201.ReserveNowResponse{
+		Status:     status,
+		StatusInfo: statusInfo,
+	}
+
+	if err := c.sendCallResult(uniqueId, resp); err != nil {
+		c.log().charger.Error("Failed to send ReserveNow response", logging.Action("ReserveNow"), logging.UniqueID(uniqueId), logging.Err(err))
+		return
+	}
+
+	if status == "Accepted" {
+		c.applyReservation()
+	}
+}
+
+// handleCancelReservationV201 handles CancelReservation from server (OCPP 2.0.1)
+func (c *Charger) handleCancelReservationV201(uniqueId string, payload json.RawMessage) {
+	var req v201.CancelReservationRequest
+	if err := json.Unmarshal(payload, &req); err != nil {
+		c.log().charger.Error("Failed to parse CancelReservation", logging.Action("CancelReservation"), logging.UniqueID(uniqueId), logging.Err(err))
+		return
+	}
+
+	c.log().charger.Info("Received CancelReservation", logging.Action("CancelReservation"), logging.UniqueID(uniqueId), "reservation_id", req.ReservationId)
+
+	c.mu.RLock()
+	found := c.reservation != nil && c.reservation.id == req.ReservationId
+	c.mu.RUnlock()
+
+	status := "Rejected"
+	if found {
+		status = "Accepted"
+	}
+
+	resp := v201.CancelReservationResponse{
+		Status: status,
+	}
+
+	if err := c.sendCallResult(uniqueId, resp); err != nil {
+		c.log().charger.Error("Failed to send CancelReservation response", logging.Action("CancelReservation"), logging.UniqueID(uniqueId), logging.Err(err))
+		return
+	}
+
+	if found {
+		c.cancelReservation(req.ReservationId)
+	}
+}
+
+// sendReservationStatusUpdateV201 reports an expired or removed reservation (OCPP 2.0.1)
+func (c *Charger) sendReservationStatusUpdateV201(reservationId int, updateStatus string) error {
+	req := v201.ReservationStatusUpdateRequest{
+		ReservationId:           reservationId,
+		ReservationUpdateStatus: updateStatus,
+	}
+
+	if _, err := c.sendCall(v201.ActionReservationStatusUpdate, req); err != nil {
+		return fmt.Errorf("ReservationStatusUpdate failed: %w", err)
+	}
+
+	c.log().charger.Info("ReservationStatusUpdate sent", "reservation_id", reservationId, "status", updateStatus)
+	return nil
+}
@@ -0,0 +1,133 @@
+package charger
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/weilun-shrimp/wlgo_ocpp_charger_simulator/logging"
+	"github.com/weilun-shrimp/wlgo_ocpp_charger_simulator/ocpp/v16"
+	"github.com/weilun-shrimp/wlgo_ocpp_charger_simulator/ocpp/v201"
+)
+
+// Stop reasons of a transaction ended by a reset
+const (
+	StopReasonHardReset      = "HardReset"      // Reset Hard (OCPP 1.6)
+	StopReasonSoftReset      = "SoftReset"      // Reset Soft (OCPP 1.6)
+	StopReasonImmediateReset = "ImmediateReset" // Reset Immediate (OCPP 2.0.1)
+	StopReasonReboot         = "Reboot"         // local or watchdog reset
+)
+
+// Reset restarts the charger as if the operator pressed its reset button. A
+// running transaction is stopped first. The next BootNotification reports a
+// LocalReset.
+func (c *Charger) Reset() error {
+	return c.restart(bootReasonLocalReset, StopReasonReboot)
+}
+
+// WatchdogReset restarts the charger as if its watchdog fired. A running
+// transaction is stopped first. The next BootNotification reports a Watchdog
+// boot.
+func (c *Charger) WatchdogReset() error {
+	return c.restart(bootReasonWatchdog, StopReasonReboot)
+}
+
+// restart stops the running transaction, if any, with stopReason and reboots
+// with the boot reason of the cause
+func (c *Charger) restart(bootReason, stopReason string) error {
+	if c.IsCharging() {
+		if err := c.StopTransaction(stopReason); err != nil {
+			c.log().charger.Error("Failed to stop transaction for reset", logging.Err(err))
+		}
+	}
+	return c.reboot(bootReason)
+}
+
+// remoteReset restarts the charger for a Reset from the server
+func (c *Charger) remoteReset(stopReason string) {
+	if err := c.restart(bootReasonRemoteReset, stopReason); err != nil {
+		c.log().charger.Warn("Reboot after Reset failed", logging.Err(err))
+	}
+}
+
+// handleResetV16 handles Reset from server (OCPP 1.6). Both types stop a
+// running transaction, with reason HardReset or SoftReset, and reboot once the
+// request is answered.
+func (c *Charger) handleResetV16(uniqueId string, payload json.RawMessage) {
+	var req v16.ResetRequest
+	if err := json.Unmarshal(payload, &req); err != nil {
+		c.log().charger.Error("Failed to parse Reset", logging.Action("Reset"), logging.UniqueID(uniqueId), logging.Err(err))
+		return
+	}
+
+	c.log().charger.Info("Received Reset", logging.Action("Reset"), logging.UniqueID(uniqueId), "type", req.Type)
+
+	status := "Accepted"
+	stopReason := StopReasonSoftReset
+	switch req.Type {
+	case "Hard":
+		stopReason = StopReasonHardReset
+	case "Soft":
+	default:
+		status = "Rejected"
+	}
+
+	resp := v16.ResetResponse{
+		Status: status,
+	}
+
+	if err := c.sendCallResult(uniqueId, resp); err != nil {
+		c.log().charger.Error("Failed to send Reset response", logging.Action("Reset"), logging.UniqueID(uniqueId), logging.Err(err))
+	}
+
+	if status == "Accepted" {
+		go c.remoteReset(stopReason)
+	}
+}
+
+// handleResetV201 handles Reset from server (OCPP 2.0.1). Immediate stops a
+// running transaction with reason ImmediateReset and reboots; OnIdle answers
+// Scheduled while a transaction runs and reboots once it has ended. Resetting
+// a single EVSE is not simulated.
+func (c *Charger) handleResetV201(uniqueId string, payload json.RawMessage) {
+	var req v201.ResetRequest
+	if err := json.Unmarshal(payload, &req); err != nil {
+		c.log().charger.Error("Failed to parse Reset", logging.Action("Reset"), logging.UniqueID(uniqueId), logging.Err(err))
+		return
+	}
+
+	c.log().charger.Info("Received Reset", logging.Action("Reset"), logging.UniqueID(uniqueId), "type", req.Type)
+
+	status := "Accepted"
+	var statusInfo *v201.StatusInfo
+	switch {
+	case req.EvseId != nil:
+		status = "Rejected"
+		statusInfo = &v201.StatusInfo{ReasonCode: "UnsupportedRequest", AdditionalInfo: "EVSE reset not supported"}
+	case req.Type == "OnIdle" && c.IsCharging():
+		status = "Scheduled"
+	case req.Type != "Immediate" && req.Type != "OnIdle":
+		status = "Rejected"
+	}
+
+	resp := v201.ResetResponse{
+		Status:     status,
+		StatusInfo: statusInfo,
+	}
+
+	if err := c.sendCallResult(uniqueId, resp); err != nil {
+		c.log().charger.Error("Failed to send Reset response", logging.Action("Reset"), logging.UniqueID(uniqueId), logging.Err(err))
+	}
+
+	switch status {
+	case "Accepted":
+		go c.remoteReset(StopReasonImmediateReset)
+	case "Scheduled":
+		go func() {
+			c.log().charger.Info("Reset waits for the transaction to end")
+			for c.IsCharging() {
+				time.Sleep(time.Second)
+			}
+			c.remoteReset(StopReasonImmediateReset)
+		}()
+	}
+}
@@ -0,0 +1,175 @@
+package charger
+
+import (
+	"encoding/base64"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/weilun-shrimp/wlgo_ocpp_charger_simulator/logging"
+)
+
+// OCPP Security Profiles (1.6 Security Whitepaper, 2.0.1 Part 2 A00)
+const (
+	securityProfileNone      = 0 // no OCPP security, optional static auth header
+	securityProfileBasic     = 1 // unsecured transport with Basic auth
+	securityProfileTLSBasic  = 2 // TLS with Basic auth
+	securityProfileTLSClient = 3 // TLS with client certificate
+)
+
+// Length limits of the AuthorizationKey (1.6) / BasicAuthPassword (2.0.1)
+const (
+	minAuthorizationKeyLength = 16
+	maxAuthorizationKeyLength = 40
+)
+
+// securityState is the security profile and credentials used to connect
+type securityState struct {
+	profile          int
+	authorizationKey string
+}
+
+// usesBasicAuth returns true if the profile authenticates with Basic auth
+func (s securityState) usesBasicAuth() bool {
+	return s.profile == securityProfileBasic || s.profile == securityProfileTLSBasic
+}
+
+// serverURL returns the URL to connect to. Profiles 2 and 3 require TLS, so a
+// ws:// URL is upgraded to wss:// after the server raised the profile.
+func (s securityState) serverURL(configured string) string {
+	if s.profile >= securityProfileTLSBasic && strings.HasPrefix(configured, "ws://") {
+		return "wss://" + strings.TrimPrefix(configured, "ws://")
+	}
+	return configured
+}
+
+// securityState returns the current security state
+func (c *Charger) securityState() securityState {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.security
+}
+
+// restoreSecurityState replaces the security state, e.g. to fall back after a failed reconnect
+func (c *Charger) restoreSecurityState(s securityState) {
+	c.mu.Lock()
+	c.security = s
+	c.mu.Unlock()
+}
+
+// GetSecurityProfile returns the active OCPP security profile
+func (c *Charger) GetSecurityProfile() int {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.security.profile
+}
+
+// authHeader returns the Authorization header for the handshake, or "" if none.
+// Profiles 1 and 2 use Basic auth with the charger ID as user and the
+// AuthorizationKey as password; without a profile the configured auth block is used.
+func (c *Charger) authHeader(s securityState) string {
+	switch {
+	case s.profile == securityProfileNone:
+		return c.config.GetAuthHeader()
+	case s.usesBasicAuth():
+		credentials := c.config.ChargerID + ":" + s.authorizationKey
+		return "Basic " + base64.StdEncoding.EncodeToString([]byte(credentials))
+	default:
+		return ""
+	}
+}
+
+// setAuthorizationKey validates and stores a new AuthorizationKey. It returns
+// true if the key is in use, so the charger must reconnect with it.
+func (c *Charger) setAuthorizationKey(value string) (bool, error) {
+	if len(value) < minAuthorizationKeyLength || len(value) > maxAuthorizationKeyLength {
+		return false, fmt.Errorf("authorization key must be %d to %d characters, got %d",
+			minAuthorizationKeyLength, maxAuthorizationKeyLength, len(value))
+	}
+
+	c.diagnostics.addSecret(value)
+
+	c.mu.Lock()
+	c.security.authorizationKey = value
+	inUse := c.security.usesBasicAuth()
+	c.mu.Unlock()
+
+	c.log().charger.Info("AuthorizationKey changed")
+	return inUse, nil
+}
+
+// setSecurityProfile validates and stores a new security profile. The profile
+// can only be raised, and its credentials must be available. It returns true
+// if the profile changed, so the charger must reconnect with it.
+func (c *Charger) setSecurityProfile(value string) (bool, error) {
+	profile, err := strconv.Atoi(value)
+	if err != nil || profile < securityProfileNone || profile > securityProfileTLSClient {
+		return false, fmt.Errorf("invalid security profile %q", value)
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	current := c.security.profile
+	if profile < current {
+		return false, fmt.Errorf("security profile cannot be lowered from %d to %d", current, profile)
+	}
+	if profile == current {
+		return false, nil
+	}
+
+	next := securityState{profile: profile, authorizationKey: c.security.authorizationKey}
+	if next.usesBasicAuth() && next.authorizationKey == "" {
+		return false, fmt.Errorf("security profile %d requires an AuthorizationKey", profile)
+	}
+	if profile == securityProfileTLSClient && c.clientCertificateLocked() == nil {
+		return false, fmt.Errorf("security profile %d requires a client certificate", profile)
+	}
+
+	c.security = next
+	c.log().charger.Info("Security profile changed", "from", current, "to", profile)
+	return true, nil
+}
+
+// reconnectWithSecurity reconnects with the security settings the server just
+// changed, raising a ReconfigurationOfSecurityParameters event with techInfo
+// while offline. If the new connection fails, the previous settings are
+// restored and used to reconnect. A running transaction continues across the
+// reconnect.
+func (c *Charger) reconnectWithSecurity(previous securityState, techInfo string) {
+	c.mu.Lock()
+	if c.isConnected {
+		c.closeConnectionLocked()
+	}
+	c.mu.Unlock()
+
+	c.RaiseSecurityEvent(securityEventReconfigurationOfSecurityParameters, techInfo)
+
+	c.log().charger.Info("Reconnecting", "security_profile", c.GetSecurityProfile())
+	err := c.reconnect()
+	if err == nil {
+		return
+	}
+
+	c.log().charger.Warn("Reconnect failed, falling back", "security_profile", previous.profile, logging.Err(err))
+	c.restoreSecurityState(previous)
+	if err := c.reconnect(); err != nil {
+		c.log().charger.Error("Reconnect failed", "security_profile", previous.profile, logging.Err(err))
+	}
+}
+
+// reconnect connects and resumes the heartbeat loop. No BootNotification is
+// sent if the charger was accepted: it did not reboot. A Pending or Rejected
+// charger retries the BootNotification instead.
+func (c *Charger) reconnect() error {
+	if err := c.Connect(); err != nil {
+		return err
+	}
+	if c.GetRegistrationStatus() != registrationAccepted {
+		go c.retryBootNotification()
+		return nil
+	}
+	go c.StartHeartbeatLoop()
+	go c.resumeSecurityReporting()
+	return nil
+}
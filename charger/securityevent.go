@@ -0,0 +1,218 @@
+package charger
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/weilun-shrimp/wlgo_ocpp_charger_simulator/logging"
+	"github.com/weilun-shrimp/wlgo_ocpp_charger_simulator/ocpp/v16"
+	"github.com/weilun-shrimp/wlgo_ocpp_charger_simulator/ocpp/v201"
+)
+
+// Security event types reported in SecurityEventNotification (OCPP 1.6
+// Security Whitepaper / OCPP 2.0.1 Appendix 1). Where the versions differ, the
+// version specific helpers below pick the name.
+const (
+	securityEventFailedToAuthenticateAtCentralSystem = "FailedToAuthenticateAtCentralSystem" // OCPP 1.6
+	securityEventFailedToAuthenticateAtCsms          = "FailedToAuthenticateAtCsms"          // OCPP 2.0.1
+	securityEventInvalidCentralSystemCertificate     = "InvalidCentralSystemCertificate"     // OCPP 1.6
+	securityEventInvalidCsmsCertificate              = "InvalidCsmsCertificate"              // OCPP 2.0.1
+	securityEventInvalidChargePointCertificate       = "InvalidChargePointCertificate"       // OCPP 1.6
+	securityEventInvalidChargingStationCertificate   = "InvalidChargingStationCertificate"   // OCPP 2.0.1
+
+	securityEventResetOrReboot                       = "ResetOrReboot"
+	securityEventReconfigurationOfSecurityParameters = "ReconfigurationOfSecurityParameters"
+	securityEventInvalidFirmwareSignature            = "InvalidFirmwareSignature"
+	securityEventInvalidFirmwareSigningCertificate   = "InvalidFirmwareSigningCertificate"
+
+	// Simulator specific
+	securityEventCertificateExpiring = "CertificateExpiring"
+	securityEventUnknownIdTag        = "UnknownIdTag"
+)
+
+// Limits of the security event queue and log
+const (
+	maxQueuedSecurityEvents = 100 // events waiting to be sent; the oldest are dropped when full
+	maxSecurityLogEvents    = 500 // events kept for GetLog (SecurityLog)
+	maxTechInfoLength       = 255 // techInfo length allowed by both versions
+)
+
+// securityEvent is a security event raised by the charger
+type securityEvent struct {
+	Time     time.Time
+	Type     string
+	TechInfo string
+}
+
+// securityEventLog keeps the raised security events: the ones not yet
+// delivered to the server, and the recent ones uploaded as SecurityLog. It has
+// its own lock so events can be raised while c.mu is held.
+type securityEventLog struct {
+	mu       sync.Mutex
+	queue    []securityEvent // not yet sent, oldest first
+	recent   []securityEvent // security log
+	flushing bool            // a goroutine is sending the queue
+}
+
+// add records e and queues it for sending
+func (l *securityEventLog) add(e securityEvent, logger *slog.Logger) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if len(l.queue) >= maxQueuedSecurityEvents {
+		logger.Warn("Security event queue full: dropping the oldest", "type", l.queue[0].Type, "time", l.queue[0].Time.Format(time.RFC3339))
+		l.queue = l.queue[1:]
+	}
+	l.queue = append(l.queue, e)
+	if len(l.recent) >= maxSecurityLogEvents {
+		l.recent = l.recent[1:]
+	}
+	l.recent = append(l.recent, e)
+}
+
+// between returns the logged events within [from, to]; a zero bound is open
+func (l *securityEventLog) between(from, to time.Time) []securityEvent {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	var events []securityEvent
+	for _, e := range l.recent {
+		if (from.IsZero() || !e.Time.Before(from)) && (to.IsZero() || !e.Time.After(to)) {
+			events = append(events, e)
+		}
+	}
+	return events
+}
+
+// RaiseSecurityEvent records a security event and reports it to the server
+// with SecurityEventNotification. While offline the event is queued and sent,
+// in order, once the charger is connected again.
+func (c *Charger) RaiseSecurityEvent(eventType, techInfo string) {
+	if len(techInfo) > maxTechInfoLength {
+		techInfo = techInfo[:maxTechInfoLength]
+	}
+	c.log().charger.Info("Security event", "type", eventType, "tech_info", techInfo)
+	c.securityEvents.add(securityEvent{Time: time.Now().UTC(), Type: eventType, TechInfo: techInfo}, c.log().charger)
+	go c.flushSecurityEvents()
+}
+
+// QueuedSecurityEvents returns the number of security events not yet sent
+func (c *Charger) QueuedSecurityEvents() int {
+	c.securityEvents.mu.Lock()
+	defer c.securityEvents.mu.Unlock()
+	return len(c.securityEvents.queue)
+}
+
+// flushSecurityEvents sends the queued events, oldest first, until the queue
+// is empty or the charger is offline or not accepted. Only one flush runs at a
+// time.
+func (c *Charger) flushSecurityEvents() {
+	l := c.securityEvents
+	l.mu.Lock()
+	if l.flushing {
+		l.mu.Unlock()
+		return
+	}
+	l.flushing = true
+	l.mu.Unlock()
+
+	for {
+		// Not under l.mu: events may be raised with c.mu held
+		connected := c.IsConnected() && c.GetRegistrationStatus() == registrationAccepted
+		l.mu.Lock()
+		if len(l.queue) == 0 || !connected {
+			l.flushing = false
+			l.mu.Unlock()
+			return
+		}
+		e := l.queue[0]
+		l.mu.Unlock()
+
+		if err := c.sendSecurityEventNotification(e); err != nil {
+			c.log().charger.Warn("Security event kept queued", "type", e.Type, logging.Err(err))
+			// The send may have failed because the connection was replaced
+			// meanwhile; the loop retries if the charger is connected again.
+			time.Sleep(time.Second)
+			continue
+		}
+
+		l.mu.Lock()
+		if len(l.queue) > 0 && l.queue[0] == e {
+			l.queue = l.queue[1:]
+		}
+		l.mu.Unlock()
+	}
+}
+
+// resumeSecurityReporting runs after the charger (re)connected: it delivers
+// the events queued while offline and reports expiring certificates
+func (c *Charger) resumeSecurityReporting() {
+	c.flushSecurityEvents()
+	c.reportExpiringCertificates()
+}
+
+// sendSecurityEventNotification reports a security event to the server
+func (c *Charger) sendSecurityEventNotification(e securityEvent) error {
+	timestamp := e.Time.Format(time.RFC3339)
+
+	var err error
+	if c.config.IsOCPP16() {
+		_, err = c.sendCall(v16.ActionSecurityEventNotification, v16.SecurityEventNotificationRequest{
+			Type:      e.Type,
+			Timestamp: timestamp,
+			TechInfo:  e.TechInfo,
+		})
+	} else {
+		_, err = c.sendCall(v201.ActionSecurityEventNotification, v201.SecurityEventNotificationRequest{
+			Type:      e.Type,
+			Timestamp: timestamp,
+			TechInfo:  e.TechInfo,
+		})
+	}
+	if err != nil {
+		return fmt.Errorf("SecurityEventNotification failed: %w", err)
+	}
+
+	c.log().charger.Info("SecurityEventNotification sent", "type", e.Type, "tech_info", e.TechInfo)
+	return nil
+}
+
+// invalidCertificateEvent returns the version specific event type for a
+// rejected client certificate
+func (c *Charger) invalidCertificateEvent() string {
+	if c.config.IsOCPP16() {
+		return securityEventInvalidChargePointCertificate
+	}
+	return securityEventInvalidChargingStationCertificate
+}
+
+// raiseConnectionSecurityEvent reports a failed connection attempt caused by
+// security: a server certificate the charger does not trust, or the server
+// refusing the charger's credentials (TLS alert or HTTP 401/403). Other
+// failures, e.g. an unreachable server, raise nothing.
+func (c *Charger) raiseConnectionSecurityEvent(err error, httpStatus int) {
+	var verifyErr *tls.CertificateVerificationError
+	var unknownAuthority x509.UnknownAuthorityError
+	var invalidCert x509.CertificateInvalidError
+	var hostnameErr x509.HostnameError
+	var alert tls.AlertError
+
+	switch {
+	case errors.As(err, &verifyErr), errors.As(err, &unknownAuthority),
+		errors.As(err, &invalidCert), errors.As(err, &hostnameErr):
+		eventType := securityEventInvalidCsmsCertificate
+		if c.config.IsOCPP16() {
+			eventType = securityEventInvalidCentralSystemCertificate
+		}
+		c.RaiseSecurityEvent(eventType, err.Error())
+	case errors.As(err, &alert), httpStatus == 401, httpStatus == 403:
+		eventType := securityEventFailedToAuthenticateAtCsms
+		if c.config.IsOCPP16() {
+			eventType = securityEventFailedToAuthenticateAtCentralSystem
+		}
+		c.RaiseSecurityEvent(eventType, err.Error())
+	}
+}
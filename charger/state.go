@@ -0,0 +1,329 @@
+package charger
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/weilun-shrimp/wlgo_ocpp_charger_simulator/config"
+	"github.com/weilun-shrimp/wlgo_ocpp_charger_simulator/logging"
+)
+
+// StopReasonPowerLoss is the stop reason of a transaction interrupted by a
+// simulator restart (same value in OCPP 1.6 and 2.0.1)
+const StopReasonPowerLoss = "PowerLoss"
+
+// stateSaveInterval is how often the state file is brought up to date
+const stateSaveInterval = time.Second
+
+// persistedState is the charger state kept in the state file. Stopping or
+// killing the simulator is a power loss: the next run starts from this state.
+type persistedState struct {
+	SavedAt          time.Time `json:"savedAt"` // last time the simulator was known to run
+	OCPPVersion      string    `json:"ocppVersion"`
+	Status           string    `json:"status"`
+	Charging         bool      `json:"charging"` // a transaction is open
+	IdTag            string    `json:"idTag,omitempty"`
+	TransactionId    int       `json:"transactionId,omitempty"`
+	TransactionIdStr string    `json:"transactionIdStr,omitempty"`
+	MeterValue       int       `json:"meterValue"` // energy register in Wh
+	SoC              float64   `json:"soc"`
+	SeqNo            int       `json:"seqNo"`
+	Current          float64   `json:"current"`
+	Power            float64   `json:"power"`
+	LicensePlate     string    `json:"licensePlate,omitempty"`
+	CableLocked      bool      `json:"cableLocked"`
+	FirmwareVersion  string    `json:"firmwareVersion"`
+	// Configuration keys changeable by the server
+	SecurityProfile       int    `json:"securityProfile"`
+	AuthorizationKey      string `json:"authorizationKey,omitempty"`
+	WebSocketPingInterval int    `json:"webSocketPingInterval"`
+	// Absent in state files written before they were changeable
+	StopTransactionOnInvalidId *bool `json:"stopTransactionOnInvalidId,omitempty"`
+	MaxEnergyOnInvalidId       *int  `json:"maxEnergyOnInvalidId,omitempty"`
+	// Messages waiting until the charger is accepted again
+	SecurityEvents []securityEvent         `json:"securityEvents,omitempty"`
+	PowerLoss      *interruptedTransaction `json:"powerLoss,omitempty"`
+}
+
+// interruptedTransaction is a transaction ended by a power loss. It is
+// reported to the server once the charger is accepted again.
+type interruptedTransaction struct {
+	StoppedAt        time.Time `json:"stoppedAt"`
+	IdTag            string    `json:"idTag"`
+	TransactionId    int       `json:"transactionId,omitempty"`
+	TransactionIdStr string    `json:"transactionIdStr,omitempty"`
+	MeterStop        int       `json:"meterStop"`
+	SeqNo            int       `json:"seqNo"`
+}
+
+// statePersistence writes the state file while the simulator runs
+type statePersistence struct {
+	path    string
+	mu      sync.Mutex // serializes writes
+	last    []byte     // state last written, without the save time
+	failing bool       // the last write failed (logged once)
+	stop    chan struct{}
+	done    chan struct{}
+}
+
+// newStatePersistence returns the persistence of the configured state file,
+// or nil if the state is not kept
+func newStatePersistence(cfg *config.Config) *statePersistence {
+	path := cfg.GetStateFile()
+	if path == "" {
+		return nil
+	}
+	return &statePersistence{
+		path: path,
+		stop: make(chan struct{}),
+		done: make(chan struct{}),
+	}
+}
+
+// persistedState captures the state to keep across restarts
+func (c *Charger) persistedState() persistedState {
+	c.mu.RLock()
+	s := persistedState{
+		OCPPVersion:           c.config.OCPPVersion,
+		Status:                c.status,
+		Charging:              c.isCharging,
+		MeterValue:            c.meterValue,
+		SoC:                   c.soc,
+		SeqNo:                 c.seqNo,
+		Current:               c.current,
+		Power:                 c.power,
+		LicensePlate:          c.licensePlate,
+		CableLocked:           c.cableLocked,
+		FirmwareVersion:       c.firmwareVersion,
+		SecurityProfile:       c.security.profile,
+		AuthorizationKey:      c.security.authorizationKey,
+		WebSocketPingInterval: c.keepalive.getInterval(),
+		PowerLoss:             c.powerLoss,
+	}
+	invalidId := c.invalidId
+	s.StopTransactionOnInvalidId = &invalidId.stopTransaction
+	s.MaxEnergyOnInvalidId = &invalidId.maxEnergy
+	if c.isCharging {
+		s.IdTag = c.idTag
+		s.TransactionId = c.transactionId
+		s.TransactionIdStr = c.transactionIdStr
+	}
+	c.mu.RUnlock()
+
+	c.securityEvents.mu.Lock()
+	s.SecurityEvents = append([]securityEvent(nil), c.securityEvents.queue...)
+	c.securityEvents.mu.Unlock()
+	return s
+}
+
+// saveState writes the state file if the state changed. While a transaction
+// is open it is written every time, so the save time tells how long a later
+// outage lasted. Must not be called with c.mu held.
+func (c *Charger) saveState() error {
+	p := c.persistence
+	s := c.persistedState()
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	unchanged, err := json.Marshal(s)
+	if err != nil {
+		return fmt.Errorf("failed to encode state: %w", err)
+	}
+	if !s.Charging && bytes.Equal(unchanged, p.last) {
+		return nil
+	}
+
+	s.SavedAt = time.Now().UTC()
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode state: %w", err)
+	}
+	// Write a temporary file and rename it, so a crash never leaves half a file.
+	// The file holds the AuthorizationKey: only the owner may read it.
+	tmp := p.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o600); err != nil {
+		return fmt.Errorf("failed to write state file: %w", err)
+	}
+	if err := os.Rename(tmp, p.path); err != nil {
+		return fmt.Errorf("failed to write state file: %w", err)
+	}
+	p.last = unchanged
+	return nil
+}
+
+// saveStateLoop keeps the state file up to date until stopSavingState
+func (c *Charger) saveStateLoop() {
+	p := c.persistence
+	defer close(p.done)
+
+	ticker := time.NewTicker(stateSaveInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-p.stop:
+			return
+		case <-ticker.C:
+			err := c.saveState()
+			if err != nil && !p.failing {
+				c.log().charger.Error("Failed to save state", logging.Err(err))
+			}
+			p.failing = err != nil
+		}
+	}
+}
+
+// stopSavingState stops the save loop and writes the final state
+func (c *Charger) stopSavingState() {
+	p := c.persistence
+	if p == nil {
+		return
+	}
+	select {
+	case <-p.stop:
+		return // already stopped
+	default:
+		close(p.stop)
+	}
+	<-p.done
+	if err := c.saveState(); err != nil {
+		c.log().charger.Error("Failed to save state", logging.Err(err))
+		return
+	}
+	c.log().charger.Info("State saved", "path", p.path)
+}
+
+// restoreState loads the state file at startup. A transaction still open
+// when the simulator stopped continues if the outage was shorter than the
+// resume timeout; otherwise it is stopped with reason PowerLoss, reported once
+// the charger is accepted. Called from New, before anything else runs.
+func (c *Charger) restoreState() error {
+	path := c.persistence.path
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		c.log().charger.Info("No state file: starting with the configured state", "path", path)
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read state file: %w", err)
+	}
+
+	var s persistedState
+	if err := json.Unmarshal(data, &s); err != nil {
+		return fmt.Errorf("failed to parse state file %s: %w", path, err)
+	}
+
+	// The server may have switched the version; keep it if it is still offered
+	if s.OCPPVersion != c.config.OCPPVersion {
+		offered := false
+		for _, p := range c.config.GetSubprotocols() {
+			offered = offered || config.SubprotocolVersion(p) == s.OCPPVersion
+		}
+		if !offered {
+			c.log().charger.Warn("State file is for an OCPP version that is not offered: starting with the configured state", "path", path, "state_ocpp_version", s.OCPPVersion)
+			return nil
+		}
+		c.config.OCPPVersion = s.OCPPVersion
+	}
+
+	c.status = s.Status
+	c.meterValue = s.MeterValue
+	c.soc = s.SoC
+	c.seqNo = s.SeqNo
+	c.current = s.Current
+	c.power = s.Power
+	c.licensePlate = s.LicensePlate
+	c.cableLocked = s.CableLocked
+	c.firmwareVersion = s.FirmwareVersion
+	c.security = securityState{profile: s.SecurityProfile, authorizationKey: s.AuthorizationKey}
+	c.diagnostics.addSecret(s.AuthorizationKey)
+	c.keepalive.interval = s.WebSocketPingInterval
+	if s.StopTransactionOnInvalidId != nil {
+		c.invalidId.stopTransaction = *s.StopTransactionOnInvalidId
+	}
+	if s.MaxEnergyOnInvalidId != nil {
+		c.invalidId.maxEnergy = *s.MaxEnergyOnInvalidId
+	}
+	c.securityEvents.queue = s.SecurityEvents
+	c.powerLoss = s.PowerLoss
+
+	outage := time.Since(s.SavedAt).Round(time.Second)
+	c.log().charger.Info("State restored", "path", path, "outage", outage, "status", s.Status, "meter_wh", s.MeterValue, "soc", s.SoC)
+
+	if s.Charging {
+		c.recoverTransaction(s, outage)
+	}
+	return nil
+}
+
+// recoverTransaction continues or stops the transaction open when the
+// simulator stopped, like firmware coming back from a power loss
+func (c *Charger) recoverTransaction(s persistedState, outage time.Duration) {
+	transactionId := s.TransactionIdStr
+	if c.config.IsOCPP16() {
+		transactionId = fmt.Sprintf("%d", s.TransactionId)
+	}
+
+	if timeout := c.config.GetResumeTimeout(); timeout > 0 && outage <= timeout {
+		c.isCharging = true
+		c.idTag = s.IdTag
+		c.transactionId = s.TransactionId
+		c.transactionIdStr = s.TransactionIdStr
+		c.meterStopCh = make(chan struct{})
+		go c.StartMeterValuesLoop()
+		c.log().charger.Info("Transaction resumed after the power loss", logging.TransactionID(transactionId))
+		return
+	}
+
+	c.seqNo++
+	c.powerLoss = &interruptedTransaction{
+		StoppedAt:        s.SavedAt,
+		IdTag:            s.IdTag,
+		TransactionId:    s.TransactionId,
+		TransactionIdStr: s.TransactionIdStr,
+		MeterStop:        s.MeterValue,
+		SeqNo:            c.seqNo,
+	}
+	c.unlockCable()
+	// OCPP 1.6: the cable is still plugged in; OCPP 2.0.1 stays Occupied
+	if c.config.IsOCPP16() {
+		c.status = "Finishing"
+	}
+	c.log().charger.Warn("Transaction stopped by the power loss: reported once the charger is accepted", logging.TransactionID(transactionId))
+	c.events.publish(Event{Type: EventTransactionStopped, TransactionId: transactionId, IdTag: s.IdTag, Reason: StopReasonPowerLoss})
+}
+
+// reportPowerLoss stops the transaction interrupted by a power loss at the
+// server. If it cannot be sent, it is retried after the next accepted boot.
+func (c *Charger) reportPowerLoss() {
+	c.mu.Lock()
+	t := c.powerLoss
+	c.powerLoss = nil
+	c.mu.Unlock()
+	if t == nil {
+		return
+	}
+
+	var err error
+	if c.config.IsOCPP16() {
+		err = c.sendStopTransactionV16(context.Background(), t.MeterStop, t.TransactionId, t.IdTag, StopReasonPowerLoss, t.StoppedAt)
+	} else {
+		err = c.sendStopTransactionV201(context.Background(), t.MeterStop, t.TransactionIdStr, t.SeqNo, StopReasonPowerLoss, t.StoppedAt)
+	}
+	if err != nil {
+		c.log().charger.Error("Failed to report the power loss", logging.Err(err))
+		c.mu.Lock()
+		if c.powerLoss == nil {
+			c.powerLoss = t
+		}
+		c.mu.Unlock()
+	}
+}
@@ -1,8 +1,8 @@
 package charger
 
 import (
+	"context"
 	"fmt"
-	"log"
 	"time"
 
 	"github.com/weilun-shrimp/wlgo_ocpp_charger_simulator/ocpp/v16"
@@ -66,7 +66,9 @@ func (c *Charger) SetStatus(status string) error {
 		c.mu.Unlock()
 	}
 
-	log.Printf("Status changed to: %s", status)
+	c.log().charger.Info("Status changed", "status", status)
+	c.recordSnapshot("StatusChanged")
+	c.events.publish(Event{Type: EventStatusChanged, Status: status})
 
 	// Send to server if connected
 	if isConnected {
@@ -77,34 +79,53 @@ func (c *Charger) SetStatus(status string) error {
 
 // StatusNotification sends a StatusNotification request to the server
 func (c *Charger) StatusNotification(status string) error {
+	return c.StatusNotificationContext(context.Background(), status)
+}
+
+// StatusNotificationContext sends a StatusNotification request to the server,
+// waiting for the response until ctx is done
+func (c *Charger) StatusNotificationContext(ctx context.Context, status string) error {
 	c.mu.Lock()
+	changed := c.status != status
 	c.status = status
 	c.mu.Unlock()
+	if changed {
+		c.events.publish(Event{Type: EventStatusChanged, Status: status})
+	}
 
 	if c.config.IsOCPP16() {
-		return c.statusNotificationV16(status)
+		return c.statusNotificationV16(ctx, status)
 	}
-	return c.statusNotificationV201(status)
+	return c.statusNotificationV201(ctx, status)
 }
 
-func (c *Charger) statusNotificationV16(status string) error {
+func (c *Charger) statusNotificationV16(ctx context.Context, status string) error {
 	req := v16.StatusNotificationRequest{
 		ConnectorId: c.config.ConnectorID,
 		ErrorCode:   "NoError",
 		Status:      v16.ChargePointStatus(status),
 		Timestamp:   time.Now().UTC().Format(time.RFC3339),
 	}
+	// Report the latest active fault
+	c.mu.RLock()
+	if f := c.faults.latest(); f != nil {
+		req.ErrorCode = f.ErrorCode
+		req.Info = f.Info
+		req.VendorId = f.VendorId
+		req.VendorErrorCode = f.VendorErrorCode
+	}
+	c.mu.RUnlock()
 
-	_, err := c.sendCall(v16.ActionStatusNotification, req)
+	_, err := c.sendCallContext(ctx, v16.ActionStatusNotification, req)
 	if err != nil {
 		return fmt.Errorf("StatusNotification failed: %w", err)
 	}
 
-	log.Printf("StatusNotification sent: status=%s", status)
+	c.log().charger.Info("StatusNotification sent", "status", status, "error_code", req.ErrorCode)
 	return nil
 }
 
-func (c *Charger) statusNotificationV201(status string) error {
+func (c *Charger) statusNotificationV201(ctx context.Context, status string) error {
 	req := v201.StatusNotificationRequest{
 		Timestamp:       time.Now().UTC().Format(time.RFC3339),
 		ConnectorStatus: v201.ConnectorStatus(status),
@@ -112,11 +133,11 @@ func (c *Charger) statusNotificationV201(status string) error {
 		ConnectorId:     1,
 	}
 
-	_, err := c.sendCall(v201.ActionStatusNotification, req)
+	_, err := c.sendCallContext(ctx, v201.ActionStatusNotification, req)
 	if err != nil {
 		return fmt.Errorf("StatusNotification failed: %w", err)
 	}
 
-	log.Printf("StatusNotification sent: status=%s", status)
+	c.log().charger.Info("StatusNotification sent", "status", status)
 	return nil
 }
@@ -1,18 +1,25 @@
 package charger
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
-	"log"
 	"time"
 
 	"github.com/google/uuid"
+	"github.com/weilun-shrimp/wlgo_ocpp_charger_simulator/logging"
 	"github.com/weilun-shrimp/wlgo_ocpp_charger_simulator/ocpp/v16"
 	"github.com/weilun-shrimp/wlgo_ocpp_charger_simulator/ocpp/v201"
 )
 
 // StartTransaction starts a transaction locally and sends to server if connected
 func (c *Charger) StartTransaction(idTag string) error {
+	return c.StartTransactionContext(context.Background(), idTag)
+}
+
+// StartTransactionContext starts a transaction locally and sends it to the
+// server if connected, waiting for the response until ctx is done
+func (c *Charger) StartTransactionContext(ctx context.Context, idTag string) error {
 	c.mu.Lock()
 	// OCPP 1.6 requires "Preparing", OCPP 2.0.1 requires "Occupied"
 	requiredStatus := "Preparing"
@@ -21,12 +28,20 @@ func (c *Charger) StartTransaction(idTag string) error {
 	}
 	if c.status != requiredStatus {
 		c.mu.Unlock()
-		return fmt.Errorf("cannot start transaction: status must be %s (current: %s)", requiredStatus, c.status)
+		return invalidState("cannot start transaction: status must be %s (current: %s)", requiredStatus, c.status)
+	}
+	reservationId, err := c.useReservation(idTag)
+	if err != nil {
+		c.mu.Unlock()
+		return fmt.Errorf("cannot start transaction: %w", err)
 	}
 	c.idTag = idTag
 	c.meterValue = 0
 	c.seqNo = 0
 	c.isCharging = true
+	c.clearDeauthorization()
+	c.faults.suspended = false
+	c.lockCable()
 	isConnected := c.isConnected
 
 	// For OCPP 2.0.1, start meter loop here since we don't change status to "Charging"
@@ -36,7 +51,8 @@ func (c *Charger) StartTransaction(idTag string) error {
 	}
 	c.mu.Unlock()
 
-	log.Printf("Transaction started locally: idTag=%s", idTag)
+	c.log().charger.Info("Transaction started locally", "id_tag", idTag)
+	c.recordSnapshot("TransactionStarted")
 
 	// Start meter loop for OCPP 2.0.1 (OCPP 1.6 starts it via SetStatus("Charging"))
 	if shouldStartMeter {
@@ -51,24 +67,32 @@ func (c *Charger) StartTransaction(idTag string) error {
 	}
 
 	// Send to server if connected
+	var sendErr error
 	if isConnected {
 		if c.config.IsOCPP16() {
-			return c.sendStartTransactionV16(idTag)
+			sendErr = c.sendStartTransactionV16(ctx, idTag, reservationId)
+		} else {
+			sendErr = c.sendStartTransactionV201(ctx, idTag, reservationId)
 		}
-		return c.sendStartTransactionV201(idTag)
 	}
-	return nil
+
+	c.mu.RLock()
+	transactionId := c.transactionIdLocked()
+	c.mu.RUnlock()
+	c.events.publish(Event{Type: EventTransactionStarted, TransactionId: transactionId, IdTag: idTag})
+	return sendErr
 }
 
-func (c *Charger) sendStartTransactionV16(idTag string) error {
+func (c *Charger) sendStartTransactionV16(ctx context.Context, idTag string, reservationId int) error {
 	req := v16.StartTransactionRequest{
-		ConnectorId: c.config.ConnectorID,
-		IdTag:       idTag,
-		MeterStart:  c.meterValue,
-		Timestamp:   time.Now().UTC().Format(time.RFC3339),
+		ConnectorId:   c.config.ConnectorID,
+		IdTag:         idTag,
+		MeterStart:    c.meterValue,
+		Timestamp:     time.Now().UTC().Format(time.RFC3339),
+		ReservationId: reservationId,
 	}
 
-	resp, err := c.sendCall(v16.ActionStartTransaction, req)
+	resp, err := c.sendCallContext(ctx, v16.ActionStartTransaction, req)
 	if err != nil {
 		return fmt.Errorf("StartTransaction failed: %w", err)
 	}
@@ -88,13 +112,15 @@ func (c *Charger) sendStartTransactionV16(idTag string) error {
 		c.transactionId = startResp.TransactionId
 		c.mu.Unlock()
 
-		log.Printf("StartTransaction response: transactionId=%d, status=%s", startResp.TransactionId, startResp.IdTagInfo.Status)
+		c.log().charger.Info("StartTransaction response", logging.KeyTransactionID, startResp.TransactionId, "status", startResp.IdTagInfo.Status)
+
+		c.checkAuthorization(startResp.IdTagInfo.Status)
 	}
 
 	return nil
 }
 
-func (c *Charger) sendStartTransactionV201(idTag string) error {
+func (c *Charger) sendStartTransactionV201(ctx context.Context, idTag string, reservationId int) error {
 	c.mu.Lock()
 	c.transactionIdStr = uuid.New().String()
 	transactionIdStr := c.transactionIdStr
@@ -117,29 +143,31 @@ func (c *Charger) sendStartTransactionV201(idTag string) error {
 			IdToken: idTag,
 			Type:    "ISO14443",
 		},
+		ReservationId: reservationId,
 	}
 
-	resp, err := c.sendCall(v201.ActionTransactionEvent, req)
+	resp, err := c.sendCallContext(ctx, v201.ActionTransactionEvent, req)
 	if err != nil {
 		return fmt.Errorf("TransactionEvent (Started) failed: %w", err)
 	}
 
-	log.Printf("TransactionEvent (Started) sent: transactionId=%s", transactionIdStr)
+	c.log().charger.Info("TransactionEvent (Started) sent", logging.KeyTransactionID, transactionIdStr)
 
-	var raw []json.RawMessage
-	if err := json.Unmarshal(resp, &raw); err == nil && len(raw) >= 3 {
-		var eventResp v201.TransactionEventResponse
-		if err := json.Unmarshal(raw[2], &eventResp); err == nil {
-			log.Printf("TransactionEvent response received")
-		}
-	}
+	c.checkTransactionEventResponseV201(resp)
 
 	return nil
 }
 
 // StopTransaction stops a transaction locally and sends to server if connected
 func (c *Charger) StopTransaction(reason string) error {
+	return c.StopTransactionContext(context.Background(), reason)
+}
+
+// StopTransactionContext stops a transaction locally and sends it to the
+// server if connected, waiting for the response until ctx is done
+func (c *Charger) StopTransactionContext(ctx context.Context, reason string) error {
 	c.mu.Lock()
+	transactionIdEvent := c.transactionIdLocked()
 	c.isCharging = false
 	meterValue := c.meterValue
 	transactionId := c.transactionId
@@ -148,6 +176,10 @@ func (c *Charger) StopTransaction(reason string) error {
 	c.seqNo++
 	seqNo := c.seqNo
 	isConnected := c.isConnected
+	c.clearDeauthorization()
+	c.faults.suspended = false
+	c.unlockCable()
+	c.clearTxProfilesLocked()
 
 	// For OCPP 2.0.1, stop meter loop here since we don't change status from "Charging"
 	if !c.config.IsOCPP16() && c.meterStopCh != nil {
@@ -156,7 +188,9 @@ func (c *Charger) StopTransaction(reason string) error {
 	}
 	c.mu.Unlock()
 
-	log.Printf("Transaction stopped locally: reason=%s", reason)
+	c.log().charger.Info("Transaction stopped locally", "reason", reason)
+	c.recordSnapshot("TransactionStopped")
+	c.events.publish(Event{Type: EventTransactionStopped, TransactionId: transactionIdEvent, IdTag: idTag, Reason: reason})
 
 	// Update status locally (and send if connected)
 	// OCPP 1.6: Status changes to "Finishing" (this also stops the meter loop)
@@ -168,46 +202,46 @@ func (c *Charger) StopTransaction(reason string) error {
 	// Send to server if connected
 	if isConnected {
 		if c.config.IsOCPP16() {
-			return c.sendStopTransactionV16(meterValue, transactionId, idTag, reason)
+			return c.sendStopTransactionV16(ctx, meterValue, transactionId, idTag, reason, time.Now())
 		}
-		return c.sendStopTransactionV201(meterValue, transactionIdStr, seqNo, reason)
+		return c.sendStopTransactionV201(ctx, meterValue, transactionIdStr, seqNo, reason, time.Now())
 	}
 	return nil
 }
 
-func (c *Charger) sendStopTransactionV16(meterValue, transactionId int, idTag, reason string) error {
+func (c *Charger) sendStopTransactionV16(ctx context.Context, meterValue, transactionId int, idTag, reason string, stoppedAt time.Time) error {
 	req := v16.StopTransactionRequest{
 		IdTag:         idTag,
 		MeterStop:     meterValue,
-		Timestamp:     time.Now().UTC().Format(time.RFC3339),
+		Timestamp:     stoppedAt.UTC().Format(time.RFC3339),
 		TransactionId: transactionId,
 		Reason:        reason,
 	}
 
-	_, err := c.sendCall(v16.ActionStopTransaction, req)
+	_, err := c.sendCallContext(ctx, v16.ActionStopTransaction, req)
 	if err != nil {
 		return fmt.Errorf("StopTransaction failed: %w", err)
 	}
 
-	log.Printf("StopTransaction sent: transactionId=%d, meterStop=%d, reason=%s", transactionId, meterValue, reason)
+	c.log().charger.Info("StopTransaction sent", logging.KeyTransactionID, transactionId, "meter_stop", meterValue, "reason", reason)
 
 	return nil
 }
 
-func (c *Charger) sendStopTransactionV201(meterValue int, transactionIdStr string, seqNo int, reason string) error {
+func (c *Charger) sendStopTransactionV201(ctx context.Context, meterValue int, transactionIdStr string, seqNo int, reason string, stoppedAt time.Time) error {
 	req := v201.TransactionEventRequest{
 		EventType:     v201.TransactionEventEnded,
-		Timestamp:     time.Now().UTC().Format(time.RFC3339),
-		TriggerReason: v201.TriggerReasonStopAuthorized,
+		Timestamp:     stoppedAt.UTC().Format(time.RFC3339),
+		TriggerReason: triggerReasonForStop(reason),
 		SeqNo:         seqNo,
 		TransactionInfo: v201.Transaction{
 			TransactionId: transactionIdStr,
 			ChargingState: v201.ChargingStateIdle,
-			StoppedReason: reason,
+			StoppedReason: stoppedReasonV201(reason),
 		},
 		MeterValue: []v201.MeterValue{
 			{
-				Timestamp: time.Now().UTC().Format(time.RFC3339),
+				Timestamp: stoppedAt.UTC().Format(time.RFC3339),
 				SampledValue: []v201.SampledValue{
 					{
 						Value:     float64(meterValue),
@@ -222,12 +256,12 @@ func (c *Charger) sendStopTransactionV201(meterValue int, transactionIdStr strin
 		},
 	}
 
-	_, err := c.sendCall(v201.ActionTransactionEvent, req)
+	_, err := c.sendCallContext(ctx, v201.ActionTransactionEvent, req)
 	if err != nil {
 		return fmt.Errorf("TransactionEvent (Ended) failed: %w", err)
 	}
 
-	log.Printf("TransactionEvent (Ended) sent: transactionId=%s, meterStop=%d, reason=%s", transactionIdStr, meterValue, reason)
+	c.log().charger.Info("TransactionEvent (Ended) sent", logging.KeyTransactionID, transactionIdStr, "meter_stop", meterValue, "reason", reason)
 
 	return nil
 }
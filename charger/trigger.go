@@ -0,0 +1,315 @@
+package charger
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/weilun-shrimp/wlgo_ocpp_charger_simulator/logging"
+	"github.com/weilun-shrimp/wlgo_ocpp_charger_simulator/ocpp/v16"
+	"github.com/weilun-shrimp/wlgo_ocpp_charger_simulator/ocpp/v201"
+)
+
+// Requested messages the simulator can send on a TriggerMessage
+const (
+	triggerBootNotification   = "BootNotification"
+	triggerHeartbeat          = "Heartbeat"
+	triggerMeterValues        = "MeterValues"
+	triggerStatusNotification = "StatusNotification"
+	triggerTransactionEvent   = "TransactionEvent" // OCPP 2.0.1 only
+
+	triggerFirmwareStatusNotification    = "FirmwareStatusNotification"
+	triggerDiagnosticsStatusNotification = "DiagnosticsStatusNotification" // OCPP 1.6 only
+	triggerLogStatusNotification         = "LogStatusNotification"
+
+	triggerSignChargePointCertificate     = "SignChargePointCertificate"     // OCPP 1.6 ExtendedTriggerMessage only
+	triggerSignChargingStationCertificate = "SignChargingStationCertificate" // OCPP 2.0.1 only
+)
+
+// handleTriggerMessageV16 handles TriggerMessage from server (OCPP 1.6)
+func (c *Charger) handleTriggerMessageV16(uniqueId string, payload json.RawMessage) {
+	var req v16.TriggerMessageRequest
+	if err := json.Unmarshal(payload, &req); err != nil {
+		c.log().charger.Error("Failed to parse TriggerMessage", logging.Action("TriggerMessage"), logging.UniqueID(uniqueId), logging.Err(err))
+		return
+	}
+
+	c.log().charger.Info("Received TriggerMessage", logging.Action("TriggerMessage"), logging.UniqueID(uniqueId), "requested_message", req.RequestedMessage, "connector_id", req.ConnectorId)
+
+	// SignChargePointCertificate can only be requested with ExtendedTriggerMessage
+	status := "NotImplemented"
+	if req.RequestedMessage != triggerSignChargePointCertificate {
+		status = c.triggerStatus(req.RequestedMessage, req.ConnectorId)
+	}
+
+	resp := v16.TriggerMessageResponse{
+		Status: status,
+	}
+
+	if err := c.sendCallResult(uniqueId, resp); err != nil {
+		c.log().charger.Error("Failed to send TriggerMessage response", logging.Action("TriggerMessage"), logging.UniqueID(uniqueId), logging.Err(err))
+		return
+	}
+
+	if status == "Accepted" {
+		go c.sendTriggeredMessage(req.RequestedMessage)
+	}
+}
+
+// handleExtendedTriggerMessageV16 handles ExtendedTriggerMessage from server
+// (OCPP 1.6 Security Whitepaper)
+func (c *Charger) handleExtendedTriggerMessageV16(uniqueId string, payload json.RawMessage) {
+	var req v16.ExtendedTriggerMessageRequest
+	if err := json.Unmarshal(payload, &req); err != nil {
+		c.log().charger.Error("Failed to parse ExtendedTriggerMessage", logging.Action("ExtendedTriggerMessage"), logging.UniqueID(uniqueId), logging.Err(err))
+		return
+	}
+
+	c.log().charger.Info("Received ExtendedTriggerMessage", logging.Action("ExtendedTriggerMessage"), logging.UniqueID(uniqueId), "requested_message", req.RequestedMessage, "connector_id", req.ConnectorId)
+
+	status := c.triggerStatus(req.RequestedMessage, req.ConnectorId)
+
+	resp := v16.ExtendedTriggerMessageResponse{
+		Status: status,
+	}
+
+	if err := c.sendCallResult(uniqueId, resp); err != nil {
+		c.log().charger.Error("Failed to send ExtendedTriggerMessage response", logging.Action("ExtendedTriggerMessage"), logging.UniqueID(uniqueId), logging.Err(err))
+		return
+	}
+
+	if status != "Accepted" {
+		return
+	}
+	// The Security Whitepaper variant reports SignedFirmwareStatusNotification
+	if req.RequestedMessage == triggerFirmwareStatusNotification {
+		go func() {
+			if err := c.sendTriggered(func() error { return c.sendTriggeredFirmwareStatus(true) }); err != nil {
+				c.log().charger.Warn("Triggered message failed", "requested_message", req.RequestedMessage, logging.Err(err))
+			}
+		}()
+		return
+	}
+	go c.sendTriggeredMessage(req.RequestedMessage)
+}
+
+// handleTriggerMessageV201 handles TriggerMessage from server (OCPP 2.0.1)
+func (c *Charger) handleTriggerMessageV201(uniqueId string, payload json.RawMessage) {
+	var req v201.TriggerMessageRequest
+	if err := json.Unmarshal(payload, &req); err != nil {
+		c.log().charger.Error("Failed to parse TriggerMessage", logging.Action("TriggerMessage"), logging.UniqueID(uniqueId), logging.Err(err))
+		return
+	}
+
+	evseId := 0
+	if req.Evse != nil {
+		evseId = req.Evse.Id
+	}
+
+	c.log().charger.Info("Received TriggerMessage", logging.Action("TriggerMessage"), logging.UniqueID(uniqueId), "requested_message", req.RequestedMessage, "evse_id", evseId)
+
+	status := c.triggerStatus(req.RequestedMessage, evseId)
+
+	var statusInfo *v201.StatusInfo
+	if status == "Rejected" && evseId != 0 && evseId != c.config.ConnectorID {
+		statusInfo = &v201.StatusInfo{ReasonCode: "UnknownEvse"}
+	}
+
+	resp := v201.TriggerMessageResponse{
+		Status:     status,
+		StatusInfo: statusInfo,
+	}
+
+	if err := c.sendCallResult(uniqueId, resp); err != nil {
+		c.log().charger.Error("Failed to send TriggerMessage response", logging.Action("TriggerMessage"), logging.UniqueID(uniqueId), logging.Err(err))
+		return
+	}
+
+	if status == "Accepted" {
+		go c.sendTriggeredMessage(req.RequestedMessage)
+	}
+}
+
+// triggerStatus decides the TriggerMessage response status (Accepted,
+// Rejected, NotImplemented) for the requested message. connectorId is the
+// connector (OCPP 1.6) or EVSE (OCPP 2.0.1) the request applies to, 0 if none.
+func (c *Charger) triggerStatus(requestedMessage string, connectorId int) string {
+	switch requestedMessage {
+	case triggerBootNotification:
+		// OCPP 2.0.1 (F06.FR.17): a BootNotification is only triggered before acceptance
+		if !c.config.IsOCPP16() && c.GetRegistrationStatus() == registrationAccepted {
+			c.log().charger.Info("TriggerMessage rejected: already registered")
+			return "Rejected"
+		}
+		return "Accepted"
+	case triggerHeartbeat, triggerFirmwareStatusNotification, triggerLogStatusNotification:
+		return "Accepted"
+	case triggerDiagnosticsStatusNotification:
+		if !c.config.IsOCPP16() {
+			return "NotImplemented"
+		}
+		return "Accepted"
+	case triggerMeterValues, triggerStatusNotification:
+		if connectorId != 0 && connectorId != c.config.ConnectorID {
+			c.log().charger.Info("TriggerMessage rejected: unknown connector", "connector_id", connectorId)
+			return "Rejected"
+		}
+		return "Accepted"
+	case triggerSignChargePointCertificate:
+		if !c.config.IsOCPP16() {
+			return "NotImplemented"
+		}
+		return "Accepted"
+	case triggerSignChargingStationCertificate:
+		if c.config.IsOCPP16() {
+			return "NotImplemented"
+		}
+		return "Accepted"
+	case triggerTransactionEvent:
+		if c.config.IsOCPP16() {
+			return "NotImplemented"
+		}
+		if connectorId != 0 && connectorId != c.config.ConnectorID {
+			c.log().charger.Info("TriggerMessage rejected: unknown EVSE", "evse_id", connectorId)
+			return "Rejected"
+		}
+		// Only meaningful while a transaction is ongoing
+		if !c.IsCharging() {
+			c.log().charger.Info("TriggerMessage rejected: no transaction ongoing")
+			return "Rejected"
+		}
+		return "Accepted"
+	default:
+		c.log().charger.Warn("TriggerMessage: requested message not implemented", "requested_message", requestedMessage)
+		return "NotImplemented"
+	}
+}
+
+// sendTriggeredMessage sends the message requested by an accepted TriggerMessage,
+// reusing the regular senders
+func (c *Charger) sendTriggeredMessage(requestedMessage string) {
+	if err := c.sendTriggered(func() error { return c.sendRequestedMessage(requestedMessage) }); err != nil {
+		c.log().charger.Warn("Triggered message failed", "requested_message", requestedMessage, logging.Err(err))
+	}
+}
+
+// sendRequestedMessage sends one message requested with TriggerMessage
+func (c *Charger) sendRequestedMessage(requestedMessage string) error {
+	var err error
+	switch requestedMessage {
+	case triggerBootNotification:
+		err = c.sendBootNotification(context.Background(), bootReasonTriggered)
+	case triggerHeartbeat:
+		err = c.Heartbeat()
+	case triggerStatusNotification:
+		err = c.StatusNotification(c.GetStatus())
+	case triggerMeterValues:
+		err = c.sendTriggeredMeterValues()
+	case triggerTransactionEvent:
+		err = c.sendTriggeredTransactionEventV201()
+	case triggerFirmwareStatusNotification:
+		err = c.sendTriggeredFirmwareStatus(false)
+	case triggerDiagnosticsStatusNotification:
+		err = c.sendTriggeredUploadStatus(true)
+	case triggerLogStatusNotification:
+		err = c.sendTriggeredUploadStatus(false)
+	case triggerSignChargePointCertificate, triggerSignChargingStationCertificate:
+		err = c.RenewCertificate()
+	}
+	return err
+}
+
+// sendTriggeredMeterValues sends the current meter reading with context
+// "Trigger" without advancing the meter
+func (c *Charger) sendTriggeredMeterValues() error {
+	sample := c.currentSample()
+
+	c.mu.RLock()
+	transactionId := 0
+	if c.isCharging {
+		transactionId = c.transactionId
+	}
+	c.mu.RUnlock()
+
+	if c.config.IsOCPP16() {
+		req := v16.MeterValuesRequest{
+			ConnectorId:   c.config.ConnectorID,
+			TransactionId: transactionId,
+			MeterValue: []v16.MeterValueEntry{
+				{
+					Timestamp:    time.Now().UTC().Format(time.RFC3339),
+					SampledValue: sample.sampledValuesV16("Trigger"),
+				},
+			},
+		}
+
+		if _, err := c.sendCall(v16.ActionMeterValues, req); err != nil {
+			return fmt.Errorf("MeterValues failed: %w", err)
+		}
+	} else {
+		req := v201.MeterValuesRequest{
+			EvseId: c.config.ConnectorID,
+			MeterValue: []v201.MeterValue{
+				{
+					Timestamp:    time.Now().UTC().Format(time.RFC3339),
+					SampledValue: sample.sampledValuesV201("Trigger"),
+				},
+			},
+		}
+
+		if _, err := c.sendCall(v201.ActionMeterValues, req); err != nil {
+			return fmt.Errorf("MeterValues failed: %w", err)
+		}
+	}
+
+	c.log().meter.Info("MeterValues (Trigger) sent", "energy_wh", sample.energy, "soc", sample.soc)
+	return nil
+}
+
+// sendTriggeredTransactionEventV201 sends a TransactionEvent (Updated) with
+// trigger reason "Trigger" for the ongoing transaction (OCPP 2.0.1)
+func (c *Charger) sendTriggeredTransactionEventV201() error {
+	sample := c.currentSample()
+
+	c.mu.Lock()
+	if !c.isCharging {
+		c.mu.Unlock()
+		return invalidState("no transaction ongoing")
+	}
+	transactionIdStr := c.transactionIdStr
+	chargingState := v201.ChargingStateCharging
+	if c.energySuspendedLocked() {
+		chargingState = v201.ChargingStateSuspendedEVSE
+	}
+	c.seqNo++
+	seqNo := c.seqNo
+	c.mu.Unlock()
+
+	req := v201.TransactionEventRequest{
+		EventType:     v201.TransactionEventUpdated,
+		Timestamp:     time.Now().UTC().Format(time.RFC3339),
+		TriggerReason: v201.TriggerReasonTrigger,
+		SeqNo:         seqNo,
+		TransactionInfo: v201.Transaction{
+			TransactionId: transactionIdStr,
+			ChargingState: chargingState,
+		},
+		MeterValue: []v201.MeterValue{
+			{
+				Timestamp:    time.Now().UTC().Format(time.RFC3339),
+				SampledValue: sample.sampledValuesV201("Trigger"),
+			},
+		},
+	}
+
+	resp, err := c.sendCall(v201.ActionTransactionEvent, req)
+	if err != nil {
+		return fmt.Errorf("TransactionEvent (Updated) failed: %w", err)
+	}
+
+	c.log().charger.Info("TransactionEvent (Updated, Trigger) sent", logging.KeyTransactionID, transactionIdStr)
+
+	c.checkTransactionEventResponseV201(resp)
+	return nil
+}
@@ -0,0 +1,240 @@
+package charger
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"mime/multipart"
+	"net"
+	"net/http"
+	"net/textproto"
+	"net/url"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/weilun-shrimp/wlgo_ocpp_charger_simulator/config"
+)
+
+// uploadTimeout bounds a single upload attempt
+const uploadTimeout = 60 * time.Second
+
+// Upload errors that map to a specific LogStatusNotification status
+var (
+	errUploadPermissionDenied = errors.New("permission denied")
+	errUploadBadLocation      = errors.New("bad location")
+)
+
+// uploadFile uploads data as fileName to location. The location is a
+// directory: HTTP PUT and FTP store the file under it, HTTP POST sends it as
+// the multipart form field "file".
+func (c *Charger) uploadFile(location, fileName string, data []byte) error {
+	switch c.config.GetUploadFailure() {
+	case config.UploadFailureError:
+		return fmt.Errorf("simulated upload failure")
+	case config.UploadFailurePermissionDenied:
+		return fmt.Errorf("simulated upload failure: %w", errUploadPermissionDenied)
+	}
+
+	u, err := url.Parse(location)
+	if err != nil {
+		return fmt.Errorf("%w: %v", errUploadBadLocation, err)
+	}
+
+	switch u.Scheme {
+	case "http", "https":
+		return c.uploadHTTP(u, fileName, data)
+	case "ftp":
+		return uploadFTP(u, fileName, data)
+	default:
+		return fmt.Errorf("%w: unsupported scheme %q (only http, https and ftp)", errUploadBadLocation, u.Scheme)
+	}
+}
+
+// uploadHTTP uploads with the configured HTTP method, using the charger's TLS settings
+func (c *Charger) uploadHTTP(u *url.URL, fileName string, data []byte) error {
+	var req *http.Request
+	var err error
+
+	if c.config.GetUploadHTTPMethod() == "POST" {
+		var body bytes.Buffer
+		form := multipart.NewWriter(&body)
+		part, err := form.CreateFormFile("file", fileName)
+		if err != nil {
+			return err
+		}
+		if _, err := part.Write(data); err != nil {
+			return err
+		}
+		if err := form.Close(); err != nil {
+			return err
+		}
+		req, err = http.NewRequest(http.MethodPost, u.String(), &body)
+		if err != nil {
+			return fmt.Errorf("%w: %v", errUploadBadLocation, err)
+		}
+		req.Header.Set("Content-Type", form.FormDataContentType())
+	} else {
+		target := *u
+		target.Path = path.Join("/", u.Path, fileName)
+		req, err = http.NewRequest(http.MethodPut, target.String(), bytes.NewReader(data))
+		if err != nil {
+			return fmt.Errorf("%w: %v", errUploadBadLocation, err)
+		}
+		req.Header.Set("Content-Type", "application/zip")
+	}
+
+	client := &http.Client{
+		Timeout: uploadTimeout,
+		Transport: &http.Transport{
+			Proxy:           http.ProxyFromEnvironment,
+			TLSClientConfig: c.tlsConfig,
+		},
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+
+	switch {
+	case resp.StatusCode == http.StatusUnauthorized || resp.StatusCode == http.StatusForbidden:
+		return fmt.Errorf("server responded %s: %w", resp.Status, errUploadPermissionDenied)
+	case resp.StatusCode < 200 || resp.StatusCode > 299:
+		return fmt.Errorf("server responded %s", resp.Status)
+	}
+	return nil
+}
+
+// uploadFTP stores the file with a minimal passive-mode FTP client. Credentials
+// come from the URL (default: anonymous); the path is relative to the login directory.
+func uploadFTP(u *url.URL, fileName string, data []byte) error {
+	host := u.Host
+	if u.Port() == "" {
+		host = net.JoinHostPort(u.Hostname(), "21")
+	}
+
+	conn, err := net.DialTimeout("tcp", host, uploadTimeout)
+	if err != nil {
+		return err
+	}
+	conn.SetDeadline(time.Now().Add(uploadTimeout))
+	tp := textproto.NewConn(conn)
+	defer tp.Close()
+
+	if _, _, err := tp.ReadResponse(220); err != nil {
+		return fmt.Errorf("FTP greeting: %w", err)
+	}
+
+	user, pass := "anonymous", "anonymous@"
+	if u.User != nil {
+		user = u.User.Username()
+		if p, ok := u.User.Password(); ok {
+			pass = p
+		}
+	}
+
+	code, msg, err := ftpCmd(tp, "USER %s", user)
+	if err != nil {
+		return err
+	}
+	if code == 331 {
+		code, msg, err = ftpCmd(tp, "PASS %s", pass)
+		if err != nil {
+			return err
+		}
+	}
+	if code == 530 {
+		return fmt.Errorf("FTP login: %d %s: %w", code, msg, errUploadPermissionDenied)
+	}
+	if code != 230 {
+		return fmt.Errorf("FTP login: %d %s", code, msg)
+	}
+
+	if code, msg, err = ftpCmd(tp, "TYPE I"); err != nil {
+		return err
+	} else if code != 200 {
+		return fmt.Errorf("FTP TYPE: %d %s", code, msg)
+	}
+
+	code, msg, err = ftpCmd(tp, "PASV")
+	if err != nil {
+		return err
+	}
+	if code != 227 {
+		return fmt.Errorf("FTP PASV: %d %s", code, msg)
+	}
+	port, err := parsePASVPort(msg)
+	if err != nil {
+		return err
+	}
+
+	// Connect to the control connection's host: the address in the PASV reply
+	// is often unreachable behind NAT
+	dataConn, err := net.DialTimeout("tcp", net.JoinHostPort(u.Hostname(), strconv.Itoa(port)), uploadTimeout)
+	if err != nil {
+		return fmt.Errorf("FTP data connection: %w", err)
+	}
+	defer dataConn.Close()
+
+	target := strings.TrimPrefix(path.Join(u.Path, fileName), "/")
+	code, msg, err = ftpCmd(tp, "STOR %s", target)
+	if err != nil {
+		return err
+	}
+	if code == 550 || code == 553 {
+		return fmt.Errorf("FTP STOR: %d %s: %w", code, msg, errUploadPermissionDenied)
+	}
+	if code != 125 && code != 150 {
+		return fmt.Errorf("FTP STOR: %d %s", code, msg)
+	}
+
+	dataConn.SetDeadline(time.Now().Add(uploadTimeout))
+	if _, err := dataConn.Write(data); err != nil {
+		return fmt.Errorf("FTP transfer: %w", err)
+	}
+	dataConn.Close()
+
+	if code, msg, err = tp.ReadResponse(0); err != nil {
+		return fmt.Errorf("FTP transfer: %w", err)
+	} else if code != 226 && code != 250 {
+		return fmt.Errorf("FTP transfer: %d %s", code, msg)
+	}
+
+	ftpCmd(tp, "QUIT")
+	return nil
+}
+
+// ftpCmd sends an FTP command and reads its reply
+func ftpCmd(tp *textproto.Conn, format string, args ...any) (int, string, error) {
+	if err := tp.PrintfLine(format, args...); err != nil {
+		return 0, "", err
+	}
+	code, msg, err := tp.ReadResponse(0)
+	if err != nil {
+		return 0, "", fmt.Errorf("FTP %s: %w", strings.Fields(format)[0], err)
+	}
+	return code, msg, nil
+}
+
+// parsePASVPort extracts the data port from a "227 Entering Passive Mode
+// (h1,h2,h3,h4,p1,p2)" reply
+func parsePASVPort(msg string) (int, error) {
+	start := strings.Index(msg, "(")
+	end := strings.LastIndex(msg, ")")
+	if start < 0 || end < start {
+		return 0, fmt.Errorf("invalid PASV reply: %s", msg)
+	}
+	fields := strings.Split(msg[start+1:end], ",")
+	if len(fields) != 6 {
+		return 0, fmt.Errorf("invalid PASV reply: %s", msg)
+	}
+	p1, err1 := strconv.Atoi(strings.TrimSpace(fields[4]))
+	p2, err2 := strconv.Atoi(strings.TrimSpace(fields[5]))
+	if err1 != nil || err2 != nil {
+		return 0, fmt.Errorf("invalid PASV reply: %s", msg)
+	}
+	return p1*256 + p2, nil
+}
@@ -0,0 +1,223 @@
+package charger
+
+import (
+	"crypto/rand"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/weilun-shrimp/wlgo_ocpp_charger_simulator/config"
+	"github.com/weilun-shrimp/wlgo_ocpp_charger_simulator/logging"
+	"github.com/weilun-shrimp/wlgows/connection"
+	"github.com/weilun-shrimp/wlgows/frame"
+)
+
+// WebSocket opcodes of control frames (RFC 6455)
+const (
+	opcodeClose = 8
+	opcodePing  = 9
+	opcodePong  = 10
+)
+
+// maxControlPayload is the largest payload allowed in a control frame
+const maxControlPayload = 125
+
+// keepalive holds the WebSocket ping settings. It has its own lock so pongs can
+// be recorded while c.mu is held.
+type keepalive struct {
+	mu       sync.Mutex
+	interval int           // WebSocketPingInterval in seconds, 0: disabled
+	changed  chan struct{} // wakes the ping loop when the interval changes
+	pong     chan struct{} // signalled when a pong is received
+}
+
+// newKeepalive creates the keepalive with the given ping interval
+func newKeepalive(interval int) *keepalive {
+	return &keepalive{
+		interval: interval,
+		changed:  make(chan struct{}, 1),
+		pong:     make(chan struct{}, 1),
+	}
+}
+
+// getInterval returns the ping interval in seconds
+func (k *keepalive) getInterval() int {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	return k.interval
+}
+
+// setInterval changes the ping interval and wakes the ping loop
+func (k *keepalive) setInterval(interval int) {
+	k.mu.Lock()
+	k.interval = interval
+	k.mu.Unlock()
+	select {
+	case k.changed <- struct{}{}:
+	default:
+	}
+}
+
+// received records a pong from the server
+func (k *keepalive) received() {
+	select {
+	case k.pong <- struct{}{}:
+	default:
+	}
+}
+
+// GetSubprotocol returns the WebSocket subprotocol agreed with the server, or
+// "" if not connected
+func (c *Charger) GetSubprotocol() string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.subprotocol
+}
+
+// acceptSubprotocol checks the subprotocol the server selected in the handshake
+// against the offered ones. If the server picked another OCPP version than the
+// configured one, the charger switches to it.
+func (c *Charger) acceptSubprotocol(selected string, offered []string) error {
+	if selected == "" {
+		return fmt.Errorf("server did not agree to subprotocol %s", strings.Join(offered, ", "))
+	}
+	if !slices.Contains(offered, selected) {
+		return fmt.Errorf("server selected subprotocol %s, which was not offered (%s)", selected, strings.Join(offered, ", "))
+	}
+
+	c.mu.Lock()
+	c.subprotocol = selected
+	version := config.SubprotocolVersion(selected)
+	switched := version != c.config.OCPPVersion
+	if switched {
+		// Keep reporting the version's default firmware unless it was updated
+		defaultFirmware := c.firmwareVersion == c.config.GetFirmwareVersion()
+		c.config.OCPPVersion = version
+		if defaultFirmware {
+			c.firmwareVersion = c.config.GetFirmwareVersion()
+		}
+	}
+	c.mu.Unlock()
+
+	if switched {
+		c.updateLoggers(version)
+		c.log().wire.Info("Server selected another OCPP version: switching", "subprotocol", selected)
+	} else {
+		c.log().wire.Info("Subprotocol agreed", "subprotocol", selected)
+	}
+	return nil
+}
+
+// GetWebSocketPingInterval returns the ping interval in seconds (0: disabled)
+func (c *Charger) GetWebSocketPingInterval() int {
+	return c.keepalive.getInterval()
+}
+
+// setWebSocketPingInterval sets WebSocketPingInterval from a configuration value
+func (c *Charger) setWebSocketPingInterval(value string) (bool, error) {
+	interval, err := strconv.Atoi(value)
+	if err != nil || interval < 0 {
+		return false, fmt.Errorf("invalid WebSocketPingInterval %q", value)
+	}
+	c.keepalive.setInterval(interval)
+	c.log().wire.Info("WebSocket ping interval set", "interval_s", interval)
+	return false, nil
+}
+
+// pingLoop pings the server every WebSocketPingInterval while conn is open. A
+// pong not received within the pong timeout means the connection is half-open:
+// it is dropped, like a lost network link, so the charger goes offline.
+func (c *Charger) pingLoop(conn *connection.ClientConn, stopCh chan struct{}) {
+	k := c.keepalive
+	timeout := c.config.GetPongTimeout()
+
+	for {
+		var tick <-chan time.Time // nil while disabled: wait for a change
+		if interval := k.getInterval(); interval > 0 {
+			tick = time.After(time.Duration(interval) * time.Second)
+		}
+
+		select {
+		case <-stopCh:
+			return
+		case <-k.changed:
+			continue
+		case <-tick:
+		}
+
+		// Discard a pong to an earlier ping
+		select {
+		case <-k.pong:
+		default:
+		}
+		if err := sendControlFrame(conn, opcodePing, nil); err != nil {
+			c.log().wire.Error("Failed to send ping", logging.Err(err))
+			return
+		}
+
+		select {
+		case <-stopCh:
+			return
+		case <-k.pong:
+		case <-time.After(timeout):
+			c.log().wire.Warn("No pong in time: connection is half-open", "timeout", timeout)
+			killConnection(conn)
+			return
+		}
+	}
+}
+
+// handleControlFrame handles a ping, pong or close frame from the server. It
+// returns false if the server closed the connection.
+func (c *Charger) handleControlFrame(conn *connection.ClientConn, f *frame.Frame) bool {
+	switch f.Opcode {
+	case opcodePing:
+		if c.netFaults.dropInbound() {
+			c.log().wire.Warn("Network fault: inbound ping dropped")
+			return true
+		}
+		if err := sendControlFrame(conn, opcodePong, f.PayloadData); err != nil {
+			c.log().wire.Error("Failed to send pong", logging.Err(err))
+		}
+	case opcodePong:
+		if c.netFaults.dropInbound() {
+			c.log().wire.Warn("Network fault: inbound pong dropped")
+			return true
+		}
+		c.keepalive.received()
+	case opcodeClose:
+		c.log().wire.Warn("Server closed connection (close frame)")
+		// Echo the status code, as RFC 6455 requires
+		payload := f.PayloadData
+		if len(payload) > 2 {
+			payload = payload[:2]
+		}
+		sendControlFrame(conn, opcodeClose, payload)
+		return false
+	}
+	return true
+}
+
+// sendControlFrame sends a masked control frame
+func sendControlFrame(conn *connection.ClientConn, opcode byte, payload []byte) error {
+	if len(payload) > maxControlPayload {
+		payload = payload[:maxControlPayload]
+	}
+	maskingKey := make([]byte, 4)
+	if _, err := rand.Read(maskingKey); err != nil {
+		return err
+	}
+	f := &frame.Frame{
+		FIN:           true,
+		Opcode:        opcode,
+		Mask:          true,
+		MaskingKey:    maskingKey,
+		PayloadLength: byte(len(payload)),
+		PayloadData:   payload,
+	}
+	_, err := conn.Write(f.Seal())
+	return err
+}
@@ -1,5 +1,13 @@
 package cli
 
+import (
+	"crypto/x509"
+	"encoding/json"
+	"time"
+
+	"github.com/weilun-shrimp/wlgo_ocpp_charger_simulator/config"
+)
+
 // Charger is the subset of *charger.Charger behavior the interactive commands
 // depend on. Depending on an interface (rather than the concrete type) lets
 // tests substitute an in-memory fake so every command runs deterministically
@@ -11,7 +19,10 @@ type Charger interface {
 	IsConnected() bool
 	Connect() error
 	Disconnect()
+	Reset() error
+	WatchdogReset() error
 	BootNotification() error
+	GetRegistrationStatus() string
 	StatusNotification(status string) error
 	GetStatus() string
 	SetStatus(status string) error
@@ -29,4 +40,29 @@ type Charger interface {
 	SetPower(power float64) error
 	GetPower() float64
 	IsCharging() bool
+	IsCableLocked() bool
+	IsLockJammed() bool
+	SetLockJammed(jammed bool) error
+	GetFirmwareVersion() string
+	GetSecurityProfile() int
+	GetSubprotocol() string
+	GetClientCertificate() *x509.Certificate
+	RenewCertificate() error
+	RaiseSecurityEvent(eventType, techInfo string)
+	QueuedSecurityEvents() int
+	RaiseFault(errorCode, info, vendorId, vendorErrorCode string) error
+	ClearFault(errorCode string) error
+	ActiveFaults() []string
+	NetworkFaults() config.NetworkFaultsConfig
+	SetNetworkFaults(settings config.NetworkFaultsConfig) error
+	KillConnection() error
+	SendCall(action string, payload json.RawMessage) (json.RawMessage, error)
+	GetTransactionId() string
+	GetIdTag() string
+	GetMeterValue() int
+	GetActualPower() float64
+	GetHeartbeatInterval() int
+	GetLastHeartbeat() time.Time
+	ChargingProfiles() []string
+	RecentFrames() []string
 }
@@ -0,0 +1,50 @@
+package cli
+
+import (
+	"fmt"
+	"time"
+)
+
+func init() {
+	register("cert", Command{
+		Handler: handleCert,
+		Usage:   "cert [renew]",
+		Help: "Show the TLS client certificate, or renew it: a new key pair is generated " +
+			"and its CSR sent in SignCertificate. The signed certificate is used from " +
+			"the next connection.",
+		Complete: completeCert,
+	})
+}
+
+// handleCert shows the TLS client certificate, or renews it: a new key pair is
+// generated and its CSR sent in SignCertificate. The signed certificate is used
+// from the next connection.
+func handleCert(ctx *CommandContext, args []string) {
+	if len(args) < 1 {
+		cert := ctx.Charger.GetClientCertificate()
+		if cert == nil {
+			fmt.Fprintln(ctx.Out, "Client certificate: none")
+			return
+		}
+		fmt.Fprintf(ctx.Out, "Client certificate: %s (issuer %s, expires %s)\n",
+			cert.Subject, cert.Issuer, cert.NotAfter.UTC().Format(time.RFC3339))
+		return
+	}
+	if args[0] != "renew" {
+		ctx.Failf("Usage: cert [renew]")
+		return
+	}
+	if err := ctx.Charger.RenewCertificate(); err != nil {
+		ctx.Failf("Error: %v", err)
+		return
+	}
+	fmt.Fprintln(ctx.Out, "Certificate signing request accepted, waiting for CertificateSigned")
+}
+
+// completeCert completes the cert action.
+func completeCert(ctx *CommandContext, args []string) []string {
+	if len(args) == 1 {
+		return []string{"renew"}
+	}
+	return nil
+}
@@ -0,0 +1,65 @@
+package cli
+
+import (
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestHandleCert(t *testing.T) {
+	t.Run("no certificate", func(t *testing.T) {
+		f := &fakeCharger{}
+		ctx, buf := newCtx(f, cfg16())
+		handleCert(ctx, nil)
+		if !strings.Contains(buf.String(), "Client certificate: none") {
+			t.Errorf("got %q", buf.String())
+		}
+	})
+
+	t.Run("shows certificate", func(t *testing.T) {
+		f := &fakeCharger{clientCert: &x509.Certificate{
+			Subject:  pkix.Name{CommonName: "CP1"},
+			Issuer:   pkix.Name{CommonName: "Sub CA"},
+			NotAfter: time.Date(2027, 1, 2, 3, 4, 5, 0, time.UTC),
+		}}
+		ctx, buf := newCtx(f, cfg16())
+		handleCert(ctx, nil)
+		want := "Client certificate: CN=CP1 (issuer CN=Sub CA, expires 2027-01-02T03:04:05Z)"
+		if !strings.Contains(buf.String(), want) {
+			t.Errorf("got %q, want %q", buf.String(), want)
+		}
+	})
+
+	t.Run("usage on unknown argument", func(t *testing.T) {
+		f := &fakeCharger{}
+		ctx, buf := newCtx(f, cfg16())
+		handleCert(ctx, []string{"delete"})
+		if !strings.Contains(buf.String(), "Usage: cert [renew]") {
+			t.Errorf("got %q", buf.String())
+		}
+	})
+
+	t.Run("renew", func(t *testing.T) {
+		f := &fakeCharger{}
+		ctx, buf := newCtx(f, cfg201())
+		handleCert(ctx, []string{"renew"})
+		if f.renewCalls != 1 {
+			t.Errorf("RenewCertificate called %d times, want 1", f.renewCalls)
+		}
+		if !strings.Contains(buf.String(), "waiting for CertificateSigned") {
+			t.Errorf("got %q", buf.String())
+		}
+	})
+
+	t.Run("renew error", func(t *testing.T) {
+		f := &fakeCharger{renewErr: errors.New("SignCertificate rejected")}
+		ctx, buf := newCtx(f, cfg16())
+		handleCert(ctx, []string{"renew"})
+		if !strings.Contains(buf.String(), "Error: SignCertificate rejected") {
+			t.Errorf("got %q", buf.String())
+		}
+	})
+}
@@ -0,0 +1,51 @@
+package cli
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/weilun-shrimp/wlgo_ocpp_charger_simulator/config"
+)
+
+func init() {
+	register("config", Command{
+		Handler: handleConfig,
+		Usage:   "config show",
+		Help: "Show the effective configuration: every field that is set, its value " +
+			"(secrets masked) and where it comes from (default, file, env or flag).",
+		Complete: func(ctx *CommandContext, args []string) []string {
+			if len(args) == 1 {
+				return []string{"show"}
+			}
+			return nil
+		},
+	})
+}
+
+// handleConfig shows the effective configuration
+func handleConfig(ctx *CommandContext, args []string) {
+	if len(args) != 1 || args[0] != "show" {
+		ctx.Failf("Usage: config show")
+		return
+	}
+	PrintConfig(ctx.Out, ctx.Config)
+}
+
+// PrintConfig writes the fields of cfg that are set as "key = value (source)"
+// lines, with secrets masked.
+func PrintConfig(w io.Writer, cfg *config.Config) {
+	fields := cfg.Fields()
+	width := 0
+	for _, f := range fields {
+		width = max(width, len(f.Key))
+	}
+	fmt.Fprintln(w, "Effective configuration (precedence: default < file < env < flag):")
+	for _, f := range fields {
+		source := f.Source
+		if source == "" {
+			source = "-"
+		}
+		fmt.Fprintf(w, "  %-*s = %s (%s)\n", width, f.Key, f.Value, source)
+	}
+	fmt.Fprintln(w, "Fields not listed are unset and use their built-in defaults.")
+}
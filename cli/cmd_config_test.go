@@ -0,0 +1,59 @@
+package cli
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/weilun-shrimp/wlgo_ocpp_charger_simulator/config"
+)
+
+func TestHandleConfig(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "config.yaml")
+	yaml := "ocpp_version: \"1.6\"\ncharger_id: CP1\nserver_url: ws://localhost:8080/ocpp\nmax_current: 32\nmax_power: 7360\n" +
+		"auth:\n  scheme: Basic\n  value: from-file\n"
+	if err := os.WriteFile(path, []byte(yaml), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	secret := filepath.Join(dir, "auth")
+	if err := os.WriteFile(secret, []byte("s3cret\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	cfg, err := config.LoadWith(config.LoadOptions{
+		Path:      path,
+		Environ:   []string{"OCPPSIM_CHARGER_ID=CP2", "OCPPSIM_AUTH_VALUE_FILE=" + secret, "HOME=/root"},
+		Overrides: []config.Override{{Key: "max_current", Value: "16", Source: "flag --set"}},
+	})
+	if err != nil {
+		t.Fatalf("LoadWith: %v", err)
+	}
+	if cfg.ChargerID != "CP2" || cfg.Auth.Value != "s3cret" || cfg.MaxCurrent != 16 {
+		t.Fatalf("layers not applied: %s %q %v", cfg.ChargerID, cfg.Auth.Value, cfg.MaxCurrent)
+	}
+
+	ctx, buf := newCtx(&fakeCharger{}, cfg)
+	handleConfig(ctx, []string{"show"})
+	out := strings.Join(strings.Fields(buf.String()), " ") // without the column padding
+	for _, want := range []string{
+		"charger_id = CP2 (env OCPPSIM_CHARGER_ID)",
+		"auth.value = ******** (env OCPPSIM_AUTH_VALUE_FILE)",
+		"auth.scheme = Basic (file)",
+		"max_current = 16 (flag --set)",
+		"voltage = 230 (default)",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("missing %q in:\n%s", want, out)
+		}
+	}
+	if strings.Contains(out, "s3cret") || strings.Contains(out, "from-file") {
+		t.Errorf("secret shown:\n%s", out)
+	}
+
+	ctx, buf = newCtx(&fakeCharger{}, cfg)
+	handleConfig(ctx, nil)
+	if !strings.Contains(buf.String(), "Usage: config show") {
+		t.Errorf("expected usage, got %q", buf.String())
+	}
+}
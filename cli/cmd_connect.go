@@ -2,27 +2,44 @@ package cli
 
 import "fmt"
 
-func init() { register("connect", handleConnect) }
+func init() {
+	register("connect", Command{
+		Handler: handleConnect,
+		Usage:   "connect",
+		Help: "Connect to the OCPP server and send BootNotification, then the current " +
+			"StatusNotification once the charger is accepted. A Pending or Rejected " +
+			"charger retries the BootNotification on its own.",
+	})
+}
 
 // handleConnect connects to the server and, on success, sends BootNotification
-// followed by the current StatusNotification.
+// followed, once the charger is accepted, by the current StatusNotification. A
+// Pending or Rejected charger retries the BootNotification on its own and sends
+// the StatusNotification when accepted.
 func handleConnect(ctx *CommandContext, args []string) {
 	if ctx.Charger.IsConnected() {
-		fmt.Fprintln(ctx.Out, "Already connected")
+		ctx.Failf("Already connected")
 		return
 	}
 	if err := ctx.Charger.Connect(); err != nil {
-		fmt.Fprintf(ctx.Out, "Error: %v\n", err)
+		ctx.Failf("Error: %v", err)
 		return
 	}
 	fmt.Fprintln(ctx.Out, "Connected to server")
 
 	if err := ctx.Charger.BootNotification(); err != nil {
-		fmt.Fprintf(ctx.Out, "BootNotification failed: %v\n", err)
+		ctx.Failf("BootNotification failed: %v", err)
+		return
+	}
+
+	registration := ctx.Charger.GetRegistrationStatus()
+	fmt.Fprintf(ctx.Out, "Registration: %s\n", registration)
+	if registration != "Accepted" {
+		fmt.Fprintln(ctx.Out, "Waiting for the server to accept the charger; BootNotification will be retried")
 		return
 	}
 
 	if err := ctx.Charger.StatusNotification(ctx.Charger.GetStatus()); err != nil {
-		fmt.Fprintf(ctx.Out, "StatusNotification failed: %v\n", err)
+		ctx.Failf("StatusNotification failed: %v", err)
 	}
 }
@@ -33,6 +33,36 @@ func TestHandleConnect(t *testing.T) {
 		if !f.statusNotifSet || f.statusNotifArg != "Available" {
 			t.Errorf("StatusNotification not sent with current status: set=%v arg=%q", f.statusNotifSet, f.statusNotifArg)
 		}
+		if !strings.Contains(out, "Registration: Accepted") {
+			t.Errorf("missing registration state: %q", out)
+		}
+	})
+
+	for _, registration := range []string{"Pending", "Rejected"} {
+		t.Run("registration "+registration, func(t *testing.T) {
+			f := &fakeCharger{status: "Available", bootStatus: registration}
+			ctx, buf := newCtx(f, cfg201())
+			handleConnect(ctx, nil)
+			out := buf.String()
+			if !strings.Contains(out, "Registration: "+registration) || !strings.Contains(out, "BootNotification will be retried") {
+				t.Errorf("got %q", out)
+			}
+			if f.statusNotifSet {
+				t.Errorf("StatusNotification must wait for the charger to be accepted")
+			}
+		})
+	}
+
+	t.Run("boot error", func(t *testing.T) {
+		f := &fakeCharger{bootErr: errors.New("timeout waiting for response")}
+		ctx, buf := newCtx(f, cfg16())
+		handleConnect(ctx, nil)
+		if !strings.Contains(buf.String(), "BootNotification failed: timeout waiting for response") {
+			t.Errorf("got %q", buf.String())
+		}
+		if f.statusNotifSet {
+			t.Errorf("StatusNotification must not be sent after a failed BootNotification")
+		}
 	})
 
 	t.Run("connect error", func(t *testing.T) {
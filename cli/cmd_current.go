@@ -2,7 +2,14 @@ package cli
 
 import "fmt"
 
-func init() { register("current", handleCurrent) }
+func init() {
+	register("current", Command{
+		Handler: handleCurrent,
+		Usage:   "current <amperes>",
+		Help: "Set the charging current limit (0 = SuspendedEVSE). Without an argument, " +
+			"show the present value.",
+	})
+}
 
 // handleCurrent sets the charging current, or reports usage plus the present
 // value when no argument is given.
@@ -14,11 +21,11 @@ func handleCurrent(ctx *CommandContext, args []string) {
 	}
 	var current float64
 	if _, err := fmt.Sscanf(args[0], "%f", &current); err != nil {
-		fmt.Fprintf(ctx.Out, "Error: invalid current value: %s\n", args[0])
+		ctx.Failf("Error: invalid current value: %s", args[0])
 		return
 	}
 	if err := ctx.Charger.SetCurrent(current); err != nil {
-		fmt.Fprintf(ctx.Out, "Error: %v\n", err)
+		ctx.Failf("Error: %v", err)
 	} else {
 		fmt.Fprintf(ctx.Out, "Current set to: %.1f A\n", current)
 	}
@@ -0,0 +1,21 @@
+package cli
+
+func init() {
+	register("dashboard", Command{
+		Handler: handleDashboard,
+		Usage:   "dashboard",
+		Help: "Show a full-screen view of the charger state, an energy sparkline, the OCPP frames " +
+			"and the log, refreshed every second. Commands still run on its bottom row; " +
+			"'filter [text]' filters the frames, 'pretty' toggles pretty-printing and 'exit' leaves it.",
+	})
+}
+
+// handleDashboard shows the dashboard until it is left. It needs the
+// interactive shell on a terminal.
+func handleDashboard(ctx *CommandContext, args []string) {
+	if ctx.shell == nil || ctx.shell.editor == nil {
+		ctx.Failf("Error: the dashboard needs a terminal")
+		return
+	}
+	ctx.shell.runDashboard()
+}
@@ -0,0 +1,24 @@
+package cli
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestHandleDashboard(t *testing.T) {
+	t.Run("without a terminal", func(t *testing.T) {
+		ctx, buf := newCtx(&fakeCharger{}, cfg16())
+		handleDashboard(ctx, nil)
+		if !strings.Contains(buf.String(), "Error: the dashboard needs a terminal") {
+			t.Errorf("expected terminal error, got %q", buf.String())
+		}
+	})
+
+	t.Run("from a piped shell", func(t *testing.T) {
+		var out strings.Builder
+		NewShell(&fakeCharger{}, cfg16(), strings.NewReader("dashboard\n"), &out).Run()
+		if !strings.Contains(out.String(), "Error: the dashboard needs a terminal") {
+			t.Errorf("expected terminal error, got %q", out.String())
+		}
+	})
+}
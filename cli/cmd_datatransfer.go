@@ -0,0 +1,66 @@
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+func init() {
+	register("datatransfer", Command{
+		Handler: handleDataTransfer,
+		Usage:   "datatransfer <vendorId> [messageId|-] [data]",
+		Help: "Send a vendor DataTransfer and print the status and data of the response. " +
+			"A messageId of \"-\" sends none; the remaining arguments are the data.",
+	})
+}
+
+// handleDataTransfer sends a vendor DataTransfer and prints the status and
+// data of the response. A messageId of "-" sends none; the remaining
+// arguments are the data.
+func handleDataTransfer(ctx *CommandContext, args []string) {
+	if len(args) < 1 {
+		ctx.Failf("Usage: datatransfer <vendorId> [messageId|-] [data]")
+		fmt.Fprintln(ctx.Out, `Example: datatransfer com.example SetLedColor {"color":"green"}`)
+		return
+	}
+
+	req := struct {
+		VendorId  string `json:"vendorId"`
+		MessageId string `json:"messageId,omitempty"`
+		Data      string `json:"data,omitempty"`
+	}{VendorId: args[0]}
+	if len(args) > 1 && args[1] != "-" {
+		req.MessageId = args[1]
+	}
+	if len(args) > 2 {
+		req.Data = strings.Join(args[2:], " ")
+	}
+	payload, err := json.Marshal(req)
+	if err != nil {
+		ctx.Failf("Error: %v", err)
+		return
+	}
+
+	raw, err := ctx.Charger.SendCall("DataTransfer", payload)
+	if err != nil {
+		ctx.Failf("Error: %v", err)
+		return
+	}
+	var resp struct {
+		Status string          `json:"status"`
+		Data   json.RawMessage `json:"data"`
+	}
+	if err := json.Unmarshal(raw, &resp); err != nil {
+		ctx.Failf("Error: invalid DataTransfer response: %s", raw)
+		return
+	}
+	fmt.Fprintf(ctx.Out, "DataTransfer %s: %s\n", args[0], resp.Status)
+	if len(resp.Data) > 0 {
+		var data string
+		if json.Unmarshal(resp.Data, &data) != nil {
+			data = string(resp.Data)
+		}
+		fmt.Fprintf(ctx.Out, "Data: %s\n", data)
+	}
+}
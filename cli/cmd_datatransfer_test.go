@@ -0,0 +1,60 @@
+package cli
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestHandleDataTransfer(t *testing.T) {
+	t.Run("usage without vendorId", func(t *testing.T) {
+		f := &fakeCharger{}
+		ctx, buf := newCtx(f, cfg16())
+		handleDataTransfer(ctx, nil)
+		if !strings.Contains(buf.String(), "Usage: datatransfer <vendorId> [messageId|-] [data]") {
+			t.Errorf("got %q", buf.String())
+		}
+		if f.lastSendAction != "" {
+			t.Errorf("must not send, sent %q", f.lastSendAction)
+		}
+	})
+
+	t.Run("sends vendor message and prints response", func(t *testing.T) {
+		f := &fakeCharger{connected: true, sendResponse: json.RawMessage(`{"status":"Accepted","data":"ok"}`)}
+		ctx, buf := newCtx(f, cfg16())
+		handleDataTransfer(ctx, []string{"com.example", "SetLedColor", `{"color":`, `"green"}`})
+		if f.lastSendAction != "DataTransfer" {
+			t.Errorf("sent %q", f.lastSendAction)
+		}
+		want := `{"vendorId":"com.example","messageId":"SetLedColor","data":"{\"color\": \"green\"}"}`
+		if f.lastSendPayload != want {
+			t.Errorf("sent %s, want %s", f.lastSendPayload, want)
+		}
+		out := buf.String()
+		if !strings.Contains(out, "DataTransfer com.example: Accepted") || !strings.Contains(out, "Data: ok") {
+			t.Errorf("got %q", out)
+		}
+	})
+
+	t.Run("dash sends no messageId", func(t *testing.T) {
+		f := &fakeCharger{connected: true, sendResponse: json.RawMessage(`{"status":"UnknownVendorId"}`)}
+		ctx, buf := newCtx(f, cfg201())
+		handleDataTransfer(ctx, []string{"com.example", "-", "hello"})
+		if f.lastSendPayload != `{"vendorId":"com.example","data":"hello"}` {
+			t.Errorf("sent %s", f.lastSendPayload)
+		}
+		if !strings.Contains(buf.String(), "DataTransfer com.example: UnknownVendorId") || strings.Contains(buf.String(), "Data:") {
+			t.Errorf("got %q", buf.String())
+		}
+	})
+
+	t.Run("prints error", func(t *testing.T) {
+		f := &fakeCharger{connected: true, sendErr: errors.New("DataTransfer failed: not connected to server")}
+		ctx, buf := newCtx(f, cfg16())
+		handleDataTransfer(ctx, []string{"com.example"})
+		if !strings.Contains(buf.String(), "Error: DataTransfer failed: not connected to server") {
+			t.Errorf("got %q", buf.String())
+		}
+	})
+}
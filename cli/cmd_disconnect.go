@@ -2,12 +2,18 @@ package cli
 
 import "fmt"
 
-func init() { register("disconnect", handleDisconnect) }
+func init() {
+	register("disconnect", Command{
+		Handler: handleDisconnect,
+		Usage:   "disconnect",
+		Help:    "Close the connection to the server.",
+	})
+}
 
 // handleDisconnect disconnects from the server if currently connected.
 func handleDisconnect(ctx *CommandContext, args []string) {
 	if !ctx.Charger.IsConnected() {
-		fmt.Fprintln(ctx.Out, "Not connected")
+		ctx.Failf("Not connected")
 		return
 	}
 	ctx.Charger.Disconnect()
@@ -0,0 +1,90 @@
+package cli
+
+import (
+	"fmt"
+	"strings"
+)
+
+// faultCodes are the OCPP 1.6 ChargePointErrorCodes that can be raised
+var faultCodes = []string{"ConnectorLockFailure", "EVCommunicationError", "GroundFailure", "HighTemperature", "InternalError", "LocalListConflict", "OtherError", "OverCurrentFailure", "OverVoltage", "PowerMeterFailure", "PowerSwitchFailure", "ReaderFailure", "ResetFailure", "UnderVoltage", "WeakSignal"}
+
+func init() {
+	register("fault", Command{
+		Handler: handleFault,
+		Usage:   "fault <errorCode> [vendorId=<id>] [vendorErrorCode=<code>] [info...] | fault clear [errorCode]",
+		Help: "List the active faults, raise one, or clear one (or all). Faulted codes " +
+			"such as GroundFailure set the connector to Faulted.",
+		Complete: completeFault,
+	})
+}
+
+// handleFault lists the active faults, raises one, or clears one (or all).
+// Arguments of a raised fault in the form vendorId=<id> and
+// vendorErrorCode=<code> set the vendor fields; the rest is the info text.
+func handleFault(ctx *CommandContext, args []string) {
+	if len(args) < 1 {
+		faults := ctx.Charger.ActiveFaults()
+		if len(faults) == 0 {
+			fmt.Fprintln(ctx.Out, "No active faults")
+		}
+		for _, f := range faults {
+			fmt.Fprintf(ctx.Out, "Fault: %s\n", f)
+		}
+		fmt.Fprintln(ctx.Out, "Usage: fault <errorCode> [vendorId=<id>] [vendorErrorCode=<code>] [info...] | fault clear [errorCode]")
+		return
+	}
+
+	if args[0] == "clear" {
+		errorCode := ""
+		if len(args) > 1 {
+			errorCode = args[1]
+		}
+		if err := ctx.Charger.ClearFault(errorCode); err != nil {
+			ctx.Failf("Error: %v", err)
+			return
+		}
+		if errorCode == "" {
+			fmt.Fprintln(ctx.Out, "All faults cleared")
+			return
+		}
+		fmt.Fprintf(ctx.Out, "Fault %s cleared\n", errorCode)
+		return
+	}
+
+	var vendorId, vendorErrorCode string
+	var info []string
+	for _, arg := range args[1:] {
+		if v, ok := strings.CutPrefix(arg, "vendorId="); ok {
+			vendorId = v
+		} else if v, ok := strings.CutPrefix(arg, "vendorErrorCode="); ok {
+			vendorErrorCode = v
+		} else {
+			info = append(info, arg)
+		}
+	}
+	if err := ctx.Charger.RaiseFault(args[0], strings.Join(info, " "), vendorId, vendorErrorCode); err != nil {
+		ctx.Failf("Error: %v", err)
+		return
+	}
+	fmt.Fprintf(ctx.Out, "Fault %s raised (status: %s)\n", args[0], ctx.Charger.GetStatus())
+}
+
+// completeFault completes "clear" and the error codes, the active faults after
+// "clear", and the vendor fields of a raised fault.
+func completeFault(ctx *CommandContext, args []string) []string {
+	switch {
+	case len(args) == 1:
+		return append([]string{"clear"}, faultCodes...)
+	case args[0] == "clear":
+		if len(args) != 2 {
+			return nil
+		}
+		var codes []string
+		for _, f := range ctx.Charger.ActiveFaults() {
+			codes = append(codes, strings.Fields(f)[0])
+		}
+		return codes
+	default:
+		return []string{"vendorId=", "vendorErrorCode="}
+	}
+}
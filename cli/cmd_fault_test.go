@@ -0,0 +1,74 @@
+package cli
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestHandleFault(t *testing.T) {
+	t.Run("no faults", func(t *testing.T) {
+		f := &fakeCharger{}
+		ctx, buf := newCtx(f, cfg16())
+		handleFault(ctx, nil)
+		out := buf.String()
+		if !strings.Contains(out, "No active faults") || !strings.Contains(out, "Usage: fault <errorCode>") {
+			t.Errorf("got %q", out)
+		}
+	})
+
+	t.Run("lists faults", func(t *testing.T) {
+		f := &fakeCharger{faults: []string{"GroundFailure since 2026-01-02T03:04:05Z (RCD tripped)"}}
+		ctx, buf := newCtx(f, cfg16())
+		handleFault(ctx, nil)
+		if !strings.Contains(buf.String(), "Fault: GroundFailure since 2026-01-02T03:04:05Z (RCD tripped)") {
+			t.Errorf("got %q", buf.String())
+		}
+	})
+
+	t.Run("raises with vendor fields and info", func(t *testing.T) {
+		f := &fakeCharger{status: "Charging"}
+		ctx, buf := newCtx(f, cfg16())
+		handleFault(ctx, []string{"GroundFailure", "vendorId=ACME", "RCD", "vendorErrorCode=E42", "tripped"})
+		want := []string{"GroundFailure", "RCD tripped", "ACME", "E42"}
+		if strings.Join(f.lastFault, "|") != strings.Join(want, "|") {
+			t.Errorf("raised %q, want %q", f.lastFault, want)
+		}
+		if !strings.Contains(buf.String(), "Fault GroundFailure raised (status: Faulted)") {
+			t.Errorf("got %q", buf.String())
+		}
+	})
+
+	t.Run("raise error", func(t *testing.T) {
+		f := &fakeCharger{faultErr: errors.New(`unknown error code "Smoke"`)}
+		ctx, buf := newCtx(f, cfg201())
+		handleFault(ctx, []string{"Smoke"})
+		if !strings.Contains(buf.String(), `Error: unknown error code "Smoke"`) {
+			t.Errorf("got %q", buf.String())
+		}
+	})
+
+	t.Run("clears one", func(t *testing.T) {
+		f := &fakeCharger{}
+		ctx, buf := newCtx(f, cfg16())
+		handleFault(ctx, []string{"clear", "HighTemperature"})
+		if f.lastCleared != "HighTemperature" {
+			t.Errorf("cleared %q", f.lastCleared)
+		}
+		if !strings.Contains(buf.String(), "Fault HighTemperature cleared") {
+			t.Errorf("got %q", buf.String())
+		}
+	})
+
+	t.Run("clears all", func(t *testing.T) {
+		f := &fakeCharger{lastCleared: "unset"}
+		ctx, buf := newCtx(f, cfg201())
+		handleFault(ctx, []string{"clear"})
+		if f.lastCleared != "" {
+			t.Errorf("cleared %q, want all", f.lastCleared)
+		}
+		if !strings.Contains(buf.String(), "All faults cleared") {
+			t.Errorf("got %q", buf.String())
+		}
+	})
+}
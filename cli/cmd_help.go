@@ -3,21 +3,35 @@ package cli
 import (
 	"fmt"
 	"io"
+	"sort"
+	"strings"
 
 	"github.com/weilun-shrimp/wlgo_ocpp_charger_simulator/config"
 )
 
-func init() { register("help", handleHelp) }
+func init() {
+	register("help", Command{
+		Handler:  handleHelp,
+		Usage:    "help [command]",
+		Help:     "Show all commands, or the usage and details of one command.",
+		Complete: completeCommands,
+	})
+}
 
 // handleHelp prints the list of available commands and the valid-status list
-// for the configured OCPP version.
+// for the configured OCPP version, or with an argument the usage, description
+// and argument values of one command.
 func handleHelp(ctx *CommandContext, args []string) {
+	if len(args) > 0 {
+		printCommandHelp(ctx, strings.ToLower(args[0]))
+		return
+	}
 	printHelp(ctx.Out, ctx.Config)
 }
 
 func printHelp(out io.Writer, cfg *config.Config) {
 	fmt.Fprintln(out, "Available commands:")
-	fmt.Fprintln(out, "  help              - Show this help message")
+	fmt.Fprintln(out, "  help [command]    - Show this help message, or the details of one command")
 	fmt.Fprintln(out, "  connect           - Connect to OCPP server")
 	fmt.Fprintln(out, "  disconnect        - Disconnect from server")
 	fmt.Fprintln(out, "  plugin            - Simulate car plug in (Preparing)")
@@ -30,12 +44,56 @@ func printHelp(out io.Writer, cfg *config.Config) {
 	fmt.Fprintln(out, "  soc <0-100>       - Set State of Charge")
 	fmt.Fprintf(out, "  current <amps>    - Set charging current (0-%.1f A, 0 = SuspendedEVSE)\n", cfg.MaxCurrent)
 	fmt.Fprintf(out, "  power <watts>     - Set charging power (0-%.1f W, 0 = SuspendedEVSE)\n", cfg.MaxPower)
+	fmt.Fprintln(out, "  lock [jam|release] - Show cable lock state, jam the lock or release it")
+	fmt.Fprintln(out, "  reset [watchdog]  - Restart the charger (boot reason LocalReset or Watchdog)")
+	fmt.Fprintln(out, "  cert [renew]      - Show the client certificate or request a new one (SignCertificate)")
+	fmt.Fprintln(out, "  fault [<errorCode> [info]|clear [errorCode]] - List, raise or clear faults (e.g. GroundFailure, HighTemperature)")
+	fmt.Fprintln(out, "  net [<fault> <value>|off|kill] - Show or inject network faults (latency, drops, duplicates, ...)")
+	fmt.Fprintln(out, "  security [<type> [techInfo]] - Raise a security event (SecurityEventNotification)")
+	fmt.Fprintln(out, "  send <Action> [json] - Send any Call with a raw JSON payload and print the response")
+	fmt.Fprintln(out, "  datatransfer <vendorId> [messageId|-] [data] - Send a vendor DataTransfer")
 	fmt.Fprintln(out, "  info              - Show current charger status")
+	fmt.Fprintln(out, "  config show       - Show the effective configuration and where each value comes from")
+	fmt.Fprintln(out, "  wait <duration>   - Pause before the next command (e.g. 500ms, 60s)")
+	fmt.Fprintln(out, "  log [subsystem] <level> - Show or set log levels (debug, info, warn, error, off)")
+	fmt.Fprintln(out, "  dashboard         - Full-screen live view of the charger and its OCPP frames")
 	fmt.Fprintln(out, "  quit/exit         - Exit the simulator (use Ctrl+C)")
 	fmt.Fprintln(out)
-	if cfg.IsOCPP16() {
-		fmt.Fprintln(out, "Valid statuses (OCPP 1.6): Available, Preparing, Charging, SuspendedEVSE, SuspendedEV, Finishing, Reserved, Unavailable, Faulted")
-	} else {
-		fmt.Fprintln(out, "Valid statuses (OCPP 2.0.1): Available, Occupied, Reserved, Unavailable, Faulted")
+	printValidStatuses(out, cfg)
+}
+
+// printCommandHelp prints the metadata of one command. The values its first
+// argument completes to are listed, so they follow the configured version.
+func printCommandHelp(ctx *CommandContext, name string) {
+	cmd, ok := registry[name]
+	if !ok {
+		ctx.Failf("Unknown command: %s. Type 'help' for available commands.", name)
+		return
+	}
+	fmt.Fprintf(ctx.Out, "Usage: %s\n", cmd.Usage)
+	fmt.Fprintln(ctx.Out, cmd.Help)
+	if cmd.Complete == nil {
+		return
+	}
+	if values := cmd.Complete(ctx, []string{""}); len(values) > 0 {
+		fmt.Fprintf(ctx.Out, "Values: %s\n", strings.Join(values, ", "))
+	}
+}
+
+// commandNames returns the registered verbs in alphabetical order.
+func commandNames() []string {
+	names := make([]string, 0, len(registry))
+	for name := range registry {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// completeCommands completes the command verbs.
+func completeCommands(ctx *CommandContext, args []string) []string {
+	if len(args) == 1 {
+		return commandNames()
 	}
+	return nil
 }
@@ -36,4 +36,47 @@ func TestHandleHelp(t *testing.T) {
 			t.Errorf("must not show 1.6 status list under 2.0.1: %q", out)
 		}
 	})
+
+	t.Run("help for one command", func(t *testing.T) {
+		f := &fakeCharger{}
+		ctx, buf := newCtx(f, cfg201())
+		handleHelp(ctx, []string{"Status"})
+		out := buf.String()
+		if !strings.Contains(out, "Usage: status <status>") || !strings.Contains(out, "StatusNotification") {
+			t.Errorf("missing usage or description: %q", out)
+		}
+		if !strings.Contains(out, "Values: Available, Occupied, Reserved, Unavailable, Faulted") {
+			t.Errorf("missing 2.0.1 values: %q", out)
+		}
+		if strings.Contains(out, "Available commands:") {
+			t.Errorf("must not print the command list: %q", out)
+		}
+	})
+
+	t.Run("help for a command without values", func(t *testing.T) {
+		f := &fakeCharger{}
+		ctx, buf := newCtx(f, cfg16())
+		handleHelp(ctx, []string{"start"})
+		out := buf.String()
+		if !strings.Contains(out, "Usage: start <idTag>") || strings.Contains(out, "Values:") {
+			t.Errorf("got %q", out)
+		}
+	})
+
+	t.Run("help for an unknown command", func(t *testing.T) {
+		f := &fakeCharger{}
+		ctx, buf := newCtx(f, cfg16())
+		handleHelp(ctx, []string{"frobnicate"})
+		if !strings.Contains(buf.String(), "Unknown command: frobnicate.") {
+			t.Errorf("got %q", buf.String())
+		}
+	})
+
+	t.Run("every command has metadata", func(t *testing.T) {
+		for name, cmd := range registry {
+			if cmd.Handler == nil || cmd.Help == "" || !strings.HasPrefix(cmd.Usage, name) {
+				t.Errorf("command %q: incomplete metadata %+v", name, cmd)
+			}
+		}
+	})
 }
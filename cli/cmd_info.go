@@ -2,19 +2,45 @@ package cli
 
 import "fmt"
 
-func init() { register("info", handleInfo) }
+func init() {
+	register("info", Command{
+		Handler: handleInfo,
+		Usage:   "info",
+		Help: "Show the charger state: connection, registration, status, transaction, " +
+			"meter, limits, lock, firmware and faults.",
+	})
+}
 
-// handleInfo prints the current charger state. The license-plate line is shown
-// only when a plate is set.
+// handleInfo prints the current charger state. The subprotocol, license-plate
+// and fault lines are shown only when connected, a plate is set or a fault is
+// active.
 func handleInfo(ctx *CommandContext, args []string) {
 	fmt.Fprintf(ctx.Out, "Connected: %v\n", ctx.Charger.IsConnected())
+	if subprotocol := ctx.Charger.GetSubprotocol(); subprotocol != "" {
+		fmt.Fprintf(ctx.Out, "Subprotocol: %s\n", subprotocol)
+	}
+	fmt.Fprintf(ctx.Out, "Registration: %s\n", registrationState(ctx.Charger.GetRegistrationStatus()))
 	fmt.Fprintf(ctx.Out, "Status: %s\n", ctx.Charger.GetStatus())
+	fmt.Fprintf(ctx.Out, "Firmware: %s\n", ctx.Charger.GetFirmwareVersion())
+	fmt.Fprintf(ctx.Out, "Security Profile: %d\n", ctx.Charger.GetSecurityProfile())
 	fmt.Fprintf(ctx.Out, "Charging: %v\n", ctx.Charger.IsCharging())
 	fmt.Fprintf(ctx.Out, "Voltage: %.1f V\n", ctx.Config.Voltage)
 	fmt.Fprintf(ctx.Out, "Current: %.1f A\n", ctx.Charger.GetCurrent())
 	fmt.Fprintf(ctx.Out, "Power: %.1f W\n", ctx.Charger.GetPower())
 	fmt.Fprintf(ctx.Out, "SOC: %.1f%%\n", ctx.Charger.GetSOC())
+	fmt.Fprintf(ctx.Out, "Cable Lock: %s\n", lockState(ctx.Charger))
 	if plate := ctx.Charger.GetLicensePlate(); plate != "" {
 		fmt.Fprintf(ctx.Out, "License Plate: %s\n", plate)
 	}
+	for _, f := range ctx.Charger.ActiveFaults() {
+		fmt.Fprintf(ctx.Out, "Fault: %s\n", f)
+	}
+}
+
+// registrationState describes the registration status for display
+func registrationState(registration string) string {
+	if registration == "" {
+		return "not registered"
+	}
+	return registration
 }
@@ -7,18 +7,23 @@ import (
 
 func TestHandleInfo(t *testing.T) {
 	t.Run("without plate", func(t *testing.T) {
-		f := &fakeCharger{connected: true, status: "Charging", charging: true, current: 10, power: 2300, soc: 42}
+		f := &fakeCharger{connected: true, status: "Charging", charging: true, current: 10, power: 2300, soc: 42, firmware: "2.1.0", security: 2, subprotocol: "ocpp1.6", registration: "Accepted"}
 		ctx, buf := newCtx(f, cfg16())
 		handleInfo(ctx, nil)
 		out := buf.String()
 		for _, want := range []string{
 			"Connected: true",
+			"Subprotocol: ocpp1.6",
+			"Registration: Accepted",
 			"Status: Charging",
+			"Firmware: 2.1.0",
+			"Security Profile: 2",
 			"Charging: true",
 			"Voltage: 230.0 V",
 			"Current: 10.0 A",
 			"Power: 2300.0 W",
 			"SOC: 42.0%",
+			"Cable Lock: unlocked",
 		} {
 			if !strings.Contains(out, want) {
 				t.Errorf("missing %q in output: %q", want, out)
@@ -36,5 +41,20 @@ func TestHandleInfo(t *testing.T) {
 		if !strings.Contains(buf.String(), "License Plate: ABC123") {
 			t.Errorf("expected license-plate line, got %q", buf.String())
 		}
+		if !strings.Contains(buf.String(), "Registration: not registered") {
+			t.Errorf("expected unregistered state, got %q", buf.String())
+		}
+		if strings.Contains(buf.String(), "Subprotocol") {
+			t.Errorf("subprotocol line must be absent when not connected: %q", buf.String())
+		}
+	})
+
+	t.Run("with faults", func(t *testing.T) {
+		f := &fakeCharger{faults: []string{"HighTemperature since 2026-01-02T03:04:05Z"}}
+		ctx, buf := newCtx(f, cfg201())
+		handleInfo(ctx, nil)
+		if !strings.Contains(buf.String(), "Fault: HighTemperature since 2026-01-02T03:04:05Z") {
+			t.Errorf("expected fault line, got %q", buf.String())
+		}
 	})
 }
@@ -0,0 +1,57 @@
+package cli
+
+import "fmt"
+
+func init() {
+	register("lock", Command{
+		Handler: handleLock,
+		Usage:   "lock [jam|release]",
+		Help: "Show the cable lock state, or jam the lock so unlock attempts fail, or " +
+			"release it.",
+		Complete: completeLock,
+	})
+}
+
+// handleLock shows the cable lock state, or jams/releases the lock so that a
+// cable stuck on the charger can be simulated.
+func handleLock(ctx *CommandContext, args []string) {
+	if len(args) < 1 {
+		fmt.Fprintf(ctx.Out, "Cable lock: %s\n", lockState(ctx.Charger))
+		return
+	}
+	var jammed bool
+	switch args[0] {
+	case "jam":
+		jammed = true
+	case "release":
+		jammed = false
+	default:
+		ctx.Failf("Usage: lock [jam|release]")
+		return
+	}
+	if err := ctx.Charger.SetLockJammed(jammed); err != nil {
+		ctx.Failf("Error: %v", err)
+		return
+	}
+	fmt.Fprintf(ctx.Out, "Cable lock: %s\n", lockState(ctx.Charger))
+}
+
+// lockState describes the cable lock, e.g. "locked (jammed)".
+func lockState(ch Charger) string {
+	state := "unlocked"
+	if ch.IsCableLocked() {
+		state = "locked"
+	}
+	if ch.IsLockJammed() {
+		state += " (jammed)"
+	}
+	return state
+}
+
+// completeLock completes the lock actions.
+func completeLock(ctx *CommandContext, args []string) []string {
+	if len(args) == 1 {
+		return []string{"jam", "release"}
+	}
+	return nil
+}
@@ -0,0 +1,60 @@
+package cli
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestHandleLock(t *testing.T) {
+	t.Run("shows state", func(t *testing.T) {
+		f := &fakeCharger{cableLocked: true}
+		ctx, buf := newCtx(f, cfg16())
+		handleLock(ctx, nil)
+		if !strings.Contains(buf.String(), "Cable lock: locked") {
+			t.Errorf("got %q", buf.String())
+		}
+	})
+
+	t.Run("usage on unknown argument", func(t *testing.T) {
+		f := &fakeCharger{}
+		ctx, buf := newCtx(f, cfg16())
+		handleLock(ctx, []string{"open"})
+		if !strings.Contains(buf.String(), "Usage: lock [jam|release]") {
+			t.Errorf("got %q", buf.String())
+		}
+	})
+
+	t.Run("jam", func(t *testing.T) {
+		f := &fakeCharger{cableLocked: true, charging: true}
+		ctx, buf := newCtx(f, cfg16())
+		handleLock(ctx, []string{"jam"})
+		if !strings.Contains(buf.String(), "Cable lock: locked (jammed)") {
+			t.Errorf("got %q", buf.String())
+		}
+		if !f.lockJammed {
+			t.Error("lock not jammed")
+		}
+	})
+
+	t.Run("release", func(t *testing.T) {
+		f := &fakeCharger{cableLocked: true, lockJammed: true}
+		ctx, buf := newCtx(f, cfg16())
+		handleLock(ctx, []string{"release"})
+		if !strings.Contains(buf.String(), "Cable lock: unlocked") {
+			t.Errorf("got %q", buf.String())
+		}
+		if f.lockJammed {
+			t.Error("lock still jammed")
+		}
+	})
+
+	t.Run("error", func(t *testing.T) {
+		f := &fakeCharger{lockErr: errors.New("connector has no cable lock")}
+		ctx, buf := newCtx(f, cfg16())
+		handleLock(ctx, []string{"jam"})
+		if !strings.Contains(buf.String(), "Error: connector has no cable lock") {
+			t.Errorf("got %q", buf.String())
+		}
+	})
+}
@@ -0,0 +1,74 @@
+package cli
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/weilun-shrimp/wlgo_ocpp_charger_simulator/logging"
+)
+
+func init() {
+	register("log", Command{
+		Handler: handleLog,
+		Usage:   "log [subsystem] [debug|info|warn|error|off]",
+		Help: "Show the log level of each subsystem, set the level of all of them, or " +
+			"set the level of one (charger, cli, heartbeat, meter, profile, remote, wire).",
+		Complete: completeLog,
+	})
+}
+
+// logLevelNames lists the levels the log command accepts
+var logLevelNames = []string{"debug", "info", "warn", "error", "off"}
+
+// handleLog shows the log levels, "log <level>" sets the level of every
+// subsystem and "log <subsystem> <level>" the level of one.
+func handleLog(ctx *CommandContext, args []string) {
+	levels := ctx.Levels
+	if levels == nil {
+		ctx.Failf("Error: log levels are not available")
+		return
+	}
+
+	switch len(args) {
+	case 0:
+		fmt.Fprintf(ctx.Out, "Log level: %s\n", logging.LevelName(levels.Default()))
+		for _, subsystem := range logging.Subsystems {
+			fmt.Fprintf(ctx.Out, "  %-10s %s\n", subsystem, logging.LevelName(levels.Level(subsystem)))
+		}
+		fmt.Fprintf(ctx.Out, "Usage: log [%s] <%s>\n", strings.Join(logging.Subsystems, "|"), strings.Join(logLevelNames, "|"))
+	case 1:
+		level, err := logging.ParseLevel(args[0])
+		if err != nil {
+			ctx.Failf("Error: %v", err)
+			return
+		}
+		levels.SetDefault(level)
+		fmt.Fprintf(ctx.Out, "Log level of all subsystems set to %s\n", logging.LevelName(level))
+	default:
+		subsystem := strings.ToLower(args[0])
+		if !logging.IsSubsystem(subsystem) {
+			ctx.Failf("Error: unknown subsystem %s (valid: %s)", args[0], strings.Join(logging.Subsystems, ", "))
+			return
+		}
+		level, err := logging.ParseLevel(args[1])
+		if err != nil {
+			ctx.Failf("Error: %v", err)
+			return
+		}
+		levels.SetLevel(subsystem, level)
+		fmt.Fprintf(ctx.Out, "Log level of %s set to %s\n", subsystem, logging.LevelName(level))
+	}
+}
+
+// completeLog completes a subsystem or level, then a level.
+func completeLog(ctx *CommandContext, args []string) []string {
+	switch len(args) {
+	case 1:
+		return append(append([]string{}, logging.Subsystems...), logLevelNames...)
+	case 2:
+		if logging.IsSubsystem(strings.ToLower(args[0])) {
+			return logLevelNames
+		}
+	}
+	return nil
+}
@@ -0,0 +1,94 @@
+package cli
+
+import (
+	"log/slog"
+	"strings"
+	"testing"
+
+	"github.com/weilun-shrimp/wlgo_ocpp_charger_simulator/logging"
+)
+
+func TestHandleLog(t *testing.T) {
+	newLogCtx := func() (*CommandContext, *strings.Builder, *logging.Levels) {
+		ctx, _ := newCtx(&fakeCharger{}, cfg16())
+		out := &strings.Builder{}
+		ctx.Out = out
+		ctx.Levels = logging.NewLevels(slog.LevelInfo)
+		return ctx, out, ctx.Levels
+	}
+
+	t.Run("show", func(t *testing.T) {
+		ctx, out, levels := newLogCtx()
+		levels.SetLevel(logging.SubsystemWire, slog.LevelWarn)
+		handleLog(ctx, nil)
+		for _, want := range []string{"Log level: info", "wire       warn", "meter      info", "Usage: log ["} {
+			if !strings.Contains(out.String(), want) {
+				t.Errorf("missing %q in output: %q", want, out.String())
+			}
+		}
+	})
+
+	t.Run("set all", func(t *testing.T) {
+		ctx, out, levels := newLogCtx()
+		levels.SetLevel(logging.SubsystemWire, slog.LevelDebug)
+		handleLog(ctx, []string{"error"})
+		if !strings.Contains(out.String(), "Log level of all subsystems set to error") {
+			t.Errorf("unexpected output: %q", out.String())
+		}
+		if got := levels.Level(logging.SubsystemWire); got != slog.LevelError {
+			t.Errorf("wire level = %v, want ERROR", got)
+		}
+	})
+
+	t.Run("set one", func(t *testing.T) {
+		ctx, out, levels := newLogCtx()
+		handleLog(ctx, []string{"Meter", "off"})
+		if !strings.Contains(out.String(), "Log level of meter set to off") {
+			t.Errorf("unexpected output: %q", out.String())
+		}
+		if got := levels.Level(logging.SubsystemMeter); got != logging.LevelOff {
+			t.Errorf("meter level = %v, want off", got)
+		}
+		if got := levels.Level(logging.SubsystemWire); got != slog.LevelInfo {
+			t.Errorf("wire level = %v, want INFO", got)
+		}
+	})
+
+	t.Run("errors", func(t *testing.T) {
+		for _, tc := range []struct {
+			args []string
+			want string
+		}{
+			{[]string{"loud"}, `Error: invalid log level "loud"`},
+			{[]string{"radio", "debug"}, "Error: unknown subsystem radio"},
+			{[]string{"wire", "loud"}, `Error: invalid log level "loud"`},
+		} {
+			ctx, out, _ := newLogCtx()
+			handleLog(ctx, tc.args)
+			if !strings.Contains(out.String(), tc.want) {
+				t.Errorf("log %v: expected %q, got %q", tc.args, tc.want, out.String())
+			}
+		}
+	})
+
+	t.Run("without levels", func(t *testing.T) {
+		ctx, buf := newCtx(&fakeCharger{}, cfg16())
+		handleLog(ctx, nil)
+		if !strings.Contains(buf.String(), "Error: log levels are not available") {
+			t.Errorf("unexpected output: %q", buf.String())
+		}
+	})
+}
+
+func TestCompleteLog(t *testing.T) {
+	ctx, _ := newCtx(&fakeCharger{}, cfg16())
+	if got := completeLog(ctx, []string{""}); len(got) != len(logging.Subsystems)+len(logLevelNames) {
+		t.Errorf("first argument completions = %v", got)
+	}
+	if got := completeLog(ctx, []string{"wire", ""}); !equalArgs(got, logLevelNames) {
+		t.Errorf("level completions = %v", got)
+	}
+	if got := completeLog(ctx, []string{"debug", ""}); got != nil {
+		t.Errorf("no completion expected after a level, got %v", got)
+	}
+}
@@ -2,12 +2,18 @@ package cli
 
 import "fmt"
 
-func init() { register("meter", handleMeter) }
+func init() {
+	register("meter", Command{
+		Handler: handleMeter,
+		Usage:   "meter",
+		Help:    "Send MeterValues with the current meter reading.",
+	})
+}
 
 // handleMeter sends a MeterValues message.
 func handleMeter(ctx *CommandContext, args []string) {
 	if err := ctx.Charger.MeterValues(); err != nil {
-		fmt.Fprintf(ctx.Out, "Error: %v\n", err)
+		ctx.Failf("Error: %v", err)
 	} else {
 		fmt.Fprintln(ctx.Out, "MeterValues updated")
 	}
@@ -0,0 +1,141 @@
+package cli
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/weilun-shrimp/wlgo_ocpp_charger_simulator/config"
+)
+
+func init() {
+	register("net", Command{
+		Handler: handleNet,
+		Usage:   "net <option|seed> <value> | net off | net kill",
+		Help: "Show or change the network fault injection: set one fault, make the " +
+			"faults reproducible with a seed, disable all of them, or drop the TCP " +
+			"connection at once.",
+		Complete: completeNet,
+	})
+}
+
+// netOption is a network fault setting changeable with "net <option> <value>"
+type netOption struct {
+	name  string
+	unit  string // "%" or "ms", "" for the seed
+	field func(n *config.NetworkFaultsConfig) *int
+}
+
+// netOptions lists the settings in display order
+var netOptions = []netOption{
+	{"latency", "ms", func(n *config.NetworkFaultsConfig) *int { return &n.LatencyMs }},
+	{"jitter", "ms", func(n *config.NetworkFaultsConfig) *int { return &n.JitterMs }},
+	{"drop-out", "%", func(n *config.NetworkFaultsConfig) *int { return &n.DropOutbound }},
+	{"drop-in", "%", func(n *config.NetworkFaultsConfig) *int { return &n.DropInbound }},
+	{"duplicate", "%", func(n *config.NetworkFaultsConfig) *int { return &n.Duplicate }},
+	{"reorder", "%", func(n *config.NetworkFaultsConfig) *int { return &n.Reorder }},
+	{"reuse-id", "%", func(n *config.NetworkFaultsConfig) *int { return &n.ReuseUniqueId }},
+	{"malformed", "%", func(n *config.NetworkFaultsConfig) *int { return &n.Malformed }},
+	{"wrong-type", "%", func(n *config.NetworkFaultsConfig) *int { return &n.WrongMessageType }},
+	{"result-delay", "ms", func(n *config.NetworkFaultsConfig) *int { return &n.CallResultDelayMs }},
+	{"disconnect", "%", func(n *config.NetworkFaultsConfig) *int { return &n.Disconnect }},
+}
+
+// handleNet shows or changes the network fault injection: "net <option>
+// <value>" sets one fault, "net seed <n>" makes the faults reproducible, "net
+// off" disables all of them and "net kill" drops the TCP connection at once.
+func handleNet(ctx *CommandContext, args []string) {
+	if len(args) < 1 {
+		fmt.Fprintf(ctx.Out, "Network faults: %s\n", describeNetFaults(ctx.Charger.NetworkFaults()))
+		names := make([]string, 0, len(netOptions))
+		for _, o := range netOptions {
+			names = append(names, o.name)
+		}
+		fmt.Fprintf(ctx.Out, "Usage: net <%s|seed> <value> | net off | net kill\n", strings.Join(names, "|"))
+		return
+	}
+
+	switch args[0] {
+	case "kill":
+		if err := ctx.Charger.KillConnection(); err != nil {
+			ctx.Failf("Error: %v", err)
+			return
+		}
+		fmt.Fprintln(ctx.Out, "TCP connection dropped")
+		return
+	case "off":
+		settings := config.NetworkFaultsConfig{Seed: ctx.Charger.NetworkFaults().Seed}
+		if err := ctx.Charger.SetNetworkFaults(settings); err != nil {
+			ctx.Failf("Error: %v", err)
+			return
+		}
+		fmt.Fprintln(ctx.Out, "Network faults: none")
+		return
+	}
+
+	if len(args) < 2 {
+		ctx.Failf("Usage: net %s <value>", args[0])
+		return
+	}
+	settings := ctx.Charger.NetworkFaults()
+	if args[0] == "seed" {
+		seed, err := strconv.ParseInt(args[1], 10, 64)
+		if err != nil {
+			ctx.Failf("Invalid seed: %s", args[1])
+			return
+		}
+		settings.Seed = seed
+	} else {
+		var option *netOption
+		for i := range netOptions {
+			if netOptions[i].name == args[0] {
+				option = &netOptions[i]
+			}
+		}
+		if option == nil {
+			ctx.Failf("Unknown network fault: %s", args[0])
+			return
+		}
+		value, err := strconv.Atoi(strings.TrimSuffix(strings.TrimSuffix(args[1], option.unit), "%"))
+		if err != nil {
+			ctx.Failf("Invalid value: %s", args[1])
+			return
+		}
+		*option.field(&settings) = value
+	}
+
+	if err := ctx.Charger.SetNetworkFaults(settings); err != nil {
+		ctx.Failf("Error: %v", err)
+		return
+	}
+	fmt.Fprintf(ctx.Out, "Network faults: %s\n", describeNetFaults(settings))
+}
+
+// describeNetFaults lists the enabled faults, e.g. "latency=200ms drop-out=5%"
+func describeNetFaults(settings config.NetworkFaultsConfig) string {
+	var parts []string
+	for _, o := range netOptions {
+		if v := *o.field(&settings); v != 0 {
+			parts = append(parts, fmt.Sprintf("%s=%d%s", o.name, v, o.unit))
+		}
+	}
+	if len(parts) == 0 {
+		return "none"
+	}
+	if settings.Seed != 0 {
+		parts = append(parts, fmt.Sprintf("seed=%d", settings.Seed))
+	}
+	return strings.Join(parts, " ")
+}
+
+// completeNet completes the fault options and the off, kill and seed actions.
+func completeNet(ctx *CommandContext, args []string) []string {
+	if len(args) != 1 {
+		return nil
+	}
+	names := []string{"off", "kill", "seed"}
+	for _, o := range netOptions {
+		names = append(names, o.name)
+	}
+	return names
+}
@@ -0,0 +1,95 @@
+package cli
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/weilun-shrimp/wlgo_ocpp_charger_simulator/config"
+)
+
+func TestHandleNet(t *testing.T) {
+	t.Run("shows settings and usage", func(t *testing.T) {
+		f := &fakeCharger{netFaults: config.NetworkFaultsConfig{LatencyMs: 200, DropOutbound: 5, Seed: 7}}
+		ctx, buf := newCtx(f, cfg16())
+		handleNet(ctx, nil)
+		out := buf.String()
+		if !strings.Contains(out, "Network faults: latency=200ms drop-out=5% seed=7") || !strings.Contains(out, "Usage: net <latency|") {
+			t.Errorf("got %q", out)
+		}
+	})
+
+	t.Run("sets one fault", func(t *testing.T) {
+		f := &fakeCharger{netFaults: config.NetworkFaultsConfig{LatencyMs: 200}}
+		ctx, buf := newCtx(f, cfg201())
+		handleNet(ctx, []string{"duplicate", "10%"})
+		if f.netFaults.Duplicate != 10 || f.netFaults.LatencyMs != 200 {
+			t.Errorf("settings %+v", f.netFaults)
+		}
+		if !strings.Contains(buf.String(), "Network faults: latency=200ms duplicate=10%") {
+			t.Errorf("got %q", buf.String())
+		}
+	})
+
+	t.Run("sets seed", func(t *testing.T) {
+		f := &fakeCharger{}
+		ctx, _ := newCtx(f, cfg16())
+		handleNet(ctx, []string{"seed", "42"})
+		if f.netFaults.Seed != 42 {
+			t.Errorf("seed %d", f.netFaults.Seed)
+		}
+	})
+
+	t.Run("unknown fault and invalid value", func(t *testing.T) {
+		f := &fakeCharger{}
+		ctx, buf := newCtx(f, cfg16())
+		handleNet(ctx, []string{"corrupt", "5"})
+		handleNet(ctx, []string{"latency", "slow"})
+		handleNet(ctx, []string{"latency"})
+		out := buf.String()
+		for _, want := range []string{"Unknown network fault: corrupt", "Invalid value: slow", "Usage: net latency <value>"} {
+			if !strings.Contains(out, want) {
+				t.Errorf("missing %q in %q", want, out)
+			}
+		}
+	})
+
+	t.Run("rejected setting", func(t *testing.T) {
+		f := &fakeCharger{netFaultsErr: errors.New("network_faults.reorder_percent must be between 0 and 100, got 150")}
+		ctx, buf := newCtx(f, cfg16())
+		handleNet(ctx, []string{"reorder", "150"})
+		if !strings.Contains(buf.String(), "Error: network_faults.reorder_percent") {
+			t.Errorf("got %q", buf.String())
+		}
+	})
+
+	t.Run("off keeps the seed", func(t *testing.T) {
+		f := &fakeCharger{netFaults: config.NetworkFaultsConfig{Seed: 3, Malformed: 50}}
+		ctx, buf := newCtx(f, cfg16())
+		handleNet(ctx, []string{"off"})
+		if f.netFaults != (config.NetworkFaultsConfig{Seed: 3}) {
+			t.Errorf("settings %+v", f.netFaults)
+		}
+		if !strings.Contains(buf.String(), "Network faults: none") {
+			t.Errorf("got %q", buf.String())
+		}
+	})
+
+	t.Run("kill", func(t *testing.T) {
+		f := &fakeCharger{connected: true}
+		ctx, buf := newCtx(f, cfg16())
+		handleNet(ctx, []string{"kill"})
+		if f.killCalls != 1 || !strings.Contains(buf.String(), "TCP connection dropped") {
+			t.Errorf("kill calls %d, output %q", f.killCalls, buf.String())
+		}
+	})
+
+	t.Run("kill while offline", func(t *testing.T) {
+		f := &fakeCharger{}
+		ctx, buf := newCtx(f, cfg16())
+		handleNet(ctx, []string{"kill"})
+		if !strings.Contains(buf.String(), "Error: not connected to server") {
+			t.Errorf("got %q", buf.String())
+		}
+	})
+}
@@ -2,17 +2,23 @@ package cli
 
 import "fmt"
 
-func init() { register("plate", handlePlate) }
+func init() {
+	register("plate", Command{
+		Handler: handlePlate,
+		Usage:   "plate <license_plate>",
+		Help:    "Set the EV license plate and send it via DataTransfer (vendor LicensePlate).",
+	})
+}
 
 // handlePlate sends the EV license plate via DataTransfer.
 func handlePlate(ctx *CommandContext, args []string) {
 	if len(args) < 1 {
-		fmt.Fprintln(ctx.Out, "Usage: plate <license_plate>")
+		ctx.Failf("Usage: plate <license_plate>")
 		return
 	}
 	plate := args[0]
 	if err := ctx.Charger.SetLicensePlateAndSend(plate); err != nil {
-		fmt.Fprintf(ctx.Out, "Error: %v\n", err)
+		ctx.Failf("Error: %v", err)
 	} else {
 		fmt.Fprintf(ctx.Out, "License plate set: %s\n", plate)
 	}
@@ -2,12 +2,18 @@ package cli
 
 import "fmt"
 
-func init() { register("plugin", handlePlugin) }
+func init() {
+	register("plugin", Command{
+		Handler: handlePlugin,
+		Usage:   "plugin",
+		Help:    "Simulate a car plugging in (Preparing in 1.6, Occupied in 2.0.1).",
+	})
+}
 
 // handlePlugin simulates a car plugging in.
 func handlePlugin(ctx *CommandContext, args []string) {
 	if err := ctx.Charger.Plugin(); err != nil {
-		fmt.Fprintf(ctx.Out, "Error: %v\n", err)
+		ctx.Failf("Error: %v", err)
 	} else {
 		fmt.Fprintln(ctx.Out, "Car plugged in (Preparing)")
 	}
@@ -2,7 +2,14 @@ package cli
 
 import "fmt"
 
-func init() { register("power", handlePower) }
+func init() {
+	register("power", Command{
+		Handler: handlePower,
+		Usage:   "power <watts>",
+		Help: "Set the charging power limit (0 = SuspendedEVSE). Without an argument, show " +
+			"the present value.",
+	})
+}
 
 // handlePower sets the charging power, or reports usage plus the present value
 // when no argument is given.
@@ -14,11 +21,11 @@ func handlePower(ctx *CommandContext, args []string) {
 	}
 	var power float64
 	if _, err := fmt.Sscanf(args[0], "%f", &power); err != nil {
-		fmt.Fprintf(ctx.Out, "Error: invalid power value: %s\n", args[0])
+		ctx.Failf("Error: invalid power value: %s", args[0])
 		return
 	}
 	if err := ctx.Charger.SetPower(power); err != nil {
-		fmt.Fprintf(ctx.Out, "Error: %v\n", err)
+		ctx.Failf("Error: %v", err)
 	} else {
 		fmt.Fprintf(ctx.Out, "Power set to: %.1f W\n", power)
 	}
@@ -3,8 +3,16 @@ package cli
 import "fmt"
 
 func init() {
-	register("quit", handleQuit)
-	register("exit", handleQuit)
+	register("quit", Command{
+		Handler: handleQuit,
+		Usage:   "quit",
+		Help:    "Exit guidance: the simulator is exited with Ctrl+C.",
+	})
+	register("exit", Command{
+		Handler: handleQuit,
+		Usage:   "exit",
+		Help:    "Exit guidance: the simulator is exited with Ctrl+C.",
+	})
 }
 
 // handleQuit handles both the "quit" and "exit" aliases. The interactive loop
@@ -0,0 +1,41 @@
+package cli
+
+import "fmt"
+
+func init() {
+	register("reset", Command{
+		Handler: handleReset,
+		Usage:   "reset [watchdog]",
+		Help: "Restart the charger as if its reset button was pressed (boot reason LocalReset), " +
+			"or as if its watchdog fired (Watchdog). A running transaction is stopped first; " +
+			"the charger reconnects if it was connected.",
+		Complete: completeReset,
+	})
+}
+
+// handleReset restarts the charger with a local reset or a watchdog reset, so
+// the server sees the matching boot reason.
+func handleReset(ctx *CommandContext, args []string) {
+	reset, reason := ctx.Charger.Reset, "LocalReset"
+	if len(args) > 0 {
+		if args[0] != "watchdog" {
+			ctx.Failf("Usage: reset [watchdog]")
+			return
+		}
+		reset, reason = ctx.Charger.WatchdogReset, "Watchdog"
+	}
+	fmt.Fprintf(ctx.Out, "Rebooting (%s)\n", reason)
+	if err := reset(); err != nil {
+		ctx.Failf("Error: %v", err)
+		return
+	}
+	fmt.Fprintf(ctx.Out, "Reboot complete (status: %s)\n", ctx.Charger.GetStatus())
+}
+
+// completeReset completes the reset kinds.
+func completeReset(ctx *CommandContext, args []string) []string {
+	if len(args) == 1 {
+		return []string{"watchdog"}
+	}
+	return nil
+}
@@ -0,0 +1,51 @@
+package cli
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestHandleReset(t *testing.T) {
+	t.Run("local reset", func(t *testing.T) {
+		f := &fakeCharger{status: "Available"}
+		ctx, buf := newCtx(f, cfg16())
+		handleReset(ctx, nil)
+		if f.lastReset != "LocalReset" {
+			t.Errorf("reset = %q, want LocalReset", f.lastReset)
+		}
+		if !strings.Contains(buf.String(), "Rebooting (LocalReset)") || !strings.Contains(buf.String(), "Reboot complete (status: Available)") {
+			t.Errorf("got %q", buf.String())
+		}
+	})
+
+	t.Run("watchdog", func(t *testing.T) {
+		f := &fakeCharger{status: "Available"}
+		ctx, buf := newCtx(f, cfg16())
+		handleReset(ctx, []string{"watchdog"})
+		if f.lastReset != "Watchdog" {
+			t.Errorf("reset = %q, want Watchdog", f.lastReset)
+		}
+		if !strings.Contains(buf.String(), "Rebooting (Watchdog)") {
+			t.Errorf("got %q", buf.String())
+		}
+	})
+
+	t.Run("usage on unknown argument", func(t *testing.T) {
+		f := &fakeCharger{}
+		ctx, buf := newCtx(f, cfg16())
+		handleReset(ctx, []string{"hard"})
+		if !strings.Contains(buf.String(), "Usage: reset [watchdog]") || f.lastReset != "" {
+			t.Errorf("got %q, reset %q", buf.String(), f.lastReset)
+		}
+	})
+
+	t.Run("error", func(t *testing.T) {
+		f := &fakeCharger{resetErr: errors.New("reconnect after reboot failed")}
+		ctx, buf := newCtx(f, cfg16())
+		handleReset(ctx, nil)
+		if !strings.Contains(buf.String(), "Error: reconnect after reboot failed") {
+			t.Errorf("got %q", buf.String())
+		}
+	})
+}
@@ -0,0 +1,52 @@
+package cli
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Security event types of the 1.6 Security Whitepaper and of 2.0.1, which
+// name the server CSMS instead of Central System
+var (
+	securityEvents16  = []string{"FirmwareUpdated", "FailedToAuthenticateAtCentralSystem", "CentralSystemFailedToAuthenticate", "SettingSystemTime", "StartupOfTheDevice", "ResetOrReboot", "SecurityLogWasCleared", "ReconfigurationOfSecurityParameters", "MemoryExhaustion", "InvalidMessages", "AttemptedReplayAttacks", "TamperDetectionActivated", "InvalidFirmwareSignature", "InvalidFirmwareSigningCertificate", "InvalidCentralSystemCertificate", "InvalidChargePointCertificate", "InvalidTLSVersion", "InvalidTLSCipherSuite"}
+	securityEvents201 = []string{"FirmwareUpdated", "FailedToAuthenticateAtCsms", "CsmsFailedToAuthenticate", "SettingSystemTime", "StartupOfTheDevice", "ResetOrReboot", "SecurityLogWasCleared", "ReconfigurationOfSecurityParameters", "MemoryExhaustion", "InvalidMessages", "AttemptedReplayAttacks", "TamperDetectionActivated", "InvalidFirmwareSignature", "InvalidFirmwareSigningCertificate", "InvalidCsmsCertificate", "InvalidChargingStationCertificate", "InvalidTLSVersion", "InvalidTLSCipherSuite"}
+)
+
+func init() {
+	register("security", Command{
+		Handler: handleSecurity,
+		Usage:   "security <type> [techInfo...]",
+		Help: "Raise a security event (SecurityEventNotification), queued until " +
+			"connected. Without arguments, show how many events are queued.",
+		Complete: completeSecurityEvent,
+	})
+}
+
+// handleSecurity raises a security event with optional tech info, e.g. to test
+// how the server handles SecurityEventNotification. Without arguments it shows
+// how many events are waiting to be sent.
+func handleSecurity(ctx *CommandContext, args []string) {
+	if len(args) < 1 {
+		fmt.Fprintf(ctx.Out, "Queued security events: %d\n", ctx.Charger.QueuedSecurityEvents())
+		fmt.Fprintln(ctx.Out, "Usage: security <type> [techInfo...]")
+		return
+	}
+	ctx.Charger.RaiseSecurityEvent(args[0], strings.Join(args[1:], " "))
+	if !ctx.Charger.IsConnected() {
+		fmt.Fprintf(ctx.Out, "Security event %s queued until connected\n", args[0])
+		return
+	}
+	fmt.Fprintf(ctx.Out, "Security event %s raised\n", args[0])
+}
+
+// completeSecurityEvent completes the security event types of the configured
+// OCPP version.
+func completeSecurityEvent(ctx *CommandContext, args []string) []string {
+	if len(args) != 1 {
+		return nil
+	}
+	if ctx.Config.IsOCPP16() {
+		return securityEvents16
+	}
+	return securityEvents201
+}
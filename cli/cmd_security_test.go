@@ -0,0 +1,42 @@
+package cli
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestHandleSecurity(t *testing.T) {
+	t.Run("shows queue and usage", func(t *testing.T) {
+		f := &fakeCharger{queuedEvents: 3}
+		ctx, buf := newCtx(f, cfg16())
+		handleSecurity(ctx, nil)
+		out := buf.String()
+		if !strings.Contains(out, "Queued security events: 3") || !strings.Contains(out, "Usage: security <type> [techInfo...]") {
+			t.Errorf("got %q", out)
+		}
+	})
+
+	t.Run("raises event with tech info", func(t *testing.T) {
+		f := &fakeCharger{connected: true}
+		ctx, buf := newCtx(f, cfg201())
+		handleSecurity(ctx, []string{"TamperDetectionActivated", "enclosure", "opened"})
+		if f.lastEventType != "TamperDetectionActivated" || f.lastEventInfo != "enclosure opened" {
+			t.Errorf("raised %q with %q", f.lastEventType, f.lastEventInfo)
+		}
+		if !strings.Contains(buf.String(), "Security event TamperDetectionActivated raised") {
+			t.Errorf("got %q", buf.String())
+		}
+	})
+
+	t.Run("queued while offline", func(t *testing.T) {
+		f := &fakeCharger{}
+		ctx, buf := newCtx(f, cfg16())
+		handleSecurity(ctx, []string{"MemoryExhaustion"})
+		if f.lastEventType != "MemoryExhaustion" || f.lastEventInfo != "" {
+			t.Errorf("raised %q with %q", f.lastEventType, f.lastEventInfo)
+		}
+		if !strings.Contains(buf.String(), "queued until connected") {
+			t.Errorf("got %q", buf.String())
+		}
+	})
+}
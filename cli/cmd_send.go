@@ -0,0 +1,42 @@
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+func init() {
+	register("send", Command{
+		Handler: handleSend,
+		Usage:   "send <Action> [json-payload]",
+		Help: "Send a Call with any action and JSON payload (default {}) and print the " +
+			"raw response or CallError.",
+	})
+}
+
+// handleSend sends a Call with any action and JSON payload, e.g. a vendor
+// DataTransfer or an action the simulator has no code for, and prints the raw
+// response. The payload defaults to {}.
+func handleSend(ctx *CommandContext, args []string) {
+	if len(args) < 1 {
+		ctx.Failf("Usage: send <Action> [json-payload]")
+		fmt.Fprintln(ctx.Out, `Example: send DataTransfer {"vendorId":"com.example","messageId":"Ping"}`)
+		return
+	}
+	payload := "{}"
+	if len(args) > 1 {
+		payload = strings.Join(args[1:], " ")
+	}
+	if !json.Valid([]byte(payload)) {
+		ctx.Failf("Error: payload is not valid JSON: %s", payload)
+		return
+	}
+
+	resp, err := ctx.Charger.SendCall(args[0], json.RawMessage(payload))
+	if err != nil {
+		ctx.Failf("Error: %v", err)
+		return
+	}
+	fmt.Fprintf(ctx.Out, "Response: %s\n", resp)
+}
@@ -0,0 +1,64 @@
+package cli
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestHandleSend(t *testing.T) {
+	t.Run("usage without action", func(t *testing.T) {
+		f := &fakeCharger{}
+		ctx, buf := newCtx(f, cfg16())
+		handleSend(ctx, nil)
+		if !strings.Contains(buf.String(), "Usage: send <Action> [json-payload]") {
+			t.Errorf("got %q", buf.String())
+		}
+		if f.lastSendAction != "" {
+			t.Errorf("must not send, sent %q", f.lastSendAction)
+		}
+	})
+
+	t.Run("sends payload and prints response", func(t *testing.T) {
+		f := &fakeCharger{connected: true, sendResponse: json.RawMessage(`{"status":"Accepted","data":"pong"}`)}
+		ctx, buf := newCtx(f, cfg16())
+		handleSend(ctx, []string{"DataTransfer", `{"vendorId":"com.example",`, `"messageId":"Ping"}`})
+		if f.lastSendAction != "DataTransfer" || f.lastSendPayload != `{"vendorId":"com.example", "messageId":"Ping"}` {
+			t.Errorf("sent %q with %q", f.lastSendAction, f.lastSendPayload)
+		}
+		if !strings.Contains(buf.String(), `Response: {"status":"Accepted","data":"pong"}`) {
+			t.Errorf("got %q", buf.String())
+		}
+	})
+
+	t.Run("payload defaults to empty object", func(t *testing.T) {
+		f := &fakeCharger{connected: true, sendResponse: json.RawMessage(`{}`)}
+		ctx, _ := newCtx(f, cfg201())
+		handleSend(ctx, []string{"Heartbeat"})
+		if f.lastSendPayload != "{}" {
+			t.Errorf("sent %q", f.lastSendPayload)
+		}
+	})
+
+	t.Run("rejects invalid JSON", func(t *testing.T) {
+		f := &fakeCharger{connected: true}
+		ctx, buf := newCtx(f, cfg16())
+		handleSend(ctx, []string{"DataTransfer", "{vendorId}"})
+		if !strings.Contains(buf.String(), "Error: payload is not valid JSON") {
+			t.Errorf("got %q", buf.String())
+		}
+		if f.lastSendAction != "" {
+			t.Errorf("must not send, sent %q", f.lastSendAction)
+		}
+	})
+
+	t.Run("prints error", func(t *testing.T) {
+		f := &fakeCharger{connected: true, sendErr: errors.New("server answered Foo with CallError NotImplemented")}
+		ctx, buf := newCtx(f, cfg201())
+		handleSend(ctx, []string{"Foo", "{}"})
+		if !strings.Contains(buf.String(), "Error: server answered Foo with CallError NotImplemented") {
+			t.Errorf("got %q", buf.String())
+		}
+	})
+}
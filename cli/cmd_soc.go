@@ -2,21 +2,27 @@ package cli
 
 import "fmt"
 
-func init() { register("soc", handleSoc) }
+func init() {
+	register("soc", Command{
+		Handler: handleSoc,
+		Usage:   "soc <0-100>",
+		Help:    "Set the State of Charge of the EV battery in percent.",
+	})
+}
 
 // handleSoc sets the State of Charge from a numeric argument.
 func handleSoc(ctx *CommandContext, args []string) {
 	if len(args) < 1 {
-		fmt.Fprintln(ctx.Out, "Usage: soc <0-100>")
+		ctx.Failf("Usage: soc <0-100>")
 		return
 	}
 	var soc float64
 	if _, err := fmt.Sscanf(args[0], "%f", &soc); err != nil {
-		fmt.Fprintf(ctx.Out, "Error: invalid SOC value: %s\n", args[0])
+		ctx.Failf("Error: invalid SOC value: %s", args[0])
 		return
 	}
 	if err := ctx.Charger.SetSOC(soc); err != nil {
-		fmt.Fprintf(ctx.Out, "Error: %v\n", err)
+		ctx.Failf("Error: %v", err)
 	} else {
 		fmt.Fprintf(ctx.Out, "SOC set to: %.1f%%\n", soc)
 	}
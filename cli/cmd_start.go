@@ -2,17 +2,23 @@ package cli
 
 import "fmt"
 
-func init() { register("start", handleStart) }
+func init() {
+	register("start", Command{
+		Handler: handleStart,
+		Usage:   "start <idTag>",
+		Help:    "Authorize idTag and start a transaction. The car must be plugged in.",
+	})
+}
 
 // handleStart starts a transaction for the given idTag.
 func handleStart(ctx *CommandContext, args []string) {
 	if len(args) < 1 {
-		fmt.Fprintln(ctx.Out, "Usage: start <idTag>")
+		ctx.Failf("Usage: start <idTag>")
 		return
 	}
 	idTag := args[0]
 	if err := ctx.Charger.StartTransaction(idTag); err != nil {
-		fmt.Fprintf(ctx.Out, "Error: %v\n", err)
+		ctx.Failf("Error: %v", err)
 	} else {
 		fmt.Fprintln(ctx.Out, "Transaction started")
 	}
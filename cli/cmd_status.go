@@ -1,25 +1,61 @@
 package cli
 
-import "fmt"
+import (
+	"fmt"
+	"io"
+	"strings"
 
-func init() { register("status", handleStatus) }
+	"github.com/weilun-shrimp/wlgo_ocpp_charger_simulator/config"
+)
+
+// Connector statuses of each OCPP version
+var (
+	statuses16  = config.ConnectorStatuses16
+	statuses201 = config.ConnectorStatuses201
+)
+
+func init() {
+	register("status", Command{
+		Handler:  handleStatus,
+		Usage:    "status <status>",
+		Help:     "Set the connector status and send StatusNotification.",
+		Complete: completeStatus,
+	})
+}
 
 // handleStatus sets the charger status, or prints usage plus the valid-status
 // list for the configured OCPP version when no argument is given.
 func handleStatus(ctx *CommandContext, args []string) {
 	if len(args) < 1 {
-		fmt.Fprintln(ctx.Out, "Usage: status <status>")
-		if ctx.Config.IsOCPP16() {
-			fmt.Fprintln(ctx.Out, "Valid statuses (OCPP 1.6): Available, Preparing, Charging, SuspendedEVSE, SuspendedEV, Finishing, Reserved, Unavailable, Faulted")
-		} else {
-			fmt.Fprintln(ctx.Out, "Valid statuses (OCPP 2.0.1): Available, Occupied, Reserved, Unavailable, Faulted")
-		}
+		ctx.Failf("Usage: status <status>")
+		printValidStatuses(ctx.Out, ctx.Config)
 		return
 	}
 	status := args[0]
 	if err := ctx.Charger.SetStatus(status); err != nil {
-		fmt.Fprintf(ctx.Out, "Error: %v\n", err)
+		ctx.Failf("Error: %v", err)
 	} else {
 		fmt.Fprintf(ctx.Out, "Status updated to: %s\n", status)
 	}
 }
+
+// validStatuses returns the connector statuses of the configured OCPP version.
+func validStatuses(cfg *config.Config) []string {
+	if cfg.IsOCPP16() {
+		return statuses16
+	}
+	return statuses201
+}
+
+// printValidStatuses prints the valid-status list of the configured version.
+func printValidStatuses(out io.Writer, cfg *config.Config) {
+	fmt.Fprintf(out, "Valid statuses (OCPP %s): %s\n", cfg.OCPPVersion, strings.Join(validStatuses(cfg), ", "))
+}
+
+// completeStatus completes the statuses of the configured OCPP version.
+func completeStatus(ctx *CommandContext, args []string) []string {
+	if len(args) == 1 {
+		return validStatuses(ctx.Config)
+	}
+	return nil
+}
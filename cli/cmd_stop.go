@@ -2,7 +2,20 @@ package cli
 
 import "fmt"
 
-func init() { register("stop", handleStop) }
+// Stop reasons of each OCPP version (Reason in 1.6, stoppedReason in 2.0.1)
+var (
+	stopReasons16  = []string{"Local", "Remote", "EVDisconnected", "EmergencyStop", "HardReset", "SoftReset", "PowerLoss", "Reboot", "UnlockCommand", "DeAuthorized", "Other"}
+	stopReasons201 = []string{"Local", "Remote", "EVDisconnected", "EmergencyStop", "ImmediateReset", "PowerLoss", "Reboot", "DeAuthorized", "EnergyLimitReached", "GroundFault", "LocalOutOfCredit", "MasterPass", "OvercurrentFault", "PowerQuality", "SOCLimitReached", "StoppedByEV", "TimeLimitReached", "Timeout", "Other"}
+)
+
+func init() {
+	register("stop", Command{
+		Handler:  handleStop,
+		Usage:    "stop [reason]",
+		Help:     "Stop the current transaction with an OCPP stop reason (default: Local).",
+		Complete: completeStopReason,
+	})
+}
 
 // handleStop stops the current transaction, defaulting the reason to "Local".
 func handleStop(ctx *CommandContext, args []string) {
@@ -11,8 +24,19 @@ func handleStop(ctx *CommandContext, args []string) {
 		reason = args[0]
 	}
 	if err := ctx.Charger.StopTransaction(reason); err != nil {
-		fmt.Fprintf(ctx.Out, "Error: %v\n", err)
+		ctx.Failf("Error: %v", err)
 	} else {
 		fmt.Fprintln(ctx.Out, "Transaction stopped")
 	}
 }
+
+// completeStopReason completes the stop reasons of the configured OCPP version.
+func completeStopReason(ctx *CommandContext, args []string) []string {
+	if len(args) != 1 {
+		return nil
+	}
+	if ctx.Config.IsOCPP16() {
+		return stopReasons16
+	}
+	return stopReasons201
+}
@@ -2,12 +2,18 @@ package cli
 
 import "fmt"
 
-func init() { register("unplug", handleUnplug) }
+func init() {
+	register("unplug", Command{
+		Handler: handleUnplug,
+		Usage:   "unplug",
+		Help:    "Simulate a car unplugging (Available).",
+	})
+}
 
 // handleUnplug simulates a car unplugging.
 func handleUnplug(ctx *CommandContext, args []string) {
 	if err := ctx.Charger.Unplug(); err != nil {
-		fmt.Fprintf(ctx.Out, "Error: %v\n", err)
+		ctx.Failf("Error: %v", err)
 	} else {
 		fmt.Fprintln(ctx.Out, "Car unplugged (Available)")
 	}
@@ -0,0 +1,46 @@
+package cli
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+)
+
+func init() {
+	register("wait", Command{
+		Handler: handleWait,
+		Usage:   "wait <duration>",
+		Help: "Pause before the next command, e.g. in a script: a duration such as " +
+			"500ms, 60s or 2m, or a number of seconds.",
+	})
+}
+
+// handleWait sleeps for the given duration
+func handleWait(ctx *CommandContext, args []string) {
+	if len(args) < 1 {
+		ctx.Failf("Usage: wait <duration>")
+		return
+	}
+	d, err := parseWaitDuration(args[0])
+	if err != nil {
+		ctx.Failf("Invalid duration: %s", args[0])
+		return
+	}
+	time.Sleep(d)
+}
+
+// parseWaitDuration parses a Go duration or a number of seconds
+func parseWaitDuration(s string) (time.Duration, error) {
+	d, err := time.ParseDuration(s)
+	if err != nil {
+		seconds, serr := strconv.ParseFloat(s, 64)
+		if serr != nil {
+			return 0, err
+		}
+		d = time.Duration(seconds * float64(time.Second))
+	}
+	if d < 0 {
+		return 0, fmt.Errorf("negative duration %s", s)
+	}
+	return d, nil
+}
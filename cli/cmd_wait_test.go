@@ -0,0 +1,50 @@
+package cli
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestHandleWait(t *testing.T) {
+	ctx, buf := newCtx(&fakeCharger{}, cfg16())
+	start := time.Now()
+	handleWait(ctx, []string{"20ms"})
+	if elapsed := time.Since(start); elapsed < 20*time.Millisecond {
+		t.Errorf("wait returned after %v", elapsed)
+	}
+	if buf.Len() != 0 {
+		t.Errorf("expected no output, got %q", buf.String())
+	}
+
+	for _, tc := range []struct {
+		args []string
+		want string
+	}{
+		{nil, "Usage: wait <duration>"},
+		{[]string{"soon"}, "Invalid duration: soon"},
+		{[]string{"-1s"}, "Invalid duration: -1s"},
+	} {
+		ctx, buf := newCtx(&fakeCharger{}, cfg16())
+		handleWait(ctx, tc.args)
+		if !strings.Contains(buf.String(), tc.want) {
+			t.Errorf("wait %v: expected %q, got %q", tc.args, tc.want, buf.String())
+		}
+	}
+}
+
+func TestParseWaitDuration(t *testing.T) {
+	for _, tc := range []struct {
+		in   string
+		want time.Duration
+	}{
+		{"500ms", 500 * time.Millisecond},
+		{"2m", 2 * time.Minute},
+		{"60", time.Minute},
+		{"0.5", 500 * time.Millisecond},
+	} {
+		if got, err := parseWaitDuration(tc.in); err != nil || got != tc.want {
+			t.Errorf("parseWaitDuration(%q) = %v, %v; want %v", tc.in, got, err, tc.want)
+		}
+	}
+}
@@ -0,0 +1,55 @@
+package cli
+
+import "strings"
+
+// completeLine returns the completions of the word before the cursor in line
+// (the text up to the cursor) and the rune offset where that word starts. The
+// first word completes to command verbs, later ones through the command's
+// Completer. Candidates match the typed prefix case-insensitively.
+func completeLine(ctx *CommandContext, line string) (start int, candidates []string) {
+	wordStart := strings.LastIndexAny(line, " \t") + 1
+	word := line[wordStart:]
+	start = len([]rune(line[:wordStart]))
+
+	var all []string
+	if wordStart == 0 {
+		all = commandNames()
+	} else {
+		fields := strings.Fields(line)
+		cmd, ok := registry[strings.ToLower(fields[0])]
+		if !ok || cmd.Complete == nil {
+			return start, nil
+		}
+		args := fields[1:]
+		if word == "" {
+			args = append(args, "")
+		}
+		all = cmd.Complete(ctx, args)
+	}
+
+	prefix := strings.ToLower(word)
+	for _, c := range all {
+		if strings.HasPrefix(strings.ToLower(c), prefix) {
+			candidates = append(candidates, c)
+		}
+	}
+	return start, candidates
+}
+
+// commonPrefix returns the longest prefix shared by all candidates, compared
+// case-insensitively and taken from the first candidate.
+func commonPrefix(candidates []string) string {
+	if len(candidates) == 0 {
+		return ""
+	}
+	prefix := []rune(candidates[0])
+	for _, c := range candidates[1:] {
+		r := []rune(c)
+		n := 0
+		for n < len(prefix) && n < len(r) && strings.EqualFold(string(prefix[n]), string(r[n])) {
+			n++
+		}
+		prefix = prefix[:n]
+	}
+	return string(prefix)
+}
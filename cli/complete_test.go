@@ -0,0 +1,70 @@
+package cli
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestCompleteLine(t *testing.T) {
+	cases := []struct {
+		name      string
+		cfg16     bool
+		line      string
+		wantStart int
+		want      []string
+	}{
+		{"verbs by prefix", true, "sta", 0, []string{"start", "status"}},
+		{"verb prefix is case-insensitive", true, "DIS", 0, []string{"disconnect"}},
+		{"1.6 statuses", true, "status Su", 7, []string{"SuspendedEVSE", "SuspendedEV"}},
+		{"2.0.1 statuses", false, "status ", 7, statuses201},
+		{"2.0.1 has no Preparing", false, "status Pre", 7, nil},
+		{"1.6 stop reasons", true, "stop Un", 5, []string{"UnlockCommand"}},
+		{"2.0.1 stop reasons", false, "stop Un", 5, nil},
+		{"help completes verbs", true, "help dat", 5, []string{"datatransfer"}},
+		{"free text argument", true, "start T", 6, nil},
+		{"unknown verb", true, "frobnicate x", 11, nil},
+		{"no completion past the argument", true, "lock jam ", 9, nil},
+		{"net options", true, "net re", 4, []string{"reorder", "reuse-id", "result-delay"}},
+		{"security events follow the version", false, "security InvalidC", 9, []string{"InvalidCsmsCertificate", "InvalidChargingStationCertificate"}},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			cfg := cfg201()
+			if tc.cfg16 {
+				cfg = cfg16()
+			}
+			ctx, _ := newCtx(&fakeCharger{}, cfg)
+			start, got := completeLine(ctx, tc.line)
+			if start != tc.wantStart || !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("completeLine(%q) = %d, %v; want %d, %v", tc.line, start, got, tc.wantStart, tc.want)
+			}
+		})
+	}
+}
+
+func TestCompleteFaultClear(t *testing.T) {
+	f := &fakeCharger{faults: []string{"GroundFailure since 2026-01-02T03:04:05Z (RCD tripped)"}}
+	ctx, _ := newCtx(f, cfg16())
+	_, got := completeLine(ctx, "fault clear ")
+	if !reflect.DeepEqual(got, []string{"GroundFailure"}) {
+		t.Errorf("got %v", got)
+	}
+}
+
+func TestCommonPrefix(t *testing.T) {
+	cases := []struct {
+		candidates []string
+		want       string
+	}{
+		{nil, ""},
+		{[]string{"status"}, "status"},
+		{[]string{"SuspendedEVSE", "SuspendedEV"}, "SuspendedEV"},
+		{[]string{"start", "status", "stop"}, "st"},
+		{[]string{"Local", "lower"}, "Lo"},
+	}
+	for _, tc := range cases {
+		if got := commonPrefix(tc.candidates); got != tc.want {
+			t.Errorf("commonPrefix(%v) = %q; want %q", tc.candidates, got, tc.want)
+		}
+	}
+}
@@ -1,9 +1,12 @@
 package cli
 
 import (
+	"fmt"
 	"io"
+	"strings"
 
 	"github.com/weilun-shrimp/wlgo_ocpp_charger_simulator/config"
+	"github.com/weilun-shrimp/wlgo_ocpp_charger_simulator/logging"
 )
 
 // CommandContext carries everything a command handler is allowed to touch: the
@@ -14,4 +17,23 @@ type CommandContext struct {
 	Charger Charger
 	Config  *config.Config
 	Out     io.Writer
+	Levels  *logging.Levels // log levels changed by the log command, nil if unknown
+	Output  string          // OutputText (also when empty) or OutputJSON
+
+	shell *Shell // interactive shell running the command, nil in tests
+
+	failure string // first failure reported with Failf
+	failed  bool
+}
+
+// Failf writes a failure line to Out, like Fprintf with a trailing newline,
+// and marks the command failed. The json output and scripts report the first
+// failure, without its "Error: " prefix.
+func (ctx *CommandContext) Failf(format string, args ...interface{}) {
+	line := fmt.Sprintf(format, args...)
+	fmt.Fprintln(ctx.Out, line)
+	if !ctx.failed {
+		ctx.failure = strings.TrimPrefix(line, "Error: ")
+		ctx.failed = true
+	}
 }
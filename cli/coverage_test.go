@@ -10,7 +10,7 @@ func TestRegistryCoversAllCommands(t *testing.T) {
 	want := []string{
 		"help", "connect", "disconnect", "plugin", "unplug",
 		"status", "start", "stop", "meter", "plate",
-		"soc", "current", "power", "info", "quit", "exit",
+		"soc", "current", "power", "lock", "reset", "cert", "security", "fault", "net", "send", "datatransfer", "dashboard", "log", "wait", "config", "info", "quit", "exit",
 	}
 
 	for _, name := range want {
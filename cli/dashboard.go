@@ -0,0 +1,373 @@
+package cli
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Dashboard layout
+const (
+	dashboardRefresh     = time.Second
+	dashboardOutputLines = 6   // rows of the command output and log panel
+	dashboardKeptOutput  = 200 // output lines kept for the panel
+	dashboardPrompt      = "dashboard> "
+)
+
+// sparkBlocks draws a sparkline, lowest value first
+var sparkBlocks = []rune("▁▂▃▄▅▆▇█")
+
+// dashboard is the full-screen view shown by the dashboard command: the
+// charger state, an energy sparkline, the OCPP frame log and the output of
+// commands and the log, redrawn every second and after each command. It is an
+// io.Writer collecting the output panel.
+type dashboard struct {
+	ctx *CommandContext
+
+	mu      sync.Mutex
+	filter  string // frames must contain it (case-insensitive), "" for all
+	compact bool   // frames on one line instead of pretty-printed
+	output  []string
+	partial string // output line not ended yet
+	energy  []int  // meter readings, one per refresh
+}
+
+// Write adds command output or log lines to the output panel.
+func (d *dashboard) Write(p []byte) (int, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	text := d.partial + strings.ReplaceAll(string(p), "\r", "")
+	lines := strings.Split(text, "\n")
+	d.partial = lines[len(lines)-1]
+	d.output = append(d.output, lines[:len(lines)-1]...)
+	if len(d.output) > dashboardKeptOutput {
+		d.output = d.output[len(d.output)-dashboardKeptOutput:]
+	}
+	return len(p), nil
+}
+
+// setFilter sets the frame filter; "" shows all frames.
+func (d *dashboard) setFilter(filter string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.filter = filter
+}
+
+// togglePretty switches between pretty-printed and one-line frames.
+func (d *dashboard) togglePretty() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.compact = !d.compact
+}
+
+// sample records the meter reading for the sparkline, keeping as many as fit
+// in width.
+func (d *dashboard) sample(width int) {
+	meter := d.ctx.Charger.GetMeterValue()
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.energy = append(d.energy, meter)
+	if keep := max(width-24, 1); len(d.energy) > keep {
+		d.energy = d.energy[len(d.energy)-keep:]
+	}
+}
+
+// lines renders the screen above the prompt row for a terminal of the given
+// size. Every line fits in width.
+func (d *dashboard) lines(width, height int) []string {
+	ch := d.ctx.Charger
+	cfg := d.ctx.Config
+
+	connection := "disconnected"
+	if ch.IsConnected() {
+		connection = "connected (" + ch.GetSubprotocol() + ")"
+	}
+	registration := ch.GetRegistrationStatus()
+	if registration == "" {
+		registration = "not registered"
+	}
+	heartbeat := fmt.Sprintf("every %d s", ch.GetHeartbeatInterval())
+	if last := ch.GetLastHeartbeat(); !last.IsZero() {
+		heartbeat += fmt.Sprintf(", last %s ago", time.Since(last).Round(time.Second))
+	}
+
+	transaction := "none"
+	if ch.IsCharging() {
+		transaction = ch.GetTransactionId()
+		if transaction == "" {
+			transaction = "pending"
+		}
+		transaction += " (idTag " + ch.GetIdTag() + ")"
+	}
+
+	soc := ch.GetSOC()
+	socBar := strings.Repeat("█", int(soc/10)) + strings.Repeat("░", 10-int(soc/10))
+	actualPower := ch.GetActualPower()
+	actualCurrent := 0.0
+	if cfg.Voltage > 0 {
+		actualCurrent = actualPower / cfg.Voltage
+	}
+
+	profiles := strings.Join(ch.ChargingProfiles(), "; ")
+	if profiles == "" {
+		profiles = "none"
+	}
+	faults := strings.Join(ch.ActiveFaults(), "; ")
+	if faults == "" {
+		faults = "none"
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	title := fmt.Sprintf(" %s  OCPP %s", cfg.ChargerID, cfg.OCPPVersion)
+	clock := time.Now().Format("15:04:05") + " "
+	header := title + strings.Repeat(" ", max(width-len([]rune(title))-len(clock), 1)) + clock
+
+	lines := []string{
+		"\x1b[7m" + truncate(header, width) + "\x1b[0m",
+		truncate(fmt.Sprintf(" Connection: %s, %s   Heartbeat: %s", connection, registration, heartbeat), width),
+		truncate(fmt.Sprintf(" Status: %s   Transaction: %s   Cable: %s", ch.GetStatus(), transaction, lockState(ch)), width),
+		truncate(fmt.Sprintf(" SoC: %s %.1f%%   Energy: %d Wh", socBar, soc, ch.GetMeterValue()), width),
+		truncate(fmt.Sprintf(" Current: %.1f / %.1f A   Power: %.0f / %.0f W   (drawn / limit)", actualCurrent, ch.GetCurrent(), actualPower, ch.GetPower()), width),
+		truncate(" Energy trend: "+sparkline(d.energy), width),
+		truncate(" Profiles: "+profiles, width),
+		truncate(" Faults: "+faults, width),
+	}
+
+	frameTitle := "Frames"
+	if d.filter != "" {
+		frameTitle += " (filter: " + d.filter + ")"
+	}
+	outputRows := min(dashboardOutputLines, max(height-len(lines)-4, 0))
+	frameRows := max(height-len(lines)-outputRows-3, 0)
+
+	lines = append(lines, separator(frameTitle, width))
+	lines = append(lines, d.frameLines(ch.RecentFrames(), frameRows, width)...)
+	lines = append(lines, separator("Output", width))
+	output := d.output
+	if len(output) > outputRows {
+		output = output[len(output)-outputRows:]
+	}
+	for _, line := range output {
+		lines = append(lines, truncate(" "+line, width))
+	}
+	for len(lines) < height-1 {
+		lines = append(lines, "")
+	}
+	return lines[:max(height-1, 0)]
+}
+
+// frameLines renders the newest frames matching the filter into rows lines,
+// padding with blank lines on top. d.mu must be held.
+func (d *dashboard) frameLines(frames []string, rows, width int) []string {
+	var lines []string
+	filter := strings.ToLower(d.filter)
+	for i := len(frames) - 1; i >= 0 && len(lines) < rows; i-- {
+		frame := frames[i]
+		if filter != "" && !strings.Contains(strings.ToLower(frame), filter) {
+			continue
+		}
+		color := "\x1b[36m" // sent
+		if strings.Contains(frame, " <- ") {
+			color = "\x1b[32m"
+		}
+		var rendered []string
+		for _, line := range d.formatFrame(frame) {
+			rendered = append(rendered, color+truncate(" "+line, width)+"\x1b[0m")
+		}
+		lines = append(rendered, lines...)
+	}
+	if len(lines) > rows {
+		lines = lines[len(lines)-rows:]
+	}
+	for len(lines) < rows {
+		lines = append([]string{""}, lines...)
+	}
+	return lines
+}
+
+// formatFrame splits a "15:04:05 -> [...]" frame into lines, pretty-printing
+// the JSON unless compact. d.mu must be held.
+func (d *dashboard) formatFrame(frame string) []string {
+	prefix, message, ok := strings.Cut(frame, "[")
+	if !ok || d.compact {
+		return []string{frame}
+	}
+	var pretty bytes.Buffer
+	if err := json.Indent(&pretty, []byte("["+message), "", "  "); err != nil {
+		return []string{frame}
+	}
+	lines := strings.Split(pretty.String(), "\n")
+	indent := strings.Repeat(" ", len(prefix))
+	lines[0] = prefix + lines[0]
+	for i := 1; i < len(lines); i++ {
+		lines[i] = indent + lines[i]
+	}
+	return lines
+}
+
+// sparkline draws values scaled between their minimum and maximum.
+func sparkline(values []int) string {
+	if len(values) == 0 {
+		return ""
+	}
+	lo, hi := values[0], values[0]
+	for _, v := range values {
+		lo, hi = min(lo, v), max(hi, v)
+	}
+	var s strings.Builder
+	for _, v := range values {
+		level := 0
+		if hi > lo {
+			level = (v - lo) * (len(sparkBlocks) - 1) / (hi - lo)
+		}
+		s.WriteRune(sparkBlocks[level])
+	}
+	return fmt.Sprintf("%s %d Wh", s.String(), hi-lo)
+}
+
+// separator draws a panel title line across width.
+func separator(title string, width int) string {
+	line := "── " + title + " "
+	return truncate(line+strings.Repeat("─", max(width-len([]rune(line)), 0)), width)
+}
+
+// truncate cuts s to width characters.
+func truncate(s string, width int) string {
+	r := []rune(s)
+	if len(r) <= width {
+		return s
+	}
+	return string(r[:max(width, 0)])
+}
+
+// runDashboard shows the dashboard until exit, reading commands on its bottom
+// row. Besides the commands, "filter [text]" filters the frames and "pretty"
+// toggles pretty-printing.
+func (s *Shell) runDashboard() {
+	e := s.editor
+	d := &dashboard{ctx: s.ctx}
+	refresh := make(chan struct{}, 1)
+
+	s.mu.Lock()
+	s.dashboard = d
+	s.refresh = refresh
+	s.mu.Unlock()
+
+	e.mu.Lock()
+	e.prompt = dashboardPrompt
+	e.fullScreen = true
+	e.onList = func(candidates []string) {
+		fmt.Fprintln(d, strings.Join(candidates, "  "))
+		s.refreshDashboard()
+	}
+	fmt.Fprint(e.out, "\x1b[?1049h") // alternate screen
+	e.mu.Unlock()
+
+	stop := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		ticker := time.NewTicker(dashboardRefresh)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				s.renderDashboard(d, true)
+			case <-refresh:
+				s.renderDashboard(d, false)
+			case <-stop:
+				return
+			}
+		}
+	}()
+
+	ctx := &CommandContext{Charger: s.ctx.Charger, Config: s.ctx.Config, Out: d, Levels: s.ctx.Levels, shell: s}
+	s.renderDashboard(d, true)
+	for {
+		line, err := e.readLine()
+		if err != nil {
+			break
+		}
+		cmd, args, ok := parseCommand(line)
+		if !ok {
+			continue
+		}
+		e.history.add(line)
+		if cmd == "exit" || cmd == "quit" {
+			break
+		}
+		switch cmd {
+		case "filter":
+			d.setFilter(strings.Join(args, " "))
+		case "pretty":
+			d.togglePretty()
+		case "dashboard":
+			fmt.Fprintln(d, "Already showing the dashboard")
+		default:
+			fmt.Fprintln(d, dashboardPrompt+line)
+			Dispatch(ctx, cmd, args)
+		}
+		s.renderDashboard(d, false)
+	}
+
+	close(stop)
+	<-done
+	s.mu.Lock()
+	s.dashboard = nil
+	s.refresh = nil
+	s.mu.Unlock()
+
+	e.mu.Lock()
+	e.prompt = prompt
+	e.fullScreen = false
+	e.onList = nil
+	fmt.Fprint(e.out, "\x1b[?1049l") // back to the normal screen
+	e.mu.Unlock()
+}
+
+// refreshDashboard asks for a redraw without waiting for it, so it can be
+// called while the charger holds its lock (e.g. when it logs).
+func (s *Shell) refreshDashboard() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.refresh == nil {
+		return
+	}
+	select {
+	case s.refresh <- struct{}{}:
+	default:
+	}
+}
+
+// renderDashboard draws the dashboard over the whole screen and the prompt on
+// its last row, optionally sampling the meter first.
+func (s *Shell) renderDashboard(d *dashboard, sample bool) {
+	width, height, err := terminalSize(s.fd)
+	if err != nil || width <= 0 || height <= 0 {
+		width, height = 80, 24
+	}
+	if sample {
+		d.sample(width)
+	}
+	lines := d.lines(width, height)
+
+	var screen strings.Builder
+	screen.WriteString("\x1b[H")
+	for _, line := range lines {
+		screen.WriteString(line + "\x1b[K\r\n")
+	}
+	screen.WriteString("\x1b[J")
+
+	e := s.editor
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	fmt.Fprint(e.out, screen.String())
+	if e.editing {
+		e.redrawLocked()
+	}
+}
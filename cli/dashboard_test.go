@@ -0,0 +1,131 @@
+package cli
+
+import (
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf8"
+)
+
+func TestDashboardLines(t *testing.T) {
+	frames := []string{
+		`12:00:00 -> [2,"1","Heartbeat",{}]`,
+		`12:00:01 <- [3,"1",{"currentTime":"2026-01-01T00:00:00Z"}]`,
+		`12:00:02 -> [2,"2","MeterValues",{"connectorId":1}]`,
+	}
+	newDashboard := func() (*dashboard, *fakeCharger) {
+		f := &fakeCharger{
+			connected: true, subprotocol: "ocpp1.6", registration: "Accepted", status: "Charging",
+			charging: true, transactionId: "42", idTag: "TAG1", soc: 55, current: 16, power: 3680,
+			meterValue: 1200, actualPower: 2300, heartbeatInterval: 60, lastHeartbeat: time.Now(),
+			profiles: []string{"#1 TxProfile stack 0: 16.0 A"}, frames: frames,
+		}
+		cfg := cfg16()
+		cfg.ChargerID = "CP1"
+		ctx, _ := newCtx(f, cfg)
+		return &dashboard{ctx: ctx}, f
+	}
+
+	t.Run("state panels", func(t *testing.T) {
+		d, _ := newDashboard()
+		out := strings.Join(d.lines(120, 40), "\n")
+		for _, want := range []string{
+			"CP1  OCPP 1.6",
+			"Connection: connected (ocpp1.6), Accepted",
+			"Heartbeat: every 60 s, last 0s ago",
+			"Status: Charging",
+			"Transaction: 42 (idTag TAG1)",
+			"55.0%   Energy: 1200 Wh",
+			"Current: 10.0 / 16.0 A",
+			"Power: 2300 / 3680 W",
+			"Profiles: #1 TxProfile stack 0: 16.0 A",
+			"Faults: none",
+		} {
+			if !strings.Contains(out, want) {
+				t.Errorf("missing %q in dashboard:\n%s", want, out)
+			}
+		}
+	})
+
+	t.Run("size", func(t *testing.T) {
+		d, _ := newDashboard()
+		lines := d.lines(30, 20)
+		if len(lines) != 19 {
+			t.Errorf("expected 19 lines above the prompt, got %d", len(lines))
+		}
+		for _, line := range lines {
+			plain := strings.NewReplacer("\x1b[7m", "", "\x1b[0m", "", "\x1b[36m", "", "\x1b[32m", "").Replace(line)
+			if n := utf8.RuneCountInString(plain); n > 30 {
+				t.Errorf("line wider than 30 (%d): %q", n, plain)
+			}
+		}
+	})
+
+	t.Run("frames", func(t *testing.T) {
+		d, _ := newDashboard()
+		out := strings.Join(d.lines(120, 40), "\n")
+		if !strings.Contains(out, `"MeterValues",`) || !strings.Contains(out, `"connectorId": 1`) {
+			t.Errorf("expected pretty-printed frames:\n%s", out)
+		}
+		if !strings.Contains(out, "\x1b[32m") || !strings.Contains(out, "\x1b[36m") {
+			t.Errorf("expected sent and received frames colored:\n%s", out)
+		}
+
+		d.togglePretty()
+		out = strings.Join(d.lines(120, 40), "\n")
+		if !strings.Contains(out, frames[2]) {
+			t.Errorf("expected compact frame %q:\n%s", frames[2], out)
+		}
+
+		d.setFilter("heartbeat")
+		out = strings.Join(d.lines(120, 40), "\n")
+		if !strings.Contains(out, "Frames (filter: heartbeat)") || !strings.Contains(out, frames[0]) {
+			t.Errorf("expected filtered heartbeat frame:\n%s", out)
+		}
+		if strings.Contains(out, "MeterValues") {
+			t.Errorf("filter must hide other frames:\n%s", out)
+		}
+	})
+
+	t.Run("output panel", func(t *testing.T) {
+		d, _ := newDashboard()
+		for i := 0; i < 10; i++ {
+			d.Write([]byte("line " + string(rune('0'+i)) + "\n"))
+		}
+		d.Write([]byte("partial"))
+		out := strings.Join(d.lines(120, 40), "\n")
+		if strings.Contains(out, "line 3") || !strings.Contains(out, "line 9") {
+			t.Errorf("expected the last %d output lines:\n%s", dashboardOutputLines, out)
+		}
+		if strings.Contains(out, "partial") {
+			t.Errorf("unfinished line must not be shown yet:\n%s", out)
+		}
+	})
+
+	t.Run("idle", func(t *testing.T) {
+		d, f := newDashboard()
+		f.connected, f.charging, f.registration, f.lastHeartbeat = false, false, "", time.Time{}
+		f.faults = []string{"GroundFailure since 2026-01-02T03:04:05Z"}
+		out := strings.Join(d.lines(120, 40), "\n")
+		if strings.Contains(out, "last") {
+			t.Errorf("no last heartbeat expected before the first one:\n%s", out)
+		}
+		for _, want := range []string{"Connection: disconnected, not registered", "Transaction: none", "Faults: GroundFailure"} {
+			if !strings.Contains(out, want) {
+				t.Errorf("missing %q in dashboard:\n%s", want, out)
+			}
+		}
+	})
+}
+
+func TestSparkline(t *testing.T) {
+	if got := sparkline(nil); got != "" {
+		t.Errorf("sparkline(nil) = %q", got)
+	}
+	if got := sparkline([]int{100, 150, 200}); got != "▁▄█ 100 Wh" {
+		t.Errorf("sparkline = %q", got)
+	}
+	if got := sparkline([]int{5, 5}); got != "▁▁ 0 Wh" {
+		t.Errorf("flat sparkline = %q", got)
+	}
+}
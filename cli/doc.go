@@ -1,5 +1,10 @@
 // Package cli implements the interactive command layer for the OCPP charger
 // simulator. It parses lines from an input stream, dispatches each command to a
 // dedicated handler registered in a command table, and writes all output to an
-// injected writer so that every command is independently testable.
+// injected writer so that every command is independently testable. On a
+// terminal, Shell reads the lines with a line editor offering history and tab
+// completion from the command metadata, and the dashboard command turns the
+// terminal into a full-screen live view. With the json output each command's
+// result is written as one JSON object for scripts, and RunScript runs a
+// script of commands, optionally stopping at the first failure.
 package cli
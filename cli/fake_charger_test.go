@@ -1,5 +1,14 @@
 package cli
 
+import (
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/weilun-shrimp/wlgo_ocpp_charger_simulator/config"
+)
+
 // fakeCharger is an in-memory implementation of the Charger interface used by
 // the command tests. It holds simple state, exposes per-method programmable
 // error hooks, and records calls/arguments for assertions. It performs no
@@ -13,6 +22,27 @@ type fakeCharger struct {
 	power        float64
 	charging     bool
 	licensePlate string
+	cableLocked  bool
+	lockJammed   bool
+	firmware     string
+	security     int
+	subprotocol  string
+	registration string
+	bootStatus   string // registration set by BootNotification ("" = Accepted)
+	clientCert   *x509.Certificate
+	queuedEvents int
+	faults       []string
+	netFaults    config.NetworkFaultsConfig
+	sendResponse json.RawMessage
+	// Dashboard state
+	transactionId     string
+	idTag             string
+	meterValue        int
+	actualPower       float64
+	heartbeatInterval int
+	lastHeartbeat     time.Time
+	profiles          []string
+	frames            []string
 
 	// Programmable errors (nil = success path).
 	connectErr     error
@@ -28,6 +58,12 @@ type fakeCharger struct {
 	setSOCErr      error
 	setCurrentErr  error
 	setPowerErr    error
+	lockErr        error
+	renewErr       error
+	faultErr       error
+	netFaultsErr   error
+	sendErr        error
+	resetErr       error
 
 	// Call recording.
 	connectCalls    int
@@ -39,6 +75,15 @@ type fakeCharger struct {
 	lastStopReason  string
 	meterCalls      int
 	lastPlate       string
+	renewCalls      int
+	lastEventType   string
+	lastEventInfo   string
+	lastFault       []string // errorCode, info, vendorId, vendorErrorCode
+	lastCleared     string
+	killCalls       int
+	lastSendAction  string
+	lastSendPayload string
+	lastReset       string // boot reason of the last reset
 }
 
 func (f *fakeCharger) IsConnected() bool { return f.connected }
@@ -58,11 +103,33 @@ func (f *fakeCharger) Disconnect() {
 	f.charging = false
 }
 
+func (f *fakeCharger) Reset() error { return f.reset("LocalReset") }
+
+func (f *fakeCharger) WatchdogReset() error { return f.reset("Watchdog") }
+
+func (f *fakeCharger) reset(reason string) error {
+	f.lastReset = reason
+	if f.resetErr != nil {
+		return f.resetErr
+	}
+	f.charging = false
+	return nil
+}
+
 func (f *fakeCharger) BootNotification() error {
 	f.bootCalls++
-	return f.bootErr
+	if f.bootErr != nil {
+		return f.bootErr
+	}
+	f.registration = f.bootStatus
+	if f.registration == "" {
+		f.registration = "Accepted"
+	}
+	return nil
 }
 
+func (f *fakeCharger) GetRegistrationStatus() string { return f.registration }
+
 func (f *fakeCharger) StatusNotification(status string) error {
 	f.statusNotifArg = status
 	f.statusNotifSet = true
@@ -162,5 +229,99 @@ func (f *fakeCharger) GetPower() float64 { return f.power }
 
 func (f *fakeCharger) IsCharging() bool { return f.charging }
 
+func (f *fakeCharger) IsCableLocked() bool { return f.cableLocked }
+
+func (f *fakeCharger) IsLockJammed() bool { return f.lockJammed }
+
+func (f *fakeCharger) SetLockJammed(jammed bool) error {
+	if f.lockErr != nil {
+		return f.lockErr
+	}
+	f.lockJammed = jammed
+	if !jammed && !f.charging {
+		f.cableLocked = false
+	}
+	return nil
+}
+
+func (f *fakeCharger) GetFirmwareVersion() string { return f.firmware }
+
+func (f *fakeCharger) GetSubprotocol() string { return f.subprotocol }
+
+func (f *fakeCharger) GetSecurityProfile() int { return f.security }
+
+func (f *fakeCharger) GetClientCertificate() *x509.Certificate { return f.clientCert }
+
+func (f *fakeCharger) RenewCertificate() error {
+	f.renewCalls++
+	return f.renewErr
+}
+
+func (f *fakeCharger) RaiseSecurityEvent(eventType, techInfo string) {
+	f.lastEventType = eventType
+	f.lastEventInfo = techInfo
+	if !f.connected {
+		f.queuedEvents++
+	}
+}
+
+func (f *fakeCharger) QueuedSecurityEvents() int { return f.queuedEvents }
+
+func (f *fakeCharger) RaiseFault(errorCode, info, vendorId, vendorErrorCode string) error {
+	if f.faultErr != nil {
+		return f.faultErr
+	}
+	f.lastFault = []string{errorCode, info, vendorId, vendorErrorCode}
+	f.status = "Faulted"
+	return nil
+}
+
+func (f *fakeCharger) ClearFault(errorCode string) error {
+	if f.faultErr != nil {
+		return f.faultErr
+	}
+	f.lastCleared = errorCode
+	return nil
+}
+
+func (f *fakeCharger) ActiveFaults() []string { return f.faults }
+
+func (f *fakeCharger) NetworkFaults() config.NetworkFaultsConfig { return f.netFaults }
+
+func (f *fakeCharger) SetNetworkFaults(settings config.NetworkFaultsConfig) error {
+	if f.netFaultsErr != nil {
+		return f.netFaultsErr
+	}
+	f.netFaults = settings
+	return nil
+}
+
+func (f *fakeCharger) KillConnection() error {
+	if !f.connected {
+		return errors.New("not connected to server")
+	}
+	f.killCalls++
+	f.connected = false
+	return nil
+}
+
+func (f *fakeCharger) SendCall(action string, payload json.RawMessage) (json.RawMessage, error) {
+	f.lastSendAction = action
+	f.lastSendPayload = string(payload)
+	if f.sendErr != nil {
+		return nil, f.sendErr
+	}
+	return f.sendResponse, nil
+}
+
+func (f *fakeCharger) GetTransactionId() string    { return f.transactionId }
+func (f *fakeCharger) GetIdTag() string            { return f.idTag }
+func (f *fakeCharger) GetMeterValue() int          { return f.meterValue }
+func (f *fakeCharger) GetActualPower() float64     { return f.actualPower }
+func (f *fakeCharger) GetHeartbeatInterval() int   { return f.heartbeatInterval }
+func (f *fakeCharger) GetLastHeartbeat() time.Time { return f.lastHeartbeat }
+func (f *fakeCharger) ChargingProfiles() []string  { return f.profiles }
+func (f *fakeCharger) RecentFrames() []string      { return f.frames }
+
 // Compile-time assertion that the fake satisfies the interface under test.
 var _ Charger = (*fakeCharger)(nil)
@@ -0,0 +1,73 @@
+package cli
+
+import (
+	"bufio"
+	"os"
+	"strings"
+)
+
+// historySize is how many command lines are kept
+const historySize = 1000
+
+// history holds the entered command lines, oldest first, and appends each new
+// one to a file so it survives restarts. Without a file it is kept in memory.
+type history struct {
+	path    string
+	entries []string
+}
+
+// loadHistory reads the history file, keeping the last historySize lines. A
+// missing or unreadable file starts an empty history.
+func loadHistory(path string) *history {
+	h := &history{path: path}
+	if path == "" {
+		return h
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return h
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		if line := scanner.Text(); line != "" {
+			h.entries = append(h.entries, line)
+		}
+	}
+	if len(h.entries) > historySize {
+		h.entries = h.entries[len(h.entries)-historySize:]
+		h.rewrite()
+	}
+	return h
+}
+
+// add appends a line, unless it is blank or repeats the previous one.
+func (h *history) add(line string) {
+	line = strings.TrimSpace(line)
+	if line == "" || (len(h.entries) > 0 && h.entries[len(h.entries)-1] == line) {
+		return
+	}
+	h.entries = append(h.entries, line)
+	if len(h.entries) > historySize {
+		h.entries = h.entries[1:]
+	}
+	if h.path == "" {
+		return
+	}
+	f, err := os.OpenFile(h.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return
+	}
+	defer f.Close()
+	f.WriteString(line + "\n")
+}
+
+// rewrite replaces the file with the kept entries.
+func (h *history) rewrite() {
+	tmp := h.path + ".tmp"
+	if err := os.WriteFile(tmp, []byte(strings.Join(h.entries, "\n")+"\n"), 0600); err != nil {
+		return
+	}
+	os.Rename(tmp, h.path)
+}
@@ -0,0 +1,52 @@
+package cli
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestHistory(t *testing.T) {
+	t.Run("persists across loads", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "history")
+		h := loadHistory(path)
+		h.add("connect")
+		h.add("status Charging")
+		h.add("status Charging") // repeat is not kept
+		h.add("   ")             // blank is not kept
+
+		got := loadHistory(path).entries
+		if want := []string{"connect", "status Charging"}; !reflect.DeepEqual(got, want) {
+			t.Errorf("got %v; want %v", got, want)
+		}
+	})
+
+	t.Run("keeps the last entries", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "history")
+		var lines []string
+		for i := 0; i < historySize+10; i++ {
+			lines = append(lines, "soc "+strings.Repeat("1", i%5+1))
+			lines = append(lines, "meter")
+		}
+		if err := os.WriteFile(path, []byte(strings.Join(lines, "\n")+"\n"), 0600); err != nil {
+			t.Fatal(err)
+		}
+		h := loadHistory(path)
+		if len(h.entries) != historySize || h.entries[len(h.entries)-1] != "meter" {
+			t.Errorf("got %d entries ending in %q", len(h.entries), h.entries[len(h.entries)-1])
+		}
+		if got := len(loadHistory(path).entries); got != historySize {
+			t.Errorf("file not trimmed: %d entries", got)
+		}
+	})
+
+	t.Run("without a file", func(t *testing.T) {
+		h := loadHistory("")
+		h.add("info")
+		if !reflect.DeepEqual(h.entries, []string{"info"}) {
+			t.Errorf("got %v", h.entries)
+		}
+	})
+}
@@ -0,0 +1,277 @@
+package cli
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Control keys handled by the line editor
+const (
+	keyCtrlA     = 1
+	keyCtrlB     = 2
+	keyCtrlD     = 4
+	keyCtrlE     = 5
+	keyCtrlF     = 6
+	keyCtrlH     = 8
+	keyTab       = 9
+	keyLF        = 10
+	keyCtrlK     = 11
+	keyCtrlL     = 12
+	keyCR        = 13
+	keyCtrlN     = 14
+	keyCtrlP     = 16
+	keyCtrlU     = 21
+	keyCtrlW     = 23
+	keyEscape    = 27
+	keyBackspace = 127
+)
+
+// lineEditor reads lines from a terminal in raw mode with cursor movement,
+// history and tab completion, emacs-style. Output written with writeAbove
+// while a line is edited is printed above the prompt instead of through it.
+type lineEditor struct {
+	in       *bufio.Reader
+	out      io.Writer
+	prompt   string
+	history  *history
+	complete func(line string) (start int, candidates []string)
+
+	mu         sync.Mutex // guards the fields below and writes to out
+	editing    bool       // a line is being edited and the prompt is shown
+	line       []rune
+	pos        int                       // cursor position in line
+	fullScreen bool                      // the prompt is the last row of a full-screen view: Enter does not scroll
+	onList     func(candidates []string) // shows ambiguous completions, nil: below the prompt
+}
+
+// readLine reads one line. It returns io.EOF on Ctrl+D in an empty line or
+// at the end of the input.
+func (e *lineEditor) readLine() (string, error) {
+	e.mu.Lock()
+	e.editing = true
+	e.line = e.line[:0]
+	e.pos = 0
+	e.redrawLocked()
+	e.mu.Unlock()
+
+	historyIdx := len(e.history.entries)
+	draft := ""
+
+	for {
+		r, _, err := e.in.ReadRune()
+		if err != nil {
+			e.finish()
+			return "", err
+		}
+
+		e.mu.Lock()
+		switch r {
+		case keyCR, keyLF:
+			line := string(e.line)
+			e.mu.Unlock()
+			e.finish()
+			return line, nil
+		case keyCtrlD:
+			if len(e.line) == 0 {
+				e.mu.Unlock()
+				e.finish()
+				return "", io.EOF
+			}
+			e.deleteLocked(e.pos, e.pos+1)
+		case keyCtrlA:
+			e.pos = 0
+		case keyCtrlE:
+			e.pos = len(e.line)
+		case keyCtrlB:
+			e.moveLocked(-1)
+		case keyCtrlF:
+			e.moveLocked(1)
+		case keyBackspace, keyCtrlH:
+			if e.pos > 0 {
+				e.deleteLocked(e.pos-1, e.pos)
+			}
+		case keyCtrlK:
+			e.deleteLocked(e.pos, len(e.line))
+		case keyCtrlU:
+			e.deleteLocked(0, e.pos)
+		case keyCtrlW:
+			start := e.pos
+			for start > 0 && e.line[start-1] == ' ' {
+				start--
+			}
+			for start > 0 && e.line[start-1] != ' ' {
+				start--
+			}
+			e.deleteLocked(start, e.pos)
+		case keyCtrlL:
+			fmt.Fprint(e.out, "\x1b[H\x1b[2J")
+		case keyCtrlP, keyCtrlN:
+			historyIdx, draft = e.historyLocked(r == keyCtrlP, historyIdx, draft)
+		case keyTab:
+			e.completeLocked()
+		case keyEscape:
+			switch e.readEscape() {
+			case "A":
+				historyIdx, draft = e.historyLocked(true, historyIdx, draft)
+			case "B":
+				historyIdx, draft = e.historyLocked(false, historyIdx, draft)
+			case "C":
+				e.moveLocked(1)
+			case "D":
+				e.moveLocked(-1)
+			case "H", "1~", "7~":
+				e.pos = 0
+			case "F", "4~", "8~":
+				e.pos = len(e.line)
+			case "3~":
+				e.deleteLocked(e.pos, e.pos+1)
+			}
+		default:
+			if r >= ' ' {
+				e.line = append(e.line[:e.pos], append([]rune{r}, e.line[e.pos:]...)...)
+				e.pos++
+			}
+		}
+		e.redrawLocked()
+		e.mu.Unlock()
+	}
+}
+
+// finish ends the edited line, moving to the next one.
+func (e *lineEditor) finish() {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.editing = false
+	if !e.fullScreen {
+		fmt.Fprint(e.out, "\r\n")
+	}
+}
+
+// readEscape reads the rest of an escape sequence such as ESC [ A or ESC [ 3 ~
+// and returns it without the ESC [ (or ESC O) introducer.
+func (e *lineEditor) readEscape() string {
+	r, _, err := e.in.ReadRune()
+	if err != nil || (r != '[' && r != 'O') {
+		return ""
+	}
+	var seq strings.Builder
+	for {
+		r, _, err := e.in.ReadRune()
+		if err != nil {
+			return ""
+		}
+		seq.WriteRune(r)
+		if r >= 0x40 && r <= 0x7e {
+			return seq.String()
+		}
+	}
+}
+
+// moveLocked moves the cursor by delta within the line.
+func (e *lineEditor) moveLocked(delta int) {
+	e.pos = min(max(e.pos+delta, 0), len(e.line))
+}
+
+// deleteLocked removes line[from:to], clamped to the line.
+func (e *lineEditor) deleteLocked(from, to int) {
+	to = min(to, len(e.line))
+	if from >= to {
+		return
+	}
+	e.line = append(e.line[:from], e.line[to:]...)
+	e.pos = from
+}
+
+// historyLocked replaces the line with the previous (older) or next history
+// entry. The line being typed is kept as draft and comes back after the
+// newest entry.
+func (e *lineEditor) historyLocked(older bool, idx int, draft string) (int, string) {
+	entries := e.history.entries
+	switch {
+	case older && idx > 0:
+		if idx == len(entries) {
+			draft = string(e.line)
+		}
+		idx--
+		e.line = []rune(entries[idx])
+	case !older && idx < len(entries):
+		idx++
+		if idx == len(entries) {
+			e.line = []rune(draft)
+		} else {
+			e.line = []rune(entries[idx])
+		}
+	default:
+		return idx, draft
+	}
+	e.pos = len(e.line)
+	return idx, draft
+}
+
+// completeLocked completes the word before the cursor: a single candidate is
+// inserted, several extend the word to their common prefix or, if that adds
+// nothing, are listed below the prompt.
+func (e *lineEditor) completeLocked() {
+	if e.complete == nil {
+		return
+	}
+	start, candidates := e.complete(string(e.line[:e.pos]))
+	word := e.line[start:e.pos]
+
+	var insert string
+	switch {
+	case len(candidates) == 0:
+		fmt.Fprint(e.out, "\a")
+		return
+	case len(candidates) == 1:
+		insert = candidates[0]
+		if !strings.HasSuffix(insert, "=") {
+			insert += " "
+		}
+	default:
+		insert = commonPrefix(candidates)
+		if len([]rune(insert)) <= len(word) {
+			if e.onList != nil {
+				e.onList(candidates)
+				return
+			}
+			fmt.Fprintf(e.out, "\r\n%s\r\n", strings.Join(candidates, "  "))
+			return
+		}
+	}
+
+	rest := append([]rune(insert), e.line[e.pos:]...)
+	e.line = append(e.line[:start], rest...)
+	e.pos = start + len([]rune(insert))
+}
+
+// redrawLocked redraws the prompt and the line and places the cursor.
+func (e *lineEditor) redrawLocked() {
+	s := "\r" + e.prompt + string(e.line) + "\x1b[K"
+	if back := len(e.line) - e.pos; back > 0 {
+		s += "\x1b[" + strconv.Itoa(back) + "D"
+	}
+	fmt.Fprint(e.out, s)
+}
+
+// writeAbove writes p to w. While a line is edited, the prompt is cleared
+// first and redrawn after p, so asynchronous output such as log lines never
+// mixes with the line being typed.
+func (e *lineEditor) writeAbove(w io.Writer, p []byte) (int, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if !e.editing {
+		return w.Write(p)
+	}
+	fmt.Fprint(e.out, "\r\x1b[K")
+	n, err := w.Write(p)
+	if len(p) > 0 && p[len(p)-1] != '\n' {
+		fmt.Fprint(e.out, "\r\n")
+	}
+	e.redrawLocked()
+	return n, err
+}
@@ -0,0 +1,106 @@
+package cli
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+)
+
+// newEditor returns a line editor reading the given keys, completing with the
+// registry for an OCPP 1.6 charger.
+func newEditor(keys string, entries ...string) (*lineEditor, *bytes.Buffer) {
+	out := &bytes.Buffer{}
+	ctx, _ := newCtx(&fakeCharger{}, cfg16())
+	return &lineEditor{
+		in:      bufio.NewReader(strings.NewReader(keys)),
+		out:     out,
+		prompt:  prompt,
+		history: &history{entries: entries},
+		complete: func(line string) (int, []string) {
+			return completeLine(ctx, line)
+		},
+	}, out
+}
+
+func TestLineEditor(t *testing.T) {
+	cases := []struct {
+		name    string
+		keys    string
+		history []string
+		want    string
+	}{
+		{"plain line", "info\r", nil, "info"},
+		{"backspace", "infx\x7fo\r", nil, "info"},
+		{"cursor movement and insert", "soc 5\x1b[D\x1b[D\x1b[C7\r", nil, "soc 75"},
+		{"home, end and delete", "xsoc 5\x01\x1b[3~\x055\r", nil, "soc 55"},
+		{"kill to end and start", "status Charging\x1b[D\x0b\x01\x0bplugin\r", nil, "plugin"},
+		{"delete word", "stop Remote\x17Local\r", nil, "stop Local"},
+		{"history up and down", "\x1b[A\x1b[A\x1b[B\r", []string{"connect", "info"}, "info"},
+		{"draft comes back", "sta\x1b[A\x1b[B\r", []string{"info"}, "sta"},
+		{"completes a unique verb", "disc\t\r", nil, "disconnect "},
+		{"completes the common prefix", "status Su\t\r", nil, "status SuspendedEV"},
+		{"completes a version-aware argument", "status Pre\t\r", nil, "status Preparing "},
+		{"utf-8 input", "plate 台A\x7f北\r", nil, "plate 台北"},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			e, _ := newEditor(tc.keys, tc.history...)
+			got, err := e.readLine()
+			if err != nil || got != tc.want {
+				t.Errorf("readLine() = %q, %v; want %q", got, err, tc.want)
+			}
+		})
+	}
+}
+
+func TestLineEditor_ListsAmbiguousCompletions(t *testing.T) {
+	e, out := newEditor("st\t\r")
+	got, _ := e.readLine()
+	if got != "st" {
+		t.Errorf("line changed to %q", got)
+	}
+	if !strings.Contains(out.String(), "start  status  stop") {
+		t.Errorf("candidates not listed: %q", out.String())
+	}
+}
+
+func TestLineEditor_EOF(t *testing.T) {
+	for _, keys := range []string{"\x04", "", "inf"} {
+		e, _ := newEditor(keys)
+		if _, err := e.readLine(); err != io.EOF {
+			t.Errorf("keys %q: err = %v; want EOF", keys, err)
+		}
+	}
+
+	// Ctrl+D deletes under the cursor in a non-empty line
+	e, _ := newEditor("infoo\x01\x04\r")
+	if got, _ := e.readLine(); got != "nfoo" {
+		t.Errorf("got %q", got)
+	}
+}
+
+func TestLineEditor_WriteAbove(t *testing.T) {
+	e, out := newEditor("")
+	e.editing = true
+	e.line = []rune("stat")
+	e.pos = 4
+
+	log := &bytes.Buffer{}
+	e.writeAbove(log, []byte("2026/01/02 Status changed\n"))
+	if log.String() != "2026/01/02 Status changed\n" {
+		t.Errorf("log got %q", log.String())
+	}
+	if want := "\r\x1b[K\r> stat\x1b[K"; out.String() != want {
+		t.Errorf("terminal got %q; want %q", out.String(), want)
+	}
+
+	out.Reset()
+	log.Reset()
+	e.editing = false
+	e.writeAbove(log, []byte("line\n"))
+	if out.Len() != 0 || log.String() != "line\n" {
+		t.Errorf("not editing: terminal %q, log %q", out.String(), log.String())
+	}
+}
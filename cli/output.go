@@ -0,0 +1,96 @@
+package cli
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// Output formats of the command results
+const (
+	OutputText = "text" // the handler's lines, as written
+	OutputJSON = "json" // one JSON object per command, for scripts
+)
+
+// ParseOutput checks an output format given with --output
+func ParseOutput(format string) (string, error) {
+	switch format {
+	case OutputText, OutputJSON:
+		return format, nil
+	}
+	return "", fmt.Errorf("invalid output format %q (valid: text, json)", format)
+}
+
+// commandResult is the JSON object written for a command in the json output
+type commandResult struct {
+	Command string        `json:"command"`
+	Args    []string      `json:"args"`
+	OK      bool          `json:"ok"`
+	Error   *string       `json:"error"`  // the failure, null when ok
+	Output  []string      `json:"output"` // the lines the text output has
+	State   stateSnapshot `json:"state"`
+}
+
+// stateSnapshot is the charger state after a command
+type stateSnapshot struct {
+	Status    string  `json:"status"`
+	SOC       float64 `json:"soc"`
+	Current   float64 `json:"current"`
+	Power     float64 `json:"power"`
+	Connected bool    `json:"connected"`
+	Charging  bool    `json:"charging"`
+}
+
+// dispatchJSON runs a command with its text output captured and writes the
+// result as a single JSON line. Handlers keep writing text to ctx.Out and
+// report a failure with Failf. The failure is returned too.
+func dispatchJSON(ctx *CommandContext, cmd string, args []string) (string, bool) {
+	var buf bytes.Buffer
+	captured := *ctx
+	captured.Out = &buf
+	dispatch(&captured, cmd, args)
+
+	result := commandResult{
+		Command: cmd,
+		Args:    args,
+		OK:      true,
+		Output:  outputLines(buf.String()),
+		State:   snapshotState(ctx.Charger),
+	}
+	if result.Args == nil {
+		result.Args = []string{}
+	}
+	failure, failed := captured.failure, captured.failed
+	if failed {
+		result.OK = false
+		result.Error = &failure
+	}
+
+	enc := json.NewEncoder(ctx.Out)
+	enc.SetEscapeHTML(false)
+	enc.Encode(result)
+	return failure, failed
+}
+
+// outputLines splits captured output into lines, without the trailing
+// newline and without an empty result for no output
+func outputLines(out string) []string {
+	out = strings.TrimRight(out, "\n")
+	if out == "" {
+		return []string{}
+	}
+	return strings.Split(out, "\n")
+}
+
+// snapshotState reads the state reported with every json result
+func snapshotState(c Charger) stateSnapshot {
+	return stateSnapshot{
+		Status:    c.GetStatus(),
+		SOC:       c.GetSOC(),
+		Current:   c.GetCurrent(),
+		Power:     c.GetPower(),
+		Connected: c.IsConnected(),
+		Charging:  c.IsCharging(),
+	}
+}
@@ -0,0 +1,125 @@
+package cli
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+)
+
+// decodeResults decodes the JSON lines written in the json output
+func decodeResults(t *testing.T, out string) []commandResult {
+	t.Helper()
+	var results []commandResult
+	for _, line := range strings.Split(strings.TrimRight(out, "\n"), "\n") {
+		var r commandResult
+		if err := json.Unmarshal([]byte(line), &r); err != nil {
+			t.Fatalf("line %q is not a JSON object: %v", line, err)
+		}
+		results = append(results, r)
+	}
+	return results
+}
+
+func TestDispatchJSON(t *testing.T) {
+	t.Run("success with state", func(t *testing.T) {
+		f := &fakeCharger{status: "Available", soc: 42, current: 16, power: 3680, connected: true}
+		ctx, buf := newCtx(f, cfg16())
+		ctx.Output = OutputJSON
+		Dispatch(ctx, "status", []string{"Preparing"})
+
+		results := decodeResults(t, buf.String())
+		if len(results) != 1 {
+			t.Fatalf("expected one JSON object, got %d: %q", len(results), buf.String())
+		}
+		r := results[0]
+		if r.Command != "status" || !equalArgs(r.Args, []string{"Preparing"}) || !r.OK || r.Error != nil {
+			t.Errorf("unexpected result: %+v", r)
+		}
+		want := stateSnapshot{Status: "Preparing", SOC: 42, Current: 16, Power: 3680, Connected: true}
+		if r.State != want {
+			t.Errorf("state = %+v, want %+v", r.State, want)
+		}
+		if len(r.Output) == 0 || !strings.Contains(r.Output[0], "Preparing") {
+			t.Errorf("expected the text output in the result, got %q", r.Output)
+		}
+	})
+
+	t.Run("failures", func(t *testing.T) {
+		for _, tc := range []struct {
+			name string
+			fake *fakeCharger
+			cmd  string
+			args []string
+			want string
+		}{
+			{"error line", &fakeCharger{setStatusErr: errors.New("boom")}, "status", []string{"Charging"}, "boom"},
+			{"usage only", &fakeCharger{}, "status", nil, "Usage: status <status>"},
+			{"unknown command", &fakeCharger{}, "frobnicate", nil, "Unknown command: frobnicate. Type 'help' for available commands."},
+			{"invalid value", &fakeCharger{}, "net", []string{"latency", "soon"}, "Invalid value: soon"},
+			{"connect boot failed", &fakeCharger{bootErr: errors.New("timeout")}, "connect", nil, "BootNotification failed: timeout"},
+			{"connect status failed", &fakeCharger{statusNotifErr: errors.New("timeout")}, "connect", nil, "StatusNotification failed: timeout"},
+			{"already connected", &fakeCharger{connected: true}, "connect", nil, "Already connected"},
+			{"not connected", &fakeCharger{}, "disconnect", nil, "Not connected"},
+		} {
+			t.Run(tc.name, func(t *testing.T) {
+				ctx, buf := newCtx(tc.fake, cfg16())
+				ctx.Output = OutputJSON
+				Dispatch(ctx, tc.cmd, tc.args)
+				r := decodeResults(t, buf.String())[0]
+				if r.OK || r.Error == nil || *r.Error != tc.want {
+					t.Errorf("expected failure %q, got ok=%v error=%v", tc.want, r.OK, r.Error)
+				}
+			})
+		}
+	})
+
+	t.Run("usage with help is not a failure", func(t *testing.T) {
+		ctx, buf := newCtx(&fakeCharger{}, cfg16())
+		ctx.Output = OutputJSON
+		Dispatch(ctx, "help", []string{"status"})
+		if r := decodeResults(t, buf.String())[0]; !r.OK {
+			t.Errorf("help <cmd> should succeed, got %+v", r)
+		}
+	})
+
+	t.Run("query usage is not a failure", func(t *testing.T) {
+		ctx, buf := newCtx(&fakeCharger{current: 16}, cfg16())
+		ctx.Output = OutputJSON
+		Dispatch(ctx, "current", nil)
+		if r := decodeResults(t, buf.String())[0]; !r.OK {
+			t.Errorf("current without a value should succeed, got %+v", r)
+		}
+	})
+
+	t.Run("no output", func(t *testing.T) {
+		ctx, buf := newCtx(&fakeCharger{}, cfg16())
+		ctx.Output = OutputJSON
+		Dispatch(ctx, "quit", nil)
+		if !strings.Contains(buf.String(), `"args":[]`) {
+			t.Errorf("expected empty args array, got %q", buf.String())
+		}
+	})
+}
+
+func TestRunJSONHasNoPrompt(t *testing.T) {
+	ctx, buf := newCtx(&fakeCharger{status: "Available"}, cfg16())
+	ctx.Output = OutputJSON
+	runLines(ctx, strings.NewReader("info\nsoc 50\n"))
+
+	results := decodeResults(t, buf.String())
+	if len(results) != 2 || results[0].Command != "info" || results[1].State.SOC != 50 {
+		t.Errorf("unexpected results: %+v", results)
+	}
+}
+
+func TestParseOutput(t *testing.T) {
+	for _, format := range []string{OutputText, OutputJSON} {
+		if got, err := ParseOutput(format); err != nil || got != format {
+			t.Errorf("ParseOutput(%q) = %q, %v", format, got, err)
+		}
+	}
+	if _, err := ParseOutput("yaml"); err == nil {
+		t.Error("expected an error for yaml")
+	}
+}
@@ -1,8 +1,6 @@
 package cli
 
 import (
-	"bufio"
-	"fmt"
 	"io"
 	"strings"
 
@@ -11,21 +9,36 @@ import (
 
 // Handler executes a single interactive command. args holds the
 // whitespace-split tokens that follow the command word (the verb itself is
-// excluded). All user-facing output must be written to ctx.Out.
+// excluded). All user-facing output must be written to ctx.Out, and failures
+// reported with ctx.Failf.
 type Handler func(ctx *CommandContext, args []string)
 
-// registry maps a lower-cased command verb to its handler. Command files
+// Completer returns the candidates for the last element of args, the argument
+// being typed (possibly ""), given the arguments before it. Candidates are
+// filtered by the typed prefix by the caller.
+type Completer func(ctx *CommandContext, args []string) []string
+
+// Command is a registered command: its handler plus the metadata behind
+// "help <cmd>" and tab completion.
+type Command struct {
+	Handler  Handler
+	Usage    string    // verb and arguments, e.g. "stop [reason]"
+	Help     string    // what the command does, shown by "help <cmd>"
+	Complete Completer // argument candidates, nil if arguments are free text
+}
+
+// registry maps a lower-cased command verb to its command. Command files
 // populate it from their init() functions via register().
-var registry = map[string]Handler{}
+var registry = map[string]Command{}
 
-// register adds a handler for the given command verb. It panics on a duplicate
+// register adds a command for the given verb. It panics on a duplicate
 // registration so that a wiring mistake fails loudly at startup rather than
 // silently shadowing a command.
-func register(name string, h Handler) {
+func register(name string, cmd Command) {
 	if _, exists := registry[name]; exists {
 		panic("cli: duplicate command registration: " + name)
 	}
-	registry[name] = h
+	registry[name] = cmd
 }
 
 // parseCommand splits a raw input line into a lower-cased command verb and its
@@ -40,33 +53,42 @@ func parseCommand(line string) (cmd string, args []string, ok bool) {
 }
 
 // Dispatch runs the handler registered for cmd. When no handler is registered
-// it prints the unknown-command message to ctx.Out.
+// it prints the unknown-command message to ctx.Out. With the json output the
+// result is written as one JSON object instead.
 func Dispatch(ctx *CommandContext, cmd string, args []string) {
-	h, ok := registry[cmd]
+	if ctx.Output == OutputJSON {
+		dispatchJSON(ctx, cmd, args)
+		return
+	}
+	dispatch(ctx, cmd, args)
+}
+
+// execute dispatches cmd like Dispatch and returns the failure it reported,
+// if any
+func execute(ctx *CommandContext, cmd string, args []string) (string, bool) {
+	if ctx.Output == OutputJSON {
+		return dispatchJSON(ctx, cmd, args)
+	}
+	captured := *ctx
+	dispatch(&captured, cmd, args)
+	return captured.failure, captured.failed
+}
+
+// dispatch runs the handler registered for cmd, writing text to ctx.Out
+func dispatch(ctx *CommandContext, cmd string, args []string) {
+	ctx.failure, ctx.failed = "", false
+	c, ok := registry[cmd]
 	if !ok {
-		fmt.Fprintf(ctx.Out, "Unknown command: %s. Type 'help' for available commands.\n", cmd)
+		ctx.Failf("Unknown command: %s. Type 'help' for available commands.", cmd)
 		return
 	}
-	h(ctx, args)
+	c.Handler(ctx, args)
 }
 
 // Run reads command lines from in, printing a prompt to out before each read,
 // and dispatches each parsed command. It returns when in is exhausted (EOF),
 // which lets tests drive a finite sequence of commands and then assert results.
+// A terminal input gets the line editor of Shell.
 func Run(c Charger, cfg *config.Config, in io.Reader, out io.Writer) {
-	ctx := &CommandContext{Charger: c, Config: cfg, Out: out}
-	reader := bufio.NewReader(in)
-
-	for {
-		fmt.Fprint(out, "> ")
-		line, err := reader.ReadString('\n')
-		if line != "" {
-			if cmd, args, ok := parseCommand(line); ok {
-				Dispatch(ctx, cmd, args)
-			}
-		}
-		if err != nil {
-			return
-		}
-	}
+	NewShell(c, cfg, in, out).Run()
 }
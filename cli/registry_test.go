@@ -95,3 +95,17 @@ func TestDispatch_UnknownAndEmpty(t *testing.T) {
 		t.Error("expected empty/whitespace input to be ignored (ok=false)")
 	}
 }
+
+// execute writes the text output like Dispatch and reports the failure.
+func TestExecute_ReportsFailure(t *testing.T) {
+	ctx, buf := newCtx(&fakeCharger{status: "Available"}, cfg16())
+	if failure, failed := execute(ctx, "start", nil); !failed || failure != "Usage: start <idTag>" {
+		t.Errorf("expected the usage failure, got %q, %v", failure, failed)
+	}
+	if !strings.Contains(buf.String(), "Usage: start <idTag>") {
+		t.Errorf("expected the text output, got %q", buf.String())
+	}
+	if _, failed := execute(ctx, "info", nil); failed {
+		t.Error("info should not fail")
+	}
+}
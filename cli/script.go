@@ -0,0 +1,89 @@
+package cli
+
+import (
+	"bufio"
+	"fmt"
+	"strings"
+)
+
+// ScriptError is returned by RunScript for the first failed command when it
+// stops on failures.
+type ScriptError struct {
+	Line    int    // line of the script, from 1
+	Command string // the command as written
+	Failure string // what the command reported
+}
+
+func (e *ScriptError) Error() string {
+	return fmt.Sprintf("line %d: %s: %s", e.Line, e.Command, e.Failure)
+}
+
+// RunScript runs the commands read from the shell's input without the line
+// editor, until the input ends. Commands are separated by newlines or ";"
+// (outside quotes and JSON), and lines starting with "#" are comments. In the text output each command
+// is echoed after the prompt before its output. With failFast the first
+// failed command stops the script and is returned as a *ScriptError.
+func (s *Shell) RunScript(failFast bool) error {
+	reader := bufio.NewReader(s.in)
+	for n := 1; ; n++ {
+		line, err := reader.ReadString('\n')
+		for _, command := range splitScriptLine(line) {
+			cmd, args, ok := parseCommand(command)
+			if !ok {
+				continue
+			}
+			if s.ctx.Output != OutputJSON {
+				fmt.Fprintln(s.ctx.Out, prompt+command)
+			}
+			if failure, failed := execute(s.ctx, cmd, args); failed && failFast {
+				return &ScriptError{Line: n, Command: command, Failure: failure}
+			}
+		}
+		if err != nil {
+			return nil
+		}
+	}
+}
+
+// splitScriptLine returns the commands of a script line, trimmed; a comment
+// line has none. A ";" inside double quotes or a JSON object or array (e.g.
+// the payload of send) does not separate commands.
+func splitScriptLine(line string) []string {
+	line = strings.TrimSpace(line)
+	if strings.HasPrefix(line, "#") {
+		return nil
+	}
+	var commands []string
+	add := func(command string) {
+		if command = strings.TrimSpace(command); command != "" {
+			commands = append(commands, command)
+		}
+	}
+	depth, start := 0, 0
+	quoted, escaped := false, false
+	for i := 0; i < len(line); i++ {
+		switch ch := line[i]; {
+		case escaped:
+			escaped = false
+		case quoted:
+			if ch == '\\' {
+				escaped = true
+			} else if ch == '"' {
+				quoted = false
+			}
+		case ch == '"':
+			quoted = true
+		case ch == '{' || ch == '[':
+			depth++
+		case ch == '}' || ch == ']':
+			if depth > 0 {
+				depth--
+			}
+		case ch == ';' && depth == 0:
+			add(line[start:i])
+			start = i + 1
+		}
+	}
+	add(line[start:])
+	return commands
+}
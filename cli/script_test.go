@@ -0,0 +1,107 @@
+package cli
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func newScriptShell(f *fakeCharger, script string) (*Shell, *bytes.Buffer) {
+	out := &bytes.Buffer{}
+	return NewShell(f, cfg16(), strings.NewReader(script), out), out
+}
+
+func TestRunScript(t *testing.T) {
+	t.Run("runs every command", func(t *testing.T) {
+		f := &fakeCharger{status: "Available"}
+		s, out := newScriptShell(f, "connect; plugin\n# a comment\n\nstart TAG1; wait 1ms")
+		if err := s.RunScript(false); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if !f.connected || !f.charging {
+			t.Errorf("expected connected and charging, got connected=%v charging=%v", f.connected, f.charging)
+		}
+		for _, want := range []string{"> connect\n", "> plugin\n", "> start TAG1\n", "> wait 1ms\n"} {
+			if !strings.Contains(out.String(), want) {
+				t.Errorf("missing echoed command %q in %q", want, out.String())
+			}
+		}
+		if strings.Contains(out.String(), "comment") {
+			t.Errorf("comment should be skipped: %q", out.String())
+		}
+	})
+
+	t.Run("continues after a failure", func(t *testing.T) {
+		f := &fakeCharger{status: "Available"}
+		s, out := newScriptShell(f, "bogus; soc 40")
+		if err := s.RunScript(false); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if f.soc != 40 || !strings.Contains(out.String(), "Unknown command: bogus") {
+			t.Errorf("expected the script to go on, soc=%v output=%q", f.soc, out.String())
+		}
+	})
+
+	t.Run("fail fast", func(t *testing.T) {
+		f := &fakeCharger{status: "Available", setSOCErr: errors.New("out of range")}
+		s, _ := newScriptShell(f, "connect\nsoc 150; plugin")
+		err := s.RunScript(true)
+		var scriptErr *ScriptError
+		if !errors.As(err, &scriptErr) {
+			t.Fatalf("expected a *ScriptError, got %v", err)
+		}
+		if scriptErr.Line != 2 || scriptErr.Command != "soc 150" || scriptErr.Failure != "out of range" {
+			t.Errorf("unexpected error: %+v", scriptErr)
+		}
+		if f.status != "Available" {
+			t.Errorf("commands after the failure should not run, status %s", f.status)
+		}
+	})
+
+	t.Run("fail fast on a failed connect", func(t *testing.T) {
+		f := &fakeCharger{status: "Available", bootErr: errors.New("timeout")}
+		s, _ := newScriptShell(f, "connect; start TAG1")
+		err := s.RunScript(true)
+		var scriptErr *ScriptError
+		if !errors.As(err, &scriptErr) {
+			t.Fatalf("expected a *ScriptError, got %v", err)
+		}
+		if scriptErr.Line != 1 || scriptErr.Command != "connect" || scriptErr.Failure != "BootNotification failed: timeout" {
+			t.Errorf("unexpected error: %+v", scriptErr)
+		}
+		if f.lastStartIDTag != "" {
+			t.Errorf("start should not run after the failed connect, got idTag %q", f.lastStartIDTag)
+		}
+	})
+
+	t.Run("json", func(t *testing.T) {
+		s, out := newScriptShell(&fakeCharger{status: "Available"}, "connect; info")
+		s.SetOutput(OutputJSON)
+		if err := s.RunScript(true); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		results := decodeResults(t, out.String())
+		if len(results) != 2 || results[0].Command != "connect" || results[1].Command != "info" {
+			t.Errorf("unexpected results: %+v", results)
+		}
+	})
+}
+
+func TestSplitScriptLine(t *testing.T) {
+	for _, tc := range []struct {
+		line string
+		want []string
+	}{
+		{"connect; plugin ;start TAG1\n", []string{"connect", "plugin", "start TAG1"}},
+		{"  # stop; unplug", nil},
+		{" ; ;", nil},
+		{`send DataTransfer {"vendorId":"x","data":"a;b"}; info`, []string{`send DataTransfer {"vendorId":"x","data":"a;b"}`, "info"}},
+		{`send Heartbeat {}; send DataTransfer {"data":[";"]}`, []string{"send Heartbeat {}", `send DataTransfer {"data":[";"]}`}},
+		{`datatransfer v1 m "a\";b"; info`, []string{`datatransfer v1 m "a\";b"`, "info"}},
+	} {
+		if got := splitScriptLine(tc.line); !equalArgs(got, tc.want) {
+			t.Errorf("splitScriptLine(%q) = %q, want %q", tc.line, got, tc.want)
+		}
+	}
+}
@@ -0,0 +1,154 @@
+package cli
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+
+	"github.com/weilun-shrimp/wlgo_ocpp_charger_simulator/config"
+	"github.com/weilun-shrimp/wlgo_ocpp_charger_simulator/logging"
+)
+
+// prompt is printed before each command line
+const prompt = "> "
+
+// Shell is the interactive command loop. When its input is a terminal, lines
+// are edited in raw mode with a persistent history and tab completion driven
+// by the registry; otherwise plain lines are read, e.g. from a pipe.
+type Shell struct {
+	ctx    *CommandContext
+	in     io.Reader
+	fd     int         // terminal file descriptor of in, -1 if none
+	editor *lineEditor // nil when reading plain lines
+
+	mu        sync.Mutex
+	restore   func() error    // leaves raw mode, nil when not in it
+	dashboard *dashboard      // shown dashboard, nil if none
+	refresh   chan<- struct{} // redraws the shown dashboard
+}
+
+// NewShell creates a shell reading commands from in and writing to out.
+func NewShell(c Charger, cfg *config.Config, in io.Reader, out io.Writer) *Shell {
+	s := &Shell{
+		ctx: &CommandContext{Charger: c, Config: cfg, Out: out},
+		in:  in,
+		fd:  -1,
+	}
+	s.ctx.shell = s
+	if f, ok := in.(*os.File); ok && isTerminal(int(f.Fd())) {
+		s.fd = int(f.Fd())
+		s.editor = &lineEditor{
+			in:      bufio.NewReader(in),
+			out:     out,
+			prompt:  prompt,
+			history: loadHistory(cfg.GetHistoryFile()),
+			complete: func(line string) (int, []string) {
+				return completeLine(s.ctx, line)
+			},
+		}
+	}
+	return s
+}
+
+// SetLogLevels sets the log levels the log command shows and changes.
+func (s *Shell) SetLogLevels(levels *logging.Levels) {
+	s.ctx.Levels = levels
+}
+
+// SetOutput sets the output format of the command results, OutputText or
+// OutputJSON.
+func (s *Shell) SetOutput(format string) {
+	s.ctx.Output = format
+}
+
+// Run reads and dispatches commands until the input ends.
+func (s *Shell) Run() {
+	if s.editor == nil || !s.enterRawMode() {
+		runLines(s.ctx, s.in)
+		return
+	}
+	defer s.Close()
+
+	for {
+		line, err := s.editor.readLine()
+		if err != nil {
+			return
+		}
+		if cmd, args, ok := parseCommand(line); ok {
+			s.editor.history.add(line)
+			Dispatch(s.ctx, cmd, args)
+		}
+	}
+}
+
+// enterRawMode switches the terminal to raw mode for the line editor.
+func (s *Shell) enterRawMode() bool {
+	restore, err := makeRaw(s.fd)
+	if err != nil {
+		fmt.Fprintf(s.ctx.Out, "Line editing disabled: %v\n", err)
+		return false
+	}
+	s.mu.Lock()
+	s.restore = restore
+	s.mu.Unlock()
+	return true
+}
+
+// Close restores the terminal mode. It is safe to call more than once and
+// while Run is reading.
+func (s *Shell) Close() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.restore != nil {
+		s.restore()
+		s.restore = nil
+	}
+}
+
+// LogWriter returns a writer to w for asynchronous output such as the log: on
+// a terminal, its lines are printed above the prompt instead of through the
+// line being typed, or in the output panel while the dashboard is shown.
+func (s *Shell) LogWriter(w io.Writer) io.Writer {
+	if s.editor == nil {
+		return w
+	}
+	return writerFunc(func(p []byte) (int, error) {
+		s.mu.Lock()
+		d := s.dashboard
+		s.mu.Unlock()
+		if d != nil {
+			d.Write(p)
+			s.refreshDashboard()
+			return len(p), nil
+		}
+		return s.editor.writeAbove(w, p)
+	})
+}
+
+// writerFunc adapts a function to io.Writer
+type writerFunc func(p []byte) (int, error)
+
+func (f writerFunc) Write(p []byte) (int, error) { return f(p) }
+
+// runLines reads plain command lines, printing the prompt before each read,
+// until in is exhausted. The json output has no prompt, so it is only JSON
+// lines.
+func runLines(ctx *CommandContext, in io.Reader) {
+	reader := bufio.NewReader(in)
+	for {
+		if ctx.Output != OutputJSON {
+			fmt.Fprint(ctx.Out, prompt)
+		}
+		line, err := reader.ReadString('\n')
+		if line != "" {
+			if cmd, args, ok := parseCommand(line); ok {
+				Dispatch(ctx, cmd, args)
+			}
+		}
+		if err != nil {
+			return
+		}
+	}
+}
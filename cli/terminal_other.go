@@ -0,0 +1,21 @@
+//go:build !(linux || darwin || dragonfly || freebsd || netbsd || openbsd)
+
+package cli
+
+import "errors"
+
+// isTerminal reports false: line editing needs termios, so other systems read
+// plain lines
+func isTerminal(fd int) bool {
+	return false
+}
+
+// makeRaw is not supported without termios
+func makeRaw(fd int) (restore func() error, err error) {
+	return nil, errors.New("line editing is not supported on this system")
+}
+
+// terminalSize is not supported without termios
+func terminalSize(fd int) (width, height int, err error) {
+	return 0, 0, errors.New("terminal size is not available on this system")
+}
@@ -0,0 +1,9 @@
+package cli
+
+import "syscall"
+
+// ioctl requests reading and setting the terminal attributes
+const (
+	ioctlGetTermios = syscall.TCGETS
+	ioctlSetTermios = syscall.TCSETS
+)
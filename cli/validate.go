@@ -0,0 +1,59 @@
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+
+	"github.com/weilun-shrimp/wlgo_ocpp_charger_simulator/config"
+)
+
+// validationReport is the json output of PrintValidation
+type validationReport struct {
+	Config   string   `json:"config"`
+	Valid    bool     `json:"valid"`
+	Errors   []string `json:"errors"`
+	Warnings []string `json:"warnings"`
+}
+
+// PrintValidation writes the problems found in the configuration read from
+// source, as text lines or, with OutputJSON, one JSON object.
+func PrintValidation(w io.Writer, format, source string, problems config.Problems) {
+	if format == OutputJSON {
+		report := validationReport{
+			Config:   source,
+			Valid:    len(problems.Errors) == 0,
+			Errors:   problems.Errors,
+			Warnings: problems.Warnings,
+		}
+		if report.Errors == nil {
+			report.Errors = []string{}
+		}
+		if report.Warnings == nil {
+			report.Warnings = []string{}
+		}
+		json.NewEncoder(w).Encode(report)
+		return
+	}
+
+	for _, e := range problems.Errors {
+		fmt.Fprintf(w, "error: %s\n", e)
+	}
+	for _, warning := range problems.Warnings {
+		fmt.Fprintf(w, "warning: %s\n", warning)
+	}
+	state := "valid"
+	if len(problems.Errors) > 0 {
+		state = "invalid"
+	}
+	fmt.Fprintf(w, "%s: %s (%s, %s)\n", source, state,
+		plural(len(problems.Errors), "error"), plural(len(problems.Warnings), "warning"))
+}
+
+// plural returns "1 error", "2 errors" and the like
+func plural(n int, noun string) string {
+	if n == 1 {
+		return "1 " + noun
+	}
+	return fmt.Sprintf("%d %ss", n, noun)
+}
@@ -0,0 +1,54 @@
+package cli
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/weilun-shrimp/wlgo_ocpp_charger_simulator/config"
+)
+
+func TestPrintValidation(t *testing.T) {
+	cfg := &config.Config{
+		OCPPVersion:         "2.0.1",
+		ChargerID:           "CP1",
+		ServerURL:           "http://localhost/ocpp",
+		InitialStatus:       "Preparing",
+		MaxCurrent:          32,
+		MaxPower:            22000,
+		Voltage:             230,
+		ConnectorID:         1,
+		BatteryCapacity:     60000,
+		MeterValuesInterval: 0,
+	}
+	problems := cfg.Check()
+
+	t.Run("text", func(t *testing.T) {
+		var buf bytes.Buffer
+		PrintValidation(&buf, OutputText, "c.yaml", problems)
+		for _, want := range []string{
+			"error: server_url must be a ws:// or wss:// URL, got 'http://localhost/ocpp'",
+			"error: initial_status must be one of Available, Occupied, Reserved, Unavailable, Faulted for OCPP 2.0.1, got 'Preparing'",
+			"error: meter_values_interval must be positive, got 0",
+			"warning: max_power (22000 W) exceeds voltage x max_current (7360 W)",
+			"c.yaml: invalid (3 errors, 1 warning)",
+		} {
+			if !strings.Contains(buf.String(), want) {
+				t.Errorf("missing %q in:\n%s", want, buf.String())
+			}
+		}
+	})
+
+	t.Run("json", func(t *testing.T) {
+		var buf bytes.Buffer
+		PrintValidation(&buf, OutputJSON, "c.yaml", config.Problems{})
+		var report validationReport
+		if err := json.Unmarshal(buf.Bytes(), &report); err != nil {
+			t.Fatalf("not JSON: %v: %q", err, buf.String())
+		}
+		if !report.Valid || report.Config != "c.yaml" || report.Errors == nil || report.Warnings == nil {
+			t.Errorf("unexpected report: %+v", report)
+		}
+	})
+}
@@ -3,19 +3,28 @@ package config
 import (
 	"crypto/tls"
 	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
 	"fmt"
+	"log/slog"
 	"os"
+	"path/filepath"
+	"strings"
+	"text/template"
+	"time"
 
-	"gopkg.in/yaml.v3"
+	"github.com/weilun-shrimp/wlgo_ocpp_charger_simulator/jsonpath"
+	"github.com/weilun-shrimp/wlgo_ocpp_charger_simulator/logging"
 )
 
 // TLSConfig holds TLS certificate configuration
 type TLSConfig struct {
-	CAFile         string `yaml:"ca_file"`          // CA certificate to verify server cert chain
-	ServerCertFile string `yaml:"server_cert_file"` // Trusted server certificate (for self-signed certs)
-	CertFile       string `yaml:"cert_file"`        // Client certificate
-	KeyFile        string `yaml:"key_file"`         // Client private key
-	SkipVerify     bool   `yaml:"skip_verify"`      // Skip server certificate verification (insecure)
+	CAFile         string `yaml:"ca_file"`                      // CA certificate to verify server cert chain
+	ServerCertFile string `yaml:"server_cert_file"`             // Trusted server certificate (for self-signed certs)
+	CertFile       string `yaml:"cert_file"`                    // Client certificate
+	KeyFile        string `yaml:"key_file"`                     // Client private key
+	KeyPassphrase  string `yaml:"key_passphrase" secret:"true"` // Passphrase of an encrypted (PEM Proc-Type) client key
+	SkipVerify     bool   `yaml:"skip_verify"`                  // Skip server certificate verification (insecure)
 }
 
 // AuthConfig holds the Authorization header sent on the WebSocket handshake.
@@ -23,119 +32,374 @@ type TLSConfig struct {
 // scheme "Basic" + value "dXNlcjpwYXNz" => "Authorization: Basic dXNlcjpwYXNz".
 // For Basic auth, Value is base64(username:password).
 type AuthConfig struct {
-	Scheme string `yaml:"scheme"` // auth scheme, e.g. "Basic" or "Bearer"
-	Value  string `yaml:"value"`  // credentials value for the scheme
+	Scheme string `yaml:"scheme"`              // auth scheme, e.g. "Basic" or "Bearer"
+	Value  string `yaml:"value" secret:"true"` // credentials value for the scheme
 }
 
-// Config holds the charger simulator configuration
-type Config struct {
-	OCPPVersion         string      `yaml:"ocpp_version"`
-	ChargerID           string      `yaml:"charger_id"`
-	ServerURL           string      `yaml:"server_url"`
-	TLS                 *TLSConfig  `yaml:"tls"`
-	Auth                *AuthConfig `yaml:"auth"`
-	InitialStatus       string      `yaml:"initial_status"`
-	MaxCurrent          float64     `yaml:"max_current"`
-	MaxPower            float64     `yaml:"max_power"`
-	MinCurrent          float64     `yaml:"min_current"`
-	MinPower            float64     `yaml:"min_power"`
-	Voltage             float64     `yaml:"voltage"` // Voltage in V (for power calculation)
-	ConnectorID         int         `yaml:"connector_id"`
-	MeterValuesInterval int         `yaml:"meter_values_interval"`
-	// EV Battery simulation
-	InitialSOC      float64 `yaml:"initial_soc"`      // Initial State of Charge (0-100%)
-	BatteryCapacity float64 `yaml:"battery_capacity"` // Battery capacity in Wh
+// CableLockConfig configures the simulated cable lock of the connector.
+// Without it the connector has a working lock.
+type CableLockConfig struct {
+	NotSupported bool `yaml:"not_supported"` // Connector has no lock: UnlockConnector answers NotSupported
+	Jammed       bool `yaml:"jammed"`        // Lock starts jammed: it locks but every unlock attempt fails
 }
 
-// Load reads and parses the configuration file
-func Load(path string) (*Config, error) {
-	data, err := os.ReadFile(path)
-	if err != nil {
-		return nil, fmt.Errorf("failed to read config file: %w", err)
-	}
+// FirmwareConfig configures the simulated firmware update
+type FirmwareConfig struct {
+	FailAt          string `yaml:"fail_at"`          // Failure point: "download", "verify", "install" or "" (no failure)
+	InstallDuration int    `yaml:"install_duration"` // Seconds spent installing (default: 5)
+}
 
-	cfg := &Config{
-		// Set defaults
-		InitialStatus:       "Available",
-		MinCurrent:          0,
-		MinPower:            0,
-		Voltage:             230, // Default 230V
-		ConnectorID:         1,
-		MeterValuesInterval: 30,
-		InitialSOC:          20,    // Default 20%
-		BatteryCapacity:     60000, // Default 60 kWh
-	}
+// Firmware update failure points
+const (
+	FirmwareFailDownload = "download"
+	FirmwareFailVerify   = "verify"
+	FirmwareFailInstall  = "install"
+)
 
-	if err := yaml.Unmarshal(data, cfg); err != nil {
-		return nil, fmt.Errorf("failed to parse config file: %w", err)
-	}
+// DiagnosticsConfig configures diagnostics and log uploads
+type DiagnosticsConfig struct {
+	HTTPMethod    string `yaml:"http_method"`    // "PUT" (default, file appended to the location) or "POST" (multipart form field "file")
+	UploadFailure string `yaml:"upload_failure"` // Simulated upload failure: "error", "permission_denied" or "" (none)
+}
 
-	if err := cfg.Validate(); err != nil {
-		return nil, fmt.Errorf("invalid configuration: %w", err)
-	}
+// Diagnostics upload failures
+const (
+	UploadFailureError            = "error"
+	UploadFailurePermissionDenied = "permission_denied"
+)
 
-	return cfg, nil
+// CertificatesConfig configures the certificate management of the charger
+type CertificatesConfig struct {
+	Organization      string `yaml:"organization"`        // Organization (O) of the CSR subject (default: "Simulator")
+	ExpiryWarningDays int    `yaml:"expiry_warning_days"` // Report certificates expiring within this many days (default: 30)
 }
 
-// Validate checks if the configuration is valid
-func (c *Config) Validate() error {
-	if c.OCPPVersion != "1.6" && c.OCPPVersion != "2.0.1" {
-		return fmt.Errorf("ocpp_version must be '1.6' or '2.0.1', got '%s'", c.OCPPVersion)
-	}
+// IdentityConfig sets what the charger reports about itself in
+// BootNotification, to impersonate specific hardware. Empty fields keep the
+// defaults. Values are templates that may use {{.ChargerID}}, {{.ConnectorID}}
+// and {{.OCPPVersion}}, e.g. "SN-{{.ChargerID}}", so one identity fits many
+// chargers.
+type IdentityConfig struct {
+	Vendor                string `yaml:"vendor"`                   // Vendor name (default: "Simulator")
+	Model                 string `yaml:"model"`                    // Model (default: "WLGO-SIM-1" for 1.6, "WLGO-SIM-2" for 2.0.1)
+	SerialNumber          string `yaml:"serial_number"`            // Charge point serial number (default: charger_id)
+	ChargeBoxSerialNumber string `yaml:"charge_box_serial_number"` // Charge box serial number (OCPP 1.6 only)
+	FirmwareVersion       string `yaml:"firmware_version"`         // Firmware version at first boot (default: "1.0.0" for 1.6, "2.0.0" for 2.0.1)
+	Iccid                 string `yaml:"iccid"`                    // ICCID of the modem's SIM card
+	Imsi                  string `yaml:"imsi"`                     // IMSI of the modem's SIM card
+	MeterType             string `yaml:"meter_type"`               // Main electrical meter type (OCPP 1.6 only)
+	MeterSerialNumber     string `yaml:"meter_serial_number"`      // Main electrical meter serial number (OCPP 1.6 only)
+}
 
-	if c.ChargerID == "" {
-		return fmt.Errorf("charger_id is required")
-	}
+// identityTemplateData is the data available to the identity templates
+type identityTemplateData struct {
+	ChargerID   string
+	ConnectorID int
+	OCPPVersion string
+}
 
-	if c.ServerURL == "" {
-		return fmt.Errorf("server_url is required")
-	}
+// NetworkFaultsConfig injects faults between the charger and the server
+// connection, to test how the server copes with a misbehaving charger.
+// Percentages are the chance per frame (0-100); zero values disable a fault.
+type NetworkFaultsConfig struct {
+	Seed              int64 `yaml:"seed"`                  // Random seed for reproducible runs (0: random)
+	LatencyMs         int   `yaml:"latency_ms"`            // Delay added to every outbound frame
+	JitterMs          int   `yaml:"jitter_ms"`             // Random extra delay of up to this many ms
+	DropOutbound      int   `yaml:"drop_outbound_percent"` // Outbound frames silently dropped
+	DropInbound       int   `yaml:"drop_inbound_percent"`  // Inbound frames silently dropped
+	Duplicate         int   `yaml:"duplicate_percent"`     // Outbound frames sent twice
+	Reorder           int   `yaml:"reorder_percent"`       // Outbound frames held back and sent after the next one
+	ReuseUniqueId     int   `yaml:"reuse_id_percent"`      // Calls sent with the unique ID of the previous Call
+	Malformed         int   `yaml:"malformed_percent"`     // Outbound frames truncated to invalid JSON
+	WrongMessageType  int   `yaml:"wrong_type_percent"`    // Outbound frames with an invalid MessageTypeId
+	CallResultDelayMs int   `yaml:"call_result_delay_ms"`  // Extra delay of CallResults, e.g. past the server's timeout
+	Disconnect        int   `yaml:"disconnect_percent"`    // Outbound frames during a transaction that drop the TCP connection instead
+}
 
-	if c.MaxCurrent <= 0 {
-		return fmt.Errorf("max_current must be positive")
+// Validate checks the fault settings
+func (n *NetworkFaultsConfig) Validate() error {
+	for _, p := range []struct {
+		name  string
+		value int
+	}{
+		{"drop_outbound_percent", n.DropOutbound},
+		{"drop_inbound_percent", n.DropInbound},
+		{"duplicate_percent", n.Duplicate},
+		{"reorder_percent", n.Reorder},
+		{"reuse_id_percent", n.ReuseUniqueId},
+		{"malformed_percent", n.Malformed},
+		{"wrong_type_percent", n.WrongMessageType},
+		{"disconnect_percent", n.Disconnect},
+	} {
+		if p.value < 0 || p.value > 100 {
+			return fmt.Errorf("network_faults.%s must be between 0 and 100, got %d", p.name, p.value)
+		}
 	}
-
-	if c.MaxPower <= 0 {
-		return fmt.Errorf("max_power must be positive")
+	if n.LatencyMs < 0 || n.JitterMs < 0 || n.CallResultDelayMs < 0 {
+		return fmt.Errorf("network_faults delays cannot be negative")
 	}
+	return nil
+}
 
-	if c.MinCurrent < 0 {
-		return fmt.Errorf("min_current cannot be negative")
-	}
+// StateConfig keeps the charger state in a file so a restarted simulator
+// resumes where it stopped, like a charger coming back from a power loss
+type StateConfig struct {
+	File string `yaml:"file"` // JSON state file, written while running and read at startup
+	// A transaction interrupted for at most this many seconds continues after the
+	// restart; after a longer outage it is stopped with reason PowerLoss (0: always stopped)
+	ResumeTimeout int `yaml:"resume_timeout"`
+}
 
-	if c.MinPower < 0 {
-		return fmt.Errorf("min_power cannot be negative")
-	}
+// LoggingConfig sets the format and levels of the log
+type LoggingConfig struct {
+	Format string `yaml:"format"` // text (default) or json, one entry per line
+	Level  string `yaml:"level"`  // debug, info (default), warn, error or off
+	// Level per subsystem (charger, cli, heartbeat, meter, profile, remote,
+	// wire), overriding level
+	Subsystems map[string]string `yaml:"subsystems"`
+}
 
-	if c.MinCurrent > c.MaxCurrent {
-		return fmt.Errorf("min_current cannot exceed max_current")
+// Validate checks the format, levels and subsystem names
+func (l *LoggingConfig) Validate() error {
+	if l.Format != "" && l.Format != logging.FormatText && l.Format != logging.FormatJSON {
+		return fmt.Errorf("logging.format must be 'text' or 'json', got '%s'", l.Format)
 	}
-
-	if c.MinPower > c.MaxPower {
-		return fmt.Errorf("min_power cannot exceed max_power")
+	if l.Level != "" {
+		if _, err := logging.ParseLevel(l.Level); err != nil {
+			return fmt.Errorf("logging.level: %w", err)
+		}
 	}
-
-	if c.Voltage <= 0 {
-		return fmt.Errorf("voltage must be positive")
+	for subsystem, level := range l.Subsystems {
+		if !logging.IsSubsystem(subsystem) {
+			return fmt.Errorf("logging.subsystems: unknown subsystem '%s' (valid: %s)", subsystem, strings.Join(logging.Subsystems, ", "))
+		}
+		if _, err := logging.ParseLevel(level); err != nil {
+			return fmt.Errorf("logging.subsystems.%s: %w", subsystem, err)
+		}
 	}
+	return nil
+}
+
+// CallResponseConfig is a canned answer to a server Call the simulator has no
+// handler for, e.g. a vendor DataTransfer or a newer 2.0.1 action. The first
+// entry whose action and match predicates fit the request answers it.
+type CallResponseConfig struct {
+	Action string `yaml:"action"` // Action of the Call, e.g. "DataTransfer"
+	// JSONPath (e.g. "$.vendorId") -> value the request payload must have there
+	Match map[string]interface{} `yaml:"match"`
+	// CallResult payload: a JSON text/template with .Action, .UniqueId,
+	// .ChargerID, .Now (RFC 3339) and .Request (the decoded payload), and the
+	// function json, e.g. {"status":"Accepted","data":{{json .Request.data}}}
+	Response string `yaml:"response"`
+	// Answer with a CallError instead
+	Error *CallErrorConfig `yaml:"error"`
+}
 
-	if c.InitialSOC < 0 || c.InitialSOC > 100 {
-		return fmt.Errorf("initial_soc must be between 0 and 100")
+// CallErrorConfig is a CallError answered to a server Call
+type CallErrorConfig struct {
+	Code        string `yaml:"code"`        // errorCode, e.g. "NotSupported"
+	Description string `yaml:"description"` // errorDescription
+}
+
+// ResponseTemplate parses the response template
+func (r *CallResponseConfig) ResponseTemplate() (*template.Template, error) {
+	return template.New(r.Action).Funcs(template.FuncMap{
+		"json": func(v interface{}) (string, error) {
+			b, err := json.Marshal(v)
+			return string(b), err
+		},
+	}).Parse(r.Response)
+}
+
+// MatchPaths parses the JSONPaths of the match predicates
+func (r *CallResponseConfig) MatchPaths() (map[*jsonpath.Path]interface{}, error) {
+	paths := make(map[*jsonpath.Path]interface{}, len(r.Match))
+	for raw, value := range r.Match {
+		path, err := jsonpath.Parse(raw)
+		if err != nil {
+			return nil, err
+		}
+		paths[path] = value
 	}
+	return paths, nil
+}
 
-	if c.BatteryCapacity <= 0 {
-		return fmt.Errorf("battery_capacity must be positive")
+// Validate checks a canned response
+func (r *CallResponseConfig) Validate() error {
+	if r.Action == "" {
+		return fmt.Errorf("call_responses: action is required")
 	}
+	if (r.Response == "") == (r.Error == nil) {
+		return fmt.Errorf("call_responses %s: set either response or error", r.Action)
+	}
+	if r.Error != nil && r.Error.Code == "" {
+		return fmt.Errorf("call_responses %s: error.code is required", r.Action)
+	}
+	if _, err := r.ResponseTemplate(); err != nil {
+		return fmt.Errorf("call_responses %s: %w", r.Action, err)
+	}
+	if _, err := r.MatchPaths(); err != nil {
+		return fmt.Errorf("call_responses %s: %w", r.Action, err)
+	}
+	return nil
+}
 
-	if c.Auth != nil {
-		if c.Auth.Scheme == "" || c.Auth.Value == "" {
-			return fmt.Errorf("auth requires both scheme and value")
+// DataTransferConfig is a scripted handler for incoming DataTransfer requests
+// of a vendor. It answers with a built-in handler or with status and data.
+type DataTransferConfig struct {
+	VendorId  string `yaml:"vendor_id"`
+	MessageId string `yaml:"message_id"` // empty: every message of the vendor
+	// Built-in handler: echo (Accepted, data returned), reject (Rejected) or
+	// unknown_vendor (UnknownVendorId)
+	Handler string `yaml:"handler"`
+	// Otherwise: Accepted, Rejected, UnknownMessageId or UnknownVendorId, and
+	// a text/template for data with .VendorId, .MessageId, .Data, .ChargerID,
+	// .Now and the function json
+	Status string `yaml:"status"`
+	Data   string `yaml:"data"`
+}
+
+// DataTransfer built-in handlers
+const (
+	DataTransferEcho          = "echo"
+	DataTransferReject        = "reject"
+	DataTransferUnknownVendor = "unknown_vendor"
+)
+
+// DataTemplate parses the data template
+func (d *DataTransferConfig) DataTemplate() (*template.Template, error) {
+	return template.New(d.VendorId).Funcs(template.FuncMap{
+		"json": func(v interface{}) (string, error) {
+			b, err := json.Marshal(v)
+			return string(b), err
+		},
+	}).Parse(d.Data)
+}
+
+// Validate checks a scripted DataTransfer handler
+func (d *DataTransferConfig) Validate() error {
+	if d.VendorId == "" {
+		return fmt.Errorf("data_transfer: vendor_id is required")
+	}
+	switch d.Handler {
+	case DataTransferEcho, DataTransferReject, DataTransferUnknownVendor:
+		if d.Status != "" || d.Data != "" {
+			return fmt.Errorf("data_transfer %s: set either handler or status and data", d.VendorId)
 		}
+		return nil
+	case "":
+	default:
+		return fmt.Errorf("data_transfer %s: handler must be echo, reject or unknown_vendor, got '%s'", d.VendorId, d.Handler)
+	}
+	switch d.Status {
+	case "Accepted", "Rejected", "UnknownMessageId", "UnknownVendorId":
+	default:
+		return fmt.Errorf("data_transfer %s: status must be Accepted, Rejected, UnknownMessageId or UnknownVendorId, got '%s'", d.VendorId, d.Status)
+	}
+	if _, err := d.DataTemplate(); err != nil {
+		return fmt.Errorf("data_transfer %s: %w", d.VendorId, err)
 	}
-
 	return nil
 }
 
+// WebSocket subprotocols of the supported OCPP versions
+const (
+	SubprotocolOCPP16  = "ocpp1.6"
+	SubprotocolOCPP201 = "ocpp2.0.1"
+)
+
+// SubprotocolVersion returns the OCPP version of a WebSocket subprotocol, or ""
+// if it is not supported
+func SubprotocolVersion(subprotocol string) string {
+	switch subprotocol {
+	case SubprotocolOCPP16:
+		return "1.6"
+	case SubprotocolOCPP201:
+		return "2.0.1"
+	}
+	return ""
+}
+
+// Config holds the charger simulator configuration
+type Config struct {
+	OCPPVersion         string      `yaml:"ocpp_version"`
+	ChargerID           string      `yaml:"charger_id"`
+	ServerURL           string      `yaml:"server_url"`
+	TLS                 *TLSConfig  `yaml:"tls"`
+	Auth                *AuthConfig `yaml:"auth"`
+	InitialStatus       string      `yaml:"initial_status"`
+	MaxCurrent          float64     `yaml:"max_current"`
+	MaxPower            float64     `yaml:"max_power"`
+	MinCurrent          float64     `yaml:"min_current"`
+	MinPower            float64     `yaml:"min_power"`
+	Voltage             float64     `yaml:"voltage"` // Voltage in V (for power calculation)
+	ConnectorID         int         `yaml:"connector_id"`
+	MeterValuesInterval int         `yaml:"meter_values_interval"`
+	// EV Battery simulation
+	InitialSOC      float64 `yaml:"initial_soc"`      // Initial State of Charge (0-100%)
+	BatteryCapacity float64 `yaml:"battery_capacity"` // Battery capacity in Wh
+	// Behavior when the server deauthorizes the idTag of a running transaction
+	StopTransactionOnInvalidId bool `yaml:"stop_transaction_on_invalid_id"` // Stop the transaction (true) or only stop energy delivery (false)
+	MaxEnergyOnInvalidId       int  `yaml:"max_energy_on_invalid_id"`       // Energy in Wh still delivered after deauthorization when not stopping
+	// Cable lock simulation
+	CableLock *CableLockConfig `yaml:"cable_lock"`
+	// Hardware identity reported in BootNotification
+	Identity *IdentityConfig `yaml:"identity"`
+	// Firmware updates (the version at first boot is identity.firmware_version)
+	Firmware *FirmwareConfig `yaml:"firmware"`
+	// Diagnostics and log uploads
+	Diagnostics *DiagnosticsConfig `yaml:"diagnostics"`
+	// OCPP Security Profile: 0 (none, optional auth block), 1 (Basic auth),
+	// 2 (TLS + Basic auth) or 3 (TLS with client certificate)
+	SecurityProfile  int    `yaml:"security_profile"`
+	AuthorizationKey string `yaml:"authorization_key" secret:"true"` // Basic auth password for profiles 1 and 2
+	// Client certificate signing and trust store
+	Certificates *CertificatesConfig `yaml:"certificates"`
+	// Network fault injection
+	NetworkFaults *NetworkFaultsConfig `yaml:"network_faults"`
+	// WebSocket subprotocols offered in the handshake, preferred first (default:
+	// the one of ocpp_version). The charger follows the version the server picks.
+	Subprotocols []string `yaml:"subprotocols"`
+	// WebSocket keepalive: ping interval (0: disabled, changeable by the server as
+	// WebSocketPingInterval) and how long to wait for the pong (default: 10)
+	WebSocketPingInterval int `yaml:"websocket_ping_interval"`
+	WebSocketPongTimeout  int `yaml:"websocket_pong_timeout"`
+	// State kept across simulator restarts
+	State *StateConfig `yaml:"state"`
+	// Canned answers to server Calls without a handler
+	CallResponses []CallResponseConfig `yaml:"call_responses"`
+	// Scripted handlers for incoming DataTransfer requests
+	DataTransfer []DataTransferConfig `yaml:"data_transfer"`
+	// Command history of the interactive shell (default:
+	// ~/.ocpp_charger_simulator_history, "none": not saved)
+	HistoryFile string `yaml:"history_file"`
+	// Log format and levels per subsystem
+	Logging *LoggingConfig `yaml:"logging"`
+
+	sources map[string]string // field key -> source of its value, see Fields
+}
+
+// Load reads and parses the configuration file. LoadWith adds the
+// environment and command line layers.
+func Load(path string) (*Config, error) {
+	return LoadWith(LoadOptions{Path: path})
+}
+
+// defaultConfig returns the configuration before any layer
+func defaultConfig() *Config {
+	return &Config{
+		InitialStatus:              "Available",
+		MinCurrent:                 0,
+		MinPower:                   0,
+		Voltage:                    230, // Default 230V
+		ConnectorID:                1,
+		MeterValuesInterval:        30,
+		InitialSOC:                 20,    // Default 20%
+		BatteryCapacity:            60000, // Default 60 kWh
+		StopTransactionOnInvalidId: true,
+	}
+}
+
 // GetTLSConfig returns the tls.Config if TLS is configured
 func (c *Config) GetTLSConfig() (*tls.Config, error) {
 	if c.TLS == nil {
@@ -183,7 +447,7 @@ func (c *Config) GetTLSConfig() (*tls.Config, error) {
 
 	// Load client certificate and key if provided
 	if c.TLS.CertFile != "" && c.TLS.KeyFile != "" {
-		cert, err := tls.LoadX509KeyPair(c.TLS.CertFile, c.TLS.KeyFile)
+		cert, err := c.loadClientCertificate()
 		if err != nil {
 			return nil, fmt.Errorf("failed to load client certificate: %w", err)
 		}
@@ -193,6 +457,36 @@ func (c *Config) GetTLSConfig() (*tls.Config, error) {
 	return tlsConfig, nil
 }
 
+// loadClientCertificate loads the client certificate and its key, decrypting
+// the key with key_passphrase when it is set
+func (c *Config) loadClientCertificate() (tls.Certificate, error) {
+	if c.TLS.KeyPassphrase == "" {
+		return tls.LoadX509KeyPair(c.TLS.CertFile, c.TLS.KeyFile)
+	}
+	certPEM, err := os.ReadFile(c.TLS.CertFile)
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+	keyPEM, err := os.ReadFile(c.TLS.KeyFile)
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+	block, _ := pem.Decode(keyPEM)
+	if block == nil {
+		return tls.Certificate{}, fmt.Errorf("no PEM key in %s", c.TLS.KeyFile)
+	}
+	// Legacy encrypted PEM (Proc-Type: 4,ENCRYPTED), as written by e.g.
+	// "openssl rsa -aes256" or "openssl ec -aes256"
+	if x509.IsEncryptedPEMBlock(block) {
+		der, err := x509.DecryptPEMBlock(block, []byte(c.TLS.KeyPassphrase))
+		if err != nil {
+			return tls.Certificate{}, fmt.Errorf("failed to decrypt %s: %w", c.TLS.KeyFile, err)
+		}
+		keyPEM = pem.EncodeToMemory(&pem.Block{Type: block.Type, Bytes: der})
+	}
+	return tls.X509KeyPair(certPEM, keyPEM)
+}
+
 // GetAuthHeader returns the Authorization header value ("<scheme> <value>"),
 // or "" if auth is not configured.
 func (c *Config) GetAuthHeader() string {
@@ -202,6 +496,242 @@ func (c *Config) GetAuthHeader() string {
 	return c.Auth.Scheme + " " + c.Auth.Value
 }
 
+// validateSecurityProfile checks the prerequisites of the configured security profile
+func (c *Config) validateSecurityProfile() error {
+	if c.SecurityProfile < 0 || c.SecurityProfile > 3 {
+		return fmt.Errorf("security_profile must be between 0 and 3, got %d", c.SecurityProfile)
+	}
+	if c.SecurityProfile == 0 {
+		return nil
+	}
+	if c.Auth != nil {
+		return fmt.Errorf("auth cannot be combined with security_profile %d", c.SecurityProfile)
+	}
+	if c.SecurityProfile <= 2 && c.AuthorizationKey == "" {
+		return fmt.Errorf("security_profile %d requires authorization_key", c.SecurityProfile)
+	}
+	if c.SecurityProfile >= 2 && !strings.HasPrefix(c.ServerURL, "wss://") {
+		return fmt.Errorf("security_profile %d requires a wss:// server_url", c.SecurityProfile)
+	}
+	if c.SecurityProfile == 3 && !c.HasClientCertificate() {
+		return fmt.Errorf("security_profile 3 requires tls.cert_file and tls.key_file")
+	}
+	return nil
+}
+
+// HasClientCertificate returns true if a TLS client certificate is configured
+func (c *Config) HasClientCertificate() bool {
+	return c.TLS != nil && c.TLS.CertFile != "" && c.TLS.KeyFile != ""
+}
+
+// HasCableLock returns true if the connector has a cable lock
+func (c *Config) HasCableLock() bool {
+	return c.CableLock == nil || !c.CableLock.NotSupported
+}
+
+// GetIdentity returns the identity reported in BootNotification, with the
+// templates expanded and the defaults filled in
+func (c *Config) GetIdentity() IdentityConfig {
+	identity, _ := c.expandIdentity() // templates checked by Validate
+	if identity.Vendor == "" {
+		identity.Vendor = "Simulator"
+	}
+	if identity.Model == "" {
+		identity.Model = "WLGO-SIM-2"
+		if c.IsOCPP16() {
+			identity.Model = "WLGO-SIM-1"
+		}
+	}
+	if identity.SerialNumber == "" {
+		identity.SerialNumber = c.ChargerID
+	}
+	identity.FirmwareVersion = c.GetFirmwareVersion()
+	return identity
+}
+
+// expandIdentity returns the configured identity with the templates expanded
+func (c *Config) expandIdentity() (IdentityConfig, error) {
+	if c.Identity == nil {
+		return IdentityConfig{}, nil
+	}
+	identity := *c.Identity
+	data := identityTemplateData{ChargerID: c.ChargerID, ConnectorID: c.ConnectorID, OCPPVersion: c.OCPPVersion}
+	for _, f := range []struct {
+		name  string
+		value *string
+	}{
+		{"vendor", &identity.Vendor},
+		{"model", &identity.Model},
+		{"serial_number", &identity.SerialNumber},
+		{"charge_box_serial_number", &identity.ChargeBoxSerialNumber},
+		{"firmware_version", &identity.FirmwareVersion},
+		{"iccid", &identity.Iccid},
+		{"imsi", &identity.Imsi},
+		{"meter_type", &identity.MeterType},
+		{"meter_serial_number", &identity.MeterSerialNumber},
+	} {
+		if !strings.Contains(*f.value, "{{") {
+			continue
+		}
+		tmpl, err := template.New(f.name).Option("missingkey=error").Parse(*f.value)
+		if err != nil {
+			return identity, fmt.Errorf("identity.%s: %w", f.name, err)
+		}
+		var out strings.Builder
+		if err := tmpl.Execute(&out, data); err != nil {
+			return identity, fmt.Errorf("identity.%s: %w", f.name, err)
+		}
+		*f.value = out.String()
+	}
+	return identity, nil
+}
+
+// GetFirmwareVersion returns the firmware version reported at first boot
+func (c *Config) GetFirmwareVersion() string {
+	if identity, _ := c.expandIdentity(); identity.FirmwareVersion != "" {
+		return identity.FirmwareVersion
+	}
+	if c.IsOCPP16() {
+		return "1.0.0"
+	}
+	return "2.0.0"
+}
+
+// GetFirmwareFailAt returns the configured firmware update failure point, or "" if none
+func (c *Config) GetFirmwareFailAt() string {
+	if c.Firmware == nil {
+		return ""
+	}
+	return c.Firmware.FailAt
+}
+
+// GetFirmwareInstallDuration returns how long a firmware installation takes
+func (c *Config) GetFirmwareInstallDuration() time.Duration {
+	if c.Firmware == nil || c.Firmware.InstallDuration == 0 {
+		return 5 * time.Second
+	}
+	return time.Duration(c.Firmware.InstallDuration) * time.Second
+}
+
+// GetUploadHTTPMethod returns the HTTP method used for diagnostics uploads
+func (c *Config) GetUploadHTTPMethod() string {
+	if c.Diagnostics == nil || c.Diagnostics.HTTPMethod == "" {
+		return "PUT"
+	}
+	return c.Diagnostics.HTTPMethod
+}
+
+// GetUploadFailure returns the configured upload failure, or "" if none
+func (c *Config) GetUploadFailure() string {
+	if c.Diagnostics == nil {
+		return ""
+	}
+	return c.Diagnostics.UploadFailure
+}
+
+// GetCertificateOrganization returns the organization of the CSR subject
+func (c *Config) GetCertificateOrganization() string {
+	if c.Certificates == nil || c.Certificates.Organization == "" {
+		return "Simulator"
+	}
+	return c.Certificates.Organization
+}
+
+// GetCertificateExpiryWarning returns how long before expiry a certificate is reported
+func (c *Config) GetCertificateExpiryWarning() time.Duration {
+	days := 30
+	if c.Certificates != nil && c.Certificates.ExpiryWarningDays > 0 {
+		days = c.Certificates.ExpiryWarningDays
+	}
+	return time.Duration(days) * 24 * time.Hour
+}
+
+// GetNetworkFaults returns the configured network faults (none if not configured)
+func (c *Config) GetNetworkFaults() NetworkFaultsConfig {
+	if c.NetworkFaults == nil {
+		return NetworkFaultsConfig{}
+	}
+	return *c.NetworkFaults
+}
+
+// GetHistoryFile returns the command history file, or "" if the history is
+// not saved
+func (c *Config) GetHistoryFile() string {
+	switch c.HistoryFile {
+	case "none":
+		return ""
+	case "":
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return ""
+		}
+		return filepath.Join(home, ".ocpp_charger_simulator_history")
+	}
+	return c.HistoryFile
+}
+
+// GetLogFormat returns the log format, text or json
+func (c *Config) GetLogFormat() string {
+	if c.Logging == nil || c.Logging.Format == "" {
+		return logging.FormatText
+	}
+	return c.Logging.Format
+}
+
+// GetLogLevels returns the configured log levels (default: info). The
+// configuration must be valid.
+func (c *Config) GetLogLevels() *logging.Levels {
+	levels := logging.NewLevels(slog.LevelInfo)
+	if c.Logging == nil {
+		return levels
+	}
+	if level, err := logging.ParseLevel(c.Logging.Level); err == nil {
+		levels.SetDefault(level)
+	}
+	for subsystem, name := range c.Logging.Subsystems {
+		if level, err := logging.ParseLevel(name); err == nil {
+			levels.SetLevel(subsystem, level)
+		}
+	}
+	return levels
+}
+
+// GetStateFile returns the state file path, or "" if the state is not kept
+func (c *Config) GetStateFile() string {
+	if c.State == nil {
+		return ""
+	}
+	return c.State.File
+}
+
+// GetResumeTimeout returns the longest outage after which an interrupted
+// transaction continues (0: it is always stopped)
+func (c *Config) GetResumeTimeout() time.Duration {
+	if c.State == nil {
+		return 0
+	}
+	return time.Duration(c.State.ResumeTimeout) * time.Second
+}
+
+// GetSubprotocols returns the WebSocket subprotocols offered to the server
+func (c *Config) GetSubprotocols() []string {
+	if len(c.Subprotocols) > 0 {
+		return c.Subprotocols
+	}
+	if c.IsOCPP16() {
+		return []string{SubprotocolOCPP16}
+	}
+	return []string{SubprotocolOCPP201}
+}
+
+// GetPongTimeout returns how long the charger waits for the pong to a ping
+func (c *Config) GetPongTimeout() time.Duration {
+	if c.WebSocketPongTimeout == 0 {
+		return 10 * time.Second
+	}
+	return time.Duration(c.WebSocketPongTimeout) * time.Second
+}
+
 // IsOCPP16 returns true if the configured version is 1.6
 func (c *Config) IsOCPP16() bool {
 	return c.OCPPVersion == "1.6"
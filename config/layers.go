@@ -0,0 +1,416 @@
+package config
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// EnvPrefix starts the environment variables that set configuration fields:
+// OCPPSIM_ and the field key in upper case with "_" for ".", e.g.
+// OCPPSIM_AUTH_VALUE for auth.value. With the suffix _FILE the value is read
+// from a file instead, e.g. a container secret.
+const EnvPrefix = "OCPPSIM_"
+
+// envFileSuffix reads the value of a variable from the file it names
+const envFileSuffix = "_FILE"
+
+// Sources of the configuration values, from lowest to highest precedence
+const (
+	SourceDefault = "default"
+	SourceFile    = "file"
+	SourceEnv     = "env"
+	SourceFlag    = "flag"
+)
+
+// secretMask replaces secrets in Redacted and Fields
+const secretMask = "********"
+
+// LoadOptions are the layers of the configuration. Each layer overrides the
+// ones before it: the defaults, the YAML file, the environment and the
+// command line overrides.
+type LoadOptions struct {
+	Path         string     // YAML file, "" for none
+	OptionalFile bool       // a missing file leaves the defaults
+	Environ      []string   // "KEY=value" pairs, e.g. os.Environ()
+	Overrides    []Override // from the command line, applied in order
+	NoValidate   bool       // leave Validate or Check to the caller
+}
+
+// Override sets one field, e.g. from a --set flag
+type Override struct {
+	Key    string // field key, e.g. "auth.value"
+	Value  string
+	Source string // shown by Fields, e.g. "flag --set"
+}
+
+// ParseOverride parses "key=value"
+func ParseOverride(s, source string) (Override, error) {
+	key, value, ok := strings.Cut(s, "=")
+	if !ok || strings.TrimSpace(key) == "" {
+		return Override{}, fmt.Errorf("expected key=value, got %q", s)
+	}
+	return Override{Key: strings.TrimSpace(key), Value: value, Source: source}, nil
+}
+
+// ReadSecretFile reads a value such as a password from a file, without the
+// trailing newline
+func ReadSecretFile(path string) (string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimRight(string(data), "\r\n"), nil
+}
+
+// Field is a configuration field with its effective value
+type Field struct {
+	Key    string // YAML path, e.g. "auth.value"
+	Env    string // environment variable, e.g. "OCPPSIM_AUTH_VALUE"
+	Value  string // secrets masked
+	Source string // default, file, env or flag, with the variable or flag
+}
+
+// fieldInfo locates a field of Config by its key
+type fieldInfo struct {
+	key    string
+	index  []int // reflect field indexes, through pointers to structs
+	typ    reflect.Type
+	secret bool // tagged secret:"true", masked by Redacted
+}
+
+// configFields lists the fields of Config, nested blocks included, in
+// declaration order
+var configFields = collectFields(reflect.TypeOf(Config{}), "", nil)
+
+func collectFields(t reflect.Type, prefix string, index []int) []fieldInfo {
+	var fields []fieldInfo
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		name, _, _ := strings.Cut(f.Tag.Get("yaml"), ",")
+		if !f.IsExported() || name == "" || name == "-" {
+			continue
+		}
+		key := prefix + name
+		idx := append(append([]int{}, index...), i)
+		if f.Type.Kind() == reflect.Pointer && f.Type.Elem().Kind() == reflect.Struct {
+			fields = append(fields, collectFields(f.Type.Elem(), key+".", idx)...)
+			continue
+		}
+		fields = append(fields, fieldInfo{key: key, index: idx, typ: f.Type, secret: f.Tag.Get("secret") == "true"})
+	}
+	return fields
+}
+
+// findField returns the field with key
+func findField(key string) (fieldInfo, bool) {
+	for _, f := range configFields {
+		if f.key == key {
+			return f, true
+		}
+	}
+	return fieldInfo{}, false
+}
+
+// envName returns the environment variable of a field key
+func envName(key string) string {
+	return EnvPrefix + strings.ToUpper(strings.ReplaceAll(key, ".", "_"))
+}
+
+// LoadWith reads the configuration from its layers and validates it
+func LoadWith(opts LoadOptions) (*Config, error) {
+	cfg := defaultConfig()
+	cfg.sources = make(map[string]string)
+	for _, f := range configFields {
+		if v, ok := cfg.fieldValue(f); ok && !v.IsZero() {
+			cfg.sources[f.key] = SourceDefault
+		}
+	}
+
+	if opts.Path != "" {
+		data, err := os.ReadFile(opts.Path)
+		switch {
+		case err == nil:
+			if err := cfg.applyFile(data); err != nil {
+				return nil, err
+			}
+		case opts.OptionalFile && os.IsNotExist(err):
+		default:
+			return nil, fmt.Errorf("failed to read config file: %w", err)
+		}
+	}
+
+	if err := cfg.applyEnv(opts.Environ); err != nil {
+		return nil, err
+	}
+
+	for _, o := range opts.Overrides {
+		if err := cfg.set(o.Key, o.Value, o.Source); err != nil {
+			return nil, fmt.Errorf("%s: %w", o.Source, err)
+		}
+	}
+
+	if opts.NoValidate {
+		return cfg, nil
+	}
+	if err := cfg.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid configuration: %w", err)
+	}
+	return cfg, nil
+}
+
+// applyFile parses the YAML file over the defaults and records the keys it
+// sets. Unknown keys, e.g. typos, are errors.
+func (c *Config) applyFile(data []byte) error {
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(true)
+	if err := dec.Decode(c); err != nil && err != io.EOF {
+		return fmt.Errorf("failed to parse config file: %w", err)
+	}
+	var tree map[string]interface{}
+	if err := yaml.Unmarshal(data, &tree); err != nil {
+		return fmt.Errorf("failed to parse config file: %w", err)
+	}
+	c.markFileKeys(tree, "")
+	return nil
+}
+
+// markFileKeys records the fields present in a parsed YAML mapping
+func (c *Config) markFileKeys(tree map[string]interface{}, prefix string) {
+	for name, value := range tree {
+		key := prefix + name
+		if _, ok := findField(key); ok {
+			c.sources[key] = SourceFile
+			continue
+		}
+		if nested, ok := value.(map[string]interface{}); ok {
+			c.markFileKeys(nested, key+".")
+		}
+	}
+}
+
+// applyEnv sets the fields of the OCPPSIM_ variables in environ
+func (c *Config) applyEnv(environ []string) error {
+	// Sorted so the same environment always fails on the same variable
+	vars := append([]string{}, environ...)
+	sort.Strings(vars)
+	seen := make(map[string]string)
+	for _, kv := range vars {
+		name, value, _ := strings.Cut(kv, "=")
+		if !strings.HasPrefix(name, EnvPrefix) {
+			continue
+		}
+		key, fromFile, ok := envKey(name)
+		if !ok {
+			return fmt.Errorf("unknown environment variable %s", name)
+		}
+		if other, dup := seen[key]; dup {
+			return fmt.Errorf("%s and %s both set %s", other, name, key)
+		}
+		seen[key] = name
+		if fromFile {
+			secret, err := ReadSecretFile(value)
+			if err != nil {
+				return fmt.Errorf("%s: %w", name, err)
+			}
+			value = secret
+		}
+		if err := c.set(key, value, SourceEnv+" "+name); err != nil {
+			return fmt.Errorf("%s: %w", name, err)
+		}
+	}
+	return nil
+}
+
+// envKey returns the field key of an environment variable, and whether it
+// names a file holding the value. Fields ending in _file, e.g.
+// OCPPSIM_TLS_CA_FILE, take precedence over the _FILE suffix.
+func envKey(name string) (key string, fromFile bool, ok bool) {
+	for _, f := range configFields {
+		if envName(f.key) == name {
+			return f.key, false, true
+		}
+	}
+	if base, cut := strings.CutSuffix(name, envFileSuffix); cut {
+		for _, f := range configFields {
+			if envName(f.key) == base {
+				return f.key, true, true
+			}
+		}
+	}
+	return "", false, false
+}
+
+// set parses value into the field with key, creating the blocks on its way
+func (c *Config) set(key, value, source string) error {
+	f, ok := findField(key)
+	if !ok {
+		return fmt.Errorf("unknown config field %s", key)
+	}
+	v := reflect.ValueOf(c).Elem()
+	for i, idx := range f.index {
+		v = v.Field(idx)
+		if i < len(f.index)-1 {
+			if v.IsNil() {
+				v.Set(reflect.New(v.Type().Elem()))
+			}
+			v = v.Elem()
+		}
+	}
+	if err := parseFieldValue(v, value); err != nil {
+		return fmt.Errorf("%s: %w", key, err)
+	}
+	c.sources[key] = source
+	return nil
+}
+
+// parseFieldValue sets v from its text form: a comma-separated list for
+// lists of text and "name=value" pairs for maps
+func parseFieldValue(v reflect.Value, value string) error {
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(value)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return fmt.Errorf("invalid boolean %q", value)
+		}
+		v.SetBool(b)
+	case reflect.Int, reflect.Int64:
+		n, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return fmt.Errorf("invalid integer %q", value)
+		}
+		v.SetInt(n)
+	case reflect.Float64:
+		n, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return fmt.Errorf("invalid number %q", value)
+		}
+		v.SetFloat(n)
+	case reflect.Slice:
+		if v.Type().Elem().Kind() != reflect.String {
+			return fmt.Errorf("can only be set in the config file")
+		}
+		var items []string
+		for _, item := range strings.Split(value, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				items = append(items, item)
+			}
+		}
+		v.Set(reflect.ValueOf(items))
+	case reflect.Map:
+		m := make(map[string]string)
+		for _, pair := range strings.Split(value, ",") {
+			if pair = strings.TrimSpace(pair); pair == "" {
+				continue
+			}
+			name, val, ok := strings.Cut(pair, "=")
+			if !ok {
+				return fmt.Errorf("expected name=value pairs, got %q", pair)
+			}
+			m[strings.TrimSpace(name)] = strings.TrimSpace(val)
+		}
+		v.Set(reflect.ValueOf(m))
+	default:
+		return fmt.Errorf("unsupported field type %s", v.Type())
+	}
+	return nil
+}
+
+// fieldValue returns the value of a field, false when a block on its way is
+// not set
+func (c *Config) fieldValue(f fieldInfo) (reflect.Value, bool) {
+	v := reflect.ValueOf(c).Elem()
+	for i, idx := range f.index {
+		v = v.Field(idx)
+		if i < len(f.index)-1 {
+			if v.IsNil() {
+				return reflect.Value{}, false
+			}
+			v = v.Elem()
+		}
+	}
+	return v, true
+}
+
+// Redacted returns a copy of the configuration with every field tagged
+// secret:"true" that is set replaced by a mask, e.g. to show or upload it.
+// Blocks holding a secret are copied; c is not changed.
+func (c *Config) Redacted() *Config {
+	redacted := *c
+	for _, f := range configFields {
+		if !f.secret {
+			continue
+		}
+		v := reflect.ValueOf(&redacted).Elem()
+		for i, idx := range f.index {
+			v = v.Field(idx)
+			if i == len(f.index)-1 {
+				break
+			}
+			if v.IsNil() {
+				v = reflect.Value{}
+				break
+			}
+			block := reflect.New(v.Type().Elem())
+			block.Elem().Set(v.Elem())
+			v.Set(block)
+			v = block.Elem()
+		}
+		if v.IsValid() && v.Kind() == reflect.String && v.String() != "" {
+			v.SetString(secretMask)
+		}
+	}
+	return &redacted
+}
+
+// Fields returns the fields that are set, by default or by a layer, with
+// their source and secrets masked. Unlisted fields are zero, which the
+// getters may replace by a built-in default.
+func (c *Config) Fields() []Field {
+	redacted := c.Redacted()
+	var fields []Field
+	for _, f := range configFields {
+		v, ok := redacted.fieldValue(f)
+		source := c.sources[f.key]
+		if !ok || (source == "" && v.IsZero()) {
+			continue
+		}
+		fields = append(fields, Field{
+			Key:    f.key,
+			Env:    envName(f.key),
+			Value:  formatFieldValue(v),
+			Source: source,
+		})
+	}
+	return fields
+}
+
+// formatFieldValue renders a value in the form parseFieldValue reads
+func formatFieldValue(v reflect.Value) string {
+	switch v.Kind() {
+	case reflect.String:
+		return v.String()
+	case reflect.Slice:
+		if v.Type().Elem().Kind() != reflect.String {
+			return fmt.Sprintf("%d entries", v.Len())
+		}
+		return strings.Join(v.Interface().([]string), ",")
+	case reflect.Map:
+		pairs := make([]string, 0, v.Len())
+		for name, value := range v.Interface().(map[string]string) {
+			pairs = append(pairs, name+"="+value)
+		}
+		sort.Strings(pairs)
+		return strings.Join(pairs, ",")
+	}
+	return fmt.Sprint(v.Interface())
+}
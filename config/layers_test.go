@@ -0,0 +1,281 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// writeFile writes content to a file in a temporary directory
+func writeFile(t *testing.T, name, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+const baseYAML = `ocpp_version: "1.6"
+charger_id: "CP-file"
+server_url: "ws://localhost:9000/ocpp"
+max_current: 32
+max_power: 7360
+meter_values_interval: 10
+`
+
+// fieldSources maps the keys of Fields to their sources
+func fieldSources(cfg *Config) map[string]string {
+	sources := make(map[string]string)
+	for _, f := range cfg.Fields() {
+		sources[f.Key] = f.Source
+	}
+	return sources
+}
+
+func TestLoadWithPrecedence(t *testing.T) {
+	path := writeFile(t, "config.yaml", baseYAML)
+	cfg, err := LoadWith(LoadOptions{
+		Path: path,
+		Environ: []string{
+			"HOME=/root",
+			"OCPPSIM_CHARGER_ID=CP-env",
+			"OCPPSIM_METER_VALUES_INTERVAL=20",
+		},
+		Overrides: []Override{
+			{Key: "meter_values_interval", Value: "30", Source: "flag --set"},
+			{Key: "voltage", Value: "400", Source: "flag --set"},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tc := range []struct {
+		key    string
+		got    interface{}
+		want   interface{}
+		source string
+	}{
+		{"connector_id", cfg.ConnectorID, 1, SourceDefault},
+		{"max_current", cfg.MaxCurrent, 32.0, SourceFile},
+		{"charger_id", cfg.ChargerID, "CP-env", "env OCPPSIM_CHARGER_ID"},
+		{"meter_values_interval", cfg.MeterValuesInterval, 30, "flag --set"},
+		{"voltage", cfg.Voltage, 400.0, "flag --set"},
+	} {
+		if tc.got != tc.want {
+			t.Errorf("%s = %v, want %v", tc.key, tc.got, tc.want)
+		}
+		if source := fieldSources(cfg)[tc.key]; source != tc.source {
+			t.Errorf("%s source = %q, want %q", tc.key, source, tc.source)
+		}
+	}
+}
+
+func TestLoadWithOverridesInOrder(t *testing.T) {
+	cfg, err := LoadWith(LoadOptions{
+		Path: writeFile(t, "config.yaml", baseYAML),
+		Overrides: []Override{
+			{Key: "charger_id", Value: "first", Source: "flag --set"},
+			{Key: "charger_id", Value: "second", Source: "flag --set-file"},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.ChargerID != "second" || fieldSources(cfg)["charger_id"] != "flag --set-file" {
+		t.Errorf("charger_id = %q from %q, want the last override", cfg.ChargerID, fieldSources(cfg)["charger_id"])
+	}
+}
+
+func TestLoadWithEnvFile(t *testing.T) {
+	secret := writeFile(t, "auth", "s3cret\n")
+	cfg, err := LoadWith(LoadOptions{
+		Path: writeFile(t, "config.yaml", baseYAML),
+		Environ: []string{
+			"OCPPSIM_AUTH_SCHEME=Basic",
+			"OCPPSIM_AUTH_VALUE_FILE=" + secret,
+			// A field ending in _file is set directly, not read from a file
+			"OCPPSIM_TLS_CA_FILE=/etc/ca.pem",
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Auth == nil || cfg.Auth.Value != "s3cret" {
+		t.Fatalf("auth = %+v, want the value read from the file without the newline", cfg.Auth)
+	}
+	if cfg.TLS == nil || cfg.TLS.CAFile != "/etc/ca.pem" {
+		t.Errorf("tls = %+v, want ca_file /etc/ca.pem", cfg.TLS)
+	}
+	if source := fieldSources(cfg)["auth.value"]; source != "env OCPPSIM_AUTH_VALUE_FILE" {
+		t.Errorf("auth.value source = %q", source)
+	}
+}
+
+func TestLoadWithErrors(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		opts LoadOptions
+		want string
+	}{
+		{
+			"unknown file key",
+			LoadOptions{Path: writeFile(t, "c.yaml", baseYAML+"max_curent: 16\n")},
+			"field max_curent not found",
+		},
+		{
+			"unknown nested file key",
+			LoadOptions{Path: writeFile(t, "c.yaml", baseYAML+"auth:\n  schema: Basic\n")},
+			"field schema not found",
+		},
+		{
+			"missing file",
+			LoadOptions{Path: filepath.Join(t.TempDir(), "none.yaml")},
+			"failed to read config file",
+		},
+		{
+			"unknown variable",
+			LoadOptions{Path: writeFile(t, "c.yaml", baseYAML), Environ: []string{"OCPPSIM_CHARGERID=x"}},
+			"unknown environment variable OCPPSIM_CHARGERID",
+		},
+		{
+			"variable and its _FILE",
+			LoadOptions{Path: writeFile(t, "c.yaml", baseYAML), Environ: []string{"OCPPSIM_CHARGER_ID=a", "OCPPSIM_CHARGER_ID_FILE=/x"}},
+			"both set charger_id",
+		},
+		{
+			"unreadable _FILE",
+			LoadOptions{Path: writeFile(t, "c.yaml", baseYAML), Environ: []string{"OCPPSIM_AUTH_VALUE_FILE=" + filepath.Join(t.TempDir(), "none")}},
+			"OCPPSIM_AUTH_VALUE_FILE:",
+		},
+		{
+			"invalid variable value",
+			LoadOptions{Path: writeFile(t, "c.yaml", baseYAML), Environ: []string{"OCPPSIM_MAX_CURRENT=lots"}},
+			`OCPPSIM_MAX_CURRENT: max_current: invalid number "lots"`,
+		},
+		{
+			"unknown override",
+			LoadOptions{Path: writeFile(t, "c.yaml", baseYAML), Overrides: []Override{{Key: "nope", Value: "1", Source: "flag --set"}}},
+			"flag --set: unknown config field nope",
+		},
+		{
+			"list of blocks",
+			LoadOptions{Path: writeFile(t, "c.yaml", baseYAML), Overrides: []Override{{Key: "call_responses", Value: "x", Source: "flag --set"}}},
+			"can only be set in the config file",
+		},
+		{
+			"invalid result",
+			LoadOptions{Path: writeFile(t, "c.yaml", baseYAML), Overrides: []Override{{Key: "connector_id", Value: "0", Source: "flag --set"}}},
+			"invalid configuration: connector_id must be at least 1",
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := LoadWith(tc.opts)
+			if err == nil || !strings.Contains(err.Error(), tc.want) {
+				t.Errorf("LoadWith error = %v, want it to contain %q", err, tc.want)
+			}
+		})
+	}
+}
+
+func TestLoadWithOptionalFile(t *testing.T) {
+	cfg, err := LoadWith(LoadOptions{
+		Path:         filepath.Join(t.TempDir(), "none.yaml"),
+		OptionalFile: true,
+		NoValidate:   true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.ConnectorID != 1 || cfg.Voltage != 230 {
+		t.Errorf("a missing optional file should leave the defaults, got %+v", cfg)
+	}
+}
+
+func TestParseOverride(t *testing.T) {
+	for _, tc := range []struct {
+		in         string
+		key, value string
+	}{
+		{"charger_id=CP1", "charger_id", "CP1"},
+		{" auth.value =a=b", "auth.value", "a=b"},
+		{"identity.vendor=", "identity.vendor", ""},
+	} {
+		o, err := ParseOverride(tc.in, "flag --set")
+		if err != nil || o.Key != tc.key || o.Value != tc.value || o.Source != "flag --set" {
+			t.Errorf("ParseOverride(%q) = %+v, %v, want %s=%s", tc.in, o, err, tc.key, tc.value)
+		}
+	}
+	for _, in := range []string{"charger_id", "=CP1", " =x"} {
+		if _, err := ParseOverride(in, "flag --set"); err == nil {
+			t.Errorf("ParseOverride(%q) should fail", in)
+		}
+	}
+}
+
+func TestSetFieldTypes(t *testing.T) {
+	for _, tc := range []struct {
+		key, value string
+		check      func(c *Config) bool
+	}{
+		{"stop_transaction_on_invalid_id", "false", func(c *Config) bool { return !c.StopTransactionOnInvalidId }},
+		{"network_faults.seed", "42", func(c *Config) bool { return c.NetworkFaults.Seed == 42 }},
+		{"initial_soc", "55.5", func(c *Config) bool { return c.InitialSOC == 55.5 }},
+		{"subprotocols", " ocpp1.6 , ,ocpp2.0.1", func(c *Config) bool {
+			return strings.Join(c.Subprotocols, "|") == "ocpp1.6|ocpp2.0.1"
+		}},
+		{"logging.subsystems", "wire=debug, meter = off", func(c *Config) bool {
+			return len(c.Logging.Subsystems) == 2 && c.Logging.Subsystems["wire"] == "debug" && c.Logging.Subsystems["meter"] == "off"
+		}},
+	} {
+		c := defaultConfig()
+		c.sources = make(map[string]string)
+		if err := c.set(tc.key, tc.value, SourceFlag); err != nil || !tc.check(c) {
+			t.Errorf("set(%q, %q) error %v, or value not set", tc.key, tc.value, err)
+		}
+	}
+
+	for _, tc := range []struct{ key, value, want string }{
+		{"stop_transaction_on_invalid_id", "maybe", "invalid boolean"},
+		{"connector_id", "1.5", "invalid integer"},
+		{"logging.subsystems", "wire", "expected name=value pairs"},
+	} {
+		c := defaultConfig()
+		c.sources = make(map[string]string)
+		if err := c.set(tc.key, tc.value, SourceFlag); err == nil || !strings.Contains(err.Error(), tc.want) {
+			t.Errorf("set(%q, %q) error = %v, want %q", tc.key, tc.value, err, tc.want)
+		}
+	}
+}
+
+func TestRedacted(t *testing.T) {
+	cfg, err := LoadWith(LoadOptions{
+		Path: writeFile(t, "config.yaml", baseYAML+"auth:\n  scheme: Basic\n  value: s3cret\ntls:\n  key_passphrase: pass\n"),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	redacted := cfg.Redacted()
+	if redacted.Auth.Value != secretMask || redacted.TLS.KeyPassphrase != secretMask {
+		t.Errorf("secrets not masked: auth %+v, tls %+v", redacted.Auth, redacted.TLS)
+	}
+	if redacted.Auth.Scheme != "Basic" || redacted.ChargerID != "CP-file" {
+		t.Errorf("other fields changed: auth %+v, charger_id %q", redacted.Auth, redacted.ChargerID)
+	}
+	if cfg.Auth.Value != "s3cret" || cfg.TLS.KeyPassphrase != "pass" {
+		t.Errorf("Redacted changed the original: auth %+v, tls %+v", cfg.Auth, cfg.TLS)
+	}
+	// Unset secrets stay empty rather than suggesting a value
+	if redacted.AuthorizationKey != "" {
+		t.Errorf("authorization_key = %q, want empty", redacted.AuthorizationKey)
+	}
+
+	for _, f := range cfg.Fields() {
+		if f.Key == "auth.value" && (f.Value != secretMask || f.Env != "OCPPSIM_AUTH_VALUE") {
+			t.Errorf("Fields auth.value = %+v", f)
+		}
+	}
+}
@@ -0,0 +1,295 @@
+package config
+
+import (
+	"fmt"
+	"net/url"
+	"slices"
+	"strings"
+)
+
+// Connector statuses of each OCPP version
+var (
+	ConnectorStatuses16  = []string{"Available", "Preparing", "Charging", "SuspendedEVSE", "SuspendedEV", "Finishing", "Reserved", "Unavailable", "Faulted"}
+	ConnectorStatuses201 = []string{"Available", "Occupied", "Reserved", "Unavailable", "Faulted"}
+)
+
+// statusesWithTransaction are the OCPP 1.6 statuses of a running transaction,
+// which the simulator does not have at startup
+var statusesWithTransaction = []string{"Charging", "SuspendedEVSE", "SuspendedEV", "Finishing"}
+
+// ConnectorStatuses returns the connector statuses of the configured OCPP
+// version
+func (c *Config) ConnectorStatuses() []string {
+	if c.IsOCPP16() {
+		return ConnectorStatuses16
+	}
+	return ConnectorStatuses201
+}
+
+// Problems are the findings of Check. Errors make the configuration unusable;
+// warnings point at settings that work but are inconsistent.
+type Problems struct {
+	Errors   []string
+	Warnings []string
+}
+
+func (p *Problems) errorf(format string, args ...interface{}) {
+	p.Errors = append(p.Errors, fmt.Sprintf(format, args...))
+}
+
+func (p *Problems) warnf(format string, args ...interface{}) {
+	p.Warnings = append(p.Warnings, fmt.Sprintf(format, args...))
+}
+
+// add records err, if any, as an error
+func (p *Problems) add(err error) {
+	if err != nil {
+		p.Errors = append(p.Errors, err.Error())
+	}
+}
+
+// CheckInvalidId checks the handling of a transaction whose idTag the server
+// deauthorizes, as configured or as changed by the server
+func CheckInvalidId(stopTransaction bool, maxEnergy int) Problems {
+	var p Problems
+	p.checkInvalidId(stopTransaction, maxEnergy)
+	return p
+}
+
+func (p *Problems) checkInvalidId(stopTransaction bool, maxEnergy int) {
+	if maxEnergy < 0 {
+		p.errorf("max_energy_on_invalid_id cannot be negative")
+	} else if maxEnergy > 0 && stopTransaction {
+		p.warnf("max_energy_on_invalid_id is unused while stop_transaction_on_invalid_id is true")
+	}
+}
+
+// ValidationError is returned by Validate with every error Check found
+type ValidationError struct {
+	Errors []string
+}
+
+func (e *ValidationError) Error() string {
+	if len(e.Errors) == 1 {
+		return e.Errors[0]
+	}
+	return fmt.Sprintf("%d errors: %s", len(e.Errors), strings.Join(e.Errors, "; "))
+}
+
+// Validate checks if the configuration is valid. The error is a
+// *ValidationError listing every error; warnings are left to Check.
+func (c *Config) Validate() error {
+	if p := c.Check(); len(p.Errors) > 0 {
+		return &ValidationError{Errors: p.Errors}
+	}
+	return nil
+}
+
+// Check collects every error and warning of the configuration
+func (c *Config) Check() Problems {
+	var p Problems
+
+	versionOK := c.OCPPVersion == "1.6" || c.OCPPVersion == "2.0.1"
+	if !versionOK {
+		p.errorf("ocpp_version must be '1.6' or '2.0.1', got '%s'", c.OCPPVersion)
+	}
+
+	if c.ChargerID == "" {
+		p.errorf("charger_id is required")
+	}
+
+	c.checkServerURL(&p)
+
+	if versionOK {
+		if !slices.Contains(c.ConnectorStatuses(), c.InitialStatus) {
+			p.errorf("initial_status must be one of %s for OCPP %s, got '%s'",
+				strings.Join(c.ConnectorStatuses(), ", "), c.OCPPVersion, c.InitialStatus)
+		} else if c.IsOCPP16() && slices.Contains(statusesWithTransaction, c.InitialStatus) {
+			p.warnf("initial_status '%s' is the status of a transaction, but the charger starts without one", c.InitialStatus)
+		}
+	}
+
+	c.checkLimits(&p)
+
+	if c.ConnectorID < 1 {
+		p.errorf("connector_id must be at least 1, got %d", c.ConnectorID)
+	}
+
+	if c.MeterValuesInterval <= 0 {
+		p.errorf("meter_values_interval must be positive, got %d", c.MeterValuesInterval)
+	}
+
+	if c.InitialSOC < 0 || c.InitialSOC > 100 {
+		p.errorf("initial_soc must be between 0 and 100")
+	} else if c.InitialSOC == 100 {
+		p.warnf("initial_soc is 100: the battery is full, so a transaction delivers no energy")
+	}
+
+	if c.BatteryCapacity <= 0 {
+		p.errorf("battery_capacity must be positive")
+	}
+
+	p.checkInvalidId(c.StopTransactionOnInvalidId, c.MaxEnergyOnInvalidId)
+
+	if c.CableLock != nil && c.CableLock.NotSupported && c.CableLock.Jammed {
+		p.errorf("cable_lock cannot be jammed when not_supported is set")
+	}
+
+	if c.Firmware != nil {
+		switch c.Firmware.FailAt {
+		case "", FirmwareFailDownload, FirmwareFailVerify, FirmwareFailInstall:
+		default:
+			p.errorf("firmware.fail_at must be one of 'download', 'verify', 'install', got '%s'", c.Firmware.FailAt)
+		}
+		if c.Firmware.InstallDuration < 0 {
+			p.errorf("firmware.install_duration cannot be negative")
+		}
+	}
+
+	if c.Diagnostics != nil {
+		switch c.Diagnostics.HTTPMethod {
+		case "", "PUT", "POST":
+		default:
+			p.errorf("diagnostics.http_method must be 'PUT' or 'POST', got '%s'", c.Diagnostics.HTTPMethod)
+		}
+		switch c.Diagnostics.UploadFailure {
+		case "", UploadFailureError, UploadFailurePermissionDenied:
+		default:
+			p.errorf("diagnostics.upload_failure must be 'error' or 'permission_denied', got '%s'", c.Diagnostics.UploadFailure)
+		}
+	}
+
+	if c.Auth != nil {
+		if c.Auth.Scheme == "" || c.Auth.Value == "" {
+			p.errorf("auth requires both scheme and value")
+		}
+	}
+
+	if c.Certificates != nil && c.Certificates.ExpiryWarningDays < 0 {
+		p.errorf("certificates.expiry_warning_days cannot be negative")
+	}
+
+	if c.NetworkFaults != nil {
+		p.add(c.NetworkFaults.Validate())
+	}
+
+	if c.Identity != nil {
+		_, err := c.expandIdentity()
+		p.add(err)
+	}
+
+	for i := range c.CallResponses {
+		p.add(c.CallResponses[i].Validate())
+	}
+
+	for i := range c.DataTransfer {
+		p.add(c.DataTransfer[i].Validate())
+	}
+
+	if c.Logging != nil {
+		p.add(c.Logging.Validate())
+	}
+
+	if c.State != nil && c.State.ResumeTimeout < 0 {
+		p.errorf("state.resume_timeout cannot be negative")
+	}
+
+	for _, sp := range c.Subprotocols {
+		if SubprotocolVersion(sp) == "" {
+			p.errorf("subprotocols must be '%s' or '%s', got '%s'", SubprotocolOCPP16, SubprotocolOCPP201, sp)
+		}
+	}
+
+	if c.WebSocketPingInterval < 0 {
+		p.errorf("websocket_ping_interval cannot be negative")
+	}
+
+	if c.WebSocketPongTimeout < 0 {
+		p.errorf("websocket_pong_timeout cannot be negative")
+	} else if c.WebSocketPingInterval > 0 && c.GetPongTimeout().Seconds() >= float64(c.WebSocketPingInterval) {
+		p.warnf("websocket_pong_timeout (%.0f s) is not shorter than websocket_ping_interval (%d s)",
+			c.GetPongTimeout().Seconds(), c.WebSocketPingInterval)
+	}
+
+	p.add(c.validateSecurityProfile())
+	c.checkTLS(&p)
+
+	return p
+}
+
+// checkServerURL checks that server_url is a WebSocket URL the client can dial
+func (c *Config) checkServerURL(p *Problems) {
+	if c.ServerURL == "" {
+		p.errorf("server_url is required")
+		return
+	}
+	u, err := url.Parse(c.ServerURL)
+	if err != nil || (u.Scheme != "ws" && u.Scheme != "wss") || u.Hostname() == "" {
+		p.errorf("server_url must be a ws:// or wss:// URL, got '%s'", c.ServerURL)
+		return
+	}
+	if u.Port() == "" {
+		p.errorf("server_url needs a port, e.g. '%s://%s:%s%s'", u.Scheme, u.Hostname(), map[string]string{"ws": "80", "wss": "443"}[u.Scheme], u.Path)
+	}
+}
+
+// checkLimits checks the current and power limits against each other
+func (c *Config) checkLimits(p *Problems) {
+	if c.MaxCurrent <= 0 {
+		p.errorf("max_current must be positive")
+	}
+	if c.MaxPower <= 0 {
+		p.errorf("max_power must be positive")
+	}
+	if c.MinCurrent < 0 {
+		p.errorf("min_current cannot be negative")
+	}
+	if c.MinPower < 0 {
+		p.errorf("min_power cannot be negative")
+	}
+	if c.MinCurrent > c.MaxCurrent {
+		p.errorf("min_current cannot exceed max_current")
+	}
+	if c.MinPower > c.MaxPower {
+		p.errorf("min_power cannot exceed max_power")
+	}
+	if c.Voltage <= 0 {
+		p.errorf("voltage must be positive")
+		return
+	}
+	if c.MaxCurrent <= 0 || c.MaxPower <= 0 {
+		return
+	}
+
+	// Power is voltage x current on the single simulated phase
+	if limit := c.Voltage * c.MaxCurrent; c.MaxPower > limit {
+		p.warnf("max_power (%.0f W) exceeds voltage x max_current (%.0f W): a power limit above it draws more than max_current",
+			c.MaxPower, limit)
+	} else if c.MaxPower < limit {
+		p.warnf("max_power (%.0f W) is below voltage x max_current (%.0f W): currents above %.1f A draw more than max_power",
+			c.MaxPower, limit, c.MaxPower/c.Voltage)
+	}
+}
+
+// checkTLS checks that the tls block fits the server_url and is complete
+func (c *Config) checkTLS(p *Problems) {
+	secure := strings.HasPrefix(c.ServerURL, "wss://")
+	if c.TLS == nil {
+		if secure {
+			p.warnf("server_url uses wss:// without a tls block: the server certificate must be trusted by the system")
+		}
+		return
+	}
+	if !secure {
+		p.warnf("tls is set but server_url is not wss://, so it is not used")
+	}
+	if (c.TLS.CertFile == "") != (c.TLS.KeyFile == "") {
+		p.errorf("tls.cert_file and tls.key_file must be set together")
+	}
+	if c.TLS.KeyPassphrase != "" && c.TLS.KeyFile == "" {
+		p.warnf("tls.key_passphrase is set without tls.key_file")
+	}
+	if c.TLS.SkipVerify && (c.TLS.CAFile != "" || c.TLS.ServerCertFile != "") {
+		p.warnf("tls.skip_verify is set, so tls.ca_file and tls.server_cert_file are not used")
+	}
+}
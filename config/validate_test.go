@@ -0,0 +1,182 @@
+package config
+
+import (
+	"strings"
+	"testing"
+)
+
+// validConfig returns a configuration Check finds nothing wrong with
+func validConfig() *Config {
+	c := defaultConfig()
+	c.OCPPVersion = "1.6"
+	c.ChargerID = "CP1"
+	c.ServerURL = "ws://localhost:9000/ocpp"
+	c.MaxCurrent = 32
+	c.MaxPower = 7360 // 230 V x 32 A
+	return c
+}
+
+func TestCheckValid(t *testing.T) {
+	if p := validConfig().Check(); len(p.Errors) > 0 || len(p.Warnings) > 0 {
+		t.Fatalf("Check() = %+v, want no problems", p)
+	}
+	if err := validConfig().Validate(); err != nil {
+		t.Fatalf("Validate() = %v", err)
+	}
+}
+
+func TestCheckErrors(t *testing.T) {
+	for _, tc := range []struct {
+		name   string
+		mutate func(c *Config)
+		want   string
+	}{
+		{"version", func(c *Config) { c.OCPPVersion = "2.0" }, "ocpp_version must be '1.6' or '2.0.1'"},
+		{"charger id", func(c *Config) { c.ChargerID = "" }, "charger_id is required"},
+		{"no server url", func(c *Config) { c.ServerURL = "" }, "server_url is required"},
+		{"http server url", func(c *Config) { c.ServerURL = "http://localhost:9000" }, "server_url must be a ws:// or wss:// URL"},
+		{"no host", func(c *Config) { c.ServerURL = "ws://:9000/ocpp" }, "server_url must be a ws:// or wss:// URL"},
+		{"no port", func(c *Config) { c.ServerURL = "wss://csms.example/ocpp" }, "server_url needs a port, e.g. 'wss://csms.example:443/ocpp'"},
+		{"status of 2.0.1 on 1.6", func(c *Config) { c.InitialStatus = "Occupied" }, "initial_status must be one of"},
+		{"status of 1.6 on 2.0.1", func(c *Config) { c.OCPPVersion = "2.0.1"; c.InitialStatus = "Preparing" }, "for OCPP 2.0.1, got 'Preparing'"},
+		{"max current", func(c *Config) { c.MaxCurrent = 0 }, "max_current must be positive"},
+		{"max power", func(c *Config) { c.MaxPower = -1 }, "max_power must be positive"},
+		{"min current", func(c *Config) { c.MinCurrent = -1 }, "min_current cannot be negative"},
+		{"min power", func(c *Config) { c.MinPower = -1 }, "min_power cannot be negative"},
+		{"min above max current", func(c *Config) { c.MinCurrent = 40 }, "min_current cannot exceed max_current"},
+		{"min above max power", func(c *Config) { c.MinPower = 8000 }, "min_power cannot exceed max_power"},
+		{"voltage", func(c *Config) { c.Voltage = 0 }, "voltage must be positive"},
+		{"connector id", func(c *Config) { c.ConnectorID = 0 }, "connector_id must be at least 1"},
+		{"meter interval", func(c *Config) { c.MeterValuesInterval = 0 }, "meter_values_interval must be positive"},
+		{"soc", func(c *Config) { c.InitialSOC = 101 }, "initial_soc must be between 0 and 100"},
+		{"battery", func(c *Config) { c.BatteryCapacity = 0 }, "battery_capacity must be positive"},
+		{"max energy on invalid id", func(c *Config) { c.MaxEnergyOnInvalidId = -1 }, "max_energy_on_invalid_id cannot be negative"},
+		{"cable lock", func(c *Config) { c.CableLock = &CableLockConfig{NotSupported: true, Jammed: true} }, "cable_lock cannot be jammed"},
+		{"firmware fail at", func(c *Config) { c.Firmware = &FirmwareConfig{FailAt: "boot"} }, "firmware.fail_at must be one of"},
+		{"firmware install", func(c *Config) { c.Firmware = &FirmwareConfig{InstallDuration: -1} }, "firmware.install_duration cannot be negative"},
+		{"diagnostics method", func(c *Config) { c.Diagnostics = &DiagnosticsConfig{HTTPMethod: "GET"} }, "diagnostics.http_method must be 'PUT' or 'POST'"},
+		{"diagnostics failure", func(c *Config) { c.Diagnostics = &DiagnosticsConfig{UploadFailure: "timeout"} }, "diagnostics.upload_failure must be"},
+		{"auth", func(c *Config) { c.Auth = &AuthConfig{Scheme: "Basic"} }, "auth requires both scheme and value"},
+		{"certificates", func(c *Config) { c.Certificates = &CertificatesConfig{ExpiryWarningDays: -1} }, "certificates.expiry_warning_days cannot be negative"},
+		{"network faults", func(c *Config) { c.NetworkFaults = &NetworkFaultsConfig{Duplicate: 101} }, "network_faults.duplicate_percent must be between 0 and 100"},
+		{"identity template", func(c *Config) { c.Identity = &IdentityConfig{SerialNumber: "{{.Serial}}"} }, "identity.serial_number"},
+		{"call response", func(c *Config) { c.CallResponses = []CallResponseConfig{{Action: "Reset"}} }, "call_responses Reset: set either response or error"},
+		{"data transfer", func(c *Config) { c.DataTransfer = []DataTransferConfig{{VendorId: "acme", Handler: "drop"}} }, "data_transfer acme: handler must be"},
+		{"logging", func(c *Config) { c.Logging = &LoggingConfig{Subsystems: map[string]string{"ocpp": "debug"}} }, "logging.subsystems: unknown subsystem 'ocpp'"},
+		{"resume timeout", func(c *Config) { c.State = &StateConfig{ResumeTimeout: -1} }, "state.resume_timeout cannot be negative"},
+		{"subprotocols", func(c *Config) { c.Subprotocols = []string{"ocpp2.1"} }, "subprotocols must be"},
+		{"ping interval", func(c *Config) { c.WebSocketPingInterval = -1 }, "websocket_ping_interval cannot be negative"},
+		{"pong timeout", func(c *Config) { c.WebSocketPongTimeout = -1 }, "websocket_pong_timeout cannot be negative"},
+		{"security profile range", func(c *Config) { c.SecurityProfile = 4 }, "security_profile must be between 0 and 3"},
+		{"security profile with auth", func(c *Config) {
+			c.SecurityProfile = 1
+			c.AuthorizationKey = "0123456789abcdef"
+			c.Auth = &AuthConfig{Scheme: "Basic", Value: "x"}
+		}, "auth cannot be combined with security_profile 1"},
+		{"security profile key", func(c *Config) { c.SecurityProfile = 1 }, "security_profile 1 requires authorization_key"},
+		{"security profile wss", func(c *Config) { c.SecurityProfile = 2; c.AuthorizationKey = "0123456789abcdef" }, "security_profile 2 requires a wss:// server_url"},
+		{"security profile certificate", func(c *Config) { c.SecurityProfile = 3; c.ServerURL = "wss://localhost:9443/ocpp" }, "security_profile 3 requires tls.cert_file and tls.key_file"},
+		{"tls key pair", func(c *Config) { c.ServerURL = "wss://localhost:9443/ocpp"; c.TLS = &TLSConfig{CertFile: "cert.pem"} }, "tls.cert_file and tls.key_file must be set together"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			c := validConfig()
+			tc.mutate(c)
+			p := c.Check()
+			if !containsProblem(p.Errors, tc.want) {
+				t.Errorf("Check() errors = %q, want one containing %q", p.Errors, tc.want)
+			}
+			if err := c.Validate(); err == nil {
+				t.Error("Validate() = nil, want an error")
+			}
+		})
+	}
+}
+
+func TestCheckWarnings(t *testing.T) {
+	for _, tc := range []struct {
+		name   string
+		mutate func(c *Config)
+		want   string
+	}{
+		{"transaction status", func(c *Config) { c.InitialStatus = "Charging" }, "initial_status 'Charging' is the status of a transaction"},
+		{"full battery", func(c *Config) { c.InitialSOC = 100 }, "initial_soc is 100"},
+		{"power above current", func(c *Config) { c.MaxPower = 11000 }, "max_power (11000 W) exceeds voltage x max_current (7360 W)"},
+		{"power below current", func(c *Config) { c.MaxPower = 3680 }, "currents above 16.0 A draw more than max_power"},
+		{"unused max energy", func(c *Config) { c.MaxEnergyOnInvalidId = 100 }, "max_energy_on_invalid_id is unused"},
+		{"pong timeout", func(c *Config) { c.WebSocketPingInterval = 10 }, "websocket_pong_timeout (10 s) is not shorter than websocket_ping_interval (10 s)"},
+		{"wss without tls", func(c *Config) { c.ServerURL = "wss://localhost:9443/ocpp" }, "server_url uses wss:// without a tls block"},
+		{"tls without wss", func(c *Config) { c.TLS = &TLSConfig{CAFile: "ca.pem"} }, "tls is set but server_url is not wss://"},
+		{"passphrase without key", func(c *Config) {
+			c.ServerURL = "wss://localhost:9443/ocpp"
+			c.TLS = &TLSConfig{KeyPassphrase: "pass"}
+		}, "tls.key_passphrase is set without tls.key_file"},
+		{"skip verify", func(c *Config) {
+			c.ServerURL = "wss://localhost:9443/ocpp"
+			c.TLS = &TLSConfig{SkipVerify: true, CAFile: "ca.pem"}
+		}, "tls.skip_verify is set"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			c := validConfig()
+			tc.mutate(c)
+			p := c.Check()
+			if len(p.Errors) > 0 {
+				t.Errorf("Check() errors = %q, want none", p.Errors)
+			}
+			if !containsProblem(p.Warnings, tc.want) {
+				t.Errorf("Check() warnings = %q, want one containing %q", p.Warnings, tc.want)
+			}
+			// Warnings alone leave the configuration usable
+			if err := c.Validate(); err != nil {
+				t.Errorf("Validate() = %v, want nil", err)
+			}
+		})
+	}
+}
+
+func TestCheckInvalidId(t *testing.T) {
+	for _, tc := range []struct {
+		stop             bool
+		maxEnergy        int
+		errors, warnings int
+	}{
+		{true, 0, 0, 0},
+		{false, 0, 0, 0},
+		{false, 500, 0, 0},
+		{true, 500, 0, 1},
+		{false, -1, 1, 0},
+	} {
+		p := CheckInvalidId(tc.stop, tc.maxEnergy)
+		if len(p.Errors) != tc.errors || len(p.Warnings) != tc.warnings {
+			t.Errorf("CheckInvalidId(%v, %d) = %+v, want %d errors and %d warnings", tc.stop, tc.maxEnergy, p, tc.errors, tc.warnings)
+		}
+	}
+}
+
+func TestValidationError(t *testing.T) {
+	c := validConfig()
+	c.ChargerID = ""
+	err := c.Validate()
+	if err == nil || err.Error() != "charger_id is required" {
+		t.Errorf("Validate() = %v, want the single error", err)
+	}
+
+	c.ConnectorID = 0
+	err = c.Validate()
+	verr, ok := err.(*ValidationError)
+	if !ok || len(verr.Errors) != 2 {
+		t.Fatalf("Validate() = %#v, want a *ValidationError with 2 errors", err)
+	}
+	if want := "2 errors: charger_id is required; connector_id must be at least 1, got 0"; err.Error() != want {
+		t.Errorf("Error() = %q, want %q", err.Error(), want)
+	}
+}
+
+// containsProblem reports whether one of problems contains want
+func containsProblem(problems []string, want string) bool {
+	for _, p := range problems {
+		if strings.Contains(p, want) {
+			return true
+		}
+	}
+	return false
+}
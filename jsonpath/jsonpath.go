@@ -0,0 +1,98 @@
+// Package jsonpath evaluates the subset of JSONPath used to match OCPP
+// payloads: a path from the root ($) through object members (.name or
+// ['name']) and array elements ([index]), e.g. $.evse.id or
+// $.chargingProfile.chargingSchedule[0].chargingRateUnit.
+package jsonpath
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// step is one member name or array index of a path
+type step struct {
+	name  string
+	index int
+	isIdx bool
+}
+
+// Path is a parsed JSONPath
+type Path struct {
+	raw   string
+	steps []step
+}
+
+// Parse parses a path such as $.a.b[0]['c d']
+func Parse(path string) (*Path, error) {
+	if !strings.HasPrefix(path, "$") {
+		return nil, fmt.Errorf("JSONPath %q must start with $", path)
+	}
+	p := &Path{raw: path}
+	rest := path[1:]
+	for rest != "" {
+		switch {
+		case rest[0] == '.':
+			end := strings.IndexAny(rest[1:], ".[")
+			if end < 0 {
+				end = len(rest) - 1
+			}
+			name := rest[1 : end+1]
+			if name == "" {
+				return nil, fmt.Errorf("JSONPath %q has an empty member name", path)
+			}
+			p.steps = append(p.steps, step{name: name})
+			rest = rest[end+1:]
+		case strings.HasPrefix(rest, "['"):
+			end := strings.Index(rest, "']")
+			if end < 0 {
+				return nil, fmt.Errorf("JSONPath %q has an unterminated ['name']", path)
+			}
+			p.steps = append(p.steps, step{name: rest[2:end]})
+			rest = rest[end+2:]
+		case rest[0] == '[':
+			end := strings.Index(rest, "]")
+			if end < 0 {
+				return nil, fmt.Errorf("JSONPath %q has an unterminated [index]", path)
+			}
+			index, err := strconv.Atoi(rest[1:end])
+			if err != nil || index < 0 {
+				return nil, fmt.Errorf("JSONPath %q has an invalid index %q", path, rest[1:end])
+			}
+			p.steps = append(p.steps, step{index: index, isIdx: true})
+			rest = rest[end+1:]
+		default:
+			return nil, fmt.Errorf("JSONPath %q: unexpected %q", path, rest)
+		}
+	}
+	return p, nil
+}
+
+// String returns the path as written
+func (p *Path) String() string {
+	return p.raw
+}
+
+// Lookup returns the value at the path in a document decoded with
+// encoding/json into interface{}, and false if there is none
+func (p *Path) Lookup(doc interface{}) (interface{}, bool) {
+	value := doc
+	for _, s := range p.steps {
+		if s.isIdx {
+			array, ok := value.([]interface{})
+			if !ok || s.index >= len(array) {
+				return nil, false
+			}
+			value = array[s.index]
+			continue
+		}
+		object, ok := value.(map[string]interface{})
+		if !ok {
+			return nil, false
+		}
+		if value, ok = object[s.name]; !ok {
+			return nil, false
+		}
+	}
+	return value, true
+}
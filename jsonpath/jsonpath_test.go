@@ -0,0 +1,96 @@
+package jsonpath
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	for _, tc := range []struct {
+		path string
+		want []step
+	}{
+		{"$", nil},
+		{"$.evse.id", []step{{name: "evse"}, {name: "id"}}},
+		{"$.schedule[0].unit", []step{{name: "schedule"}, {index: 0, isIdx: true}, {name: "unit"}}},
+		{"$['a.b']['c d']", []step{{name: "a.b"}, {name: "c d"}}},
+		{"$[2][10]", []step{{index: 2, isIdx: true}, {index: 10, isIdx: true}}},
+		{"$.a['b'][1].c", []step{{name: "a"}, {name: "b"}, {index: 1, isIdx: true}, {name: "c"}}},
+	} {
+		p, err := Parse(tc.path)
+		if err != nil {
+			t.Errorf("Parse(%q): unexpected error: %v", tc.path, err)
+			continue
+		}
+		if !reflect.DeepEqual(p.steps, tc.want) {
+			t.Errorf("Parse(%q) steps = %+v, want %+v", tc.path, p.steps, tc.want)
+		}
+		if p.String() != tc.path {
+			t.Errorf("String() = %q, want %q", p.String(), tc.path)
+		}
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	for _, tc := range []struct {
+		path string
+		want string
+	}{
+		{"", "must start with $"},
+		{"evse.id", "must start with $"},
+		{"$.", "empty member name"},
+		{"$..a", "empty member name"},
+		{"$.a[", "unterminated [index]"},
+		{"$['a", "unterminated ['name']"},
+		{"$[x]", "invalid index"},
+		{"$[-1]", "invalid index"},
+		{"$a", "unexpected"},
+	} {
+		_, err := Parse(tc.path)
+		if err == nil || !strings.Contains(err.Error(), tc.want) {
+			t.Errorf("Parse(%q) error = %v, want it to contain %q", tc.path, err, tc.want)
+		}
+	}
+}
+
+func TestLookup(t *testing.T) {
+	var doc interface{}
+	payload := `{"evse":{"id":1},"schedule":[{"unit":"A"},{"unit":"W"}],"a.b":"dotted","n":null}`
+	if err := json.Unmarshal([]byte(payload), &doc); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tc := range []struct {
+		path  string
+		want  interface{}
+		found bool
+	}{
+		{"$.evse.id", 1.0, true},
+		{"$.schedule[1].unit", "W", true},
+		{"$['a.b']", "dotted", true},
+		{"$.n", nil, true},
+		{"$.missing", nil, false},
+		{"$.schedule[2]", nil, false},
+		{"$.evse[0]", nil, false},
+		{"$.schedule.unit", nil, false},
+		{"$.evse.id.x", nil, false},
+	} {
+		p, err := Parse(tc.path)
+		if err != nil {
+			t.Fatalf("Parse(%q): %v", tc.path, err)
+		}
+		got, found := p.Lookup(doc)
+		if found != tc.found || !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("Lookup(%q) = %v, %v, want %v, %v", tc.path, got, found, tc.want, tc.found)
+		}
+	}
+
+	t.Run("root", func(t *testing.T) {
+		p, _ := Parse("$")
+		if got, found := p.Lookup(doc); !found || !reflect.DeepEqual(got, doc) {
+			t.Errorf("Lookup($) = %v, %v, want the document", got, found)
+		}
+	})
+}
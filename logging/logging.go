@@ -0,0 +1,298 @@
+// Package logging provides the structured, leveled logging of the simulator:
+// a log/slog handler writing text lines for the terminal or JSON lines for a
+// log stack, with the level set per subsystem.
+package logging
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Subsystems whose level can be set separately
+const (
+	SubsystemCharger   = "charger"   // everything without a more specific subsystem
+	SubsystemWire      = "wire"      // OCPP frames, the WebSocket connection and network faults
+	SubsystemMeter     = "meter"     // meter values
+	SubsystemHeartbeat = "heartbeat" // heartbeats
+	SubsystemRemote    = "remote"    // remote start and stop of transactions
+	SubsystemProfile   = "profile"   // charging profiles and current/power limits
+	SubsystemCLI       = "cli"       // the simulator program and its shell
+)
+
+// Subsystems lists the subsystems in alphabetical order
+var Subsystems = []string{
+	SubsystemCharger, SubsystemCLI, SubsystemHeartbeat, SubsystemMeter,
+	SubsystemProfile, SubsystemRemote, SubsystemWire,
+}
+
+// Field keys of the log entries
+const (
+	KeySubsystem     = "subsystem"
+	KeyChargerID     = "charger_id"
+	KeyOCPPVersion   = "ocpp_version"
+	KeyAction        = "action"
+	KeyUniqueID      = "unique_id"
+	KeyTransactionID = "transaction_id"
+	KeyDirection     = "direction" // sent or received
+	KeyError         = "error"
+)
+
+// Frame directions
+const (
+	DirectionSent     = "sent"
+	DirectionReceived = "received"
+)
+
+// Output formats
+const (
+	FormatText = "text"
+	FormatJSON = "json"
+)
+
+// LevelOff disables a subsystem
+const LevelOff = slog.Level(100)
+
+// Action returns the action field of an OCPP message
+func Action(action string) slog.Attr { return slog.String(KeyAction, action) }
+
+// UniqueID returns the unique ID field of an OCPP message
+func UniqueID(uniqueId string) slog.Attr { return slog.String(KeyUniqueID, uniqueId) }
+
+// TransactionID returns the transaction ID field
+func TransactionID(transactionId string) slog.Attr {
+	return slog.String(KeyTransactionID, transactionId)
+}
+
+// Direction returns the direction field of an OCPP frame
+func Direction(direction string) slog.Attr { return slog.String(KeyDirection, direction) }
+
+// Err returns the error field
+func Err(err error) slog.Attr { return slog.Any(KeyError, err) }
+
+// ParseLevel parses debug, info, warn, error or off
+func ParseLevel(s string) (slog.Level, error) {
+	switch strings.ToLower(s) {
+	case "debug":
+		return slog.LevelDebug, nil
+	case "info":
+		return slog.LevelInfo, nil
+	case "warn", "warning":
+		return slog.LevelWarn, nil
+	case "error":
+		return slog.LevelError, nil
+	case "off":
+		return LevelOff, nil
+	}
+	return 0, fmt.Errorf("invalid log level %q (valid: debug, info, warn, error, off)", s)
+}
+
+// LevelName returns the name ParseLevel accepts for level
+func LevelName(level slog.Level) string {
+	if level >= LevelOff {
+		return "off"
+	}
+	return strings.ToLower(level.String())
+}
+
+// IsSubsystem reports whether name is a known subsystem
+func IsSubsystem(name string) bool {
+	return slices.Contains(Subsystems, name)
+}
+
+// Levels holds the minimum level of each subsystem. It is safe for concurrent
+// use, so levels can be changed while logging.
+type Levels struct {
+	mu         sync.RWMutex
+	level      slog.Level            // of subsystems without their own level
+	subsystems map[string]slog.Level // own levels
+}
+
+// NewLevels returns levels with level for every subsystem
+func NewLevels(level slog.Level) *Levels {
+	return &Levels{level: level, subsystems: make(map[string]slog.Level)}
+}
+
+// Level returns the minimum level of subsystem
+func (l *Levels) Level(subsystem string) slog.Level {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	if level, ok := l.subsystems[subsystem]; ok {
+		return level
+	}
+	return l.level
+}
+
+// SetLevel sets the minimum level of subsystem
+func (l *Levels) SetLevel(subsystem string, level slog.Level) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.subsystems[subsystem] = level
+}
+
+// SetDefault sets the minimum level of every subsystem, dropping their own
+// levels
+func (l *Levels) SetDefault(level slog.Level) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.level = level
+	clear(l.subsystems)
+}
+
+// Default returns the level of subsystems without their own level
+func (l *Levels) Default() slog.Level {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	return l.level
+}
+
+// Handler is a slog.Handler writing one line per entry, as text or JSON. The
+// subsystem field of a logger selects the level that applies to its entries.
+// Loggers derived with With share the output, so SetOutput redirects all of
+// them.
+type Handler struct {
+	out       *output
+	levels    *Levels
+	subsystem string
+	inner     slog.Handler
+}
+
+// output is the writer shared by a handler and the handlers derived from it
+type output struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+func (o *output) Write(p []byte) (int, error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	return o.w.Write(p)
+}
+
+// NewHandler returns a handler writing entries allowed by levels to w, in
+// format text or json
+func NewHandler(w io.Writer, format string, levels *Levels) *Handler {
+	out := &output{w: w}
+	h := &Handler{out: out, levels: levels, subsystem: SubsystemCharger}
+	all := &slog.HandlerOptions{Level: slog.LevelDebug - 4}
+	if format == FormatJSON {
+		h.inner = slog.NewJSONHandler(out, all)
+	} else {
+		h.inner = &textHandler{out: out}
+	}
+	return h
+}
+
+// SetOutput redirects the entries of the handler and the ones derived from it
+func (h *Handler) SetOutput(w io.Writer) {
+	h.out.mu.Lock()
+	defer h.out.mu.Unlock()
+	h.out.w = w
+}
+
+// Levels returns the levels the handler applies
+func (h *Handler) Levels() *Levels { return h.levels }
+
+func (h *Handler) Enabled(ctx context.Context, level slog.Level) bool {
+	return level >= h.levels.Level(h.subsystem)
+}
+
+func (h *Handler) Handle(ctx context.Context, r slog.Record) error {
+	return h.inner.Handle(ctx, r)
+}
+
+func (h *Handler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	clone := *h
+	for _, a := range attrs {
+		if a.Key == KeySubsystem {
+			clone.subsystem = a.Value.String()
+		}
+	}
+	clone.inner = h.inner.WithAttrs(attrs)
+	return &clone
+}
+
+func (h *Handler) WithGroup(name string) slog.Handler {
+	clone := *h
+	clone.inner = h.inner.WithGroup(name)
+	return &clone
+}
+
+// textHandler writes "2006/01/02 15:04:05 LEVEL message key=value ..." lines
+// like the standard log package. The level is omitted for INFO, and the
+// subsystem, charger ID and OCPP version a logger is derived With, which are
+// the same on a terminal, are left out.
+type textHandler struct {
+	out    io.Writer
+	prefix string // group prefix of the keys
+	attrs  string // rendered attributes of With
+}
+
+// textOmitted are the With keys the text format leaves out
+var textOmitted = map[string]bool{KeySubsystem: true, KeyChargerID: true, KeyOCPPVersion: true}
+
+func (h *textHandler) Enabled(ctx context.Context, level slog.Level) bool { return true }
+
+func (h *textHandler) Handle(ctx context.Context, r slog.Record) error {
+	var b strings.Builder
+	t := r.Time
+	if t.IsZero() {
+		t = time.Now()
+	}
+	b.WriteString(t.Format("2006/01/02 15:04:05 "))
+	if r.Level != slog.LevelInfo {
+		b.WriteString(r.Level.String() + " ")
+	}
+	b.WriteString(r.Message)
+	b.WriteString(h.attrs)
+	r.Attrs(func(a slog.Attr) bool {
+		writeTextAttr(&b, h.prefix, a)
+		return true
+	})
+	b.WriteByte('\n')
+	_, err := io.WriteString(h.out, b.String())
+	return err
+}
+
+func (h *textHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	var b strings.Builder
+	for _, a := range attrs {
+		if h.prefix == "" && textOmitted[a.Key] {
+			continue
+		}
+		writeTextAttr(&b, h.prefix, a)
+	}
+	return &textHandler{out: h.out, prefix: h.prefix, attrs: h.attrs + b.String()}
+}
+
+func (h *textHandler) WithGroup(name string) slog.Handler {
+	if name == "" {
+		return h
+	}
+	return &textHandler{out: h.out, prefix: h.prefix + name + ".", attrs: h.attrs}
+}
+
+// writeTextAttr writes " key=value", quoting values with spaces or quotes
+func writeTextAttr(b *strings.Builder, prefix string, a slog.Attr) {
+	a.Value = a.Value.Resolve()
+	if a.Equal(slog.Attr{}) {
+		return
+	}
+	if a.Value.Kind() == slog.KindGroup {
+		for _, ga := range a.Value.Group() {
+			writeTextAttr(b, prefix+a.Key+".", ga)
+		}
+		return
+	}
+	value := a.Value.String()
+	if value == "" || strings.ContainsAny(value, " \t\n\"=") {
+		value = strconv.Quote(value)
+	}
+	b.WriteString(" " + prefix + a.Key + "=" + value)
+}
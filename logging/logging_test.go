@@ -0,0 +1,193 @@
+package logging
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"strings"
+	"testing"
+)
+
+func TestParseLevel(t *testing.T) {
+	for _, tc := range []struct {
+		in   string
+		want slog.Level
+	}{
+		{"debug", slog.LevelDebug},
+		{"INFO", slog.LevelInfo},
+		{"warn", slog.LevelWarn},
+		{"warning", slog.LevelWarn},
+		{"error", slog.LevelError},
+		{"Off", LevelOff},
+	} {
+		got, err := ParseLevel(tc.in)
+		if err != nil || got != tc.want {
+			t.Errorf("ParseLevel(%q) = %v, %v, want %v", tc.in, got, err, tc.want)
+		}
+	}
+
+	for _, in := range []string{"", "verbose", "3"} {
+		if _, err := ParseLevel(in); err == nil || !strings.Contains(err.Error(), "invalid log level") {
+			t.Errorf("ParseLevel(%q) error = %v, want invalid log level", in, err)
+		}
+	}
+}
+
+func TestLevelName(t *testing.T) {
+	for _, name := range []string{"debug", "info", "warn", "error", "off"} {
+		level, _ := ParseLevel(name)
+		if got := LevelName(level); got != name {
+			t.Errorf("LevelName(%v) = %q, want %q", level, got, name)
+		}
+	}
+}
+
+func TestIsSubsystem(t *testing.T) {
+	for _, name := range Subsystems {
+		if !IsSubsystem(name) {
+			t.Errorf("IsSubsystem(%q) = false", name)
+		}
+	}
+	if IsSubsystem("ocpp") {
+		t.Error(`IsSubsystem("ocpp") = true`)
+	}
+}
+
+func TestLevels(t *testing.T) {
+	l := NewLevels(slog.LevelInfo)
+	l.SetLevel(SubsystemWire, slog.LevelDebug)
+	l.SetLevel(SubsystemHeartbeat, LevelOff)
+
+	for _, tc := range []struct {
+		subsystem string
+		want      slog.Level
+	}{
+		{SubsystemWire, slog.LevelDebug},
+		{SubsystemHeartbeat, LevelOff},
+		{SubsystemMeter, slog.LevelInfo},
+	} {
+		if got := l.Level(tc.subsystem); got != tc.want {
+			t.Errorf("Level(%q) = %v, want %v", tc.subsystem, got, tc.want)
+		}
+	}
+
+	l.SetDefault(slog.LevelWarn)
+	if l.Default() != slog.LevelWarn || l.Level(SubsystemWire) != slog.LevelWarn || l.Level(SubsystemHeartbeat) != slog.LevelWarn {
+		t.Errorf("SetDefault should drop the subsystem levels: wire %v, heartbeat %v", l.Level(SubsystemWire), l.Level(SubsystemHeartbeat))
+	}
+}
+
+func TestHandlerLevels(t *testing.T) {
+	var buf bytes.Buffer
+	levels := NewLevels(slog.LevelInfo)
+	levels.SetLevel(SubsystemWire, slog.LevelDebug)
+	levels.SetLevel(SubsystemHeartbeat, LevelOff)
+	logger := slog.New(NewHandler(&buf, FormatText, levels))
+
+	logger.Debug("charger debug")
+	logger.Info("charger info")
+	logger.With(KeySubsystem, SubsystemWire).Debug("wire debug")
+	logger.With(KeySubsystem, SubsystemHeartbeat).Error("heartbeat error")
+	logger.With(KeySubsystem, SubsystemMeter).Warn("meter warn")
+
+	out := buf.String()
+	for _, want := range []string{"charger info", "wire debug", "meter warn"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("missing %q in %q", want, out)
+		}
+	}
+	for _, unwanted := range []string{"charger debug", "heartbeat error"} {
+		if strings.Contains(out, unwanted) {
+			t.Errorf("unexpected %q in %q", unwanted, out)
+		}
+	}
+
+	// Levels changed later apply to loggers already derived
+	wire := logger.With(KeySubsystem, SubsystemWire)
+	levels.SetLevel(SubsystemWire, slog.LevelError)
+	buf.Reset()
+	wire.Info("wire info")
+	if buf.Len() != 0 {
+		t.Errorf("wire info should be filtered after the level change, got %q", buf.String())
+	}
+}
+
+func TestTextHandler(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		log  func(l *slog.Logger)
+		want string // line without the timestamp
+	}{
+		{
+			"info without level",
+			func(l *slog.Logger) { l.Info("Connected", "url", "ws://x") },
+			"Connected url=ws://x",
+		},
+		{
+			"level and quoting",
+			func(l *slog.Logger) { l.Warn("Failed", Err(errors.New("dial tcp: refused")), "empty", "") },
+			`WARN Failed error="dial tcp: refused" empty=""`,
+		},
+		{
+			"With fields of the terminal left out",
+			func(l *slog.Logger) {
+				l.With(KeySubsystem, SubsystemWire, KeyChargerID, "CP1", KeyOCPPVersion, "1.6").Info("Sending", Action("Heartbeat"), Direction(DirectionSent))
+			},
+			"Sending action=Heartbeat direction=sent",
+		},
+		{
+			"fields of the entry kept",
+			func(l *slog.Logger) { l.Info("Entry", KeyChargerID, "CP2") },
+			"Entry charger_id=CP2",
+		},
+		{
+			"groups",
+			func(l *slog.Logger) {
+				l.WithGroup("tx").Info("Started", TransactionID("42"), slog.Group("meter", "wh", 10))
+			},
+			"Started tx.transaction_id=42 tx.meter.wh=10",
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			tc.log(slog.New(NewHandler(&buf, FormatText, NewLevels(slog.LevelDebug))))
+			line := strings.TrimSuffix(buf.String(), "\n")
+			// "2006/01/02 15:04:05 " prefix
+			if len(line) < 20 || line[20:] != tc.want {
+				t.Errorf("got %q, want %q after the timestamp", line, tc.want)
+			}
+		})
+	}
+}
+
+func TestJSONHandler(t *testing.T) {
+	var buf bytes.Buffer
+	logger := slog.New(NewHandler(&buf, FormatJSON, NewLevels(slog.LevelInfo)))
+	logger.With(KeySubsystem, SubsystemWire, KeyChargerID, "CP1").Info("Received", UniqueID("u1"))
+
+	var entry map[string]interface{}
+	if err := json.Unmarshal(buf.Bytes(), &entry); err != nil {
+		t.Fatalf("not a JSON line: %q", buf.String())
+	}
+	for key, want := range map[string]string{"msg": "Received", "level": "INFO", KeySubsystem: SubsystemWire, KeyChargerID: "CP1", KeyUniqueID: "u1"} {
+		if entry[key] != want {
+			t.Errorf("%s = %v, want %q", key, entry[key], want)
+		}
+	}
+}
+
+func TestHandlerSetOutput(t *testing.T) {
+	var first, second bytes.Buffer
+	h := NewHandler(&first, FormatText, NewLevels(slog.LevelInfo))
+	derived := slog.New(h).With(KeySubsystem, SubsystemMeter)
+
+	h.SetOutput(&second)
+	derived.Info("after redirect")
+	if first.Len() != 0 || !strings.Contains(second.String(), "after redirect") {
+		t.Errorf("derived logger should follow SetOutput: first %q, second %q", first.String(), second.String())
+	}
+	if h.Levels() == nil {
+		t.Error("Levels() = nil")
+	}
+}
@@ -1,15 +1,22 @@
 package main
 
 import (
+	"bufio"
+	"errors"
 	"flag"
+	"fmt"
+	"io"
 	"log"
+	"log/slog"
 	"os"
 	"os/signal"
+	"strings"
 	"syscall"
 
 	"github.com/weilun-shrimp/wlgo_ocpp_charger_simulator/charger"
 	"github.com/weilun-shrimp/wlgo_ocpp_charger_simulator/cli"
 	"github.com/weilun-shrimp/wlgo_ocpp_charger_simulator/config"
+	"github.com/weilun-shrimp/wlgo_ocpp_charger_simulator/logging"
 )
 
 // Compile-time assertion that the concrete charger satisfies the CLI's Charger
@@ -18,30 +25,96 @@ var _ cli.Charger = (*charger.Charger)(nil)
 
 func main() {
 	configPath := flag.String("config", "config.yaml", "Path to configuration file")
+	outputFormat := flag.String("output", cli.OutputText, "Command output: text, or json for one JSON object per command")
+	var overrides []config.Override
+	flag.Var(overrideFlag{&overrides, false}, "set", "Set a config field, overriding the file and environment: key=value, e.g. charger_id=CP2 (repeatable)")
+	flag.Var(overrideFlag{&overrides, true}, "set-file", "Set a config field to the contents of a file, e.g. auth.value=/run/secrets/auth (repeatable)")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "Usage: %s [flags] [run [--fail-fast] [--file path|-] [commands] | config show | validate [--strict]]\n", os.Args[0])
+		fmt.Fprintf(flag.CommandLine.Output(), "Config fields are also set by %s<KEY> environment variables, e.g. %sCHARGER_ID\n", config.EnvPrefix, config.EnvPrefix)
+		flag.PrintDefaults()
+	}
 	flag.Parse()
 
-	// Load configuration
-	cfg, err := config.Load(*configPath)
+	output, err := cli.ParseOutput(*outputFormat)
+	if err != nil {
+		log.Fatalf("Invalid --output: %v", err)
+	}
+
+	// Commands given to run replace the interactive shell
+	var script *script
+	showConfig, validate, strict := false, false, false
+	switch flag.Arg(0) {
+	case "":
+	case "validate":
+		fs := flag.NewFlagSet("validate", flag.ExitOnError)
+		fs.BoolVar(&strict, "strict", false, "Fail on warnings too")
+		fs.Parse(flag.Args()[1:])
+		validate = true
+	case "config":
+		if flag.NArg() != 2 || flag.Arg(1) != "show" {
+			log.Fatalf("Usage: %s [flags] config show", os.Args[0])
+		}
+		showConfig = true
+	case "run":
+		script, err = parseRun(flag.Args()[1:])
+		if errors.Is(err, flag.ErrHelp) {
+			os.Exit(0)
+		}
+		if err != nil {
+			log.Fatalf("Invalid run: %v", err)
+		}
+		defer script.close()
+	default:
+		log.Fatalf("Unknown subcommand: %s (valid: run, config show, validate)", flag.Arg(0))
+	}
+
+	// Load configuration: defaults, file, environment, then flags. Without
+	// --config a missing config.yaml is fine when the rest sets the fields.
+	explicitConfig := false
+	flag.Visit(func(f *flag.Flag) { explicitConfig = explicitConfig || f.Name == "config" })
+	loadOptions := config.LoadOptions{
+		Path:         *configPath,
+		OptionalFile: !explicitConfig,
+		Environ:      os.Environ(),
+		Overrides:    overrides,
+	}
+	if validate {
+		os.Exit(validateConfig(loadOptions, output, strict))
+	}
+	cfg, err := config.LoadWith(loadOptions)
 	if err != nil {
 		log.Fatalf("Failed to load config: %v", err)
 	}
+	if showConfig {
+		cli.PrintConfig(os.Stdout, cfg)
+		return
+	}
 
-	log.Printf("OCPP Charger Simulator")
-	log.Printf("======================")
-	log.Printf("Charger ID: %s", cfg.ChargerID)
-	log.Printf("OCPP Version: %s", cfg.OCPPVersion)
-	log.Printf("Server URL: %s", cfg.ServerURL)
-	log.Printf("Voltage: %.1f V", cfg.Voltage)
-	log.Printf("Max Current: %.1f A", cfg.MaxCurrent)
-	log.Printf("Max Power: %.1f W", cfg.MaxPower)
-	log.Printf("Initial Status: %s", cfg.InitialStatus)
-	log.Printf("Initial SOC: %.1f%%", cfg.InitialSOC)
-	log.Printf("Battery Capacity: %.0f Wh", cfg.BatteryCapacity)
+	// Structured logging; the shell takes over the output once it runs
+	handler := logging.NewHandler(os.Stderr, cfg.GetLogFormat(), cfg.GetLogLevels())
+	slog.SetDefault(slog.New(handler))
+	banner := slog.New(handler).With(logging.KeySubsystem, logging.SubsystemCLI)
+	banner.Info("OCPP Charger Simulator",
+		logging.KeyChargerID, cfg.ChargerID,
+		logging.KeyOCPPVersion, cfg.OCPPVersion,
+		"server_url", cfg.ServerURL,
+		"voltage_v", cfg.Voltage,
+		"max_current_a", cfg.MaxCurrent,
+		"max_power_w", cfg.MaxPower,
+		"initial_status", cfg.InitialStatus,
+		"initial_soc", cfg.InitialSOC,
+		"battery_capacity_wh", cfg.BatteryCapacity)
+	logger := banner.With(logging.KeyChargerID, cfg.ChargerID, logging.KeyOCPPVersion, cfg.OCPPVersion)
+	for _, warning := range cfg.Check().Warnings {
+		logger.Warn("Inconsistent configuration", "problem", warning)
+	}
 
 	// Create charger
-	sim, err := charger.New(cfg)
+	sim, err := charger.New(cfg, charger.WithLogger(slog.New(handler)))
 	if err != nil {
-		log.Fatalf("Failed to create charger: %v", err)
+		logger.Error("Failed to create charger", logging.Err(err))
+		os.Exit(1)
 	}
 	defer sim.Close()
 
@@ -49,12 +122,136 @@ func main() {
 	sigCh := make(chan os.Signal, 1)
 	signal.Notify(sigCh, syscall.SIGINT, syscall.SIGTERM)
 
-	// Start interactive command loop
-	go cli.Run(sim, cfg, os.Stdin, os.Stdout)
+	// Start the command loop, or the script of run; on a terminal log lines
+	// are printed above the prompt
+	in := io.Reader(os.Stdin)
+	if script != nil {
+		in = script.in
+	}
+	shell := cli.NewShell(sim, cfg, in, os.Stdout)
+	handler.SetOutput(shell.LogWriter(os.Stderr))
+	shell.SetLogLevels(handler.Levels())
+	shell.SetOutput(output)
+	defer shell.Close()
 
-	log.Println("Charger simulator ready. Type 'connect' to connect to server, 'help' for commands.")
+	done := make(chan error, 1)
+	if script != nil {
+		go func() { done <- shell.RunScript(script.failFast) }()
+	} else {
+		go func() {
+			shell.Run()
+			done <- nil
+		}()
+		logger.Info("Charger simulator ready. Type 'connect' to connect to server, 'help' for commands.")
+	}
+
+	// Wait for the end of the commands or a shutdown signal
+	var failed error
+	select {
+	case <-sigCh:
+	case failed = <-done:
+	}
+	if failed != nil {
+		logger.Error("Command failed", logging.Err(failed))
+	}
+	logger.Info("Shutting down...")
+	if failed != nil {
+		shell.Close()
+		sim.Close()
+		os.Exit(1)
+	}
+}
+
+// validateConfig reports every error and warning of the configuration and
+// returns the exit status: 1 with errors, or with warnings when strict
+func validateConfig(opts config.LoadOptions, output string, strict bool) int {
+	source := opts.Path
+	if _, err := os.Stat(opts.Path); opts.OptionalFile && os.IsNotExist(err) {
+		source = "environment"
+	}
+
+	opts.NoValidate = true
+	var problems config.Problems
+	if cfg, err := config.LoadWith(opts); err != nil {
+		problems.Errors = []string{err.Error()}
+	} else {
+		problems = cfg.Check()
+	}
 
-	// Wait for shutdown signal
-	<-sigCh
-	log.Println("Shutting down...")
+	cli.PrintValidation(os.Stdout, output, source, problems)
+	if len(problems.Errors) > 0 || (strict && len(problems.Warnings) > 0) {
+		return 1
+	}
+	return 0
+}
+
+// overrideFlag collects the --set and --set-file flags, in command line order
+type overrideFlag struct {
+	overrides *[]config.Override
+	fromFile  bool
+}
+
+func (f overrideFlag) String() string { return "" }
+
+func (f overrideFlag) Set(s string) error {
+	name := "--set"
+	if f.fromFile {
+		name = "--set-file"
+	}
+	o, err := config.ParseOverride(s, config.SourceFlag+" "+name)
+	if err != nil {
+		return err
+	}
+	if f.fromFile {
+		if o.Value, err = config.ReadSecretFile(o.Value); err != nil {
+			return err
+		}
+	}
+	*f.overrides = append(*f.overrides, o)
+	return nil
+}
+
+// script holds the commands of the run subcommand
+type script struct {
+	in       io.Reader
+	failFast bool
+	file     *os.File // opened with --file, nil otherwise
+}
+
+// parseRun parses "run [--fail-fast] [--file path|-] [commands]". The
+// commands are the remaining arguments joined by spaces, separated by ";"
+// outside quotes and JSON; without them or with --file - they are read from
+// stdin.
+func parseRun(args []string) (*script, error) {
+	fs := flag.NewFlagSet("run", flag.ContinueOnError)
+	failFast := fs.Bool("fail-fast", false, "Exit with status 1 at the first failed command")
+	file := fs.String("file", "", "Read the commands from a file, - for stdin")
+	if err := fs.Parse(args); err != nil {
+		return nil, err
+	}
+
+	s := &script{failFast: *failFast}
+	switch {
+	case *file != "" && fs.NArg() > 0:
+		return nil, fmt.Errorf("commands and --file are exclusive")
+	case *file != "" && *file != "-":
+		f, err := os.Open(*file)
+		if err != nil {
+			return nil, err
+		}
+		s.in, s.file = f, f
+	case fs.NArg() > 0:
+		s.in = strings.NewReader(strings.Join(fs.Args(), " "))
+	default:
+		// Buffered, so a terminal stdin is read as plain lines as well
+		s.in = bufio.NewReader(os.Stdin)
+	}
+	return s, nil
+}
+
+// close closes the script file
+func (s *script) close() {
+	if s.file != nil {
+		s.file.Close()
+	}
 }
@@ -24,6 +24,36 @@ const (
 	ActionSetChargingProfile     = "SetChargingProfile"
 	ActionHeartbeat              = "Heartbeat"
 	ActionDataTransfer           = "DataTransfer"
+	ActionReserveNow             = "ReserveNow"
+	ActionCancelReservation      = "CancelReservation"
+	ActionTriggerMessage         = "TriggerMessage"
+	ActionExtendedTriggerMessage = "ExtendedTriggerMessage" // Security Whitepaper
+	ActionUnlockConnector        = "UnlockConnector"
+	ActionReset                  = "Reset"
+
+	// Firmware management
+	ActionUpdateFirmware                   = "UpdateFirmware"
+	ActionFirmwareStatusNotification       = "FirmwareStatusNotification"
+	ActionSignedUpdateFirmware             = "SignedUpdateFirmware"             // Security Whitepaper
+	ActionSignedFirmwareStatusNotification = "SignedFirmwareStatusNotification" // Security Whitepaper
+
+	// Diagnostics
+	ActionGetDiagnostics                = "GetDiagnostics"
+	ActionDiagnosticsStatusNotification = "DiagnosticsStatusNotification"
+	ActionGetLog                        = "GetLog"                // Security Whitepaper
+	ActionLogStatusNotification         = "LogStatusNotification" // Security Whitepaper
+
+	// Configuration
+	ActionChangeConfiguration = "ChangeConfiguration"
+	ActionGetConfiguration    = "GetConfiguration"
+
+	// Security (Security Whitepaper)
+	ActionSignCertificate            = "SignCertificate"
+	ActionCertificateSigned          = "CertificateSigned"
+	ActionInstallCertificate         = "InstallCertificate"
+	ActionGetInstalledCertificateIds = "GetInstalledCertificateIds"
+	ActionDeleteCertificate          = "DeleteCertificate"
+	ActionSecurityEventNotification  = "SecurityEventNotification"
 )
 
 // ChargePointStatus represents the status of a charge point
@@ -231,6 +261,274 @@ type DataTransferResponse struct {
 	Data   string `json:"data,omitempty"`
 }
 
+// ReserveNowRequest is the request from server to reserve a connector
+type ReserveNowRequest struct {
+	ConnectorId   int    `json:"connectorId"` // 0 reserves the charge point as a whole
+	ExpiryDate    string `json:"expiryDate"`
+	IdTag         string `json:"idTag"`
+	ParentIdTag   string `json:"parentIdTag,omitempty"`
+	ReservationId int    `json:"reservationId"`
+}
+
+// ReserveNowResponse is the response to ReserveNow
+type ReserveNowResponse struct {
+	Status string `json:"status"` // Accepted, Faulted, Occupied, Rejected, Unavailable
+}
+
+// CancelReservationRequest is the request from server to cancel a reservation
+type CancelReservationRequest struct {
+	ReservationId int `json:"reservationId"`
+}
+
+// CancelReservationResponse is the response to CancelReservation
+type CancelReservationResponse struct {
+	Status string `json:"status"` // Accepted, Rejected
+}
+
+// TriggerMessageRequest is the request from server to trigger a message
+type TriggerMessageRequest struct {
+	RequestedMessage string `json:"requestedMessage"` // BootNotification, DiagnosticsStatusNotification, FirmwareStatusNotification, Heartbeat, MeterValues, StatusNotification
+	ConnectorId      int    `json:"connectorId,omitempty"`
+}
+
+// TriggerMessageResponse is the response to TriggerMessage
+type TriggerMessageResponse struct {
+	Status string `json:"status"` // Accepted, Rejected, NotImplemented
+}
+
+// ExtendedTriggerMessageRequest is the request from server to trigger a message (Security Whitepaper)
+type ExtendedTriggerMessageRequest struct {
+	RequestedMessage string `json:"requestedMessage"` // BootNotification, LogStatusNotification, FirmwareStatusNotification, Heartbeat, MeterValues, SignChargePointCertificate, StatusNotification
+	ConnectorId      int    `json:"connectorId,omitempty"`
+}
+
+// ExtendedTriggerMessageResponse is the response to ExtendedTriggerMessage
+type ExtendedTriggerMessageResponse struct {
+	Status string `json:"status"` // Accepted, Rejected, NotImplemented
+}
+
+// UnlockConnectorRequest is the request from server to unlock a connector
+type UnlockConnectorRequest struct {
+	ConnectorId int `json:"connectorId"`
+}
+
+// UnlockConnectorResponse is the response to UnlockConnector
+type UnlockConnectorResponse struct {
+	Status string `json:"status"` // Unlocked, UnlockFailed, NotSupported
+}
+
+// ResetRequest is the request from server to reset the charge point
+type ResetRequest struct {
+	Type string `json:"type"` // Hard, Soft
+}
+
+// ResetResponse is the response to Reset
+type ResetResponse struct {
+	Status string `json:"status"` // Accepted, Rejected
+}
+
+// UpdateFirmwareRequest is the request from server to update the firmware
+type UpdateFirmwareRequest struct {
+	Location      string `json:"location"`
+	Retries       int    `json:"retries,omitempty"`
+	RetrieveDate  string `json:"retrieveDate"`
+	RetryInterval int    `json:"retryInterval,omitempty"`
+}
+
+// UpdateFirmwareResponse is the response to UpdateFirmware (empty)
+type UpdateFirmwareResponse struct{}
+
+// FirmwareStatusNotificationRequest reports the progress of a firmware update
+type FirmwareStatusNotificationRequest struct {
+	Status string `json:"status"` // Downloaded, DownloadFailed, Downloading, Idle, InstallationFailed, Installing, Installed
+}
+
+// FirmwareStatusNotificationResponse is the response for FirmwareStatusNotification (empty)
+type FirmwareStatusNotificationResponse struct{}
+
+// Firmware describes the firmware to install in SignedUpdateFirmware (Security Whitepaper)
+type Firmware struct {
+	Location           string `json:"location"`
+	RetrieveDateTime   string `json:"retrieveDateTime"`
+	InstallDateTime    string `json:"installDateTime,omitempty"`
+	SigningCertificate string `json:"signingCertificate"`
+	Signature          string `json:"signature"`
+}
+
+// SignedUpdateFirmwareRequest is the request from server to install signed firmware (Security Whitepaper)
+type SignedUpdateFirmwareRequest struct {
+	Retries       int      `json:"retries,omitempty"`
+	RetryInterval int      `json:"retryInterval,omitempty"`
+	RequestId     int      `json:"requestId"`
+	Firmware      Firmware `json:"firmware"`
+}
+
+// SignedUpdateFirmwareResponse is the response to SignedUpdateFirmware
+type SignedUpdateFirmwareResponse struct {
+	Status string `json:"status"` // Accepted, Rejected, AcceptedCanceled, InvalidCertificate, RevokedCertificate
+}
+
+// SignedFirmwareStatusNotificationRequest reports the progress of a signed firmware update (Security Whitepaper)
+type SignedFirmwareStatusNotificationRequest struct {
+	Status    string `json:"status"`
+	RequestId int    `json:"requestId,omitempty"`
+}
+
+// SignedFirmwareStatusNotificationResponse is the response for SignedFirmwareStatusNotification (empty)
+type SignedFirmwareStatusNotificationResponse struct{}
+
+// GetDiagnosticsRequest is the request from server to upload diagnostics
+type GetDiagnosticsRequest struct {
+	Location      string `json:"location"`
+	Retries       int    `json:"retries,omitempty"`
+	RetryInterval int    `json:"retryInterval,omitempty"`
+	StartTime     string `json:"startTime,omitempty"`
+	StopTime      string `json:"stopTime,omitempty"`
+}
+
+// GetDiagnosticsResponse is the response to GetDiagnostics
+type GetDiagnosticsResponse struct {
+	FileName string `json:"fileName,omitempty"`
+}
+
+// DiagnosticsStatusNotificationRequest reports the progress of a diagnostics upload
+type DiagnosticsStatusNotificationRequest struct {
+	Status string `json:"status"` // Idle, Uploaded, UploadFailed, Uploading
+}
+
+// DiagnosticsStatusNotificationResponse is the response for DiagnosticsStatusNotification (empty)
+type DiagnosticsStatusNotificationResponse struct{}
+
+// LogParameters describes the log to upload in GetLog (Security Whitepaper)
+type LogParameters struct {
+	RemoteLocation  string `json:"remoteLocation"`
+	OldestTimestamp string `json:"oldestTimestamp,omitempty"`
+	LatestTimestamp string `json:"latestTimestamp,omitempty"`
+}
+
+// GetLogRequest is the request from server to upload a log (Security Whitepaper)
+type GetLogRequest struct {
+	LogType       string        `json:"logType"` // DiagnosticsLog, SecurityLog
+	RequestId     int           `json:"requestId"`
+	Retries       int           `json:"retries,omitempty"`
+	RetryInterval int           `json:"retryInterval,omitempty"`
+	Log           LogParameters `json:"log"`
+}
+
+// GetLogResponse is the response to GetLog
+type GetLogResponse struct {
+	Status   string `json:"status"` // Accepted, Rejected, AcceptedCanceled
+	Filename string `json:"filename,omitempty"`
+}
+
+// LogStatusNotificationRequest reports the progress of a log upload (Security Whitepaper)
+type LogStatusNotificationRequest struct {
+	Status    string `json:"status"` // BadMessage, Idle, NotSupportedOperation, PermissionDenied, Uploaded, UploadFailure, Uploading
+	RequestId int    `json:"requestId,omitempty"`
+}
+
+// LogStatusNotificationResponse is the response for LogStatusNotification (empty)
+type LogStatusNotificationResponse struct{}
+
+// ChangeConfigurationRequest represents a ChangeConfiguration request
+type ChangeConfigurationRequest struct {
+	Key   string `json:"key"`
+	Value string `json:"value"`
+}
+
+// ChangeConfigurationResponse represents a ChangeConfiguration response
+type ChangeConfigurationResponse struct {
+	Status string `json:"status"` // Accepted, Rejected, RebootRequired, NotSupported
+}
+
+// GetConfigurationRequest represents a GetConfiguration request
+type GetConfigurationRequest struct {
+	Key []string `json:"key,omitempty"` // all keys if empty
+}
+
+// KeyValue is a configuration key and its value
+type KeyValue struct {
+	Key      string `json:"key"`
+	Readonly bool   `json:"readonly"`
+	Value    string `json:"value,omitempty"`
+}
+
+// GetConfigurationResponse represents a GetConfiguration response
+type GetConfigurationResponse struct {
+	ConfigurationKey []KeyValue `json:"configurationKey,omitempty"`
+	UnknownKey       []string   `json:"unknownKey,omitempty"`
+}
+
+// SignCertificateRequest represents a SignCertificate request (Security Whitepaper)
+type SignCertificateRequest struct {
+	Csr string `json:"csr"` // PEM encoded certificate signing request
+}
+
+// SignCertificateResponse represents a SignCertificate response
+type SignCertificateResponse struct {
+	Status string `json:"status"` // Accepted, Rejected
+}
+
+// CertificateSignedRequest represents a CertificateSigned request (Security Whitepaper)
+type CertificateSignedRequest struct {
+	CertificateChain string `json:"certificateChain"` // PEM encoded, leaf first
+}
+
+// CertificateSignedResponse represents a CertificateSigned response
+type CertificateSignedResponse struct {
+	Status string `json:"status"` // Accepted, Rejected
+}
+
+// InstallCertificateRequest represents an InstallCertificate request (Security Whitepaper)
+type InstallCertificateRequest struct {
+	CertificateType string `json:"certificateType"` // CentralSystemRootCertificate, ManufacturerRootCertificate
+	Certificate     string `json:"certificate"`     // PEM encoded
+}
+
+// InstallCertificateResponse represents an InstallCertificate response
+type InstallCertificateResponse struct {
+	Status string `json:"status"` // Accepted, Failed, Rejected
+}
+
+// CertificateHashData identifies an installed certificate
+type CertificateHashData struct {
+	HashAlgorithm  string `json:"hashAlgorithm"` // SHA256, SHA384, SHA512
+	IssuerNameHash string `json:"issuerNameHash"`
+	IssuerKeyHash  string `json:"issuerKeyHash"`
+	SerialNumber   string `json:"serialNumber"`
+}
+
+// GetInstalledCertificateIdsRequest represents a GetInstalledCertificateIds request (Security Whitepaper)
+type GetInstalledCertificateIdsRequest struct {
+	CertificateType string `json:"certificateType"`
+}
+
+// GetInstalledCertificateIdsResponse represents a GetInstalledCertificateIds response
+type GetInstalledCertificateIdsResponse struct {
+	Status              string                `json:"status"` // Accepted, NotFound
+	CertificateHashData []CertificateHashData `json:"certificateHashData,omitempty"`
+}
+
+// DeleteCertificateRequest represents a DeleteCertificate request (Security Whitepaper)
+type DeleteCertificateRequest struct {
+	CertificateHashData CertificateHashData `json:"certificateHashData"`
+}
+
+// DeleteCertificateResponse represents a DeleteCertificate response
+type DeleteCertificateResponse struct {
+	Status string `json:"status"` // Accepted, Failed, NotFound
+}
+
+// SecurityEventNotificationRequest represents a SecurityEventNotification request (Security Whitepaper)
+type SecurityEventNotificationRequest struct {
+	Type      string `json:"type"`
+	Timestamp string `json:"timestamp"`
+	TechInfo  string `json:"techInfo,omitempty"`
+}
+
+// SecurityEventNotificationResponse is the response for SecurityEventNotification (empty)
+type SecurityEventNotificationResponse struct{}
+
 // Call represents an OCPP Call message [MessageTypeId, UniqueId, Action, Payload]
 type Call struct {
 	MessageTypeId int
@@ -23,6 +23,35 @@ const (
 	ActionSetChargingProfile      = "SetChargingProfile"
 	ActionHeartbeat               = "Heartbeat"
 	ActionDataTransfer            = "DataTransfer"
+	ActionReserveNow              = "ReserveNow"
+	ActionCancelReservation       = "CancelReservation"
+	ActionReservationStatusUpdate = "ReservationStatusUpdate"
+	ActionTriggerMessage          = "TriggerMessage"
+	ActionUnlockConnector         = "UnlockConnector"
+	ActionReset                   = "Reset"
+
+	// Firmware management
+	ActionUpdateFirmware             = "UpdateFirmware"
+	ActionFirmwareStatusNotification = "FirmwareStatusNotification"
+
+	// Diagnostics
+	ActionGetLog                = "GetLog"
+	ActionLogStatusNotification = "LogStatusNotification"
+
+	// Device model
+	ActionSetVariables = "SetVariables"
+	ActionGetVariables = "GetVariables"
+
+	// Security
+	ActionSignCertificate            = "SignCertificate"
+	ActionCertificateSigned          = "CertificateSigned"
+	ActionInstallCertificate         = "InstallCertificate"
+	ActionGetInstalledCertificateIds = "GetInstalledCertificateIds"
+	ActionDeleteCertificate          = "DeleteCertificate"
+	ActionSecurityEventNotification  = "SecurityEventNotification"
+
+	// Monitoring
+	ActionNotifyEvent = "NotifyEvent"
 )
 
 // ConnectorStatus represents the status of a connector in OCPP 2.0.1
@@ -367,6 +396,322 @@ type DataTransferResponse struct {
 	Data   string `json:"data,omitempty"`
 }
 
+// ReserveNowRequest is the request from server to reserve an EVSE
+type ReserveNowRequest struct {
+	Id             int      `json:"id"`
+	ExpiryDateTime string   `json:"expiryDateTime"`
+	ConnectorType  string   `json:"connectorType,omitempty"`
+	IdToken        IdToken  `json:"idToken"`
+	EvseId         int      `json:"evseId,omitempty"` // omitted reserves any EVSE
+	GroupIdToken   *IdToken `json:"groupIdToken,omitempty"`
+}
+
+// ReserveNowResponse is the response to ReserveNow
+type ReserveNowResponse struct {
+	Status     string      `json:"status"` // Accepted, Faulted, Occupied, Rejected, Unavailable
+	StatusInfo *StatusInfo `json:"statusInfo,omitempty"`
+}
+
+// CancelReservationRequest is the request from server to cancel a reservation
+type CancelReservationRequest struct {
+	ReservationId int `json:"reservationId"`
+}
+
+// CancelReservationResponse is the response to CancelReservation
+type CancelReservationResponse struct {
+	Status     string      `json:"status"` // Accepted, Rejected
+	StatusInfo *StatusInfo `json:"statusInfo,omitempty"`
+}
+
+// ReservationStatusUpdateRequest is the request for ReservationStatusUpdate
+type ReservationStatusUpdateRequest struct {
+	ReservationId           int    `json:"reservationId"`
+	ReservationUpdateStatus string `json:"reservationUpdateStatus"` // Expired, Removed
+}
+
+// ReservationStatusUpdateResponse is the response for ReservationStatusUpdate
+type ReservationStatusUpdateResponse struct{}
+
+// TriggerMessageRequest is the request from server to trigger a message
+type TriggerMessageRequest struct {
+	RequestedMessage string `json:"requestedMessage"` // BootNotification, LogStatusNotification, FirmwareStatusNotification, Heartbeat, MeterValues, SignChargingStationCertificate, SignV2GCertificate, StatusNotification, TransactionEvent, SignCombinedCertificate, PublishFirmwareStatusNotification
+	Evse             *EVSE  `json:"evse,omitempty"`
+}
+
+// TriggerMessageResponse is the response to TriggerMessage
+type TriggerMessageResponse struct {
+	Status     string      `json:"status"` // Accepted, Rejected, NotImplemented
+	StatusInfo *StatusInfo `json:"statusInfo,omitempty"`
+}
+
+// UnlockConnectorRequest is the request from server to unlock a connector
+type UnlockConnectorRequest struct {
+	EvseId      int `json:"evseId"`
+	ConnectorId int `json:"connectorId"`
+}
+
+// UnlockConnectorResponse is the response to UnlockConnector
+type UnlockConnectorResponse struct {
+	Status     string      `json:"status"` // Unlocked, UnlockFailed, OngoingAuthorizedTransaction, UnknownConnector
+	StatusInfo *StatusInfo `json:"statusInfo,omitempty"`
+}
+
+// ResetRequest is the request from server to reset the charging station or
+// an EVSE
+type ResetRequest struct {
+	Type   string `json:"type"`             // Immediate, OnIdle
+	EvseId *int   `json:"evseId,omitempty"` // only this EVSE, the whole station if absent
+}
+
+// ResetResponse is the response to Reset
+type ResetResponse struct {
+	Status     string      `json:"status"` // Accepted, Rejected, Scheduled
+	StatusInfo *StatusInfo `json:"statusInfo,omitempty"`
+}
+
+// Firmware describes the firmware to install in UpdateFirmware
+type Firmware struct {
+	Location           string `json:"location"`
+	RetrieveDateTime   string `json:"retrieveDateTime"`
+	InstallDateTime    string `json:"installDateTime,omitempty"`
+	SigningCertificate string `json:"signingCertificate,omitempty"`
+	Signature          string `json:"signature,omitempty"`
+}
+
+// UpdateFirmwareRequest is the request from server to update the firmware
+type UpdateFirmwareRequest struct {
+	Retries       int      `json:"retries,omitempty"`
+	RetryInterval int      `json:"retryInterval,omitempty"`
+	RequestId     int      `json:"requestId"`
+	Firmware      Firmware `json:"firmware"`
+}
+
+// UpdateFirmwareResponse is the response to UpdateFirmware
+type UpdateFirmwareResponse struct {
+	Status     string      `json:"status"` // Accepted, Rejected, AcceptedCanceled, InvalidCertificate, RevokedCertificate
+	StatusInfo *StatusInfo `json:"statusInfo,omitempty"`
+}
+
+// FirmwareStatusNotificationRequest reports the progress of a firmware update
+type FirmwareStatusNotificationRequest struct {
+	Status    string `json:"status"`
+	RequestId int    `json:"requestId,omitempty"`
+}
+
+// FirmwareStatusNotificationResponse is the response for FirmwareStatusNotification (empty)
+type FirmwareStatusNotificationResponse struct{}
+
+// LogParameters describes the log to upload in GetLog
+type LogParameters struct {
+	RemoteLocation  string `json:"remoteLocation"`
+	OldestTimestamp string `json:"oldestTimestamp,omitempty"`
+	LatestTimestamp string `json:"latestTimestamp,omitempty"`
+}
+
+// GetLogRequest is the request from server to upload a log
+type GetLogRequest struct {
+	LogType       string        `json:"logType"` // DiagnosticsLog, SecurityLog
+	RequestId     int           `json:"requestId"`
+	Retries       int           `json:"retries,omitempty"`
+	RetryInterval int           `json:"retryInterval,omitempty"`
+	Log           LogParameters `json:"log"`
+}
+
+// GetLogResponse is the response to GetLog
+type GetLogResponse struct {
+	Status     string      `json:"status"` // Accepted, Rejected, AcceptedCanceled
+	Filename   string      `json:"filename,omitempty"`
+	StatusInfo *StatusInfo `json:"statusInfo,omitempty"`
+}
+
+// LogStatusNotificationRequest reports the progress of a log upload
+type LogStatusNotificationRequest struct {
+	Status    string `json:"status"` // BadMessage, Idle, NotSupportedOperation, PermissionDenied, Uploaded, UploadFailure, Uploading, AcceptedCanceled
+	RequestId int    `json:"requestId,omitempty"`
+}
+
+// LogStatusNotificationResponse is the response for LogStatusNotification (empty)
+type LogStatusNotificationResponse struct{}
+
+// Component identifies a component of the device model
+type Component struct {
+	Name     string `json:"name"`
+	Instance string `json:"instance,omitempty"`
+	Evse     *EVSE  `json:"evse,omitempty"`
+}
+
+// Variable identifies a variable of a component
+type Variable struct {
+	Name     string `json:"name"`
+	Instance string `json:"instance,omitempty"`
+}
+
+// SetVariableData is a single variable to set
+type SetVariableData struct {
+	AttributeType  string    `json:"attributeType,omitempty"` // Actual (default), Target, MinSet, MaxSet
+	AttributeValue string    `json:"attributeValue"`
+	Component      Component `json:"component"`
+	Variable       Variable  `json:"variable"`
+}
+
+// SetVariablesRequest represents a SetVariables request
+type SetVariablesRequest struct {
+	SetVariableData []SetVariableData `json:"setVariableData"`
+}
+
+// SetVariableResult is the result of setting a single variable
+type SetVariableResult struct {
+	AttributeType       string      `json:"attributeType,omitempty"`
+	AttributeStatus     string      `json:"attributeStatus"` // Accepted, Rejected, UnknownComponent, UnknownVariable, NotSupportedAttributeType, RebootRequired
+	Component           Component   `json:"component"`
+	Variable            Variable    `json:"variable"`
+	AttributeStatusInfo *StatusInfo `json:"attributeStatusInfo,omitempty"`
+}
+
+// SetVariablesResponse represents a SetVariables response
+type SetVariablesResponse struct {
+	SetVariableResult []SetVariableResult `json:"setVariableResult"`
+}
+
+// GetVariableData is a single variable to get
+type GetVariableData struct {
+	AttributeType string    `json:"attributeType,omitempty"`
+	Component     Component `json:"component"`
+	Variable      Variable  `json:"variable"`
+}
+
+// GetVariablesRequest represents a GetVariables request
+type GetVariablesRequest struct {
+	GetVariableData []GetVariableData `json:"getVariableData"`
+}
+
+// GetVariableResult is the result of getting a single variable
+type GetVariableResult struct {
+	AttributeStatus     string      `json:"attributeStatus"` // Accepted, Rejected, UnknownComponent, UnknownVariable, NotSupportedAttributeType
+	AttributeType       string      `json:"attributeType,omitempty"`
+	AttributeValue      string      `json:"attributeValue,omitempty"`
+	Component           Component   `json:"component"`
+	Variable            Variable    `json:"variable"`
+	AttributeStatusInfo *StatusInfo `json:"attributeStatusInfo,omitempty"`
+}
+
+// GetVariablesResponse represents a GetVariables response
+type GetVariablesResponse struct {
+	GetVariableResult []GetVariableResult `json:"getVariableResult"`
+}
+
+// SignCertificateRequest represents a SignCertificate request
+type SignCertificateRequest struct {
+	Csr             string `json:"csr"`                       // PEM encoded certificate signing request
+	CertificateType string `json:"certificateType,omitempty"` // ChargingStationCertificate, V2GCertificate
+}
+
+// SignCertificateResponse represents a SignCertificate response
+type SignCertificateResponse struct {
+	Status     string      `json:"status"` // Accepted, Rejected
+	StatusInfo *StatusInfo `json:"statusInfo,omitempty"`
+}
+
+// CertificateSignedRequest represents a CertificateSigned request
+type CertificateSignedRequest struct {
+	CertificateChain string `json:"certificateChain"` // PEM encoded, leaf first
+	CertificateType  string `json:"certificateType,omitempty"`
+}
+
+// CertificateSignedResponse represents a CertificateSigned response
+type CertificateSignedResponse struct {
+	Status     string      `json:"status"` // Accepted, Rejected
+	StatusInfo *StatusInfo `json:"statusInfo,omitempty"`
+}
+
+// InstallCertificateRequest represents an InstallCertificate request
+type InstallCertificateRequest struct {
+	CertificateType string `json:"certificateType"` // V2GRootCertificate, MORootCertificate, CSMSRootCertificate, ManufacturerRootCertificate
+	Certificate     string `json:"certificate"`     // PEM encoded
+}
+
+// InstallCertificateResponse represents an InstallCertificate response
+type InstallCertificateResponse struct {
+	Status     string      `json:"status"` // Accepted, Rejected, Failed
+	StatusInfo *StatusInfo `json:"statusInfo,omitempty"`
+}
+
+// CertificateHashData identifies an installed certificate
+type CertificateHashData struct {
+	HashAlgorithm  string `json:"hashAlgorithm"` // SHA256, SHA384, SHA512
+	IssuerNameHash string `json:"issuerNameHash"`
+	IssuerKeyHash  string `json:"issuerKeyHash"`
+	SerialNumber   string `json:"serialNumber"`
+}
+
+// CertificateHashDataChain is an installed certificate and its type
+type CertificateHashDataChain struct {
+	CertificateType          string                `json:"certificateType"`
+	CertificateHashData      CertificateHashData   `json:"certificateHashData"`
+	ChildCertificateHashData []CertificateHashData `json:"childCertificateHashData,omitempty"`
+}
+
+// GetInstalledCertificateIdsRequest represents a GetInstalledCertificateIds request
+type GetInstalledCertificateIdsRequest struct {
+	CertificateType []string `json:"certificateType,omitempty"` // all types if empty
+}
+
+// GetInstalledCertificateIdsResponse represents a GetInstalledCertificateIds response
+type GetInstalledCertificateIdsResponse struct {
+	Status                   string                     `json:"status"` // Accepted, NotFound
+	StatusInfo               *StatusInfo                `json:"statusInfo,omitempty"`
+	CertificateHashDataChain []CertificateHashDataChain `json:"certificateHashDataChain,omitempty"`
+}
+
+// DeleteCertificateRequest represents a DeleteCertificate request
+type DeleteCertificateRequest struct {
+	CertificateHashData CertificateHashData `json:"certificateHashData"`
+}
+
+// DeleteCertificateResponse represents a DeleteCertificate response
+type DeleteCertificateResponse struct {
+	Status     string      `json:"status"` // Accepted, Failed, NotFound
+	StatusInfo *StatusInfo `json:"statusInfo,omitempty"`
+}
+
+// SecurityEventNotificationRequest represents a SecurityEventNotification request
+type SecurityEventNotificationRequest struct {
+	Type      string `json:"type"`
+	Timestamp string `json:"timestamp"`
+	TechInfo  string `json:"techInfo,omitempty"`
+}
+
+// SecurityEventNotificationResponse is the response for SecurityEventNotification (empty)
+type SecurityEventNotificationResponse struct{}
+
+// EventData is an event reported for a component variable
+type EventData struct {
+	EventId               int       `json:"eventId"`
+	Timestamp             string    `json:"timestamp"`
+	Trigger               string    `json:"trigger"` // Alerting, Delta, Periodic
+	Cause                 int       `json:"cause,omitempty"`
+	ActualValue           string    `json:"actualValue"`
+	TechCode              string    `json:"techCode,omitempty"`
+	TechInfo              string    `json:"techInfo,omitempty"`
+	Cleared               bool      `json:"cleared,omitempty"`
+	TransactionId         string    `json:"transactionId,omitempty"`
+	VariableMonitoringId  int       `json:"variableMonitoringId,omitempty"`
+	EventNotificationType string    `json:"eventNotificationType"` // HardWiredNotification, HardWiredMonitor, PreconfiguredMonitor, CustomMonitor
+	Component             Component `json:"component"`
+	Variable              Variable  `json:"variable"`
+}
+
+// NotifyEventRequest is the request for NotifyEvent
+type NotifyEventRequest struct {
+	GeneratedAt string      `json:"generatedAt"`
+	Tbc         bool        `json:"tbc,omitempty"`
+	SeqNo       int         `json:"seqNo"`
+	EventData   []EventData `json:"eventData"`
+}
+
+// NotifyEventResponse is the response for NotifyEvent (empty)
+type NotifyEventResponse struct{}
+
 // MarshalCall marshals a Call message to JSON
 func MarshalCall(uniqueId, action string, payload interface{}) ([]byte, error) {
 	msg := []interface{}{MessageTypeCall, uniqueId, action, payload}